@@ -0,0 +1,157 @@
+package encryption
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/starbx/brew-api/internal/core"
+	"github.com/starbx/brew-api/internal/store/sqlstore"
+)
+
+type RotateOptions struct {
+	ConfigPath string
+	SpaceID    string
+}
+
+func (o *RotateOptions) AddFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVarP(&o.ConfigPath, "config", "c", "", "init api by given config")
+	flagSet.StringVarP(&o.SpaceID, "space", "s", "", "id of the space to rotate data key")
+}
+
+type RewrapOptions struct {
+	ConfigPath string
+}
+
+func (o *RewrapOptions) AddFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVarP(&o.ConfigPath, "config", "c", "", "init api by given config")
+}
+
+type ReencryptOptions struct {
+	ConfigPath string
+	SpaceID    string
+	Batch      uint64
+	Prune      bool
+}
+
+func (o *ReencryptOptions) AddFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVarP(&o.ConfigPath, "config", "c", "", "init api by given config")
+	flagSet.StringVarP(&o.SpaceID, "space", "s", "", "id of the space to re-encrypt")
+	flagSet.Uint64Var(&o.Batch, "batch", 200, "rows to re-encrypt in each batch")
+	flagSet.BoolVar(&o.Prune, "prune", false, "delete old data keys after all content is encrypted by the latest one")
+}
+
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "encryption",
+		Short: "manage data keys of encrypted spaces",
+	}
+	cmd.AddCommand(newRotateCommand(), newRewrapCommand(), newReencryptCommand())
+	return cmd
+}
+
+func newRotateCommand() *cobra.Command {
+	opts := &RotateOptions{}
+	cmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "generate a new data key for the space, new content is encrypted by it",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunRotate(opts)
+		},
+	}
+	opts.AddFlags(cmd.Flags())
+	cmd.MarkFlagRequired("space")
+	return cmd
+}
+
+func newRewrapCommand() *cobra.Command {
+	opts := &RewrapOptions{}
+	cmd := &cobra.Command{
+		Use:   "rewrap",
+		Short: "wrap all data keys with the current master key, previous master keys can be removed afterwards",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunRewrap(opts)
+		},
+	}
+	opts.AddFlags(cmd.Flags())
+	return cmd
+}
+
+func newReencryptCommand() *cobra.Command {
+	opts := &ReencryptOptions{}
+	cmd := &cobra.Command{
+		Use:   "reencrypt",
+		Short: "encrypt plaintext and outdated content of the space with its latest data key, safe to resume",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunReencrypt(opts)
+		},
+	}
+	opts.AddFlags(cmd.Flags())
+	cmd.MarkFlagRequired("space")
+	return cmd
+}
+
+func setupEncryptor(configPath string) (*sqlstore.Encryptor, error) {
+	app := core.MustSetupCore(core.MustLoadBaseConfig(configPath))
+	encryptor := app.Store().Encryptor()
+	if encryptor == nil {
+		return nil, errors.New("encryption is not enabled")
+	}
+	return encryptor, nil
+}
+
+func RunRotate(opts *RotateOptions) error {
+	encryptor, err := setupEncryptor(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+
+	version, err := encryptor.Rotate(context.Background(), opts.SpaceID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Data key of space %s is rotated to version %d, run reencrypt to encrypt existing content with it\n", opts.SpaceID, version)
+	return nil
+}
+
+func RunRewrap(opts *RewrapOptions) error {
+	encryptor, err := setupEncryptor(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+
+	rewrapped, err := encryptor.Rewrap(context.Background())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d data keys are wrapped by the current master key\n", rewrapped)
+	return nil
+}
+
+func RunReencrypt(opts *ReencryptOptions) error {
+	encryptor, err := setupEncryptor(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	result, err := encryptor.Reencrypt(ctx, opts.SpaceID, max(opts.Batch, 1), opts.Prune, func(table string, rows int64) {
+		fmt.Printf("Re-encrypting %s, %d rows\n", table, rows)
+	})
+	if err != nil {
+		return err
+	}
+
+	raw, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(raw))
+	return nil
+}
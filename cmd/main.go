@@ -5,7 +5,10 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/starbx/brew-api/cmd/encryption"
+	"github.com/starbx/brew-api/cmd/reembed"
 	"github.com/starbx/brew-api/cmd/service"
+	"github.com/starbx/brew-api/cmd/space"
 )
 
 func main() {
@@ -18,6 +21,9 @@ func main() {
 	}
 
 	root.AddCommand(service.NewCommand())
+	root.AddCommand(space.NewCommand())
+	root.AddCommand(reembed.NewCommand())
+	root.AddCommand(encryption.NewCommand())
 
 	if err := root.Execute(); err != nil {
 		os.Exit(1)
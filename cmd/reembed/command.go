@@ -0,0 +1,69 @@
+package reembed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/starbx/brew-api/internal/core"
+	"github.com/starbx/brew-api/internal/logic/v1/process"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+type Options struct {
+	ConfigPath  string
+	SpaceID     string
+	Driver      string
+	Prune       bool
+	AdoptLegacy bool
+}
+
+func (o *Options) AddFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVarP(&o.ConfigPath, "config", "c", "", "init api by given config")
+	flagSet.StringVarP(&o.SpaceID, "space", "s", "", "id of the space to re-embed, all spaces if empty")
+	flagSet.StringVarP(&o.Driver, "driver", "d", "", "embedding driver to use, default the configured embedding.document driver")
+	flagSet.BoolVar(&o.Prune, "prune", false, "delete vectors of other models after all chunks are re-embedded")
+	flagSet.BoolVar(&o.AdoptLegacy, "adopt-legacy", false, "treat vectors without model record as generated by the target model")
+}
+
+func NewCommand() *cobra.Command {
+	opts := &Options{}
+	cmd := &cobra.Command{
+		Use:   "reembed",
+		Short: "re-embed knowledge chunks after the embedding model changes, safe to resume",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Run(opts)
+		},
+	}
+	opts.AddFlags(cmd.Flags())
+	return cmd
+}
+
+func Run(opts *Options) error {
+	app := core.MustSetupCore(core.MustLoadBaseConfig(opts.ConfigPath))
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	result, err := process.Reembed(ctx, app, types.ReembedOptions{
+		SpaceID:     opts.SpaceID,
+		Driver:      opts.Driver,
+		Prune:       opts.Prune,
+		AdoptLegacy: opts.AdoptLegacy,
+	}, func(progress types.ReembedProgress) {
+		fmt.Printf("Re-embedding with %s, %d/%d, skipped %d\n", progress.Model, progress.Embedded+progress.Skipped, progress.Total, progress.Skipped)
+	})
+	if err != nil {
+		return err
+	}
+
+	raw, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(raw))
+	return nil
+}
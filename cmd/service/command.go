@@ -37,7 +37,8 @@ func Run(opts *Options) error {
 
 	app := core.MustSetupCore(core.MustLoadBaseConfig(opts.ConfigPath))
 	plugins.Setup(app.InstallPlugins, opts.Init)
-	process.StartKnowledgeProcess(app, 10)
+	process.StartKnowledgeProcess(app, app.Cfg().Process.WorkerCount())
+	process.StartRetentionProcess(app)
 	serve(app)
 
 	return nil
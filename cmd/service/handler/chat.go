@@ -179,9 +179,10 @@ func (s *HttpSrv) GetChatSessionHistory(c *gin.Context) {
 }
 
 type CreateChatMessageRequest struct {
-	MessageID string               `json:"message_id" binding:"required"`
-	Message   string               `json:"message" binding:"required"`
-	Resource  *types.ResourceQuery `json:"resource"`
+	MessageID string                 `json:"message_id" binding:"required"`
+	Message   string                 `json:"message" binding:"required"`
+	Resource  *types.ResourceQuery   `json:"resource"`
+	Filter    *types.KnowledgeFilter `json:"filter"`
 }
 
 type CreateChatMessageResponse struct {
@@ -219,7 +220,7 @@ func (s *HttpSrv) CreateChatMessage(c *gin.Context) {
 		Message:  req.Message,
 		MsgType:  types.MESSAGE_TYPE_TEXT,
 		SendTime: time.Now().Unix(),
-	}, req.Resource)
+	}, req.Resource, req.Filter)
 	if err != nil {
 		response.APIError(c, err)
 		return
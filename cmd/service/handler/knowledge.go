@@ -1,11 +1,20 @@
 package handler
 
 import (
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+
 	"github.com/gin-gonic/gin"
 
 	"github.com/starbx/brew-api/internal/core"
 	v1 "github.com/starbx/brew-api/internal/logic/v1"
 	"github.com/starbx/brew-api/internal/response"
+	"github.com/starbx/brew-api/pkg/errors"
+	"github.com/starbx/brew-api/pkg/extract"
+	"github.com/starbx/brew-api/pkg/i18n"
 	"github.com/starbx/brew-api/pkg/types"
 	"github.com/starbx/brew-api/pkg/utils"
 )
@@ -87,6 +96,111 @@ func (s *HttpSrv) CreateKnowledge(c *gin.Context) {
 	})
 }
 
+// MAX_UPLOAD_BODY_SIZE 单次上传请求的最大长度
+const MAX_UPLOAD_BODY_SIZE = 100 << 20
+
+type UploadKnowledgeRequest struct {
+	Resource string `form:"resource"`
+	// Async 默认异步处理，只有显式传入 false 时等待全部文件处理完成
+	Async *bool `form:"async"`
+}
+
+type UploadKnowledgeResult struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	MimeType string `json:"mime_type"`
+}
+
+type UploadKnowledgeResponse struct {
+	List []UploadKnowledgeResult `json:"list"`
+}
+
+// UploadKnowledge 通过multipart上传文件创建知识，每个文件对应一条知识
+// 全部文件解析校验通过后才开始保存，任意文件不合法时整个请求失败
+func (s *HttpSrv) UploadKnowledge(c *gin.Context) {
+	// 绑定参数时同样会解析整个请求体，需要在此之前限制长度
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MAX_UPLOAD_BODY_SIZE)
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["files"]) == 0 {
+		code := http.StatusBadRequest
+		var maxBytesErr *http.MaxBytesError
+		if stderrors.As(err, &maxBytesErr) {
+			code = http.StatusRequestEntityTooLarge
+		}
+		response.APIError(c, errors.New("api.UploadKnowledge.MultipartForm", i18n.ERROR_INVALIDARGUMENT, err).Code(code))
+		return
+	}
+
+	var req UploadKnowledgeRequest
+	if err = utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	var (
+		files  []v1.KnowledgeFile
+		result []UploadKnowledgeResult
+	)
+	for _, header := range form.File["files"] {
+		file, err := header.Open()
+		if err != nil {
+			response.APIError(c, errors.New("api.UploadKnowledge.FileHeader.Open", i18n.ERROR_INTERNAL, err))
+			return
+		}
+		defer file.Close()
+
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(file, head)
+		if _, err = file.Seek(0, io.SeekStart); err != nil {
+			response.APIError(c, errors.New("api.UploadKnowledge.File.Seek", i18n.ERROR_INTERNAL, err))
+			return
+		}
+
+		filename := filepath.Base(header.Filename)
+		mimeType := extract.DetectMimeType(filename, header.Header.Get("Content-Type"), head[:n])
+		if header.Size > extract.MaxFileSize(mimeType) {
+			response.APIError(c, errors.New("api.UploadKnowledge.FileSize", i18n.ERROR_INVALIDARGUMENT, fmt.Errorf("file %s is too large", header.Filename)).Code(http.StatusBadRequest))
+			return
+		}
+		files = append(files, v1.KnowledgeFile{
+			Filename: filename,
+			MimeType: mimeType,
+			Reader:   file,
+		})
+		result = append(result, UploadKnowledgeResult{
+			Filename: filename,
+			MimeType: mimeType,
+		})
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	isSync := req.Async != nil && !*req.Async
+	ids, err := v1.NewKnowledgeLogic(c, s.Core).InsertFiles(isSync, spaceID, req.Resource, files)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+	for i, id := range ids {
+		result[i].ID = id
+	}
+
+	response.APISuccess(c, UploadKnowledgeResponse{
+		List: result,
+	})
+}
+
+// ImportKnowledge 以 NDJSON 格式批量导入知识，请求体中每行为一条 types.KnowledgeImportRecord
+func (s *HttpSrv) ImportKnowledge(c *gin.Context) {
+	spaceID, _ := v1.InjectSpaceID(c)
+	result, err := v1.NewKnowledgeLogic(c, s.Core).ImportKnowledges(spaceID, c.Request.Body)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, result)
+}
+
 type GetKnowledgeRequest struct {
 	ID string `json:"id" form:"id" binding:"required"`
 }
@@ -111,10 +225,33 @@ func (s *HttpSrv) GetKnowledge(c *gin.Context) {
 	response.APISuccess(c, knowledge)
 }
 
+// GetKnowledgeBlob 返回知识的原始文件，如图片知识的原图
+func (s *HttpSrv) GetKnowledgeBlob(c *gin.Context) {
+	var (
+		err error
+		req GetKnowledgeRequest
+	)
+	if err = utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	data, mimeType, err := v1.NewKnowledgeLogic(c, s.Core).GetBlob(spaceID, req.ID)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.Data(http.StatusOK, mimeType, data)
+}
+
 type ListKnowledgeRequest struct {
-	Resource string `json:"resource" form:"resource"`
-	Page     uint64 `json:"page" form:"page" binding:"required"`
-	PageSize uint64 `json:"pagesize" form:"pagesize" binding:"required,lte=50"`
+	Resource string   `json:"resource" form:"resource"`
+	Tags     []string `json:"tags" form:"tags"`
+	Page     uint64   `json:"page" form:"page" binding:"required"`
+	PageSize uint64   `json:"pagesize" form:"pagesize" binding:"required,lte=50"`
 }
 
 type ListKnowledgeResponse struct {
@@ -138,7 +275,7 @@ func (s *HttpSrv) ListKnowledge(c *gin.Context) {
 	}
 
 	spaceID, _ := v1.InjectSpaceID(c)
-	list, total, err := v1.NewKnowledgeLogic(c, s.Core).ListKnowledges(spaceID, resource, req.Page, req.PageSize)
+	list, total, err := v1.NewKnowledgeLogic(c, s.Core).ListKnowledges(spaceID, resource, req.Tags, req.Page, req.PageSize)
 	if err != nil {
 		response.APIError(c, err)
 		return
@@ -171,8 +308,9 @@ func (s *HttpSrv) DeleteKnowledge(c *gin.Context) {
 }
 
 type QueryRequest struct {
-	Query    string               `json:"query" binding:"required"`
-	Resource *types.ResourceQuery `json:"resource"`
+	Query    string                 `json:"query" binding:"required"`
+	Resource *types.ResourceQuery   `json:"resource"`
+	Filter   *types.KnowledgeFilter `json:"filter"`
 }
 
 func (s *HttpSrv) Query(c *gin.Context) {
@@ -185,7 +323,7 @@ func (s *HttpSrv) Query(c *gin.Context) {
 
 	spaceID, _ := v1.InjectSpaceID(c)
 	// v1.KnowledgeQueryResult
-	result, err := v1.NewKnowledgeLogic(c, s.Core).Query(spaceID, req.Resource, req.Query)
+	result, err := v1.NewKnowledgeLogic(c, s.Core).Query(spaceID, req.Resource, req.Filter, req.Query)
 	if err != nil {
 		response.APIError(c, err)
 		return
@@ -193,3 +331,325 @@ func (s *HttpSrv) Query(c *gin.Context) {
 
 	response.APISuccess(c, result)
 }
+
+type ListKnowledgeRevisionsRequest struct {
+	ID       string `json:"id" form:"id" binding:"required"`
+	Page     uint64 `json:"page" form:"page" binding:"required"`
+	PageSize uint64 `json:"pagesize" form:"pagesize" binding:"required,lte=50"`
+}
+
+type ListKnowledgeRevisionsResponse struct {
+	List  []types.KnowledgeRevision `json:"list"`
+	Total int64                     `json:"total"`
+}
+
+func (s *HttpSrv) ListKnowledgeRevisions(c *gin.Context) {
+	var req ListKnowledgeRevisionsRequest
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	list, total, err := v1.NewKnowledgeLogic(c, s.Core).ListRevisions(spaceID, req.ID, req.Page, req.PageSize)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, ListKnowledgeRevisionsResponse{
+		List:  list,
+		Total: total,
+	})
+}
+
+type DiffKnowledgeRevisionsRequest struct {
+	ID   string `json:"id" form:"id" binding:"required"`
+	From string `json:"from" form:"from" binding:"required"`
+	To   string `json:"to" form:"to"`
+}
+
+func (s *HttpSrv) DiffKnowledgeRevisions(c *gin.Context) {
+	var req DiffKnowledgeRevisionsRequest
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	diff, err := v1.NewKnowledgeLogic(c, s.Core).DiffRevisions(spaceID, req.ID, req.From, req.To)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, diff)
+}
+
+type RestoreKnowledgeRevisionRequest struct {
+	ID         string `json:"id" binding:"required"`
+	RevisionID string `json:"revision_id" binding:"required"`
+}
+
+func (s *HttpSrv) RestoreKnowledgeRevision(c *gin.Context) {
+	var req RestoreKnowledgeRevisionRequest
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	if err := v1.NewKnowledgeLogic(c, s.Core).RestoreRevision(spaceID, req.ID, req.RevisionID); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, nil)
+}
+
+type ListKnowledgeDuplicatesRequest struct {
+	Page     uint64 `json:"page" form:"page" binding:"required"`
+	PageSize uint64 `json:"pagesize" form:"pagesize" binding:"required,lte=50"`
+}
+
+type ListKnowledgeDuplicatesResponse struct {
+	List  []types.KnowledgeDuplicateDetail `json:"list"`
+	Total int64                            `json:"total"`
+}
+
+func (s *HttpSrv) ListKnowledgeDuplicates(c *gin.Context) {
+	var req ListKnowledgeDuplicatesRequest
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	list, total, err := v1.NewKnowledgeLogic(c, s.Core).ListDuplicates(spaceID, req.Page, req.PageSize)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, ListKnowledgeDuplicatesResponse{
+		List:  list,
+		Total: total,
+	})
+}
+
+type MergeKnowledgesRequest struct {
+	ID         string   `json:"id" binding:"required"`
+	Duplicates []string `json:"duplicates" binding:"required"`
+}
+
+func (s *HttpSrv) MergeKnowledges(c *gin.Context) {
+	var req MergeKnowledgesRequest
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	if err := v1.NewKnowledgeLogic(c, s.Core).MergeKnowledges(spaceID, req.ID, req.Duplicates); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, nil)
+}
+
+type GetKnowledgeLinksRequest struct {
+	ID    string `json:"id" form:"id" binding:"required"`
+	Depth int    `json:"depth" form:"depth" binding:"gte=0,lte=3"`
+}
+
+func (s *HttpSrv) GetKnowledgeLinks(c *gin.Context) {
+	var req GetKnowledgeLinksRequest
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	links, err := v1.NewKnowledgeLogic(c, s.Core).GetLinks(spaceID, req.ID, req.Depth)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, links)
+}
+
+type ListKnowledgeTagsResponse struct {
+	List []types.TagCount `json:"list"`
+}
+
+func (s *HttpSrv) ListKnowledgeTags(c *gin.Context) {
+	spaceID, _ := v1.InjectSpaceID(c)
+	list, err := v1.NewKnowledgeLogic(c, s.Core).ListTags(spaceID)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, ListKnowledgeTagsResponse{
+		List: list,
+	})
+}
+
+type UpdateKnowledgeTagsResponse struct {
+	Affected int64 `json:"affected"`
+}
+
+type RenameKnowledgeTagRequest struct {
+	From string `json:"from" binding:"required"`
+	To   string `json:"to" binding:"required"`
+}
+
+func (s *HttpSrv) RenameKnowledgeTag(c *gin.Context) {
+	var req RenameKnowledgeTagRequest
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	affected, err := v1.NewKnowledgeLogic(c, s.Core).RenameTag(spaceID, req.From, req.To)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, UpdateKnowledgeTagsResponse{
+		Affected: affected,
+	})
+}
+
+type MergeKnowledgeTagsRequest struct {
+	Tags []string `json:"tags" binding:"required"`
+	To   string   `json:"to" binding:"required"`
+}
+
+func (s *HttpSrv) MergeKnowledgeTags(c *gin.Context) {
+	var req MergeKnowledgeTagsRequest
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	affected, err := v1.NewKnowledgeLogic(c, s.Core).MergeTags(spaceID, req.Tags, req.To)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, UpdateKnowledgeTagsResponse{
+		Affected: affected,
+	})
+}
+
+type ListProcessJobsRequest struct {
+	Stage    types.KnowledgeStage     `json:"stage" form:"stage"`
+	Status   types.KnowledgeJobStatus `json:"status" form:"status"`
+	Page     uint64                   `json:"page" form:"page" binding:"required"`
+	PageSize uint64                   `json:"pagesize" form:"pagesize" binding:"required,lte=50"`
+}
+
+type ListProcessJobsResponse struct {
+	List  []types.KnowledgeJobDetail `json:"list"`
+	Total int64                      `json:"total"`
+}
+
+func (s *HttpSrv) ListProcessJobs(c *gin.Context) {
+	var req ListProcessJobsRequest
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	list, total, err := v1.NewKnowledgeLogic(c, s.Core).ListProcessJobs(types.GetKnowledgeJobOptions{
+		SpaceID: spaceID,
+		Stage:   req.Stage,
+		Status:  req.Status,
+	}, req.Page, req.PageSize)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, ListProcessJobsResponse{
+		List:  list,
+		Total: total,
+	})
+}
+
+type ProcessKnowledgeRequest struct {
+	ID string `json:"id" binding:"required"`
+}
+
+func (s *HttpSrv) RetryProcess(c *gin.Context) {
+	var req ProcessKnowledgeRequest
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	if err := v1.NewKnowledgeLogic(c, s.Core).RetryProcess(spaceID, req.ID); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, nil)
+}
+
+type RetryFailedProcessesResponse struct {
+	Total int `json:"total"`
+}
+
+func (s *HttpSrv) RetryFailedProcesses(c *gin.Context) {
+	spaceID, _ := v1.InjectSpaceID(c)
+	total, err := v1.NewKnowledgeLogic(c, s.Core).RetryFailedProcesses(spaceID)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, RetryFailedProcessesResponse{
+		Total: total,
+	})
+}
+
+func (s *HttpSrv) ResetProcessRetryTimes(c *gin.Context) {
+	var req ProcessKnowledgeRequest
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	if err := v1.NewKnowledgeLogic(c, s.Core).ResetProcessRetryTimes(spaceID, req.ID); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, nil)
+}
+
+func (s *HttpSrv) CancelProcess(c *gin.Context) {
+	var req ProcessKnowledgeRequest
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	if err := v1.NewKnowledgeLogic(c, s.Core).CancelProcess(spaceID, req.ID); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, nil)
+}
@@ -10,11 +10,13 @@ import (
 )
 
 type CreateResourceRequest struct {
-	ID          string `json:"id" binding:"required"`
-	Title       string `json:"title"`
-	Cycle       *int   `json:"cycle"`
-	Prompt      string `json:"prompt"`
-	Description string `json:"description"`
+	ID          string                   `json:"id" binding:"required"`
+	Title       string                   `json:"title"`
+	Cycle       *int                     `json:"cycle"`
+	Prompt      string                   `json:"prompt"`
+	Description string                   `json:"description"`
+	ChunkMode   string                   `json:"chunk_mode"`
+	Retrieval   *types.RetrievalSettings `json:"retrieval"`
 }
 
 func (s *HttpSrv) CreateResource(c *gin.Context) {
@@ -33,7 +35,7 @@ func (s *HttpSrv) CreateResource(c *gin.Context) {
 	}
 
 	spaceID, _ := v1.InjectSpaceID(c)
-	err = v1.NewResourceLogic(c, s.Core).CreateResource(spaceID, req.ID, req.Title, req.Description, req.Prompt, cycle)
+	err = v1.NewResourceLogic(c, s.Core).CreateResource(spaceID, req.ID, req.Title, req.Description, req.Prompt, req.ChunkMode, cycle, req.Retrieval)
 	if err != nil {
 		response.APIError(c, err)
 		return
@@ -42,11 +44,13 @@ func (s *HttpSrv) CreateResource(c *gin.Context) {
 }
 
 type UpdateResourceRequest struct {
-	ID          string `json:"id" binding:"required"`
-	Title       string `json:"title"`
-	Cycle       *int   `json:"cycle"`
-	Prompt      string `json:"prompt"`
-	Description string `json:"description"`
+	ID          string                   `json:"id" binding:"required"`
+	Title       string                   `json:"title"`
+	Cycle       *int                     `json:"cycle"`
+	Prompt      string                   `json:"prompt"`
+	Description string                   `json:"description"`
+	ChunkMode   string                   `json:"chunk_mode"`
+	Retrieval   *types.RetrievalSettings `json:"retrieval"`
 }
 
 func (s *HttpSrv) UpdateResource(c *gin.Context) {
@@ -65,7 +69,7 @@ func (s *HttpSrv) UpdateResource(c *gin.Context) {
 	}
 
 	spaceID, _ := v1.InjectSpaceID(c)
-	err = v1.NewResourceLogic(c, s.Core).Update(spaceID, req.ID, req.Title, req.Description, req.Prompt, cycle)
+	err = v1.NewResourceLogic(c, s.Core).Update(spaceID, req.ID, req.Title, req.Description, req.Prompt, req.ChunkMode, cycle, req.Retrieval)
 	if err != nil {
 		response.APIError(c, err)
 		return
@@ -123,3 +127,27 @@ func (s *HttpSrv) GetResource(c *gin.Context) {
 	}
 	response.APISuccess(c, data)
 }
+
+type GetRetentionReportRequest struct {
+	Page     uint64 `json:"page" form:"page" binding:"required"`
+	PageSize uint64 `json:"pagesize" form:"pagesize" binding:"required,lte=50"`
+}
+
+func (s *HttpSrv) GetResourceRetentionReport(c *gin.Context) {
+	var (
+		err error
+		req GetRetentionReportRequest
+	)
+	if err = utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	report, err := v1.NewResourceLogic(c, s.Core).RetentionReport(spaceID, req.Page, req.PageSize)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+	response.APISuccess(c, report)
+}
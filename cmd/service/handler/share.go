@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	v1 "github.com/starbx/brew-api/internal/logic/v1"
+	"github.com/starbx/brew-api/internal/response"
+	"github.com/starbx/brew-api/pkg/types"
+	"github.com/starbx/brew-api/pkg/utils"
+)
+
+// SHARE_PASSWORD_HEADER_KEY 查看设置了访问密码的分享时，通过该请求头传递密码
+const SHARE_PASSWORD_HEADER_KEY = "X-Share-Password"
+
+type CreateShareRequest struct {
+	Kind     types.ShareKind `json:"kind" binding:"required,oneof=knowledge session"`
+	ID       string          `json:"id" binding:"required"`
+	Password string          `json:"password" binding:"max=64"`
+	// ExpiredAt 过期时间，Unix时间戳，为0时永不过期
+	ExpiredAt int64 `json:"expired_at" binding:"gte=0"`
+}
+
+func (s *HttpSrv) CreateShare(c *gin.Context) {
+	var req CreateShareRequest
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	share, err := v1.NewAuthedShareLogic(c, s.Core).CreateShare(spaceID, req.Kind, req.ID, req.Password, req.ExpiredAt)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, share)
+}
+
+type ListSharesRequest struct {
+	Kind types.ShareKind `json:"kind" form:"kind" binding:"omitempty,oneof=knowledge session"`
+	ID   string          `json:"id" form:"id"`
+}
+
+type ListSharesResponse struct {
+	List []types.ShareToken `json:"list"`
+}
+
+func (s *HttpSrv) ListShares(c *gin.Context) {
+	var req ListSharesRequest
+	if err := utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	list, err := v1.NewAuthedShareLogic(c, s.Core).ListShares(spaceID, req.Kind, req.ID)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, ListSharesResponse{
+		List: list,
+	})
+}
+
+func (s *HttpSrv) RevokeShare(c *gin.Context) {
+	spaceID, _ := v1.InjectSpaceID(c)
+	if err := v1.NewAuthedShareLogic(c, s.Core).RevokeShare(spaceID, c.Param("token")); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, nil)
+}
+
+// GetSharedContent 无需登录即可查看分享的知识或会话
+func (s *HttpSrv) GetSharedContent(c *gin.Context) {
+	content, err := v1.NewShareLogic(c, s.Core).GetSharedContent(c.Param("token"), c.GetHeader(SHARE_PASSWORD_HEADER_KEY))
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, content)
+}
+
+// GetSharedBlob 返回分享的知识的原始文件，如图片知识的原图
+func (s *HttpSrv) GetSharedBlob(c *gin.Context) {
+	data, mimeType, err := v1.NewShareLogic(c, s.Core).GetSharedBlob(c.Param("token"), c.GetHeader(SHARE_PASSWORD_HEADER_KEY))
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.Data(http.StatusOK, mimeType, data)
+}
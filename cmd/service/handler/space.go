@@ -1,10 +1,17 @@
 package handler
 
 import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
 	"github.com/gin-gonic/gin"
 
 	v1 "github.com/starbx/brew-api/internal/logic/v1"
 	"github.com/starbx/brew-api/internal/response"
+	"github.com/starbx/brew-api/pkg/errors"
+	"github.com/starbx/brew-api/pkg/i18n"
 	"github.com/starbx/brew-api/pkg/types"
 	"github.com/starbx/brew-api/pkg/utils"
 )
@@ -149,3 +156,124 @@ func (s *HttpSrv) LeaveSpace(c *gin.Context) {
 	}
 	response.APISuccess(c, nil)
 }
+
+// ExportSpace 下载空间的备份文件
+func (s *HttpSrv) ExportSpace(c *gin.Context) {
+	spaceID, _ := v1.InjectSpaceID(c)
+
+	// 先写入临时文件，导出失败时仍可以返回错误信息
+	file, err := os.CreateTemp("", "brew-space-export-*.tar.gz")
+	if err != nil {
+		response.APIError(c, errors.New("api.ExportSpace.CreateTemp", i18n.ERROR_INTERNAL, err))
+		return
+	}
+	defer func() {
+		file.Close()
+		os.Remove(file.Name())
+	}()
+
+	if err = v1.NewSpaceLogic(c, s.Core).ExportSpace(spaceID, file); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	c.FileAttachment(file.Name(), fmt.Sprintf("space-%s-%s.tar.gz", spaceID, time.Now().Format("20060102150405")))
+}
+
+type ImportSpaceRequest struct {
+	ReuseVectors bool `form:"reuse_vectors"`
+}
+
+// ImportSpace 通过上传的备份文件恢复空间，路由中包含 spaceid 时导入到该空间，否则创建新的空间
+func (s *HttpSrv) ImportSpace(c *gin.Context) {
+	var (
+		err error
+		req ImportSpaceRequest
+	)
+	if err = utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		response.APIError(c, errors.New("api.ImportSpace.FormFile", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest))
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		response.APIError(c, errors.New("api.ImportSpace.FileHeader.Open", i18n.ERROR_INTERNAL, err))
+		return
+	}
+	defer file.Close()
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	result, err := v1.NewSpaceLogic(c, s.Core).ImportSpace(spaceID, file, req.ReuseVectors)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, result)
+}
+
+func (s *HttpSrv) GetSpaceRetrieval(c *gin.Context) {
+	spaceID, _ := v1.InjectSpaceID(c)
+	settings, err := v1.NewSpaceLogic(c, s.Core).GetRetrievalSettings(spaceID)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, settings)
+}
+
+func (s *HttpSrv) UpdateSpaceRetrieval(c *gin.Context) {
+	var (
+		err error
+		req types.RetrievalSettings
+	)
+	if err = utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	if err = v1.NewSpaceLogic(c, s.Core).UpdateRetrievalSettings(spaceID, req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, nil)
+}
+
+func (s *HttpSrv) GetSpacePrivacy(c *gin.Context) {
+	spaceID, _ := v1.InjectSpaceID(c)
+	settings, err := v1.NewSpaceLogic(c, s.Core).GetPrivacySettings(spaceID)
+	if err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, settings)
+}
+
+func (s *HttpSrv) UpdateSpacePrivacy(c *gin.Context) {
+	var (
+		err error
+		req types.PrivacySettings
+	)
+	if err = utils.BindArgsWithGin(c, &req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	spaceID, _ := v1.InjectSpaceID(c)
+	if err = v1.NewSpaceLogic(c, s.Core).UpdatePrivacySettings(spaceID, req); err != nil {
+		response.APIError(c, err)
+		return
+	}
+
+	response.APISuccess(c, nil)
+}
@@ -69,6 +69,17 @@ func Authorization(core *core.Core) gin.HandlerFunc {
 	}
 }
 
+// TryAuthorization 用于无需登录的接口，请求携带了有效的 access token 时注入用户信息，否则按未登录处理
+func TryAuthorization(core *core.Core) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.GetHeader(ACCESS_TOKEN_HEADER_KEY) == "" {
+			return
+		}
+		// token 无效时不中断请求，按未登录处理
+		checkAccessToken(ctx, core)
+	}
+}
+
 func checkAccessToken(ctx *gin.Context, core *core.Core) (bool, error) {
 	tokenValue := ctx.GetHeader(ACCESS_TOKEN_HEADER_KEY)
 	if tokenValue == "" {
@@ -131,7 +142,7 @@ func Cors(c *gin.Context) {
 	if origin != "" {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, UPDATE")
-		c.Header("Access-Control-Allow-Headers", "Origin, X-Requested-With, Content-Type, Accept, Authorization, X-Access-Token")
+		c.Header("Access-Control-Allow-Headers", "Origin, X-Requested-With, Content-Type, Accept, Authorization, X-Access-Token, X-Share-Password")
 		c.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers, Cache-Control, Content-Language, Content-Type")
 		c.Header("Access-Control-Allow-Credentials", "true")
 	}
@@ -49,6 +49,16 @@ func setupHttpRouter(s *handler.HttpSrv) {
 	{
 		apiV1.GET("/connect", AuthorizationFromQuery(s.Core), handler.Websocket(s.Core))
 		apiV1.POST("/login/token", Authorization(s.Core), s.AccessLogin)
+
+		shared := apiV1.Group("/share/:token")
+		{
+			shared.Use(TryAuthorization(s.Core), UseLimit(s.Core, "share", func(c *gin.Context) string {
+				return "share:" + c.Param("token")
+			}))
+			shared.GET("", s.GetSharedContent)
+			shared.GET("/blob", s.GetSharedBlob)
+		}
+
 		authed := apiV1.Group("")
 		authed.Use(Authorization(s.Core))
 		user := authed.Group("/user")
@@ -62,12 +72,19 @@ func setupHttpRouter(s *handler.HttpSrv) {
 			space.DELETE("/:spaceid/leave", VerifySpaceIDPermission(s.Core, srv.PermissionView), s.LeaveSpace)
 
 			space.POST("", userLimit("modify_space"), s.CreateUserSpace)
+			space.POST("/import", userLimit("modify_space"), s.ImportSpace)
 
 			space.Use(VerifySpaceIDPermission(s.Core, srv.PermissionAdmin))
 			space.DELETE("/:spaceid", s.DeleteUserSpace)
 			space.PUT("/:spaceid", s.UpdateSpace)
 			space.PUT("/:spaceid/user/role", userLimit("modify_space"), s.SetUserSpaceRole)
 			space.GET("/:spaceid/users", s.ListSpaceUsers)
+			space.GET("/:spaceid/export", s.ExportSpace)
+			space.POST("/:spaceid/import", userLimit("modify_space"), s.ImportSpace)
+			space.GET("/:spaceid/retrieval", s.GetSpaceRetrieval)
+			space.PUT("/:spaceid/retrieval", s.UpdateSpaceRetrieval)
+			space.GET("/:spaceid/privacy", s.GetSpacePrivacy)
+			space.PUT("/:spaceid/privacy", s.UpdateSpacePrivacy)
 		}
 
 		knowledge := authed.Group("/:spaceid/knowledge")
@@ -76,16 +93,38 @@ func setupHttpRouter(s *handler.HttpSrv) {
 			{
 				viewScope.Use(VerifySpaceIDPermission(s.Core, srv.PermissionView))
 				viewScope.GET("", s.GetKnowledge)
+				viewScope.GET("/blob", s.GetKnowledgeBlob)
 				viewScope.GET("/list", spaceLimit("knowledge_list"), s.ListKnowledge)
 				viewScope.POST("/query", spaceLimit("query"), s.Query)
+				viewScope.GET("/revision/list", s.ListKnowledgeRevisions)
+				viewScope.GET("/revision/diff", s.DiffKnowledgeRevisions)
+				viewScope.GET("/duplicates", s.ListKnowledgeDuplicates)
+				viewScope.GET("/links", s.GetKnowledgeLinks)
+				viewScope.GET("/tags", s.ListKnowledgeTags)
 			}
 
 			editScope := knowledge.Group("")
 			{
 				editScope.Use(VerifySpaceIDPermission(s.Core, srv.PermissionEdit), spaceLimit("knowledge_modify"))
 				editScope.POST("", s.CreateKnowledge)
+				editScope.POST("/upload", s.UploadKnowledge)
+				editScope.POST("/import", s.ImportKnowledge)
 				editScope.PUT("", s.UpdateKnowledge)
 				editScope.DELETE("", s.DeleteKnowledge)
+				editScope.PUT("/revision/restore", s.RestoreKnowledgeRevision)
+				editScope.POST("/merge", s.MergeKnowledges)
+				editScope.PUT("/tag/rename", s.RenameKnowledgeTag)
+				editScope.PUT("/tag/merge", s.MergeKnowledgeTags)
+			}
+
+			pipeline := knowledge.Group("/pipeline")
+			{
+				pipeline.Use(VerifySpaceIDPermission(s.Core, srv.PermissionAdmin))
+				pipeline.GET("/list", s.ListProcessJobs)
+				pipeline.POST("/retry", spaceLimit("knowledge_modify"), s.RetryProcess)
+				pipeline.POST("/retry/all", spaceLimit("knowledge_modify"), s.RetryFailedProcesses)
+				pipeline.PUT("/reset", s.ResetProcessRetryTimes)
+				pipeline.POST("/cancel", s.CancelProcess)
 			}
 		}
 
@@ -94,6 +133,7 @@ func setupHttpRouter(s *handler.HttpSrv) {
 			resource.Use(VerifySpaceIDPermission(s.Core, srv.PermissionView))
 			resource.GET("", s.GetResource)
 			resource.GET("/list", s.ListResource)
+			resource.GET("/retention", s.GetResourceRetentionReport)
 
 			resource.Use(spaceLimit("resource"))
 			resource.POST("", s.CreateResource)
@@ -101,6 +141,14 @@ func setupHttpRouter(s *handler.HttpSrv) {
 			resource.DELETE("/:resourceid", s.DeleteResource)
 		}
 
+		share := authed.Group("/:spaceid/share")
+		{
+			share.Use(VerifySpaceIDPermission(s.Core, srv.PermissionView))
+			share.GET("/list", s.ListShares)
+			share.POST("", spaceLimit("share"), s.CreateShare)
+			share.DELETE("/:token", s.RevokeShare)
+		}
+
 		chat := authed.Group("/:spaceid/chat")
 		{
 			chat.Use(VerifySpaceIDPermission(s.Core, srv.PermissionView))
@@ -0,0 +1,128 @@
+package space
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/starbx/brew-api/internal/core"
+	"github.com/starbx/brew-api/internal/logic/v1/archive"
+	"github.com/starbx/brew-api/pkg/types"
+	"github.com/starbx/brew-api/pkg/utils"
+)
+
+type ExportOptions struct {
+	ConfigPath string
+	SpaceID    string
+	Output     string
+}
+
+func (o *ExportOptions) AddFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVarP(&o.ConfigPath, "config", "c", "", "init api by given config")
+	flagSet.StringVarP(&o.SpaceID, "space", "s", "", "id of the space to export")
+	flagSet.StringVarP(&o.Output, "output", "o", "", "archive file path, default space-{spaceid}.tar.gz")
+}
+
+type ImportOptions struct {
+	ConfigPath   string
+	Input        string
+	SpaceID      string
+	UserID       string
+	ReuseVectors bool
+}
+
+func (o *ImportOptions) AddFlags(flagSet *pflag.FlagSet) {
+	flagSet.StringVarP(&o.ConfigPath, "config", "c", "", "init api by given config")
+	flagSet.StringVarP(&o.Input, "input", "i", "", "archive file path")
+	flagSet.StringVarP(&o.SpaceID, "space", "s", "", "import into the given space, create a new space if empty")
+	flagSet.StringVarP(&o.UserID, "user", "u", "", "owner of the imported data")
+	flagSet.BoolVar(&o.ReuseVectors, "reuse-vectors", true, "reuse vectors in archive when the embedding model matches")
+}
+
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "space",
+		Short: "space backup and restore",
+	}
+	cmd.AddCommand(newExportCommand(), newImportCommand())
+	return cmd
+}
+
+func newExportCommand() *cobra.Command {
+	opts := &ExportOptions{}
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "export space to archive file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunExport(opts)
+		},
+	}
+	opts.AddFlags(cmd.Flags())
+	cmd.MarkFlagRequired("space")
+	return cmd
+}
+
+func newImportCommand() *cobra.Command {
+	opts := &ImportOptions{}
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "import space from archive file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunImport(opts)
+		},
+	}
+	opts.AddFlags(cmd.Flags())
+	cmd.MarkFlagRequired("input")
+	cmd.MarkFlagRequired("user")
+	return cmd
+}
+
+func RunExport(opts *ExportOptions) error {
+	app := core.MustSetupCore(core.MustLoadBaseConfig(opts.ConfigPath))
+
+	output := opts.Output
+	if output == "" {
+		output = fmt.Sprintf("space-%s.tar.gz", opts.SpaceID)
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err = archive.Export(context.Background(), app, opts.SpaceID, file); err != nil {
+		os.Remove(output)
+		return err
+	}
+	fmt.Println("Space exported to", output)
+	return nil
+}
+
+func RunImport(opts *ImportOptions) error {
+	app := core.MustSetupCore(core.MustLoadBaseConfig(opts.ConfigPath))
+	utils.SetupIDWorker(1)
+
+	file, err := os.Open(opts.Input)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	result, err := archive.Import(context.Background(), app, file, types.SpaceImportOptions{
+		SpaceID:      opts.SpaceID,
+		UserID:       opts.UserID,
+		ReuseVectors: opts.ReuseVectors,
+	})
+	if err != nil {
+		return err
+	}
+
+	raw, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(raw))
+	return nil
+}
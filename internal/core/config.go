@@ -1,12 +1,17 @@
 package core
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/starbx/brew-api/internal/core/srv"
+	"github.com/starbx/brew-api/pkg/security"
 )
 
 func MustLoadBaseConfig(path string) CoreConfig {
@@ -41,6 +46,392 @@ type CoreConfig struct {
 	Security Security `toml:"security"`
 
 	Prompt Prompt `toml:"prompt"`
+
+	Chunk Chunk `toml:"chunk"`
+
+	Dedup Dedup `toml:"dedup"`
+
+	Search Search `toml:"search"`
+
+	Retention Retention `toml:"retention"`
+
+	Process Process `toml:"process"`
+
+	Vector Vector `toml:"vector"`
+
+	Privacy Privacy `toml:"privacy"`
+
+	Encryption Encryption `toml:"encryption"`
+
+	Blob Blob `toml:"blob"`
+}
+
+// Chunk 知识内容的分块配置
+type Chunk struct {
+	// Mode 分块方式，llm 由AI进行分块，local 使用本地 tokenizer 按固定长度分块
+	Mode    string `toml:"mode"`
+	Size    int    `toml:"size"`
+	Overlap int    `toml:"overlap"`
+}
+
+func (c *Chunk) FromENV() {
+	c.Mode = os.Getenv("BREW_API_CHUNK_MODE")
+	c.Size, _ = strconv.Atoi(os.Getenv("BREW_API_CHUNK_SIZE"))
+	c.Overlap, _ = strconv.Atoi(os.Getenv("BREW_API_CHUNK_OVERLAP"))
+}
+
+const (
+	DEDUP_EXACT_REJECT = "reject"
+	DEDUP_EXACT_FLAG   = "flag"
+
+	DEFAULT_DEDUP_THRESHOLD = 0.95
+)
+
+// Dedup 重复知识的识别配置
+type Dedup struct {
+	// Exact 空间内出现内容完全相同的知识时的处理方式，reject 拒绝写入(默认)，flag 写入并标记为重复
+	Exact string `toml:"exact"`
+	// Threshold 完成embedding后，相似度不低于该值的知识会被标记为疑似重复，默认0.95，大于1时不做检测
+	Threshold float32 `toml:"threshold"`
+}
+
+func (c *Dedup) FromENV() {
+	c.Exact = os.Getenv("BREW_API_DEDUP_EXACT")
+	if v, err := strconv.ParseFloat(os.Getenv("BREW_API_DEDUP_THRESHOLD"), 32); err == nil {
+		c.Threshold = float32(v)
+	}
+}
+
+func (c Dedup) ExactMode() string {
+	if c.Exact == DEDUP_EXACT_FLAG {
+		return DEDUP_EXACT_FLAG
+	}
+	return DEDUP_EXACT_REJECT
+}
+
+func (c Dedup) SimilarityThreshold() float32 {
+	if c.Threshold <= 0 {
+		return DEFAULT_DEDUP_THRESHOLD
+	}
+	return c.Threshold
+}
+
+const (
+	DEFAULT_SEARCH_RRF_K = 60
+)
+
+// Search 知识检索配置
+type Search struct {
+	// TextSearchConfig 生成全文检索向量时使用的 postgres text search config，默认 simple
+	TextSearchConfig string `toml:"text_search_config"`
+	// KeywordWeight 与 VectorWeight 为混合检索中全文检索与向量检索排名的默认权重，均未设置时都为1，空间可单独设置
+	KeywordWeight float32 `toml:"keyword_weight"`
+	VectorWeight  float32 `toml:"vector_weight"`
+	// RRFK reciprocal rank fusion 的平滑常数，默认60
+	RRFK int `toml:"rrf_k"`
+}
+
+func (c *Search) FromENV() {
+	c.TextSearchConfig = os.Getenv("BREW_API_SEARCH_TEXT_SEARCH_CONFIG")
+	if v, err := strconv.ParseFloat(os.Getenv("BREW_API_SEARCH_KEYWORD_WEIGHT"), 32); err == nil {
+		c.KeywordWeight = float32(v)
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("BREW_API_SEARCH_VECTOR_WEIGHT"), 32); err == nil {
+		c.VectorWeight = float32(v)
+	}
+	c.RRFK, _ = strconv.Atoi(os.Getenv("BREW_API_SEARCH_RRF_K"))
+}
+
+// Weights 返回全文检索与向量检索的默认权重
+func (c Search) Weights() (keyword, vector float32) {
+	if c.KeywordWeight == 0 && c.VectorWeight == 0 {
+		return 1, 1
+	}
+	return c.KeywordWeight, c.VectorWeight
+}
+
+func (c Search) RRFConstant() int {
+	if c.RRFK <= 0 {
+		return DEFAULT_SEARCH_RRF_K
+	}
+	return c.RRFK
+}
+
+const (
+	RETENTION_MODE_ARCHIVE = "archive"
+	RETENTION_MODE_DELETE  = "delete"
+
+	DEFAULT_RETENTION_INTERVAL = 60
+)
+
+// Retention 资源保留周期(resource cycle)的清理配置
+type Retention struct {
+	// Mode 知识超出所属资源的保留周期后的处理方式，archive 归档(默认)，保留知识内容但删除分块与向量，delete 彻底删除
+	Mode string `toml:"mode"`
+	// Interval 清理任务的执行间隔，单位分钟，默认60
+	Interval int `toml:"interval"`
+}
+
+func (c *Retention) FromENV() {
+	c.Mode = os.Getenv("BREW_API_RETENTION_MODE")
+	c.Interval, _ = strconv.Atoi(os.Getenv("BREW_API_RETENTION_INTERVAL"))
+}
+
+func (c Retention) RetentionMode() string {
+	if c.Mode == RETENTION_MODE_DELETE {
+		return RETENTION_MODE_DELETE
+	}
+	return RETENTION_MODE_ARCHIVE
+}
+
+func (c Retention) IntervalDuration() time.Duration {
+	if c.Interval <= 0 {
+		return time.Minute * DEFAULT_RETENTION_INTERVAL
+	}
+	return time.Minute * time.Duration(c.Interval)
+}
+
+const (
+	DEFAULT_PROCESS_WORKERS            = 10
+	DEFAULT_PROCESS_VISIBILITY_TIMEOUT = 600
+	DEFAULT_PROCESS_MAX_ATTEMPTS       = 3
+	DEFAULT_PROCESS_BACKOFF            = 10
+	DEFAULT_PROCESS_MAX_BACKOFF        = 600
+)
+
+// Process 知识处理流水线(summary/embedding)的任务队列配置，任务保存在 postgres 中，多个实例可以同时消费
+type Process struct {
+	// Workers 每个实例处理任务的 worker 数量，默认10
+	Workers int `toml:"workers"`
+	// VisibilityTimeout 任务租约时长，单位秒，超时未提交结果的任务会被重新领取，默认600
+	VisibilityTimeout int `toml:"visibility_timeout"`
+	// MaxAttempts 每个阶段的最大尝试次数，默认3
+	MaxAttempts int `toml:"max_attempts"`
+	// Backoff 首次重试前的等待时间，单位秒，之后每次翻倍，默认10
+	Backoff int `toml:"backoff"`
+	// MaxBackoff 重试等待时间的上限，单位秒，默认600
+	MaxBackoff int `toml:"max_backoff"`
+}
+
+func (c *Process) FromENV() {
+	c.Workers, _ = strconv.Atoi(os.Getenv("BREW_API_PROCESS_WORKERS"))
+	c.VisibilityTimeout, _ = strconv.Atoi(os.Getenv("BREW_API_PROCESS_VISIBILITY_TIMEOUT"))
+	c.MaxAttempts, _ = strconv.Atoi(os.Getenv("BREW_API_PROCESS_MAX_ATTEMPTS"))
+	c.Backoff, _ = strconv.Atoi(os.Getenv("BREW_API_PROCESS_BACKOFF"))
+	c.MaxBackoff, _ = strconv.Atoi(os.Getenv("BREW_API_PROCESS_MAX_BACKOFF"))
+}
+
+func (c Process) WorkerCount() int {
+	if c.Workers <= 0 {
+		return DEFAULT_PROCESS_WORKERS
+	}
+	return c.Workers
+}
+
+// Visibility 任务租约时长，单位秒
+func (c Process) Visibility() int64 {
+	if c.VisibilityTimeout <= 0 {
+		return DEFAULT_PROCESS_VISIBILITY_TIMEOUT
+	}
+	return int64(c.VisibilityTimeout)
+}
+
+func (c Process) Attempts() int {
+	if c.MaxAttempts <= 0 {
+		return DEFAULT_PROCESS_MAX_ATTEMPTS
+	}
+	return c.MaxAttempts
+}
+
+// BackoffDelay 第 attempts 次失败后到下次重试的等待时间
+func (c Process) BackoffDelay(attempts int) time.Duration {
+	base, limit := c.Backoff, c.MaxBackoff
+	if base <= 0 {
+		base = DEFAULT_PROCESS_BACKOFF
+	}
+	if limit <= 0 {
+		limit = DEFAULT_PROCESS_MAX_BACKOFF
+	}
+
+	delay := base
+	for i := 1; i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		delay = limit
+	}
+	return time.Second * time.Duration(delay)
+}
+
+const (
+	VECTOR_DRIVER_PGVECTOR = "pgvector"
+	VECTOR_DRIVER_MEMORY   = "memory"
+	VECTOR_DRIVER_QDRANT   = "qdrant"
+
+	DEFAULT_VECTOR_SNAPSHOT_INTERVAL = 60
+	DEFAULT_QDRANT_COLLECTION_PREFIX = "brew_vectors"
+	DEFAULT_QDRANT_TIMEOUT           = 10
+)
+
+// Vector 向量存储配置
+type Vector struct {
+	// Driver pgvector(默认)、memory 或 qdrant，memory 在进程内维护 HNSW 索引，适用于小规模的自托管部署
+	Driver string `toml:"driver"`
+	// SnapshotPath memory 模式下的快照文件路径，为空时不持久化
+	SnapshotPath string `toml:"snapshot_path"`
+	// SnapshotInterval memory 模式下写入快照的间隔，单位秒，默认60
+	SnapshotInterval int `toml:"snapshot_interval"`
+
+	Qdrant Qdrant `toml:"qdrant"`
+}
+
+// Qdrant 通过 HTTP API 访问 qdrant，每种向量维度对应一个 collection
+type Qdrant struct {
+	Endpoint string `toml:"endpoint"`
+	APIKey   string `toml:"api_key"`
+	// CollectionPrefix collection 名称前缀，完整名称为 {prefix}_{dim}，默认 brew_vectors
+	CollectionPrefix string `toml:"collection_prefix"`
+	// Timeout 请求超时时间，单位秒，默认10
+	Timeout int `toml:"timeout"`
+}
+
+func (c *Qdrant) FromENV() {
+	c.Endpoint = os.Getenv("BREW_API_VECTOR_QDRANT_ENDPOINT")
+	c.APIKey = os.Getenv("BREW_API_VECTOR_QDRANT_API_KEY")
+	c.CollectionPrefix = os.Getenv("BREW_API_VECTOR_QDRANT_COLLECTION_PREFIX")
+	c.Timeout, _ = strconv.Atoi(os.Getenv("BREW_API_VECTOR_QDRANT_TIMEOUT"))
+}
+
+func (c Qdrant) GetCollectionPrefix() string {
+	if c.CollectionPrefix == "" {
+		return DEFAULT_QDRANT_COLLECTION_PREFIX
+	}
+	return c.CollectionPrefix
+}
+
+func (c Qdrant) TimeoutDuration() time.Duration {
+	if c.Timeout <= 0 {
+		return time.Second * DEFAULT_QDRANT_TIMEOUT
+	}
+	return time.Second * time.Duration(c.Timeout)
+}
+
+func (c *Vector) FromENV() {
+	c.Driver = os.Getenv("BREW_API_VECTOR_DRIVER")
+	c.SnapshotPath = os.Getenv("BREW_API_VECTOR_SNAPSHOT_PATH")
+	c.SnapshotInterval, _ = strconv.Atoi(os.Getenv("BREW_API_VECTOR_SNAPSHOT_INTERVAL"))
+	c.Qdrant.FromENV()
+}
+
+func (c Vector) VectorDriver() string {
+	switch c.Driver {
+	case VECTOR_DRIVER_MEMORY, VECTOR_DRIVER_QDRANT:
+		return c.Driver
+	}
+	return VECTOR_DRIVER_PGVECTOR
+}
+
+func (c Vector) SnapshotDuration() time.Duration {
+	if c.SnapshotInterval <= 0 {
+		return time.Second * DEFAULT_VECTOR_SNAPSHOT_INTERVAL
+	}
+	return time.Second * time.Duration(c.SnapshotInterval)
+}
+
+// Privacy 敏感信息自动识别的默认配置，空间可单独设置
+type Privacy struct {
+	// Detect 默认识别的类型，可选 email、phone、id_card、passport、bank_card、ip，为空时不自动识别
+	Detect []string `toml:"detect"`
+	// Patterns 对全部空间生效的自定义正则
+	Patterns []string `toml:"patterns"`
+}
+
+func (c *Privacy) FromENV() {
+	if v := os.Getenv("BREW_API_PRIVACY_DETECT"); v != "" {
+		c.Detect = strings.Split(v, ",")
+	}
+	// 正则中可能包含逗号，使用换行分隔
+	if v := os.Getenv("BREW_API_PRIVACY_PATTERNS"); v != "" {
+		c.Patterns = strings.Split(v, "\n")
+	}
+}
+
+// Encryption 知识内容与对话消息的加密存储，每个空间使用独立的数据密钥，数据密钥由主密钥包裹后存储
+// 启用后分块不再生成全文检索向量，混合检索只使用向量排名，相同内容的去重也随之失效
+type Encryption struct {
+	Enabled bool `toml:"enabled"`
+	// MasterKey 32字节的主密钥，使用 base64 或 hex 编码，与 MasterKeyFile 二选一
+	MasterKey string `toml:"master_key"`
+	// MasterKeyFile 保存主密钥的文件，内容格式与 MasterKey 相同
+	MasterKeyFile string `toml:"master_key_file"`
+	// PreviousMasterKeys 轮换前的主密钥，只用于解开已有的数据密钥，执行 encryption rewrap 后即可移除
+	PreviousMasterKeys []string `toml:"previous_master_keys"`
+}
+
+func (c *Encryption) FromENV() {
+	c.Enabled = os.Getenv("BREW_API_ENCRYPTION_ENABLED") == "true"
+	c.MasterKey = os.Getenv("BREW_API_ENCRYPTION_MASTER_KEY")
+	c.MasterKeyFile = os.Getenv("BREW_API_ENCRYPTION_MASTER_KEY_FILE")
+	if v := os.Getenv("BREW_API_ENCRYPTION_PREVIOUS_MASTER_KEYS"); v != "" {
+		c.PreviousMasterKeys = strings.Split(v, ",")
+	}
+}
+
+// Keyring 根据配置的主密钥创建 Keyring，未启用加密时返回 nil
+func (c Encryption) Keyring() (*security.Keyring, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+
+	raw := c.MasterKey
+	if c.MasterKeyFile != "" {
+		content, err := os.ReadFile(c.MasterKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read master key file, %w", err)
+		}
+		raw = string(content)
+	}
+	if raw == "" {
+		return nil, errors.New("encryption is enabled but master key is not configured")
+	}
+
+	current, err := security.ParseKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key, %w", err)
+	}
+	var previous [][]byte
+	for _, v := range c.PreviousMasterKeys {
+		key, err := security.ParseKey(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid previous master key, %w", err)
+		}
+		previous = append(previous, key)
+	}
+	return security.NewKeyring(current, previous...)
+}
+
+const (
+	BLOB_DRIVER_POSTGRES = "postgres"
+	BLOB_DRIVER_LOCAL    = "local"
+)
+
+// Blob 知识原始文件（如图片）的存储配置
+type Blob struct {
+	// Driver postgres(默认) 或 local，local 将文件保存在 Path 目录下且不会加密，只适用于单实例部署
+	Driver string `toml:"driver"`
+	Path   string `toml:"path"`
+}
+
+func (c *Blob) FromENV() {
+	c.Driver = os.Getenv("BREW_API_BLOB_DRIVER")
+	c.Path = os.Getenv("BREW_API_BLOB_PATH")
+}
+
+func (c Blob) BlobDriver() string {
+	if c.Driver == BLOB_DRIVER_LOCAL {
+		return c.Driver
+	}
+	return BLOB_DRIVER_POSTGRES
 }
 
 type Prompt struct {
@@ -60,6 +451,15 @@ func (c *CoreConfig) FromENV() {
 	c.Log.FromENV()
 	c.Postgres.FromENV()
 	c.AI.FromENV()
+	c.Chunk.FromENV()
+	c.Dedup.FromENV()
+	c.Search.FromENV()
+	c.Retention.FromENV()
+	c.Process.FromENV()
+	c.Vector.FromENV()
+	c.Privacy.FromENV()
+	c.Encryption.FromENV()
+	c.Blob.FromENV()
 }
 
 type PGConfig struct {
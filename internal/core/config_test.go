@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -18,3 +19,35 @@ func TestSetupConfigFromEnv(t *testing.T) {
 
 	assert.Equal(t, cfg.Addr, addr)
 }
+
+func TestSearchDefaults(t *testing.T) {
+	var c Search
+	keyword, vector := c.Weights()
+	assert.Equal(t, float32(1), keyword)
+	assert.Equal(t, float32(1), vector)
+	assert.Equal(t, DEFAULT_SEARCH_RRF_K, c.RRFConstant())
+
+	// 只设置向量权重时不使用全文检索
+	c.VectorWeight = 1
+	keyword, _ = c.Weights()
+	assert.Equal(t, float32(0), keyword)
+}
+
+func TestRetentionDefaults(t *testing.T) {
+	var c Retention
+	assert.Equal(t, RETENTION_MODE_ARCHIVE, c.RetentionMode())
+	assert.Equal(t, time.Minute*DEFAULT_RETENTION_INTERVAL, c.IntervalDuration())
+
+	c.Mode = RETENTION_MODE_DELETE
+	c.Interval = 5
+	assert.Equal(t, RETENTION_MODE_DELETE, c.RetentionMode())
+	assert.Equal(t, time.Minute*5, c.IntervalDuration())
+}
+
+func TestProcessBackoff(t *testing.T) {
+	var c Process
+	assert.Equal(t, DEFAULT_PROCESS_WORKERS, c.WorkerCount())
+	assert.Equal(t, time.Second*10, c.BackoffDelay(1))
+	assert.Equal(t, time.Second*40, c.BackoffDelay(3))
+	assert.Equal(t, time.Second*DEFAULT_PROCESS_MAX_BACKOFF, c.BackoffDelay(20))
+}
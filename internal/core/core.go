@@ -13,7 +13,12 @@ import (
 
 	"github.com/starbx/brew-api/internal/core/srv"
 	"github.com/starbx/brew-api/internal/store"
+	"github.com/starbx/brew-api/internal/store/filestore"
+	"github.com/starbx/brew-api/internal/store/memstore"
+	"github.com/starbx/brew-api/internal/store/qdrantstore"
 	"github.com/starbx/brew-api/internal/store/sqlstore"
+	"github.com/starbx/brew-api/pkg/mark"
+	"github.com/starbx/brew-api/pkg/safe"
 )
 
 type Core struct {
@@ -51,10 +56,16 @@ func MustSetupCore(cfg CoreConfig) *Core {
 		metrics:    NewMetrics("brew-api", "core"),
 	}
 
+	if _, err := mark.NewDetector(cfg.Privacy.Detect, cfg.Privacy.Patterns); err != nil {
+		panic(err)
+	}
+
 	// setup store
 	setupMysqlStore(core)
 
 	core.srv = srv.SetupSrvs(srv.ApplyAI(cfg.AI), // ai provider select
+		// rerank the retrieved passages
+		srv.ApplyReranker(cfg.AI.Rerank),
 		// web socket
 		srv.ApplyTower(),
 		// chat message infra
@@ -97,6 +108,57 @@ func (s *Core) Metrics() *Metrics {
 
 func setupMysqlStore(core *Core) {
 	core.stores = sqlstore.MustSetup(core.cfg.Postgres)
+	core.stores().SetTextSearchConfig(core.cfg.Search.TextSearchConfig)
+
+	keyring, err := core.cfg.Encryption.Keyring()
+	if err != nil {
+		panic(err)
+	}
+	if keyring != nil {
+		core.stores().SetEncryptor(sqlstore.NewEncryptor(core.stores(), keyring))
+		// 加密后的分块不再生成全文检索向量，内容哈希也不再保存
+		slog.Warn("content encryption is enabled, keyword search and exact duplicate detection are disabled, retrieval only uses vectors")
+	}
+
+	if core.cfg.Blob.BlobDriver() == BLOB_DRIVER_LOCAL {
+		blobs, err := filestore.NewBlobStore(core.cfg.Blob.Path)
+		if err != nil {
+			panic(err)
+		}
+		core.stores().SetBlobStore(blobs)
+	}
+
+	switch core.cfg.Vector.VectorDriver() {
+	case VECTOR_DRIVER_MEMORY:
+		setupMemoryVectorStore(core)
+	case VECTOR_DRIVER_QDRANT:
+		setupQdrantVectorStore(core)
+	}
+}
+
+// setupQdrantVectorStore 使用 qdrant 替换 pgvector，向量写入不参与数据库事务
+func setupQdrantVectorStore(core *Core) {
+	cfg := core.cfg.Vector.Qdrant
+	if cfg.Endpoint == "" {
+		panic("endpoint of qdrant is required")
+	}
+	core.stores().SetVectorStore(qdrantstore.NewVectorStore(cfg.Endpoint, cfg.APIKey, cfg.GetCollectionPrefix(),
+		&http.Client{Timeout: cfg.TimeoutDuration()}, core.stores().KnowledgeStore()))
+}
+
+// setupMemoryVectorStore 使用进程内的 HNSW 索引替换 pgvector，只适用于单实例部署
+func setupMemoryVectorStore(core *Core) {
+	vectors, err := memstore.NewVectorStore(core.stores().KnowledgeStore(), core.cfg.Vector.SnapshotPath)
+	if err != nil {
+		panic(err)
+	}
+	core.stores().SetVectorStore(vectors)
+
+	if core.cfg.Vector.SnapshotPath != "" {
+		go safe.Run(func() {
+			vectors.StartSnapshot(context.Background(), core.cfg.Vector.SnapshotDuration())
+		})
+	}
 }
 
 func (s *Core) Store() *sqlstore.Provider {
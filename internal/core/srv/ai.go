@@ -2,6 +2,7 @@ package srv
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"strings"
 
@@ -24,6 +25,16 @@ type EnhanceAI interface {
 	NewEnhance(ctx context.Context) *ai.EnhanceOptions
 }
 
+// VisionAI 支持图片输入的多模态模型
+type VisionAI interface {
+	DescribeImage(ctx context.Context, mimeType string, image []byte) (ai.ImageResult, error)
+}
+
+// TranscribeAI 支持音视频转写的模型，只有 OpenAI 兼容 /audio/transcriptions 接口的 driver 实现
+type TranscribeAI interface {
+	Transcribe(ctx context.Context, filename string, media []byte) (ai.TranscriptResult, error)
+}
+
 type EmbeddingAI interface {
 	EmbeddingForQuery(ctx context.Context, content []string) ([][]float32, error)
 	EmbeddingForDocument(ctx context.Context, title string, content []string) ([][]float32, error)
@@ -33,6 +44,11 @@ type AIDriver interface {
 	EmbeddingAI
 	EnhanceAI
 	ChatAI
+	VisionAI
+	TranscribeAI
+	EmbeddingModel() string
+	QueryEmbeddingModel() string
+	DocumentEmbedding(driver string) (EmbeddingAI, string, error)
 }
 
 type AIConfig struct {
@@ -41,6 +57,7 @@ type AIConfig struct {
 	QWen   QWen              `toml:"qwen"`
 	Azure  AzureOpenai       `toml:"azure_openai"`
 	Usage  map[string]string `toml:"usage"`
+	Rerank RerankConfig      `toml:"rerank"`
 }
 
 func (c *AIConfig) FromENV() {
@@ -50,11 +67,14 @@ func (c *AIConfig) FromENV() {
 	c.Usage["query"] = os.Getenv("BREW_API_AI_USAGE_QUERY")
 	c.Usage["summarize"] = os.Getenv("BREW_API_AI_USAGE_SUMMARIZE")
 	c.Usage["enhance_query"] = os.Getenv("BREW_API_AI_USAGE_ENHANCE_QUERY")
+	c.Usage["vision"] = os.Getenv("BREW_API_AI_USAGE_VISION")
+	c.Usage["transcription"] = os.Getenv("BREW_API_AI_USAGE_TRANSCRIPTION")
 
 	c.Gemini.FromENV()
 	c.Openai.FromENV()
 	c.Azure.FromENV()
 	c.QWen.FromENV()
+	c.Rerank.FromENV()
 }
 
 func (c *Gemini) FromENV() {
@@ -81,17 +101,21 @@ type Gemini struct {
 }
 
 type Openai struct {
-	Token          string `toml:"token"`
-	Endpoint       string `toml:"endpoint"`
-	EmbeddingModel string `toml:"embedding_model"`
-	ChatModel      string `toml:"chat_model"`
+	Token              string `toml:"token"`
+	Endpoint           string `toml:"endpoint"`
+	EmbeddingModel     string `toml:"embedding_model"`
+	ChatModel          string `toml:"chat_model"`
+	VisionModel        string `toml:"vision_model"`
+	TranscriptionModel string `toml:"transcription_model"`
 }
 
 type AzureOpenai struct {
-	Token          string `toml:"token"`
-	Endpoint       string `toml:"endpoint"`
-	EmbeddingModel string `toml:"embedding_model"`
-	ChatModel      string `toml:"chat_model"`
+	Token              string `toml:"token"`
+	Endpoint           string `toml:"endpoint"`
+	EmbeddingModel     string `toml:"embedding_model"`
+	ChatModel          string `toml:"chat_model"`
+	VisionModel        string `toml:"vision_model"`
+	TranscriptionModel string `toml:"transcription_model"`
 }
 
 type QWen struct {
@@ -99,20 +123,57 @@ type QWen struct {
 	Endpoint       string `toml:"endpoint"`
 	EmbeddingModel string `toml:"embedding_model"`
 	ChatModel      string `toml:"chat_model"`
+	VisionModel    string `toml:"vision_model"`
 }
 
 type AI struct {
 	chatDrivers    map[string]ChatAI
 	embedDrivers   map[string]EmbeddingAI
 	enhanceDrivers map[string]EnhanceAI
+	visionDrivers  map[string]VisionAI
+	transDrivers   map[string]TranscribeAI
 
 	chatUsage    map[string]ChatAI
 	enhanceUsage map[string]EnhanceAI
 	embedUsage   map[string]EmbeddingAI
+	visionUsage  map[string]VisionAI
+	transUsage   map[string]TranscribeAI
 
 	chatDefault    ChatAI
 	enhanceDefault EnhanceAI
 	embedDefault   EmbeddingAI
+	visionDefault  VisionAI
+	transDefault   TranscribeAI
+
+	// embedModel 文档 embedding 所使用的 driver 与模型
+	embedModel string
+	// queryModel 查询 embedding 所使用的 driver 与模型
+	queryModel string
+	// embedModels 各 driver 的 embedding 模型标识
+	embedModels map[string]string
+}
+
+// EmbeddingModel 返回文档 embedding 所使用的模型标识，格式为 driver:model
+// 只有该标识相同的向量才可以相互比较
+func (s *AI) EmbeddingModel() string {
+	return s.embedModel
+}
+
+// QueryEmbeddingModel 返回查询 embedding 所使用的模型标识，检索时只比较该模型生成的向量
+func (s *AI) QueryEmbeddingModel() string {
+	return s.queryModel
+}
+
+// DocumentEmbedding 返回指定 driver 的文档 embedding 实现及其模型标识，driver 为空时使用当前配置的文档 embedding
+func (s *AI) DocumentEmbedding(driver string) (EmbeddingAI, string, error) {
+	if driver == "" {
+		return s, s.embedModel, nil
+	}
+	d := s.embedDrivers[driver]
+	if d == nil {
+		return nil, "", fmt.Errorf("embedding driver %s is not configured", driver)
+	}
+	return d, s.embedModels[driver], nil
 }
 
 func (s *AI) NewQuery(ctx context.Context, query []*types.MessageContext) *ai.QueryOptions {
@@ -164,6 +225,28 @@ func (s *AI) NewEnhance(ctx context.Context) *ai.EnhanceOptions {
 	return s.enhanceDefault.NewEnhance(ctx)
 }
 
+// DescribeImage 生成图片的描述并识别其中的文字，没有配置支持图片的 driver 时返回错误
+func (s *AI) DescribeImage(ctx context.Context, mimeType string, image []byte) (ai.ImageResult, error) {
+	if d := s.visionUsage["vision"]; d != nil {
+		return d.DescribeImage(ctx, mimeType, image)
+	}
+	if s.visionDefault == nil {
+		return ai.ImageResult{}, fmt.Errorf("no vision driver is configured")
+	}
+	return s.visionDefault.DescribeImage(ctx, mimeType, image)
+}
+
+// Transcribe 将音视频转写为带时间信息的文字，没有配置支持转写的 driver 时返回错误
+func (s *AI) Transcribe(ctx context.Context, filename string, media []byte) (ai.TranscriptResult, error) {
+	if d := s.transUsage["transcription"]; d != nil {
+		return d.Transcribe(ctx, filename, media)
+	}
+	if s.transDefault == nil {
+		return ai.TranscriptResult{}, fmt.Errorf("no transcription driver is configured")
+	}
+	return s.transDefault.Transcribe(ctx, filename, media)
+}
+
 func (s *AI) MsgIsOverLimit(msgs []*types.MessageContext) bool {
 	// TODO
 	return false
@@ -181,6 +264,14 @@ func installAI(a *AI, name string, driver any) {
 	if d, ok := driver.(EnhanceAI); ok {
 		a.enhanceDrivers[name] = d
 	}
+
+	if d, ok := driver.(VisionAI); ok {
+		a.visionDrivers[name] = d
+	}
+
+	if d, ok := driver.(TranscribeAI); ok {
+		a.transDrivers[name] = d
+	}
 }
 
 func SetupAI(cfg AIConfig) (*AI, error) {
@@ -191,6 +282,11 @@ func SetupAI(cfg AIConfig) (*AI, error) {
 		enhanceUsage:   make(map[string]EnhanceAI),
 		embedDrivers:   make(map[string]EmbeddingAI),
 		embedUsage:     make(map[string]EmbeddingAI),
+		embedModels:    make(map[string]string),
+		visionDrivers:  make(map[string]VisionAI),
+		visionUsage:    make(map[string]VisionAI),
+		transDrivers:   make(map[string]TranscribeAI),
+		transUsage:     make(map[string]TranscribeAI),
 	}
 	// if cfg.Gemini.Token != "" {
 	// 	a.drivers[gemini.NAME] = gemini.New(cfg.Lang, cfg.Gemini.Token)
@@ -199,8 +295,10 @@ func SetupAI(cfg AIConfig) (*AI, error) {
 	if cfg.Openai.Token != "" {
 		var oai any
 		oai = openai.New(cfg.Openai.Token, cfg.Openai.Endpoint, ai.ModelName{
-			ChatModel:      cfg.Openai.ChatModel,
-			EmbeddingModel: cfg.Openai.EmbeddingModel,
+			ChatModel:          cfg.Openai.ChatModel,
+			EmbeddingModel:     cfg.Openai.EmbeddingModel,
+			VisionModel:        cfg.Openai.VisionModel,
+			TranscriptionModel: cfg.Openai.TranscriptionModel,
 		})
 
 		installAI(a, openai.NAME, oai)
@@ -209,8 +307,10 @@ func SetupAI(cfg AIConfig) (*AI, error) {
 	if cfg.Azure.Token != "" {
 		var oai any
 		oai = azure_openai.New(cfg.Azure.Token, cfg.Azure.Endpoint, ai.ModelName{
-			ChatModel:      cfg.Azure.ChatModel,
-			EmbeddingModel: cfg.Azure.EmbeddingModel,
+			ChatModel:          cfg.Azure.ChatModel,
+			EmbeddingModel:     cfg.Azure.EmbeddingModel,
+			VisionModel:        cfg.Azure.VisionModel,
+			TranscriptionModel: cfg.Azure.TranscriptionModel,
 		})
 
 		installAI(a, azure_openai.NAME, oai)
@@ -221,6 +321,7 @@ func SetupAI(cfg AIConfig) (*AI, error) {
 		oai = qwen.New(cfg.QWen.Token, cfg.QWen.Endpoint, ai.ModelName{
 			ChatModel:      cfg.QWen.ChatModel,
 			EmbeddingModel: cfg.QWen.EmbeddingModel,
+			VisionModel:    cfg.QWen.VisionModel,
 		})
 
 		installAI(a, qwen.NAME, oai)
@@ -229,6 +330,10 @@ func SetupAI(cfg AIConfig) (*AI, error) {
 	for k, v := range cfg.Usage {
 		if strings.Contains(k, "embedding") {
 			a.embedUsage[k] = a.embedDrivers[v]
+		} else if k == "vision" {
+			a.visionUsage[k] = a.visionDrivers[v]
+		} else if k == "transcription" {
+			a.transUsage[k] = a.transDrivers[v]
 		} else {
 			a.chatUsage[k] = a.chatDrivers[v]
 		}
@@ -239,16 +344,39 @@ func SetupAI(cfg AIConfig) (*AI, error) {
 		break
 	}
 
-	for _, v := range a.embedDrivers {
+	embedDriverName := cfg.Usage["embedding.document"]
+	queryDriverName := cfg.Usage["embedding.query"]
+	for k, v := range a.embedDrivers {
 		a.embedDefault = v
+		if a.embedUsage["embedding.document"] == nil {
+			embedDriverName = k
+		}
+		if a.embedUsage["embedding.query"] == nil {
+			queryDriverName = k
+		}
 		break
 	}
+	for k := range a.embedDrivers {
+		a.embedModels[k] = embeddingModelName(cfg, k)
+	}
+	a.embedModel = embeddingModelName(cfg, embedDriverName)
+	a.queryModel = embeddingModelName(cfg, queryDriverName)
 
 	for _, v := range a.enhanceDrivers {
 		a.enhanceDefault = v
 		break
 	}
 
+	for _, v := range a.visionDrivers {
+		a.visionDefault = v
+		break
+	}
+
+	for _, v := range a.transDrivers {
+		a.transDefault = v
+		break
+	}
+
 	if a.chatDefault == nil || a.embedDefault == nil {
 		panic("AI driver of chat and embedding must be set")
 	}
@@ -256,6 +384,22 @@ func SetupAI(cfg AIConfig) (*AI, error) {
 	return a, nil
 }
 
+func embeddingModelName(cfg AIConfig, driver string) string {
+	var model string
+	switch driver {
+	case openai.NAME:
+		model = cfg.Openai.EmbeddingModel
+	case azure_openai.NAME:
+		model = cfg.Azure.EmbeddingModel
+	case qwen.NAME:
+		model = cfg.QWen.EmbeddingModel
+	}
+	if model == "" {
+		return driver
+	}
+	return driver + ":" + model
+}
+
 type ApplyFunc func(s *Srv)
 
 func ApplyAI(cfg AIConfig) ApplyFunc {
@@ -101,6 +101,15 @@ func (t *Tower) PublishSessionReName(imtopic string, sessionID, name string) err
 	})
 }
 
+func (t *Tower) PublishKnowledgeImportProgress(spaceID string, progress *types.KnowledgeImportProgress) error {
+	return t.publish("/knowledge/list/"+spaceID, fireprotocol.PublishOperation, PublishData{
+		Subject: "import_progress",
+		Version: "v1",
+		Type:    types.WS_EVENT_OTHERS,
+		Data:    progress,
+	})
+}
+
 func (t *Tower) publish(imtopic string, _type fireprotocol.FireOperation, data PublishData) error {
 	fire := t.NewMessage(imtopic, _type, data)
 	return t.Publish(fire)
@@ -0,0 +1,313 @@
+package srv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/starbx/brew-api/pkg/mark"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+const (
+	RERANK_MODE_LLM  = "llm"
+	RERANK_MODE_HTTP = "http"
+
+	DEFAULT_RERANK_TOP_K = 10
+	// 交给 llm 打分时每个候选内容保留的最大长度
+	RERANK_LLM_MAX_CONTENT = 500
+)
+
+// RerankDocument 待重排的候选内容
+type RerankDocument struct {
+	ID      string
+	Content string
+}
+
+// RerankResult 重排结果，Score 越大相关性越高
+type RerankResult struct {
+	ID    string
+	Score float32
+}
+
+// Reranker 根据用户的问题对检索出的候选内容重新排序，并只保留相关性最高的 topK 个
+type Reranker interface {
+	Rerank(ctx context.Context, query string, docs []RerankDocument, topK int) ([]RerankResult, error)
+}
+
+type RerankConfig struct {
+	// Mode 重排方式，llm 使用对话模型打分，http 调用 cross-encoder 服务，为空时不进行重排
+	Mode string `toml:"mode"`
+	// TopK 重排后保留的候选数量，默认10
+	TopK int `toml:"top_k"`
+	// Endpoint cross-encoder 服务地址，请求与响应格式与 cohere/jina 的 rerank 接口一致
+	Endpoint string `toml:"endpoint"`
+	Token    string `toml:"token"`
+	Model    string `toml:"model"`
+	// Timeout 请求超时时间，单位秒，默认10
+	Timeout int `toml:"timeout"`
+}
+
+func (c *RerankConfig) FromENV() {
+	c.Mode = os.Getenv("BREW_API_AI_RERANK_MODE")
+	c.TopK, _ = strconv.Atoi(os.Getenv("BREW_API_AI_RERANK_TOP_K"))
+	c.Endpoint = os.Getenv("BREW_API_AI_RERANK_ENDPOINT")
+	c.Token = os.Getenv("BREW_API_AI_RERANK_TOKEN")
+	c.Model = os.Getenv("BREW_API_AI_RERANK_MODEL")
+	c.Timeout, _ = strconv.Atoi(os.Getenv("BREW_API_AI_RERANK_TIMEOUT"))
+}
+
+func (c RerankConfig) GetTopK() int {
+	if c.TopK <= 0 {
+		return DEFAULT_RERANK_TOP_K
+	}
+	return c.TopK
+}
+
+func SetupReranker(cfg RerankConfig, chat ChatAI) (Reranker, error) {
+	switch cfg.Mode {
+	case "":
+		return nil, nil
+	case RERANK_MODE_LLM:
+		return NewLLMReranker(chat), nil
+	case RERANK_MODE_HTTP:
+		if cfg.Endpoint == "" {
+			return nil, fmt.Errorf("endpoint of http reranker is required")
+		}
+		timeout := time.Second * 10
+		if cfg.Timeout > 0 {
+			timeout = time.Second * time.Duration(cfg.Timeout)
+		}
+		return NewHTTPReranker(cfg.Endpoint, cfg.Token, cfg.Model, &http.Client{Timeout: timeout}), nil
+	default:
+		return nil, fmt.Errorf("unknown rerank mode %s", cfg.Mode)
+	}
+}
+
+func ApplyReranker(cfg RerankConfig) ApplyFunc {
+	return func(s *Srv) {
+		var chat ChatAI
+		if s.ai != nil {
+			chat = s.ai
+		}
+		reranker, err := SetupReranker(cfg, chat)
+		if err != nil {
+			panic(err)
+		}
+		s.reranker = reranker
+		s.rerankTopK = cfg.GetTopK()
+	}
+}
+
+// sortRerankResults 按得分倒序排列，得分相同时保持原有的顺序
+func sortRerankResults(results []RerankResult, topK int) []RerankResult {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if topK > 0 && len(results) > topK {
+		results = results[:topK]
+	}
+	return results
+}
+
+type HTTPReranker struct {
+	endpoint string
+	token    string
+	model    string
+	client   *http.Client
+}
+
+func NewHTTPReranker(endpoint, token, model string, client *http.Client) *HTTPReranker {
+	return &HTTPReranker{
+		endpoint: endpoint,
+		token:    token,
+		model:    model,
+		client:   client,
+	}
+}
+
+type httpRerankRequest struct {
+	Model     string   `json:"model,omitempty"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+	TopN      int      `json:"top_n,omitempty"`
+}
+
+type httpRerankResponse struct {
+	Results []struct {
+		Index          int     `json:"index"`
+		RelevanceScore float32 `json:"relevance_score"`
+	} `json:"results"`
+}
+
+func (r *HTTPReranker) Rerank(ctx context.Context, query string, docs []RerankDocument, topK int) ([]RerankResult, error) {
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	req := httpRerankRequest{
+		Model: r.model,
+		Query: query,
+		TopN:  topK,
+	}
+	for _, v := range docs {
+		req.Documents = append(req.Documents, v.Content)
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if r.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+r.token)
+	}
+
+	resp, err := r.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request reranker, %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("reranker responded with status %d, %s", resp.StatusCode, string(raw))
+	}
+
+	var res httpRerankResponse
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("failed to decode reranker response, %w", err)
+	}
+
+	results := make([]RerankResult, 0, len(res.Results))
+	for _, v := range res.Results {
+		if v.Index < 0 || v.Index >= len(docs) {
+			continue
+		}
+		results = append(results, RerankResult{
+			ID:    docs[v.Index].ID,
+			Score: v.RelevanceScore,
+		})
+	}
+	return sortRerankResults(results, topK), nil
+}
+
+// MaskedReranker 问题与候选内容发送给 reranker 前替换 $hidden[] 中的内容以及识别出的敏感信息，
+// 重排结果只包含 ID 与得分，无需还原
+type MaskedReranker struct {
+	reranker Reranker
+	detector *mark.Detector
+}
+
+func NewMaskedReranker(reranker Reranker, detector *mark.Detector) *MaskedReranker {
+	return &MaskedReranker{
+		reranker: reranker,
+		detector: detector,
+	}
+}
+
+func (r *MaskedReranker) Rerank(ctx context.Context, query string, docs []RerankDocument, topK int) ([]RerankResult, error) {
+	// 问题与候选内容使用同一个 worker，相同的敏感信息替换为相同的内容，不影响相关性的判断
+	sw := mark.NewSensitiveWork().WithDetector(r.detector)
+	masked := make([]RerankDocument, 0, len(docs))
+	for _, v := range docs {
+		masked = append(masked, RerankDocument{
+			ID:      v.ID,
+			Content: sw.Do(v.Content),
+		})
+	}
+	return r.reranker.Rerank(ctx, sw.Do(query), masked, topK)
+}
+
+const PROMPT_RERANK = `You are a relevance ranker. Score how useful each numbered passage is for answering the user's question, from 0 (irrelevant) to 10 (directly answers it).
+Reply with a JSON array only, one item for every passage, for example: [{"index": 0, "score": 7}, {"index": 1, "score": 2}]`
+
+type LLMReranker struct {
+	chat ChatAI
+}
+
+func NewLLMReranker(chat ChatAI) *LLMReranker {
+	return &LLMReranker{
+		chat: chat,
+	}
+}
+
+type llmRerankScore struct {
+	Index int     `json:"index"`
+	Score float32 `json:"score"`
+}
+
+func (r *LLMReranker) Rerank(ctx context.Context, query string, docs []RerankDocument, topK int) ([]RerankResult, error) {
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	b := strings.Builder{}
+	b.WriteString("Question: ")
+	b.WriteString(query)
+	b.WriteString("\n\nPassages:\n")
+	for i, v := range docs {
+		content := []rune(v.Content)
+		if len(content) > RERANK_LLM_MAX_CONTENT {
+			content = content[:RERANK_LLM_MAX_CONTENT]
+		}
+		b.WriteString(fmt.Sprintf("[%d] %s\n", i, strings.ReplaceAll(string(content), "\n", " ")))
+	}
+
+	resp, err := r.chat.NewQuery(ctx, []*types.MessageContext{
+		{
+			Role:    types.USER_ROLE_USER,
+			Content: b.String(),
+		},
+	}).WithPrompt(PROMPT_RERANK).Query()
+	if err != nil {
+		return nil, fmt.Errorf("failed to score passages, %w", err)
+	}
+
+	scores, err := parseLLMRerankScores(resp.Message())
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]RerankResult, 0, len(docs))
+	scored := make(map[int]bool)
+	for _, v := range scores {
+		if v.Index < 0 || v.Index >= len(docs) || scored[v.Index] {
+			continue
+		}
+		scored[v.Index] = true
+		results = append(results, RerankResult{
+			ID:    docs[v.Index].ID,
+			Score: v.Score,
+		})
+	}
+	return sortRerankResults(results, topK), nil
+}
+
+// parseLLMRerankScores 从模型的回复中取出 json 数组，兼容回复被 markdown 代码块包裹的情况
+func parseLLMRerankScores(message string) ([]llmRerankScore, error) {
+	start := strings.Index(message, "[")
+	end := strings.LastIndex(message, "]")
+	if start == -1 || end < start {
+		return nil, fmt.Errorf("unexpected rerank response: %s", message)
+	}
+
+	var scores []llmRerankScore
+	if err := json.Unmarshal([]byte(message[start:end+1]), &scores); err != nil {
+		return nil, fmt.Errorf("failed to parse rerank response, %w", err)
+	}
+	return scores, nil
+}
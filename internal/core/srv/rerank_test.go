@@ -0,0 +1,166 @@
+package srv
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/starbx/brew-api/pkg/ai"
+	"github.com/starbx/brew-api/pkg/mark"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+var rerankDocs = []RerankDocument{
+	{ID: "a", Content: "how to cook rice"},
+	{ID: "b", Content: "error code E1024 means the disk is full"},
+	{ID: "c", Content: "release notes of v1.2"},
+}
+
+func TestHTTPReranker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req httpRerankRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Equal(t, "what is E1024", req.Query)
+		assert.Len(t, req.Documents, 3)
+
+		w.Write([]byte(`{"results":[{"index":0,"relevance_score":0.1},{"index":1,"relevance_score":0.9},{"index":2,"relevance_score":0.3},{"index":9,"relevance_score":1}]}`))
+	}))
+	defer server.Close()
+
+	reranker := NewHTTPReranker(server.URL, "token", "", server.Client())
+	results, err := reranker.Rerank(context.Background(), "what is E1024", rerankDocs, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []RerankResult{{ID: "b", Score: 0.9}, {ID: "c", Score: 0.3}}, results)
+}
+
+func TestHTTPRerankerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	_, err := NewHTTPReranker(server.URL, "", "", server.Client()).Rerank(context.Background(), "query", rerankDocs, 2)
+	assert.Error(t, err)
+}
+
+type fakeQueryDriver struct {
+	reply    string
+	received []*types.MessageContext
+}
+
+func (d *fakeQueryDriver) Query(ctx context.Context, query []*types.MessageContext) (ai.GenerateResponse, error) {
+	d.received = query
+	return ai.GenerateResponse{Received: []string{d.reply}}, nil
+}
+
+func (d *fakeQueryDriver) QueryStream(ctx context.Context, query []*types.MessageContext) (*openai.ChatCompletionStream, error) {
+	return nil, nil
+}
+
+func (d *fakeQueryDriver) Lang() string {
+	return ai.MODEL_BASE_LANGUAGE_EN
+}
+
+type fakeChatAI struct {
+	driver *fakeQueryDriver
+}
+
+func (f *fakeChatAI) Summarize(ctx context.Context, doc *string) (ai.SummarizeResult, error) {
+	return ai.SummarizeResult{}, nil
+}
+
+func (f *fakeChatAI) Chunk(ctx context.Context, doc *string) (ai.ChunkResult, error) {
+	return ai.ChunkResult{}, nil
+}
+
+func (f *fakeChatAI) MsgIsOverLimit(msgs []*types.MessageContext) bool {
+	return false
+}
+
+func (f *fakeChatAI) NewQuery(ctx context.Context, msgs []*types.MessageContext) *ai.QueryOptions {
+	return ai.NewQueryOptions(ctx, f.driver, msgs)
+}
+
+func (f *fakeChatAI) Lang() string {
+	return f.driver.Lang()
+}
+
+func TestLLMReranker(t *testing.T) {
+	chat := &fakeChatAI{driver: &fakeQueryDriver{
+		reply: "```json\n[{\"index\": 0, \"score\": 1}, {\"index\": 1, \"score\": 9}, {\"index\": 2, \"score\": 4}, {\"index\": 1, \"score\": 0}]\n```",
+	}}
+
+	results, err := NewLLMReranker(chat).Rerank(context.Background(), "what is E1024", rerankDocs, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []RerankResult{{ID: "b", Score: 9}, {ID: "c", Score: 4}}, results)
+
+	chat.driver.reply = "sorry"
+	_, err = NewLLMReranker(chat).Rerank(context.Background(), "what is E1024", rerankDocs, 2)
+	assert.Error(t, err)
+}
+
+func TestMaskedReranker(t *testing.T) {
+	detector, err := mark.NewDetector([]string{mark.PII_PHONE}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := []string{"hunter2", "13812345678"}
+	docs := []RerankDocument{
+		{ID: "a", Content: "vpn password is $hidden[hunter2]"},
+		{ID: "b", Content: "call 13812345678 when the disk is full"},
+	}
+	query := "what is the vpn password $hidden[hunter2] or the phone 13812345678"
+
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req httpRerankRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		sent = append([]string{req.Query}, req.Documents...)
+		w.Write([]byte(`{"results":[{"index":0,"relevance_score":0.9},{"index":1,"relevance_score":0.3}]}`))
+	}))
+	defer server.Close()
+
+	results, err := NewMaskedReranker(NewHTTPReranker(server.URL, "", "", server.Client()), detector).Rerank(context.Background(), query, docs, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []RerankResult{{ID: "a", Score: 0.9}, {ID: "b", Score: 0.3}}, results)
+	assert.Len(t, sent, 3)
+	for _, v := range sent {
+		for _, secret := range secrets {
+			assert.NotContains(t, v, secret)
+		}
+	}
+
+	chat := &fakeChatAI{driver: &fakeQueryDriver{reply: `[{"index": 0, "score": 9}]`}}
+	_, err = NewMaskedReranker(NewLLMReranker(chat), detector).Rerank(context.Background(), query, docs, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prompt string
+	for _, v := range chat.driver.received {
+		prompt += v.Content
+	}
+	assert.True(t, strings.Contains(prompt, "$hidden["))
+	for _, secret := range secrets {
+		assert.NotContains(t, prompt, secret)
+	}
+}
@@ -9,6 +9,9 @@ type Srv struct {
 	ai    *AI
 	tower *Tower
 	seq   *SeqSrv
+
+	reranker   Reranker
+	rerankTopK int
 }
 
 func SetupSrvs(opts ...ApplyFunc) *Srv {
@@ -30,6 +33,15 @@ func (s *Srv) AI() AIDriver {
 	return s.ai
 }
 
+// Reranker 未配置重排时返回nil
+func (s *Srv) Reranker() Reranker {
+	return s.reranker
+}
+
+func (s *Srv) RerankTopK() int {
+	return s.rerankTopK
+}
+
 func (s *Srv) SeqSrv() *SeqSrv {
 	return s.seq
 }
@@ -12,9 +12,11 @@ import (
 	"github.com/samber/lo"
 
 	"github.com/starbx/brew-api/internal/core"
+	"github.com/starbx/brew-api/internal/logic/v1/process"
 	"github.com/starbx/brew-api/pkg/ai"
 	"github.com/starbx/brew-api/pkg/errors"
 	"github.com/starbx/brew-api/pkg/i18n"
+	"github.com/starbx/brew-api/pkg/mark"
 	"github.com/starbx/brew-api/pkg/types"
 	"github.com/starbx/brew-api/pkg/types/protocol"
 	"github.com/starbx/brew-api/pkg/utils"
@@ -169,6 +171,11 @@ func requestAI(ctx context.Context, core *core.Core, sessionContext *SessionCont
 			marks[fake] = real
 		}
 	}
+	if sessionContext.SW != nil {
+		for fake, real := range sessionContext.SW.Map() {
+			marks[fake] = real
+		}
+	}
 
 	respChan, err := ai.HandleAIStream(requestCtx, resp, marks)
 	if err != nil {
@@ -231,7 +238,11 @@ func (s *NormalAssistant) GenSessionContext(ctx context.Context, prompt string,
 // reqMsgInfo 用户请求的内容
 // recvMsgInfo 用于承载ai回复的内容，会预先在数据库中为ai响应的数据创建出对应的记录
 func (s *NormalAssistant) RequestAssistant(ctx context.Context, docs *types.RAGDocs, reqMsgWithDocs *types.ChatMessage, recvMsgInfo *types.ChatMessage) error {
-	prompt := ai.BuildRAGPrompt(s.core.Cfg().Prompt.Query, ai.NewDocs(docs.Docs), s.core.Srv().AI())
+	tpl := s.core.Cfg().Prompt.Query
+	if docs.Prompt != "" {
+		tpl = docs.Prompt
+	}
+	prompt := ai.ReplaceVarLang(ai.BuildRAGPrompt(tpl, ai.NewDocs(docs.Docs), s.core.Srv().AI()), reqMsgWithDocs.Message)
 	chatSessionContext, err := s.GenSessionContext(ctx, prompt, reqMsgWithDocs)
 	if err != nil {
 		return err
@@ -333,6 +344,13 @@ func isErrorMessage(msg string) bool {
 func GenChatSessionContextAndSummaryIfExceedsTokenLimit(ctx context.Context, core *core.Core, basePrompt string, reqMsgWithDocs *types.ChatMessage, msgCondition messageCondition, justGenSummary types.SystemContextGenConditionType) (*SessionContext, error) {
 	reGen := false
 
+	detector, err := process.PrivacyDetector(ctx, core, reqMsgWithDocs.SpaceID)
+	if err != nil {
+		return nil, errors.New("genDialogContextAndSummaryIfExceedsTokenLimit.process.PrivacyDetector", i18n.ERROR_INTERNAL, err)
+	}
+	// 对话记录与总结中的敏感信息在发送给模型前替换，提示词中的文档已在检索时处理
+	sw := mark.NewSensitiveWork().WithDetector(detector)
+
 ReGen:
 	var reqMsg []*types.MessageContext
 	summary, err := core.Store().ChatSummaryStore().GetChatSessionLatestSummary(ctx, reqMsgWithDocs.SessionID)
@@ -347,6 +365,7 @@ ReGen:
 		})
 
 		if summary != nil {
+			summary.Content = sw.Do(summary.Content)
 			appendSummaryToPromptMsg(reqMsg[0], summary)
 		}
 	}
@@ -394,7 +413,7 @@ ReGen:
 
 		reqMsg = append(reqMsg, &types.MessageContext{
 			Role:    v.Role,
-			Content: message,
+			Content: sw.Do(message),
 		})
 	}
 
@@ -428,7 +447,7 @@ ReGen:
 
 		reGen = true
 		// 生成新的总结
-		if err = genChatSessionContextSummary(ctx, core, reqMsgWithDocs.SessionID, summaryMessageID, summaryReq); err != nil {
+		if err = genChatSessionContextSummary(ctx, core, reqMsgWithDocs.SessionID, summaryMessageID, summaryReq, sw); err != nil {
 			return nil, errors.Trace("genDialogContextAndSummaryIfExceedsTokenLimit.genDialogContextSummary", err)
 		}
 		if justGenSummary == types.GEN_SUMMARY_ONLY {
@@ -441,6 +460,7 @@ ReGen:
 		MessageID:      reqMsgWithDocs.ID,
 		SessionID:      reqMsgWithDocs.SessionID,
 		MessageContext: reqMsg,
+		SW:             sw,
 	}, nil
 }
 
@@ -449,10 +469,12 @@ type SessionContext struct {
 	SessionID      string
 	MessageContext []*types.MessageContext
 	Prompt         string
+	// SW 对话记录中被替换的敏感信息，用于还原回答
+	SW types.Undo
 }
 
-// genChatSessionContextSummary 生成dialog上下文总结
-func genChatSessionContextSummary(ctx context.Context, core *core.Core, sessionID, summaryMessageID string, reqMsg []*types.MessageContext) error {
+// genChatSessionContextSummary 生成dialog上下文总结，reqMsg 已由 sw 替换敏感信息，总结还原后再保存
+func genChatSessionContextSummary(ctx context.Context, core *core.Core, sessionID, summaryMessageID string, reqMsg []*types.MessageContext, sw types.Undo) error {
 	slog.Debug("start generating context summary", slog.String("session_id", sessionID), slog.String("msg_id", summaryMessageID), slog.Any("request_message", reqMsg))
 	prompt := core.Cfg().Prompt.ChatSummary
 	if prompt == "" {
@@ -477,7 +499,7 @@ func genChatSessionContextSummary(ctx context.Context, core *core.Core, sessionI
 		ID:        utils.GenSpecIDStr(),
 		SessionID: sessionID,
 		MessageID: summaryMessageID,
-		Content:   resp.Received[0],
+		Content:   sw.Undo(resp.Received[0]),
 	}); err != nil {
 		return errors.New("genDialogContextSummary.ChatSummaryStore.Create", i18n.ERROR_INTERNAL, err)
 	}
@@ -0,0 +1,176 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// 备份文件为 tar.gz 格式，内部按以下顺序存放json文件，导入时依赖该顺序建立id映射
+const (
+	FILE_MANIFEST           = "manifest.json"
+	FILE_SPACE              = "space.json"
+	FILE_RESOURCES          = "resources.jsonl"
+	FILE_KNOWLEDGES         = "knowledges.jsonl"
+	FILE_CHUNKS             = "chunks.jsonl"
+	FILE_VECTORS            = "vectors.jsonl"
+	FILE_CHAT_SESSIONS      = "chat_sessions.jsonl"
+	FILE_CHAT_MESSAGES      = "chat_messages.jsonl"
+	FILE_CHAT_MESSAGE_EXTS  = "chat_message_exts.jsonl"
+	FILE_CHAT_SUMMARIES     = "chat_summaries.jsonl"
+	archiveFileMode         = 0o644
+	archiveTempFilePattern  = "brew-space-archive-*"
+	archiveMaxManifestBytes = 1 << 20
+)
+
+// ErrInvalidArchive 备份文件格式错误
+var ErrInvalidArchive = fmt.Errorf("invalid space archive")
+
+// IsInvalidArchive 判断错误是否由备份文件格式错误引起
+func IsInvalidArchive(err error) bool {
+	return errors.Is(err, ErrInvalidArchive)
+}
+
+// writer 将每个文件先写入临时文件，得到文件长度后再追加到tar中
+type writer struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+func newWriter(w io.Writer) *writer {
+	gz := gzip.NewWriter(w)
+	return &writer{
+		gz: gz,
+		tw: tar.NewWriter(gz),
+	}
+}
+
+// WriteJSON 写入只包含单个json对象的文件
+func (w *writer) WriteJSON(name string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s, %w", name, err)
+	}
+	if err = w.tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    archiveFileMode,
+		Size:    int64(len(raw)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err = w.tw.Write(raw)
+	return err
+}
+
+// WriteLines 写入每行一个json对象的文件，fn 通过 encode 逐条写入记录
+func (w *writer) WriteLines(name string, fn func(encode func(v any) error) error) error {
+	tmp, err := os.CreateTemp("", archiveTempFilePattern)
+	if err != nil {
+		return fmt.Errorf("failed to create temp file, %w", err)
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	enc := json.NewEncoder(tmp)
+	if err = fn(enc.Encode); err != nil {
+		return err
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if err = w.tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    archiveFileMode,
+		Size:    size,
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	if _, err = io.Copy(w.tw, tmp); err != nil {
+		return fmt.Errorf("failed to write %s, %w", name, err)
+	}
+	return nil
+}
+
+func (w *writer) Close() error {
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	return w.gz.Close()
+}
+
+// reader 顺序读取备份文件中的各个文件
+type reader struct {
+	gz *gzip.Reader
+	tr *tar.Reader
+}
+
+func newReader(r io.Reader) (*reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w, %w", ErrInvalidArchive, err)
+	}
+	return &reader{
+		gz: gz,
+		tr: tar.NewReader(gz),
+	}, nil
+}
+
+// Next 返回下一个文件的文件名，读取结束时返回 io.EOF
+func (r *reader) Next() (string, error) {
+	for {
+		header, err := r.tr.Next()
+		if err == io.EOF {
+			return "", err
+		}
+		if err != nil {
+			return "", fmt.Errorf("%w, %w", ErrInvalidArchive, err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			return header.Name, nil
+		}
+	}
+}
+
+// ReadJSON 读取当前只包含单个json对象的文件
+func (r *reader) ReadJSON(v any) error {
+	if err := json.NewDecoder(io.LimitReader(r.tr, archiveMaxManifestBytes)).Decode(v); err != nil {
+		return fmt.Errorf("%w, %w", ErrInvalidArchive, err)
+	}
+	return nil
+}
+
+// readLines 逐行解析当前文件，每条记录调用一次 fn
+func readLines[T any](r *reader, fn func(item T) error) error {
+	dec := json.NewDecoder(r.tr)
+	for {
+		var item T
+		if err := dec.Decode(&item); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("%w, %w", ErrInvalidArchive, err)
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *reader) Close() error {
+	return r.gz.Close()
+}
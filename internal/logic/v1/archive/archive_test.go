@@ -0,0 +1,65 @@
+package archive
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	assert.NoError(t, w.WriteJSON(FILE_MANIFEST, types.SpaceArchiveManifest{
+		Version:        types.SPACE_ARCHIVE_VERSION,
+		SpaceID:        "space",
+		EmbeddingModel: "openai:text-embedding-3-small",
+	}))
+	assert.NoError(t, w.WriteLines(FILE_KNOWLEDGES, func(encode func(v any) error) error {
+		for _, id := range []string{"a", "b", "c"} {
+			if err := encode(types.Knowledge{ID: id, Tags: []string{"tag-" + id}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	assert.NoError(t, w.Close())
+
+	r, err := newReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	name, err := r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, FILE_MANIFEST, name)
+
+	var manifest types.SpaceArchiveManifest
+	assert.NoError(t, r.ReadJSON(&manifest))
+	assert.Equal(t, "openai:text-embedding-3-small", manifest.EmbeddingModel)
+
+	name, err = r.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, FILE_KNOWLEDGES, name)
+
+	var ids []string
+	assert.NoError(t, readLines(r, func(item types.Knowledge) error {
+		ids = append(ids, item.ID)
+		assert.Equal(t, "tag-"+item.ID, item.Tags[0])
+		return nil
+	}))
+	assert.Equal(t, []string{"a", "b", "c"}, ids)
+
+	_, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestInvalidArchive(t *testing.T) {
+	_, err := newReader(strings.NewReader("not a gzip file"))
+	assert.True(t, IsInvalidArchive(err))
+}
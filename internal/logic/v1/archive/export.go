@@ -0,0 +1,222 @@
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/starbx/brew-api/internal/core"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+const (
+	EXPORT_PAGE_SIZE = 100
+	// 单个知识的分块数量不会超过该值
+	exportMaxVectorsPerKnowledge = 100000
+)
+
+// Export 将空间中的资源、知识、分块、向量以及会话记录写入备份文件
+func Export(ctx context.Context, core *core.Core, spaceID string, w io.Writer) error {
+	space, err := core.Store().SpaceStore().GetSpace(ctx, spaceID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("space %s not found", spaceID)
+		}
+		return fmt.Errorf("failed to get space, %w", err)
+	}
+
+	aw := newWriter(w)
+	e := &exporter{
+		ctx:     ctx,
+		core:    core,
+		spaceID: spaceID,
+		w:       aw,
+	}
+
+	if err = aw.WriteJSON(FILE_MANIFEST, types.SpaceArchiveManifest{
+		Version:        types.SPACE_ARCHIVE_VERSION,
+		SpaceID:        spaceID,
+		EmbeddingModel: core.Srv().AI().EmbeddingModel(),
+		ExportedAt:     time.Now().Unix(),
+	}); err != nil {
+		return err
+	}
+	if err = aw.WriteJSON(FILE_SPACE, space); err != nil {
+		return err
+	}
+
+	for _, step := range []func() error{
+		e.exportResources,
+		e.exportKnowledges,
+		e.exportChatSessions,
+	} {
+		if err = step(); err != nil {
+			return err
+		}
+	}
+	return aw.Close()
+}
+
+type exporter struct {
+	ctx     context.Context
+	core    *core.Core
+	spaceID string
+	w       *writer
+
+	knowledgeIDs []string
+	sessionIDs   []string
+	messageIDs   []string
+}
+
+func (e *exporter) exportResources() error {
+	return e.w.WriteLines(FILE_RESOURCES, func(encode func(v any) error) error {
+		list, err := e.core.Store().ResourceStore().ListResources(e.ctx, e.spaceID, 0, 0)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to list resources, %w", err)
+		}
+		for _, v := range list {
+			if err = encode(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (e *exporter) exportKnowledges() error {
+	err := e.w.WriteLines(FILE_KNOWLEDGES, func(encode func(v any) error) error {
+		opts := types.GetKnowledgeOptions{SpaceID: e.spaceID}
+		for page := uint64(1); ; page++ {
+			list, err := e.core.Store().KnowledgeStore().ListKnowledges(e.ctx, opts, page, EXPORT_PAGE_SIZE)
+			if err != nil && err != sql.ErrNoRows {
+				return fmt.Errorf("failed to list knowledges, %w", err)
+			}
+			for _, v := range list {
+				e.knowledgeIDs = append(e.knowledgeIDs, v.ID)
+				if err = encode(v); err != nil {
+					return err
+				}
+			}
+			if len(list) < EXPORT_PAGE_SIZE {
+				return nil
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = e.w.WriteLines(FILE_CHUNKS, func(encode func(v any) error) error {
+		for _, id := range e.knowledgeIDs {
+			list, err := e.core.Store().KnowledgeChunkStore().List(e.ctx, e.spaceID, id)
+			if err != nil && err != sql.ErrNoRows {
+				return fmt.Errorf("failed to list knowledge chunks, %w", err)
+			}
+			for _, v := range list {
+				if err = encode(v); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return e.w.WriteLines(FILE_VECTORS, func(encode func(v any) error) error {
+		for _, id := range e.knowledgeIDs {
+			// 只导出与 manifest 中记录的模型一致的向量
+			list, err := e.core.Store().VectorStore().ListVectors(e.ctx, types.GetVectorsOptions{
+				SpaceID:     e.spaceID,
+				KnowledgeID: id,
+				Model:       e.core.Srv().AI().EmbeddingModel(),
+			}, 1, exportMaxVectorsPerKnowledge)
+			if err != nil && err != sql.ErrNoRows {
+				return fmt.Errorf("failed to list vectors, %w", err)
+			}
+			for _, v := range list {
+				if err = encode(v); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (e *exporter) exportChatSessions() error {
+	err := e.w.WriteLines(FILE_CHAT_SESSIONS, func(encode func(v any) error) error {
+		for page := uint64(1); ; page++ {
+			list, err := e.core.Store().ChatSessionStore().ListSpaceSessions(e.ctx, e.spaceID, page, EXPORT_PAGE_SIZE)
+			if err != nil && err != sql.ErrNoRows {
+				return fmt.Errorf("failed to list chat sessions, %w", err)
+			}
+			for _, v := range list {
+				e.sessionIDs = append(e.sessionIDs, v.ID)
+				if err = encode(v); err != nil {
+					return err
+				}
+			}
+			if len(list) < EXPORT_PAGE_SIZE {
+				return nil
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = e.w.WriteLines(FILE_CHAT_MESSAGES, func(encode func(v any) error) error {
+		for _, id := range e.sessionIDs {
+			list, err := e.core.Store().ChatMessageStore().ListSessionMessage(e.ctx, e.spaceID, id, "", types.NO_PAGING, types.NO_PAGING)
+			if err != nil && err != sql.ErrNoRows {
+				return fmt.Errorf("failed to list chat messages, %w", err)
+			}
+			// 按发送顺序写入，导入时依次生成递增的消息id
+			for i := len(list) - 1; i >= 0; i-- {
+				e.messageIDs = append(e.messageIDs, list[i].ID)
+				if err = encode(list[i]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err = e.w.WriteLines(FILE_CHAT_MESSAGE_EXTS, func(encode func(v any) error) error {
+		for i := 0; i < len(e.messageIDs); i += EXPORT_PAGE_SIZE {
+			end := min(i+EXPORT_PAGE_SIZE, len(e.messageIDs))
+			list, err := e.core.Store().ChatMessageExtStore().ListChatMessageExts(e.ctx, e.messageIDs[i:end])
+			if err != nil && err != sql.ErrNoRows {
+				return fmt.Errorf("failed to list chat message exts, %w", err)
+			}
+			for _, v := range list {
+				if err = encode(v); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return e.w.WriteLines(FILE_CHAT_SUMMARIES, func(encode func(v any) error) error {
+		for _, id := range e.sessionIDs {
+			list, err := e.core.Store().ChatSummaryStore().ListSessionSummaries(e.ctx, id)
+			if err != nil && err != sql.ErrNoRows {
+				return fmt.Errorf("failed to list chat summaries, %w", err)
+			}
+			for _, v := range list {
+				if err = encode(v); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
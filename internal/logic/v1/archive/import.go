@@ -0,0 +1,401 @@
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/starbx/brew-api/internal/core"
+	"github.com/starbx/brew-api/internal/core/srv"
+	"github.com/starbx/brew-api/pkg/types"
+	"github.com/starbx/brew-api/pkg/utils"
+)
+
+const IMPORT_BATCH_SIZE = 100
+
+// Import 将备份文件恢复到新的空间或已有的空间中，所有数据都会分配新的id，整个导入在同一个事务中完成
+// 当备份中的embedding模型与当前不一致或不复用向量时，已完成的知识会回到embedding阶段，由后台任务重新生成向量
+func Import(ctx context.Context, core *core.Core, r io.Reader, opts types.SpaceImportOptions) (*types.SpaceImportResult, error) {
+	if opts.UserID == "" {
+		return nil, fmt.Errorf("the owner of imported data is required")
+	}
+
+	ar, err := newReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer ar.Close()
+
+	im := &importer{
+		core:         core,
+		opts:         opts,
+		reader:       ar,
+		result:       &types.SpaceImportResult{},
+		knowledgeIDs: make(map[string]string),
+		chunkIDs:     make(map[string]string),
+		sessionIDs:   make(map[string]string),
+		messageIDs:   make(map[string]string),
+	}
+
+	err = core.Store().Transaction(ctx, func(ctx context.Context) error {
+		im.ctx = ctx
+		for {
+			name, err := ar.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if err = im.importFile(name); err != nil {
+				return fmt.Errorf("failed to import %s, %w", name, err)
+			}
+		}
+		if im.manifest == nil || im.result.SpaceID == "" {
+			return fmt.Errorf("%w, manifest or space is missing", ErrInvalidArchive)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return im.result, nil
+}
+
+type importer struct {
+	ctx    context.Context
+	core   *core.Core
+	opts   types.SpaceImportOptions
+	reader *reader
+	result *types.SpaceImportResult
+
+	manifest     *types.SpaceArchiveManifest
+	reuseVectors bool
+
+	// 备份中的id与新id的映射
+	knowledgeIDs map[string]string
+	chunkIDs     map[string]string
+	sessionIDs   map[string]string
+	messageIDs   map[string]string
+}
+
+func (im *importer) importFile(name string) error {
+	if name != FILE_MANIFEST && im.manifest == nil {
+		return fmt.Errorf("%w, manifest must be the first file", ErrInvalidArchive)
+	}
+	if name != FILE_MANIFEST && name != FILE_SPACE && im.result.SpaceID == "" {
+		return fmt.Errorf("%w, space must be placed before %s", ErrInvalidArchive, name)
+	}
+
+	switch name {
+	case FILE_MANIFEST:
+		return im.importManifest()
+	case FILE_SPACE:
+		return im.importSpace()
+	case FILE_RESOURCES:
+		return im.importResources()
+	case FILE_KNOWLEDGES:
+		return im.importKnowledges()
+	case FILE_CHUNKS:
+		return im.importChunks()
+	case FILE_VECTORS:
+		if !im.reuseVectors {
+			return nil
+		}
+		return im.importVectors()
+	case FILE_CHAT_SESSIONS:
+		return im.importChatSessions()
+	case FILE_CHAT_MESSAGES:
+		return im.importChatMessages()
+	case FILE_CHAT_MESSAGE_EXTS:
+		return im.importChatMessageExts()
+	case FILE_CHAT_SUMMARIES:
+		return im.importChatSummaries()
+	default:
+		// 忽略无法识别的文件
+		return nil
+	}
+}
+
+func (im *importer) importManifest() error {
+	var manifest types.SpaceArchiveManifest
+	if err := im.reader.ReadJSON(&manifest); err != nil {
+		return err
+	}
+	if manifest.Version <= 0 || manifest.Version > types.SPACE_ARCHIVE_VERSION {
+		return fmt.Errorf("%w, unsupported version %d", ErrInvalidArchive, manifest.Version)
+	}
+	im.manifest = &manifest
+	im.reuseVectors = im.opts.ReuseVectors && manifest.EmbeddingModel != "" &&
+		manifest.EmbeddingModel == im.core.Srv().AI().EmbeddingModel()
+	im.result.Reembedding = !im.reuseVectors
+	return nil
+}
+
+func (im *importer) importSpace() error {
+	var space types.Space
+	if err := im.reader.ReadJSON(&space); err != nil {
+		return err
+	}
+
+	if im.opts.SpaceID != "" {
+		if _, err := im.core.Store().SpaceStore().GetSpace(im.ctx, im.opts.SpaceID); err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("space %s not found", im.opts.SpaceID)
+			}
+			return err
+		}
+		im.result.SpaceID = im.opts.SpaceID
+		return nil
+	}
+
+	spaceID := utils.GenRandomID()
+	err := im.core.Store().SpaceStore().Create(im.ctx, types.Space{
+		SpaceID:     spaceID,
+		Title:       space.Title,
+		Description: space.Description,
+		Retrieval:   space.Retrieval,
+		CreatedAt:   time.Now().Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = im.core.Store().UserSpaceStore().Create(im.ctx, types.UserSpace{
+		UserID:    im.opts.UserID,
+		SpaceID:   spaceID,
+		Role:      srv.RoleAdmin,
+		CreatedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	im.result.SpaceID = spaceID
+	return nil
+}
+
+func (im *importer) importResources() error {
+	return readLines(im.reader, func(item types.Resource) error {
+		// 资源id在空间内由用户指定，已存在时沿用目标空间中的配置
+		exist, err := im.core.Store().ResourceStore().GetResource(im.ctx, im.result.SpaceID, item.ID)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if exist != nil {
+			return nil
+		}
+
+		item.SpaceID = im.result.SpaceID
+		item.UserID = im.opts.UserID
+		if err = im.core.Store().ResourceStore().Create(im.ctx, item); err != nil {
+			return err
+		}
+		im.result.Resources++
+		return nil
+	})
+}
+
+func (im *importer) importKnowledges() error {
+	var batch []types.Knowledge
+	flush := func() error {
+		if err := im.core.Store().KnowledgeStore().BatchCreate(im.ctx, batch); err != nil {
+			return err
+		}
+		im.result.Knowledges += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	err := readLines(im.reader, func(item types.Knowledge) error {
+		id := utils.GenRandomID()
+		im.knowledgeIDs[item.ID] = id
+
+		item.ID = id
+		item.SpaceID = im.result.SpaceID
+		item.UserID = im.opts.UserID
+		item.RetryTimes = 0
+		if !im.reuseVectors && item.Stage == types.KNOWLEDGE_STAGE_DONE {
+			item.Stage = types.KNOWLEDGE_STAGE_EMBEDDING
+		}
+
+		batch = append(batch, item)
+		if len(batch) >= IMPORT_BATCH_SIZE {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+func (im *importer) importChunks() error {
+	var batch []types.KnowledgeChunk
+	flush := func() error {
+		if err := im.core.Store().KnowledgeChunkStore().BatchCreate(im.ctx, batch); err != nil {
+			return err
+		}
+		im.result.Chunks += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	err := readLines(im.reader, func(item types.KnowledgeChunk) error {
+		knowledgeID, ok := im.knowledgeIDs[item.KnowledgeID]
+		if !ok {
+			return nil
+		}
+		id := utils.GenRandomID()
+		im.chunkIDs[item.ID] = id
+
+		item.ID = id
+		item.KnowledgeID = knowledgeID
+		item.SpaceID = im.result.SpaceID
+		item.UserID = im.opts.UserID
+
+		batch = append(batch, item)
+		if len(batch) >= IMPORT_BATCH_SIZE {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+func (im *importer) importVectors() error {
+	var batch []types.Vector
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := im.core.Store().VectorStore().BatchCreate(im.ctx, batch); err != nil {
+			return err
+		}
+		im.result.Vectors += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	err := readLines(im.reader, func(item types.Vector) error {
+		// 向量id与分块id相同
+		id, ok := im.chunkIDs[item.ID]
+		if !ok {
+			return nil
+		}
+		knowledgeID, ok := im.knowledgeIDs[item.KnowledgeID]
+		if !ok {
+			return nil
+		}
+		// 早期的归档没有记录向量的模型，以 manifest 中的模型为准
+		model := im.core.Srv().AI().EmbeddingModel()
+		if item.Model == "" {
+			item.Model = model
+		} else if item.Model != model {
+			return nil
+		}
+		if item.Dim == 0 {
+			item.Dim = len(item.Embedding.Slice())
+		}
+
+		item.ID = id
+		item.KnowledgeID = knowledgeID
+		item.SpaceID = im.result.SpaceID
+		item.UserID = im.opts.UserID
+
+		batch = append(batch, item)
+		if len(batch) >= IMPORT_BATCH_SIZE {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+func (im *importer) importChatSessions() error {
+	return readLines(im.reader, func(item types.ChatSession) error {
+		id := utils.GenSpecIDStr()
+		im.sessionIDs[item.ID] = id
+
+		item.ID = id
+		item.SpaceID = im.result.SpaceID
+		item.UserID = im.opts.UserID
+		if err := im.core.Store().ChatSessionStore().Create(im.ctx, item); err != nil {
+			return err
+		}
+		im.result.Sessions++
+		return nil
+	})
+}
+
+func (im *importer) importChatMessages() error {
+	// 消息按发送顺序导出，依次生成的id保持递增，与原有的消息顺序一致
+	return readLines(im.reader, func(item types.ChatMessage) error {
+		sessionID, ok := im.sessionIDs[item.SessionID]
+		if !ok {
+			return nil
+		}
+		id := utils.GenSpecIDStr()
+		im.messageIDs[item.ID] = id
+
+		item.ID = id
+		item.SessionID = sessionID
+		item.SpaceID = im.result.SpaceID
+		if item.UserID != "" {
+			item.UserID = im.opts.UserID
+		}
+		if err := im.core.Store().ChatMessageStore().Create(im.ctx, &item); err != nil {
+			return err
+		}
+		im.result.Messages++
+		return nil
+	})
+}
+
+func (im *importer) importChatMessageExts() error {
+	return readLines(im.reader, func(item types.ChatMessageExt) error {
+		messageID, ok := im.messageIDs[item.MessageID]
+		if !ok {
+			return nil
+		}
+
+		var docs []string
+		for _, v := range item.RelDocs {
+			if id, ok := im.knowledgeIDs[v]; ok {
+				docs = append(docs, id)
+			}
+		}
+
+		item.MessageID = messageID
+		item.SessionID = im.sessionIDs[item.SessionID]
+		item.SpaceID = im.result.SpaceID
+		item.RelDocs = docs
+		return im.core.Store().ChatMessageExtStore().Create(im.ctx, item)
+	})
+}
+
+func (im *importer) importChatSummaries() error {
+	return readLines(im.reader, func(item types.ChatSummary) error {
+		sessionID, ok := im.sessionIDs[item.SessionID]
+		if !ok {
+			return nil
+		}
+		messageID, ok := im.messageIDs[item.MessageID]
+		if !ok {
+			return nil
+		}
+
+		item.ID = utils.GenSpecIDStr()
+		item.SessionID = sessionID
+		item.MessageID = messageID
+		return im.core.Store().ChatSummaryStore().Create(im.ctx, item)
+	})
+}
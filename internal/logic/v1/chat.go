@@ -46,7 +46,7 @@ func GenUserTextMessage(spaceID, sessionID, userID, msgID, message string) *type
 	}
 }
 
-func (l *ChatLogic) NewUserMessage(chatSession *types.ChatSession, msgArgs types.CreateChatMessageArgs, resourceQuery *types.ResourceQuery) (seqid int64, err error) {
+func (l *ChatLogic) NewUserMessage(chatSession *types.ChatSession, msgArgs types.CreateChatMessageArgs, resourceQuery *types.ResourceQuery, filter *types.KnowledgeFilter) (seqid int64, err error) {
 	slog.Debug("new message", slog.String("msg_id", msgArgs.ID), slog.String("user_id", l.GetUserInfo().User), slog.String("session_id", chatSession.ID))
 
 	// 如果dialog为非正式状态，则转换为正式状态
@@ -54,6 +54,10 @@ func (l *ChatLogic) NewUserMessage(chatSession *types.ChatSession, msgArgs types
 		return 0, errors.New("ChatLogic.NewUserMessageSend.dialog", i18n.ERROR_INTERNAL, nil)
 	}
 
+	if err = filter.Validate(); err != nil {
+		return 0, errors.New("ChatLogic.NewUserMessageSend.filter.Validate", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest)
+	}
+
 	if chatSession.Status != types.CHAT_SESSION_STATUS_OFFICIAL {
 		go safe.Run(func() {
 			if err = l.core.Store().ChatSessionStore().UpdateSessionStatus(l.ctx, chatSession.ID, types.CHAT_SESSION_STATUS_OFFICIAL); err != nil {
@@ -150,7 +154,7 @@ func (l *ChatLogic) NewUserMessage(chatSession *types.ChatSession, msgArgs types
 	})
 
 	go safe.Run(func() {
-		docs, err := NewKnowledgeLogic(l.ctx, l.core).GetRelevanceKnowledges(chatSession.SpaceID, l.GetUserInfo().User, queryMsg, resourceQuery)
+		docs, err := NewKnowledgeLogic(l.ctx, l.core).GetRelevanceKnowledges(chatSession.SpaceID, l.GetUserInfo().User, queryMsg, resourceQuery, filter)
 		if err != nil {
 			err = errors.Trace("ChatLogic.getRelevanceKnowledges", err)
 			return
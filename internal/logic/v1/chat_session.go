@@ -8,9 +8,11 @@ import (
 	"time"
 
 	"github.com/starbx/brew-api/internal/core"
+	"github.com/starbx/brew-api/internal/logic/v1/process"
 	"github.com/starbx/brew-api/pkg/ai"
 	"github.com/starbx/brew-api/pkg/errors"
 	"github.com/starbx/brew-api/pkg/i18n"
+	"github.com/starbx/brew-api/pkg/mark"
 	"github.com/starbx/brew-api/pkg/types"
 	"github.com/starbx/brew-api/pkg/utils"
 )
@@ -49,6 +51,9 @@ func (l *ChatSessionLogic) DeleteChatSession(spaceID, sessionID string) error {
 	if err := l.core.Store().ChatSessionStore().Delete(l.ctx, spaceID, sessionID); err != nil {
 		return errors.New("ChatSessionLogic.DeleteChatSession.ChatSessionStore.Delete", i18n.ERROR_INTERNAL, err)
 	}
+	if err := l.core.Store().ShareTokenStore().DeleteByObject(l.ctx, spaceID, types.SHARE_KIND_SESSION, sessionID); err != nil {
+		return errors.New("ChatSessionLogic.DeleteChatSession.ShareTokenStore.DeleteByObject", i18n.ERROR_INTERNAL, err)
+	}
 	return nil
 }
 
@@ -98,7 +103,15 @@ type NamedSessionResult struct {
 }
 
 func (l *ChatSessionLogic) NamedSession(sessionID, firstQuery string) (NamedSessionResult, error) {
-	tool := l.core.Srv().AI().NewQuery(l.ctx, []*types.MessageContext{{Role: types.USER_ROLE_USER, Content: firstQuery}})
+	spaceID, _ := InjectSpaceID(l.ctx)
+	detector, err := process.PrivacyDetector(l.ctx, l.core, spaceID)
+	if err != nil {
+		return NamedSessionResult{}, errors.New("ChatSessionLogic.NamedSession.process.PrivacyDetector", i18n.ERROR_INTERNAL, err)
+	}
+	// 首条消息中的敏感信息在发送给模型前替换，生成的标题还原后再保存
+	sw := mark.NewSensitiveWork().WithDetector(detector)
+
+	tool := l.core.Srv().AI().NewQuery(l.ctx, []*types.MessageContext{{Role: types.USER_ROLE_USER, Content: sw.Do(firstQuery)}})
 	prompt := l.core.Cfg().Prompt.SessionName
 	if prompt == "" {
 		prompt = ai.PROMPT_NAMED_SESSION_DEFAULT_EN
@@ -109,7 +122,7 @@ func (l *ChatSessionLogic) NamedSession(sessionID, firstQuery string) (NamedSess
 		return NamedSessionResult{}, errors.New("ChatSessionLogic.NamedSession.ai.Query", i18n.ERROR_INTERNAL, err)
 	}
 
-	title := resp.Message()
+	title := sw.Undo(resp.Message())
 	if len([]rune(title)) > 30 {
 		title = string([]rune(title)[:30])
 	}
@@ -22,7 +22,7 @@ func Test_GetRelevanceKnowledges(t *testing.T) {
 	userID := os.Getenv("TEST_USER_ID")
 	message := "React 路由如何配置？"
 
-	docs, err := knowledgeLogic.GetRelevanceKnowledges(spaceID, userID, message, nil)
+	docs, err := knowledgeLogic.GetRelevanceKnowledges(spaceID, userID, message, nil, nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -53,7 +53,7 @@ func Test_NewMessageSend(t *testing.T) {
 		SendTime: time.Now().Unix(),
 		MsgType:  types.MESSAGE_TYPE_TEXT,
 		Message:  message,
-	}, nil)
+	}, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pgvector/pgvector-go"
@@ -17,9 +20,9 @@ import (
 	"github.com/starbx/brew-api/internal/logic/v1/process"
 	"github.com/starbx/brew-api/pkg/ai"
 	"github.com/starbx/brew-api/pkg/errors"
+	"github.com/starbx/brew-api/pkg/extract"
 	"github.com/starbx/brew-api/pkg/i18n"
 	"github.com/starbx/brew-api/pkg/mark"
-	"github.com/starbx/brew-api/pkg/safe"
 	"github.com/starbx/brew-api/pkg/types"
 	"github.com/starbx/brew-api/pkg/utils"
 )
@@ -77,10 +80,11 @@ func (l *KnowledgeLogic) GetKnowledge(spaceID, id string) (*types.Knowledge, err
 	return data, nil
 }
 
-func (l *KnowledgeLogic) ListKnowledges(spaceID string, resource *types.ResourceQuery, page, pagesize uint64) ([]*types.Knowledge, uint64, error) {
+func (l *KnowledgeLogic) ListKnowledges(spaceID string, resource *types.ResourceQuery, tags []string, page, pagesize uint64) ([]*types.Knowledge, uint64, error) {
 	opts := types.GetKnowledgeOptions{
 		SpaceID:  spaceID,
 		Resource: resource,
+		Tags:     tags,
 	}
 	list, err := l.core.Store().KnowledgeStore().ListKnowledges(l.ctx, opts, page, pagesize)
 	if err != nil && err != sql.ErrNoRows {
@@ -102,110 +106,126 @@ func (l *KnowledgeLogic) Delete(spaceID, id string) error {
 	}
 
 	return l.core.Store().Transaction(l.ctx, func(ctx context.Context) error {
-		if err := l.core.Store().KnowledgeStore().Delete(ctx, spaceID, id); err != nil {
-			return errors.New("KnowledgeLogic.Delete.KnowledgeStore.Delete", i18n.ERROR_INTERNAL, err)
-		}
-
-		if err := l.core.Store().KnowledgeChunkStore().BatchDelete(ctx, spaceID, id); err != nil {
-			return errors.New("KnowledgeLogic.Delete.KnowledgeChunkStore.BatchDelete", i18n.ERROR_INTERNAL, err)
-		}
-
-		if err := l.core.Store().VectorStore().BatchDelete(ctx, spaceID, id); err != nil {
-			return errors.New("KnowledgeLogic.Delete.VectorStore.Delete", i18n.ERROR_INTERNAL, err)
-		}
-
-		return nil
+		return l.deleteKnowledge(ctx, spaceID, id)
 	})
 }
 
-func (l *KnowledgeLogic) Update(spaceID, id string, args types.UpdateKnowledgeArgs) error {
-	oldKnowledge, err := l.core.Store().KnowledgeStore().GetKnowledge(l.ctx, spaceID, id)
-	if err != nil && err != sql.ErrNoRows {
-		return errors.New("KnowledgeLogic.Update.KnowledgeStore.GetKnowledge", i18n.ERROR_INTERNAL, err)
+// deleteKnowledge 删除知识及其关联的分块、向量、修订、重复记录、链接与分享，需要在事务中调用
+func (l *KnowledgeLogic) deleteKnowledge(ctx context.Context, spaceID, id string) error {
+	if err := process.DeleteKnowledge(ctx, l.core, spaceID, id); err != nil {
+		return errors.New("KnowledgeLogic.Delete.process.DeleteKnowledge", i18n.ERROR_INTERNAL, err)
 	}
+	return nil
+}
 
-	if oldKnowledge == nil || oldKnowledge.UserID != l.GetUserInfo().User {
-		return errors.New("KnowledgeLogic.Update.KnowledgeStore.GetKnowledge", i18n.ERROR_NOTFOUND, err).Code(http.StatusNotFound)
+func (l *KnowledgeLogic) Update(spaceID, id string, args types.UpdateKnowledgeArgs) error {
+	var contentHash string
+	if args.Content != "" {
+		contentHash = utils.ContentHash(args.Content)
 	}
 
-	tagsChanged := false
-	if len(args.Tags) != 0 {
-		if len(args.Tags) != len(oldKnowledge.Tags) {
-			tagsChanged = true
-		} else {
-			for _, v := range args.Tags {
-				matched := false
-				for _, vv := range oldKnowledge.Tags {
-					if v == vv {
-						matched = true
+	return l.core.Store().Transaction(l.ctx, func(ctx context.Context) error {
+		// 在事务中锁定知识后再读取，并发修改时历史版本记录的是各自实际覆盖的内容
+		oldKnowledge, err := l.core.Store().KnowledgeStore().GetKnowledgeForUpdate(ctx, spaceID, id)
+		if err != nil && err != sql.ErrNoRows {
+			return errors.New("KnowledgeLogic.Update.KnowledgeStore.GetKnowledgeForUpdate", i18n.ERROR_INTERNAL, err)
+		}
+
+		if oldKnowledge == nil || oldKnowledge.UserID != l.GetUserInfo().User {
+			return errors.New("KnowledgeLogic.Update.KnowledgeStore.GetKnowledgeForUpdate", i18n.ERROR_NOTFOUND, err).Code(http.StatusNotFound)
+		}
+
+		tagsChanged := false
+		if len(args.Tags) != 0 {
+			if len(args.Tags) != len(oldKnowledge.Tags) {
+				tagsChanged = true
+			} else {
+				for _, v := range args.Tags {
+					matched := false
+					for _, vv := range oldKnowledge.Tags {
+						if v == vv {
+							matched = true
+							break
+						}
+					}
+					if !matched {
+						tagsChanged = true
 						break
 					}
 				}
-				if !matched {
-					tagsChanged = true
-					break
-				}
 			}
 		}
-	}
 
-	var summary []string
-	if !tagsChanged {
-		summary = append(summary, "tags")
-	}
-	if args.Content != oldKnowledge.Content {
-		summary = append(summary, "content")
-	}
-	if args.Title == "" {
-		summary = append(summary, "title")
-	}
+		var summary []string
+		if !tagsChanged {
+			summary = append(summary, "tags")
+		}
+		if args.Content != oldKnowledge.Content {
+			summary = append(summary, "content")
+		}
+		if args.Title == "" {
+			summary = append(summary, "title")
+		}
 
-	err = l.core.Store().KnowledgeStore().Update(l.ctx, spaceID, id, types.UpdateKnowledgeArgs{
-		Resource: args.Resource,
-		Title:    args.Title,
-		Content:  args.Content,
-		Tags:     args.Tags,
-		Stage:    types.KNOWLEDGE_STAGE_SUMMARIZE,
-		Kind:     args.Kind,
-		Summary:  strings.Join(summary, ","),
-	})
-	if err != nil {
-		return errors.New("KnowledgeLogic.Update.KnowledgeStore.Update", i18n.ERROR_INTERNAL, err)
-	}
+		// 保留修改前的内容，用于查看历史版本与恢复
+		err = l.core.Store().KnowledgeRevisionStore().Create(ctx, types.KnowledgeRevision{
+			ID:          utils.GenSpecIDStr(),
+			KnowledgeID: id,
+			SpaceID:     spaceID,
+			UserID:      oldKnowledge.UserID,
+			ReplacedBy:  l.GetUserInfo().User,
+			Title:       oldKnowledge.Title,
+			Tags:        oldKnowledge.Tags,
+			Content:     oldKnowledge.Content,
+			CreatedAt:   time.Now().Unix(),
+		})
+		if err != nil {
+			return errors.New("KnowledgeLogic.Update.KnowledgeRevisionStore.Create", i18n.ERROR_INTERNAL, err)
+		}
 
-	go safe.Run(func() {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Minute*10)
-		defer cancel()
-		knowledge, err := l.core.Store().KnowledgeStore().GetKnowledge(ctx, spaceID, id)
+		err = l.core.Store().KnowledgeStore().Update(ctx, spaceID, id, types.UpdateKnowledgeArgs{
+			Resource:    args.Resource,
+			Title:       args.Title,
+			Content:     args.Content,
+			Tags:        args.Tags,
+			Stage:       types.KNOWLEDGE_STAGE_SUMMARIZE,
+			Kind:        args.Kind,
+			Summary:     strings.Join(summary, ","),
+			ContentHash: contentHash,
+		})
 		if err != nil {
-			slog.Error("Failed to get new knowledge after update, knowledge process stopped",
-				slog.String("space_id", spaceID),
-				slog.String("knowledge_id", id),
-				slog.String("error", err.Error()))
-			return
+			return errors.New("KnowledgeLogic.Update.KnowledgeStore.Update", i18n.ERROR_INTERNAL, err)
 		}
-		if err = l.processKnowledgeAsync(*knowledge); err != nil {
-			slog.Error("Process knowledge async failed",
-				slog.String("space_id", knowledge.SpaceID),
-				slog.String("knowledge_id", knowledge.ID),
-				slog.Any("error", err))
+
+		if err = process.Enqueue(ctx, l.core, types.Knowledge{ID: id, SpaceID: spaceID, Stage: types.KNOWLEDGE_STAGE_SUMMARIZE}); err != nil {
+			return errors.New("KnowledgeLogic.Update.process.Enqueue", i18n.ERROR_INTERNAL, err)
 		}
+		return nil
 	})
-
-	return nil
 }
 
-func (l *KnowledgeLogic) GetRelevanceKnowledges(spaceID, userID, query string, resource *types.ResourceQuery) (*types.RAGDocs, error) {
+func (l *KnowledgeLogic) GetRelevanceKnowledges(spaceID, userID, query string, resource *types.ResourceQuery, filter *types.KnowledgeFilter) (*types.RAGDocs, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, errors.New("KnowledgeLogic.GetRelevanceKnowledges.filter.Validate", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest)
+	}
+
+	detector, err := process.PrivacyDetector(l.ctx, l.core, spaceID)
+	if err != nil {
+		return nil, errors.New("KnowledgeLogic.GetRelevanceKnowledges.process.PrivacyDetector", i18n.ERROR_INTERNAL, err)
+	}
+	// 问题在发送给模型改写与向量化前替换敏感信息
+	maskedQuery := mark.NewSensitiveWork().WithDetector(detector).Do(query)
+
 	var result types.RAGDocs
 	aiOpts := l.core.Srv().AI().NewEnhance(l.ctx)
 	aiOpts.WithPrompt(l.core.Cfg().Prompt.EnhanceQuery)
-	resp, err := aiOpts.EnhanceQuery(query)
+	resp, err := aiOpts.EnhanceQuery(maskedQuery)
 	if err != nil {
-		slog.Error("failed to enhance user query", slog.String("query", query), slog.String("error", err.Error()))
+		slog.Error("failed to enhance user query", slog.String("error", err.Error()))
 		// return nil, errors.New("KnowledgeLogic.GetRelevanceKnowledges.AI.EnhanceQuery", i18n.ERROR_INTERNAL, err)
 	}
 
-	queryStrs := []string{query}
+	queryStrs := []string{maskedQuery}
 	if len(resp.News) > 0 {
 		queryStrs = append(queryStrs, resp.News...)
 	}
@@ -215,13 +235,32 @@ func (l *KnowledgeLogic) GetRelevanceKnowledges(spaceID, userID, query string, r
 		return nil, errors.New("KnowledgeLogic.GetRelevanceKnowledges.AI.EmbeddingForQuery", i18n.ERROR_INTERNAL, err)
 	}
 
-	refs, err := l.core.Store().VectorStore().Query(l.ctx, types.GetVectorsOptions{
-		SpaceID:  spaceID,
-		UserID:   userID,
-		Resource: resource,
-	}, pgvector.NewVector(vector[0]), 40)
+	scoped, err := l.scopedResource(spaceID, resource)
+	if err != nil {
+		return nil, errors.New("KnowledgeLogic.GetRelevanceKnowledges.scopedResource", i18n.ERROR_INTERNAL, err)
+	}
+	if scoped != nil {
+		result.Prompt = scoped.Prompt
+	}
+
+	retrieval, err := l.retrievalOptions(spaceID, scoped, types.RetrievalOptions{
+		TopK:        40,
+		MaxDistance: 0.5,
+		MaxDocs:     20,
+	})
+	if err != nil {
+		return nil, errors.New("KnowledgeLogic.GetRelevanceKnowledges.retrievalOptions", i18n.ERROR_INTERNAL, err)
+	}
+
+	refs, err := l.searchVectors(types.GetVectorsOptions{
+		SpaceID:          spaceID,
+		UserID:           userID,
+		Resource:         resource,
+		Filter:           filter,
+		ExcludeExpiredAt: time.Now().Unix(),
+	}, retrieval, query, pgvector.NewVector(vector[0]))
 	if err != nil {
-		return nil, errors.New("KnowledgeLogic.GetRelevanceKnowledges.VectorStore.Query", i18n.ERROR_INTERNAL, err)
+		return nil, errors.New("KnowledgeLogic.GetRelevanceKnowledges.searchVectors", i18n.ERROR_INTERNAL, err)
 	}
 
 	slog.Debug("got query result", slog.String("query", query), slog.Any("result", refs))
@@ -232,13 +271,18 @@ func (l *KnowledgeLogic) GetRelevanceKnowledges(spaceID, userID, query string, r
 	var (
 		knowledgeIDs []string
 	)
-	for i, v := range refs {
-		if i > 0 && v.Cos > 0.5 && v.OriginalLength > 200 {
-			// TODO：more and more verify best ratio
-			continue
-		}
+	if reranked, ok := l.rerankRefs(spaceID, maskedQuery, detector, refs, retrieval.MinScore); ok {
+		result.Refs = reranked
+	} else {
+		for i, v := range refs {
+			// 命中全文检索的分块即使语义距离较远也保留
+			if i > 0 && !v.KeywordMatched && v.Cos > retrieval.MaxDistance && v.OriginalLength > 200 {
+				// TODO：more and more verify best ratio
+				continue
+			}
 
-		result.Refs = append(result.Refs, v)
+			result.Refs = append(result.Refs, v)
+		}
 	}
 
 	result.Refs = lo.UniqBy(result.Refs, func(item types.QueryResult) string {
@@ -248,31 +292,55 @@ func (l *KnowledgeLogic) GetRelevanceKnowledges(spaceID, userID, query string, r
 	for _, v := range result.Refs {
 		knowledgeIDs = append(knowledgeIDs, v.KnowledgeID)
 	}
+	// 只保留相关性最高的 MaxDocs 个知识放入提示词
+	knowledgeIDs = lo.Slice(lo.Uniq(knowledgeIDs), 0, int(retrieval.MaxDocs))
 
 	knowledges, err := l.core.Store().KnowledgeStore().ListKnowledges(l.ctx, types.GetKnowledgeOptions{
 		IDs:      knowledgeIDs,
 		SpaceID:  spaceID,
 		UserID:   userID,
 		Resource: resource,
-	}, 1, 20)
+	}, 1, retrieval.MaxDocs)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, errors.New("KnowledgeLogic.Query.KnowledgeStore.ListKnowledge", i18n.ERROR_INTERNAL, err)
 	}
 	if len(knowledges) == 0 {
 		// return nil, errors.New("KnowledgeLogic.Query.KnowledgeStore.ListKnowledge.nil", i18n.ERROR_LOGIC_VECTOR_DB_NOT_MATCHED_CONTENT_DB, nil)
 	}
+	sortKnowledgesByRefs(knowledges, result.Refs)
+	offsets := l.transcriptOffsets(spaceID, result.Refs, knowledges)
 
 	slog.Debug("match knowledges", slog.String("query", query), slog.Any("resource", resource), slog.Int("knowledge_length", len(knowledges)))
 
 	for _, v := range knowledges {
-		sw := mark.NewSensitiveWork()
+		sw := mark.NewSensitiveWork().WithDetector(detector)
 		result.Docs = append(result.Docs, &types.PassageInfo{
 			ID:       v.ID,
 			Content:  sw.Do(v.Content),
 			DateTime: v.MaybeDate,
+			Offsets:  offsets[v.ID],
 			SW:       sw,
 		})
 	}
+
+	linked, linkedFrom, err := l.linkedKnowledges(types.GetKnowledgeOptions{
+		SpaceID:  spaceID,
+		UserID:   userID,
+		Resource: resource,
+	}, knowledges, retrieval.LinkedDocs)
+	if err != nil {
+		return nil, errors.Trace("KnowledgeLogic.GetRelevanceKnowledges", err)
+	}
+	for _, v := range linked {
+		sw := mark.NewSensitiveWork().WithDetector(detector)
+		result.Docs = append(result.Docs, &types.PassageInfo{
+			ID:         v.ID,
+			Content:    sw.Do(v.Content),
+			DateTime:   v.MaybeDate,
+			LinkedFrom: linkedFrom[v.ID],
+			SW:         sw,
+		})
+	}
 	return &result, nil
 }
 
@@ -281,21 +349,49 @@ type KnowledgeQueryResult struct {
 	Message string              `json:"message"`
 }
 
-func (l *KnowledgeLogic) Query(spaceID string, resource *types.ResourceQuery, query string) (*KnowledgeQueryResult, error) {
-	vector, err := l.core.Srv().AI().EmbeddingForQuery(l.ctx, []string{query})
+func (l *KnowledgeLogic) Query(spaceID string, resource *types.ResourceQuery, filter *types.KnowledgeFilter, query string) (*KnowledgeQueryResult, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, errors.New("KnowledgeLogic.Query.filter.Validate", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest)
+	}
+
+	detector, err := process.PrivacyDetector(l.ctx, l.core, spaceID)
+	if err != nil {
+		return nil, errors.New("KnowledgeLogic.Query.process.PrivacyDetector", i18n.ERROR_INTERNAL, err)
+	}
+	// 问题中的敏感信息同样需要替换，向量化与生成回答时都只使用替换后的问题
+	querySW := mark.NewSensitiveWork().WithDetector(detector)
+	maskedQuery := querySW.Do(query)
+
+	vector, err := l.core.Srv().AI().EmbeddingForQuery(l.ctx, []string{maskedQuery})
 	if err != nil || len(vector) == 0 {
 		return nil, errors.New("KnowledgeLogic.Query.AI.EmbeddingForQuery", i18n.ERROR_INTERNAL, err)
 	}
 
 	user := l.GetUserInfo()
 
-	refs, err := l.core.Store().VectorStore().Query(l.ctx, types.GetVectorsOptions{
-		SpaceID:  spaceID,
-		UserID:   user.User,
-		Resource: resource,
-	}, pgvector.NewVector(vector[0]), 20)
+	scoped, err := l.scopedResource(spaceID, resource)
 	if err != nil {
-		return nil, errors.New("KnowledgeLogic.Query.VectorStore.Query", i18n.ERROR_INTERNAL, err)
+		return nil, errors.New("KnowledgeLogic.Query.scopedResource", i18n.ERROR_INTERNAL, err)
+	}
+
+	retrieval, err := l.retrievalOptions(spaceID, scoped, types.RetrievalOptions{
+		TopK:        20,
+		MaxDistance: 0.5,
+		MaxDocs:     20,
+	})
+	if err != nil {
+		return nil, errors.New("KnowledgeLogic.Query.retrievalOptions", i18n.ERROR_INTERNAL, err)
+	}
+
+	refs, err := l.searchVectors(types.GetVectorsOptions{
+		SpaceID:          spaceID,
+		UserID:           user.User,
+		Resource:         resource,
+		Filter:           filter,
+		ExcludeExpiredAt: time.Now().Unix(),
+	}, retrieval, query, pgvector.NewVector(vector[0]))
+	if err != nil {
+		return nil, errors.New("KnowledgeLogic.Query.searchVectors", i18n.ERROR_INTERNAL, err)
 	}
 
 	slog.Debug("got query result", slog.String("query", query), slog.Any("result", refs))
@@ -312,28 +408,38 @@ func (l *KnowledgeLogic) Query(spaceID string, resource *types.ResourceQuery, qu
 		knowledgeIDs []string
 		hasMatched   bool
 	)
-	for _, v := range refs {
-		if !hasMatched && v.Cos < 0.5 {
-			hasMatched = true
-		}
-		if hasMatched && v.Cos >= 0.5 {
-			continue
+	if reranked, ok := l.rerankRefs(spaceID, maskedQuery, detector, refs, retrieval.MinScore); ok {
+		result.Refs = reranked
+	} else {
+		for _, v := range refs {
+			if !hasMatched && (v.Cos < retrieval.MaxDistance || v.KeywordMatched) {
+				hasMatched = true
+			}
+			if hasMatched && v.Cos >= retrieval.MaxDistance && !v.KeywordMatched {
+				continue
+			}
+			result.Refs = append(result.Refs, v)
 		}
+	}
+	for _, v := range result.Refs {
 		knowledgeIDs = append(knowledgeIDs, v.KnowledgeID)
-		result.Refs = append(result.Refs, v)
 	}
+	// 只保留相关性最高的 MaxDocs 个知识放入提示词
+	knowledgeIDs = lo.Slice(lo.Uniq(knowledgeIDs), 0, int(retrieval.MaxDocs))
 
 	knowledges, err := l.core.Store().KnowledgeStore().ListKnowledges(l.ctx, types.GetKnowledgeOptions{
 		IDs:     knowledgeIDs,
 		SpaceID: spaceID,
 		UserID:  user.User,
-	}, 1, 20)
+	}, 1, retrieval.MaxDocs)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, errors.New("KnowledgeLogic.Query.KnowledgeStore.ListKnowledge", i18n.ERROR_INTERNAL, err)
 	}
 	if len(knowledges) == 0 {
 		// return nil, errors.New("KnowledgeLogic.Query.KnowledgeStore.ListKnowledge.nil", i18n.ERROR_LOGIC_VECTOR_DB_NOT_MATCHED_CONTENT_DB, nil)
 	}
+	sortKnowledgesByRefs(knowledges, result.Refs)
+	offsets := l.transcriptOffsets(spaceID, result.Refs, knowledges)
 
 	slog.Debug("match knowledges", slog.String("query", query), slog.Any("resource", resource), slog.Int("knowledge_length", len(knowledges)))
 
@@ -341,23 +447,45 @@ func (l *KnowledgeLogic) Query(spaceID string, resource *types.ResourceQuery, qu
 		docs []*types.PassageInfo
 	)
 	for _, v := range knowledges {
-		sw := mark.NewSensitiveWork()
+		sw := mark.NewSensitiveWork().WithDetector(detector)
 		docs = append(docs, &types.PassageInfo{
 			ID:       v.ID,
 			Content:  sw.Do(v.Content),
 			DateTime: v.MaybeDate,
+			Offsets:  offsets[v.ID],
 			SW:       sw,
 		})
 	}
 
+	linked, linkedFrom, err := l.linkedKnowledges(types.GetKnowledgeOptions{
+		SpaceID: spaceID,
+		UserID:  user.User,
+	}, knowledges, retrieval.LinkedDocs)
+	if err != nil {
+		return nil, errors.Trace("KnowledgeLogic.Query", err)
+	}
+	for _, v := range linked {
+		sw := mark.NewSensitiveWork().WithDetector(detector)
+		docs = append(docs, &types.PassageInfo{
+			ID:         v.ID,
+			Content:    sw.Do(v.Content),
+			DateTime:   v.MaybeDate,
+			LinkedFrom: linkedFrom[v.ID],
+			SW:         sw,
+		})
+	}
+
 	message := &types.MessageContext{
 		Role:    types.USER_ROLE_USER,
-		Content: query,
+		Content: maskedQuery,
 	}
 
 	// TODO: gen query opts from user setting
 	queryOptions := l.core.Srv().AI().NewQuery(l.ctx, []*types.MessageContext{message})
-	if resource != nil && len(resource.Include) == 1 {
+	if scoped != nil && scoped.Prompt != "" {
+		// 资源设置了自定义提示词时优先使用
+		queryOptions.WithPrompt(ai.BuildRAGPrompt(scoped.Prompt, ai.NewDocs(docs), l.core.Srv().AI()))
+	} else if resource != nil && len(resource.Include) == 1 {
 		// match user resource setting
 		for _, apply := range userSetting[resource.Include[0]] {
 			apply(queryOptions)
@@ -374,6 +502,7 @@ func (l *KnowledgeLogic) Query(spaceID string, resource *types.ResourceQuery, qu
 	for _, v := range docs {
 		result.Message = v.SW.Undo(result.Message)
 	}
+	result.Message = querySW.Undo(result.Message)
 
 	return &result, nil
 }
@@ -396,27 +525,312 @@ func (l *KnowledgeLogic) insertContent(isSync bool, spaceID, resource string, ki
 		CreatedAt: time.Now().Unix(),
 		UpdatedAt: time.Now().Unix(),
 	}
-	err := l.core.Store().KnowledgeStore().Create(l.ctx, knowledge)
+
+	if kind == types.KNOWLEDGE_KIND_URL {
+		if err := l.fillKnowledgeFromURL(&knowledge); err != nil {
+			return "", errors.Trace("KnowledgeLogic.InsertContent", err)
+		}
+	}
+
+	return l.saveKnowledge(isSync, knowledge, nil)
+}
+
+// saveKnowledge 写入知识并触发summary/embedding流程，blob 不为空时作为知识的原始文件一同保存
+func (l *KnowledgeLogic) saveKnowledge(isSync bool, knowledge types.Knowledge, blob []byte) (string, error) {
+	exist, err := l.checkDuplicate(&knowledge)
 	if err != nil {
-		return "", errors.New("KnowledgeLogic.InsertContent.Store.KnowledgeStore.Create", i18n.ERROR_INTERNAL, err)
+		return "", errors.Trace("KnowledgeLogic.InsertContent", err)
 	}
 
+	err = l.core.Store().Transaction(l.ctx, func(ctx context.Context) error {
+		return l.createKnowledge(ctx, knowledge, blob, exist)
+	})
+	if err != nil {
+		return "", err
+	}
+	process.Wakeup()
+
+	// 任务已随知识一同写入，异步写入时由处理队列在后台完成
 	if isSync {
-		if err = l.processKnowledgeAsync(knowledge); err != nil {
-			return knowledgeID, errors.Trace("KnowledgeLogic.InsertContent", err)
+		if err = waitKnowledge(l.ctx, l.core, knowledge); err != nil {
+			return knowledge.ID, errors.Trace("KnowledgeLogic.InsertContent", err)
 		}
-	} else {
-		go safe.Run(func() {
-			if err = l.processKnowledgeAsync(knowledge); err != nil {
-				slog.Error("Process knowledge async failed",
-					slog.String("space_id", knowledge.SpaceID),
-					slog.String("knowledge_id", knowledge.ID),
-					slog.Any("error", err))
+	}
+
+	return knowledge.ID, nil
+}
+
+// createKnowledge 写入知识、原始文件与处理任务，exist 不为空时将知识标记为与其重复，需要在事务中调用
+func (l *KnowledgeLogic) createKnowledge(ctx context.Context, knowledge types.Knowledge, blob []byte, exist *types.Knowledge) error {
+	if err := l.core.Store().KnowledgeStore().Create(ctx, knowledge); err != nil {
+		return errors.New("KnowledgeLogic.InsertContent.Store.KnowledgeStore.Create", i18n.ERROR_INTERNAL, err)
+	}
+	if blob != nil {
+		if err := l.core.Store().BlobStore().Put(ctx, knowledge.SpaceID, knowledge.Meta.Blob, blob); err != nil {
+			return errors.New("KnowledgeLogic.InsertContent.Store.BlobStore.Put", i18n.ERROR_INTERNAL, err)
+		}
+	}
+	if err := process.Enqueue(ctx, l.core, knowledge); err != nil {
+		return errors.New("KnowledgeLogic.InsertContent.process.Enqueue", i18n.ERROR_INTERNAL, err)
+	}
+	if exist == nil {
+		return nil
+	}
+	err := l.core.Store().KnowledgeDuplicateStore().BatchCreate(ctx, []types.KnowledgeDuplicate{{
+		SpaceID:     knowledge.SpaceID,
+		KnowledgeID: knowledge.ID,
+		DuplicateID: exist.ID,
+		Similarity:  1,
+		CreatedAt:   time.Now().Unix(),
+	}})
+	if err != nil {
+		return errors.New("KnowledgeLogic.InsertContent.Store.KnowledgeDuplicateStore.BatchCreate", i18n.ERROR_INTERNAL, err)
+	}
+	return nil
+}
+
+// checkDuplicate 补全内容哈希并查找空间中内容相同的知识，未配置为标记重复时存在相同内容返回错误
+func (l *KnowledgeLogic) checkDuplicate(knowledge *types.Knowledge) (*types.Knowledge, error) {
+	if knowledge.ContentHash == "" {
+		knowledge.ContentHash = utils.ContentHash(knowledge.Content)
+	}
+	exist, err := l.findKnowledgeByContentHash(knowledge.SpaceID, knowledge.ContentHash)
+	if err != nil {
+		return nil, errors.Trace("KnowledgeLogic.checkDuplicate", err)
+	}
+	if exist != nil && l.core.Cfg().Dedup.ExactMode() != core.DEDUP_EXACT_FLAG {
+		return nil, errors.New("KnowledgeLogic.InsertContent.Duplicate", i18n.ERROR_LOGIC_KNOWLEDGE_DUPLICATE, fmt.Errorf("same content as knowledge %s", exist.ID)).Code(http.StatusConflict)
+	}
+	return exist, nil
+}
+
+// findKnowledgeByContentHash 查找空间中内容相同的知识，不存在时返回nil
+func (l *KnowledgeLogic) findKnowledgeByContentHash(spaceID, hash string) (*types.Knowledge, error) {
+	list, err := l.core.Store().KnowledgeStore().ListKnowledges(l.ctx, types.GetKnowledgeOptions{
+		SpaceID:     spaceID,
+		ContentHash: hash,
+	}, 1, 1)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("KnowledgeLogic.findKnowledgeByContentHash.KnowledgeStore.ListKnowledges", i18n.ERROR_INTERNAL, err)
+	}
+	if len(list) == 0 {
+		return nil, nil
+	}
+	return list[0], nil
+}
+
+// KnowledgeFile 上传的单个文件
+type KnowledgeFile struct {
+	Filename string
+	MimeType string
+	Reader   io.Reader
+}
+
+type preparedKnowledge struct {
+	knowledge types.Knowledge
+	blob      []byte
+	// exist 内容相同的知识，重复内容配置为标记时不为空
+	exist *types.Knowledge
+}
+
+// InsertFiles 批量通过文件创建知识，每个文件对应一条知识，返回的ID与文件顺序一致
+// 先解析并校验全部文件，任意文件不支持、解析失败或内容重复时不保存任何文件
+// 全部文件在同一个事务中保存，同步写入时在提交后并发等待全部文件处理完成
+func (l *KnowledgeLogic) InsertFiles(isSync bool, spaceID, resource string, files []KnowledgeFile) ([]string, error) {
+	var (
+		prepared = make([]preparedKnowledge, 0, len(files))
+		seen     = make(map[string]int)
+		flag     = l.core.Cfg().Dedup.ExactMode() == core.DEDUP_EXACT_FLAG
+	)
+	for _, file := range files {
+		knowledge, blob, err := l.prepareFile(spaceID, resource, file.Filename, file.MimeType, file.Reader)
+		if err != nil {
+			return nil, errors.Trace("KnowledgeLogic.InsertFiles", err)
+		}
+		exist, err := l.checkDuplicate(&knowledge)
+		if err != nil {
+			return nil, errors.Trace("KnowledgeLogic.InsertFiles", err)
+		}
+		if i, ok := seen[knowledge.ContentHash]; ok {
+			if !flag {
+				return nil, errors.New("KnowledgeLogic.InsertFiles.Duplicate", i18n.ERROR_LOGIC_KNOWLEDGE_DUPLICATE, fmt.Errorf("%s has the same content as %s", file.Filename, files[i].Filename)).Code(http.StatusConflict)
 			}
-		})
+			if exist == nil {
+				exist = &prepared[i].knowledge
+			}
+		} else {
+			seen[knowledge.ContentHash] = len(prepared)
+		}
+		prepared = append(prepared, preparedKnowledge{knowledge: knowledge, blob: blob, exist: exist})
+	}
+
+	err := l.core.Store().Transaction(l.ctx, func(ctx context.Context) error {
+		for _, v := range prepared {
+			if err := l.createKnowledge(ctx, v.knowledge, v.blob, v.exist); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Trace("KnowledgeLogic.InsertFiles", err)
+	}
+	process.Wakeup()
+
+	ids := lo.Map(prepared, func(item preparedKnowledge, _ int) string {
+		return item.knowledge.ID
+	})
+	if !isSync {
+		return ids, nil
+	}
+
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(prepared))
+	)
+	for i, v := range prepared {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = waitKnowledge(l.ctx, l.core, v.knowledge)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return ids, errors.Trace("KnowledgeLogic.InsertFiles", err)
+		}
+	}
+	return ids, nil
+}
+
+// prepareFile 解析文件生成待保存的知识，图片与音视频返回需要保存的原始文件
+func (l *KnowledgeLogic) prepareFile(spaceID, resource, filename, mimeType string, file io.Reader) (types.Knowledge, []byte, error) {
+	if extract.IsImageType(mimeType) {
+		return l.prepareBlob(spaceID, resource, filename, mimeType, types.KNOWLEDGE_KIND_IMAGE, file)
+	}
+	if extract.IsMediaType(mimeType) {
+		return l.prepareBlob(spaceID, resource, filename, mimeType, types.KNOWLEDGE_KIND_VIDEO, file)
+	}
+	if !extract.IsSupportedFileType(mimeType) {
+		return types.Knowledge{}, nil, errors.New("KnowledgeLogic.prepareFile.IsSupportedFileType", i18n.ERROR_LOGIC_UNSUPPORTED_FILE_TYPE, fmt.Errorf("unsupported mime type %s of %s", mimeType, filename)).Code(http.StatusBadRequest)
+	}
+
+	article, err := extract.ParseFile(filename, mimeType, file)
+	if err != nil {
+		return types.Knowledge{}, nil, errors.New("KnowledgeLogic.prepareFile.ParseFile", i18n.ERROR_LOGIC_FILE_PARSE_FAILED, fmt.Errorf("failed to parse %s, %w", filename, err)).Code(http.StatusBadRequest)
+	}
+	if strings.TrimSpace(article.Content) == "" {
+		return types.Knowledge{}, nil, errors.New("KnowledgeLogic.prepareFile.ParseFile", i18n.ERROR_LOGIC_FILE_PARSE_FAILED, fmt.Errorf("empty content extracted from %s", filename)).Code(http.StatusBadRequest)
+	}
+
+	if resource == "" {
+		resource = types.DEFAULT_RESOURCE
+	}
+	return types.Knowledge{
+		ID:       utils.GenRandomID(),
+		SpaceID:  spaceID,
+		UserID:   l.GetUserInfo().User,
+		Resource: resource,
+		Title:    article.Title,
+		Content:  article.Content,
+		Kind:     types.KNOWLEDGE_KIND_TEXT,
+		Meta: types.KnowledgeMeta{
+			Filename: filename,
+			MimeType: mimeType,
+		},
+		// 标题取自文件本身，summary阶段无需再由AI生成标题
+		Summary:   "tags,content",
+		Stage:     types.KNOWLEDGE_STAGE_SUMMARIZE,
+		MaybeDate: time.Now().Local().Format("2006-01-02 15:04"),
+		CreatedAt: time.Now().Unix(),
+		UpdatedAt: time.Now().Unix(),
+	}, nil, nil
+}
+
+// prepareBlob 读取原始文件并生成图片或音视频知识，知识内容在summary阶段由视觉模型识别或转写生成
+func (l *KnowledgeLogic) prepareBlob(spaceID, resource, filename, mimeType string, kind types.KnowledgeKind, file io.Reader) (types.Knowledge, []byte, error) {
+	raw, err := extract.ReadFile(file, extract.MaxFileSize(mimeType))
+	if err != nil {
+		return types.Knowledge{}, nil, errors.New("KnowledgeLogic.prepareBlob.ReadFile", i18n.ERROR_LOGIC_FILE_PARSE_FAILED, fmt.Errorf("failed to read %s, %w", filename, err)).Code(http.StatusBadRequest)
+	}
+	if len(raw) == 0 {
+		return types.Knowledge{}, nil, errors.New("KnowledgeLogic.prepareBlob.ReadFile", i18n.ERROR_LOGIC_FILE_PARSE_FAILED, fmt.Errorf("empty file %s", filename)).Code(http.StatusBadRequest)
+	}
+
+	if resource == "" {
+		resource = types.DEFAULT_RESOURCE
+	}
+	knowledgeID := utils.GenRandomID()
+	return types.Knowledge{
+		ID:       knowledgeID,
+		SpaceID:  spaceID,
+		UserID:   l.GetUserInfo().User,
+		Resource: resource,
+		Title:    strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)),
+		Kind:     kind,
+		// 内容尚未识别，使用文件本身计算内容hash用于去重
+		ContentHash: utils.ContentHash(string(raw)),
+		Meta: types.KnowledgeMeta{
+			Filename: filename,
+			MimeType: mimeType,
+			Blob:     knowledgeID,
+		},
+		Stage:     types.KNOWLEDGE_STAGE_SUMMARIZE,
+		MaybeDate: time.Now().Local().Format("2006-01-02 15:04"),
+		CreatedAt: time.Now().Unix(),
+		UpdatedAt: time.Now().Unix(),
+	}, raw, nil
+}
+
+// GetBlob 获取知识的原始文件及其类型，如图片知识的原图与音视频文件
+func (l *KnowledgeLogic) GetBlob(spaceID, id string) ([]byte, string, error) {
+	knowledge, err := l.GetKnowledge(spaceID, id)
+	if err != nil {
+		return nil, "", errors.Trace("KnowledgeLogic.GetBlob", err)
+	}
+	if knowledge.Meta.Blob == "" {
+		return nil, "", errors.New("KnowledgeLogic.GetBlob", i18n.ERROR_NOTFOUND, nil).Code(http.StatusNotFound)
 	}
 
-	return knowledgeID, nil
+	data, err := l.core.Store().BlobStore().Get(l.ctx, spaceID, knowledge.Meta.Blob)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", errors.New("KnowledgeLogic.GetBlob.BlobStore.Get", i18n.ERROR_NOTFOUND, nil).Code(http.StatusNotFound)
+		}
+		return nil, "", errors.New("KnowledgeLogic.GetBlob.BlobStore.Get", i18n.ERROR_INTERNAL, err)
+	}
+	return data, knowledge.Meta.MimeType, nil
+}
+
+const URL_FETCH_TIMEOUT = time.Second * 15
+
+// fillKnowledgeFromURL 抓取url对应的网页，以提取出的正文作为知识内容
+func (l *KnowledgeLogic) fillKnowledgeFromURL(knowledge *types.Knowledge) error {
+	source := strings.TrimSpace(knowledge.Content)
+	if !extract.IsURL(source) {
+		return errors.New("KnowledgeLogic.fillKnowledgeFromURL.IsURL", i18n.ERROR_INVALIDARGUMENT, nil).Code(http.StatusBadRequest)
+	}
+
+	ctx, cancel := context.WithTimeout(l.ctx, URL_FETCH_TIMEOUT)
+	defer cancel()
+	article, err := extract.FetchURL(ctx, nil, source)
+	if err != nil {
+		return errors.New("KnowledgeLogic.fillKnowledgeFromURL.FetchURL", i18n.ERROR_LOGIC_URL_FETCH_FAILED, err).Code(http.StatusBadRequest)
+	}
+
+	if strings.TrimSpace(article.Content) == "" {
+		return errors.New("KnowledgeLogic.fillKnowledgeFromURL.FetchURL", i18n.ERROR_LOGIC_URL_FETCH_FAILED, fmt.Errorf("empty content extracted from %s", source)).Code(http.StatusBadRequest)
+	}
+
+	knowledge.Content = article.Content
+	knowledge.Meta.Source = article.Source
+	if article.Title != "" {
+		knowledge.Title = article.Title
+		// 已从网页中获取到标题，summary阶段无需再由AI生成标题
+		knowledge.Summary = "tags,content"
+	}
+	return nil
 }
 
 const (
@@ -483,43 +897,23 @@ func (l *KnowledgeLogic) InsertContent(spaceID, resource string, kind types.Know
 	// return knowledgeID, err
 }
 
-func (l *KnowledgeLogic) processKnowledgeAsync(knowledge types.Knowledge) error {
-	ctx, cancel := context.WithTimeout(l.ctx, time.Minute*2)
-	defer cancel()
-	respChan := process.NewSummaryRequest(knowledge)
-	if respChan == nil {
-		return errors.New("KnowledgeLogic.processKnowledgeAsync.NewSummaryRequest", i18n.ERROR_INTERNAL, fmt.Errorf("unexpected, process wrong"))
-	}
-	select {
-	case <-ctx.Done():
-		return errors.New("KnowledgeLogic.processKnowledgeAsync.Summary.ctx", i18n.ERROR_INTERNAL, ctx.Err())
-	case req := <-respChan:
-		if req.Err != nil {
-			return errors.New("KnowledgeLogic.processKnowledgeAsync.Summary.Result", i18n.ERROR_INTERNAL, req.Err)
-		}
-	}
-
-	{
-		knowledge, err := l.core.Store().KnowledgeStore().GetKnowledge(ctx, knowledge.SpaceID, knowledge.ID)
-		if err != nil {
-			return errors.New("KnowledgeLogic.processKnowledgeAsync.KnowledgeStore.GetKnowledge", i18n.ERROR_INTERNAL, err)
-		}
+// PROCESS_WAIT_TIMEOUT 同步写入时等待知识处理完成的最长时间，包含失败后的重试
+const PROCESS_WAIT_TIMEOUT = time.Minute * 4
 
-		ctx, cancel := context.WithTimeout(l.ctx, time.Minute*2)
-		defer cancel()
-		respChan := process.NewEmbeddingRequest(*knowledge)
-		if respChan == nil {
-			return errors.New("KnowledgeLogic.processKnowledgeAsync.NewEmbeddingRequest", i18n.ERROR_INTERNAL, fmt.Errorf("unexpected, process wrong"))
-		}
-		select {
-		case <-ctx.Done():
-			return errors.New("KnowledgeLogic.processKnowledgeAsync.Embedding.ctx", i18n.ERROR_INTERNAL, ctx.Err())
-		case req := <-respChan:
-			if req.Err != nil {
-				return errors.New("KnowledgeLogic.processKnowledgeAsync.Embedding.Result", i18n.ERROR_INTERNAL, req.Err)
-			}
-		}
+// processKnowledge 为已写入的知识创建处理任务，并等待其完成 summary 与 embedding 阶段
+func processKnowledge(parent context.Context, core *core.Core, knowledge types.Knowledge) error {
+	if err := process.Enqueue(parent, core, knowledge); err != nil {
+		return errors.New("KnowledgeLogic.processKnowledge.process.Enqueue", i18n.ERROR_INTERNAL, err)
 	}
+	return waitKnowledge(parent, core, knowledge)
+}
 
+// waitKnowledge 等待已入队的知识处理完成
+func waitKnowledge(parent context.Context, core *core.Core, knowledge types.Knowledge) error {
+	ctx, cancel := context.WithTimeout(parent, PROCESS_WAIT_TIMEOUT)
+	defer cancel()
+	if err := process.Wait(ctx, core, knowledge.SpaceID, knowledge.ID); err != nil {
+		return errors.New("KnowledgeLogic.processKnowledge.process.Wait", i18n.ERROR_INTERNAL, err)
+	}
 	return nil
 }
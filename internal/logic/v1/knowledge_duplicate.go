@@ -0,0 +1,113 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/samber/lo"
+
+	"github.com/starbx/brew-api/internal/core/srv"
+	"github.com/starbx/brew-api/pkg/errors"
+	"github.com/starbx/brew-api/pkg/i18n"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+// ListDuplicates 分页获取空间中疑似重复的知识，相似度高的排在最前
+func (l *KnowledgeLogic) ListDuplicates(spaceID string, page, pageSize uint64) ([]types.KnowledgeDuplicateDetail, int64, error) {
+	list, err := l.core.Store().KnowledgeDuplicateStore().List(l.ctx, spaceID, page, pageSize)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, 0, errors.New("KnowledgeLogic.ListDuplicates.KnowledgeDuplicateStore.List", i18n.ERROR_INTERNAL, err)
+	}
+
+	total, err := l.core.Store().KnowledgeDuplicateStore().Total(l.ctx, spaceID)
+	if err != nil {
+		return nil, 0, errors.New("KnowledgeLogic.ListDuplicates.KnowledgeDuplicateStore.Total", i18n.ERROR_INTERNAL, err)
+	}
+
+	if len(list) == 0 {
+		return nil, total, nil
+	}
+
+	var ids []string
+	for _, v := range list {
+		ids = append(ids, v.KnowledgeID, v.DuplicateID)
+	}
+
+	knowledges, err := l.core.Store().KnowledgeStore().ListLiteKnowledges(l.ctx, types.GetKnowledgeOptions{
+		SpaceID: spaceID,
+		IDs:     lo.Uniq(ids),
+	}, 0, 0)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, 0, errors.New("KnowledgeLogic.ListDuplicates.KnowledgeStore.ListLiteKnowledges", i18n.ERROR_INTERNAL, err)
+	}
+
+	knowledgeMap := lo.SliceToMap(knowledges, func(item *types.KnowledgeLite) (string, *types.KnowledgeLite) {
+		return item.ID, item
+	})
+
+	result := make([]types.KnowledgeDuplicateDetail, 0, len(list))
+	for _, v := range list {
+		knowledge, ok := knowledgeMap[v.KnowledgeID]
+		if !ok {
+			continue
+		}
+		duplicate, ok := knowledgeMap[v.DuplicateID]
+		if !ok {
+			continue
+		}
+		result = append(result, types.KnowledgeDuplicateDetail{
+			Knowledge:  knowledge,
+			Duplicate:  duplicate,
+			Similarity: v.Similarity,
+		})
+	}
+	return result, total, nil
+}
+
+// MergeKnowledges 合并重复的知识，保留 keepID 对应的知识并合并所有标签，其余知识将被删除
+func (l *KnowledgeLogic) MergeKnowledges(spaceID, keepID string, ids []string) error {
+	ids = lo.Uniq(lo.Without(ids, keepID, ""))
+	if len(ids) == 0 {
+		return errors.New("KnowledgeLogic.MergeKnowledges.ids", i18n.ERROR_INVALIDARGUMENT, nil).Code(http.StatusBadRequest)
+	}
+
+	user := l.GetUserInfo()
+	for _, id := range append([]string{keepID}, ids...) {
+		if err := l.core.Srv().RBAC().Check(user, l.lazyRolerFromKnowledgeID(spaceID, id), srv.PermissionEdit); err != nil {
+			return errors.Trace("KnowledgeLogic.MergeKnowledges", err)
+		}
+	}
+
+	keep, err := l.GetKnowledge(spaceID, keepID)
+	if err != nil {
+		return errors.Trace("KnowledgeLogic.MergeKnowledges.keep", err)
+	}
+
+	tags := keep.Tags
+	for _, id := range ids {
+		knowledge, err := l.GetKnowledge(spaceID, id)
+		if err != nil {
+			return errors.Trace("KnowledgeLogic.MergeKnowledges.duplicate", err)
+		}
+		tags = lo.Union(tags, knowledge.Tags)
+	}
+
+	return l.core.Store().Transaction(l.ctx, func(ctx context.Context) error {
+		if len(tags) != len(keep.Tags) {
+			err := l.core.Store().KnowledgeStore().Update(ctx, spaceID, keepID, types.UpdateKnowledgeArgs{
+				Tags: tags,
+			})
+			if err != nil {
+				return errors.New("KnowledgeLogic.MergeKnowledges.KnowledgeStore.Update", i18n.ERROR_INTERNAL, err)
+			}
+		}
+
+		for _, id := range ids {
+			if err := l.deleteKnowledge(ctx, spaceID, id); err != nil {
+				return errors.Trace("KnowledgeLogic.MergeKnowledges", err)
+			}
+		}
+		return nil
+	})
+}
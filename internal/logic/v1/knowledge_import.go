@@ -0,0 +1,318 @@
+package v1
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/starbx/brew-api/internal/core"
+	"github.com/starbx/brew-api/pkg/errors"
+	"github.com/starbx/brew-api/pkg/i18n"
+	"github.com/starbx/brew-api/pkg/safe"
+	"github.com/starbx/brew-api/pkg/types"
+	"github.com/starbx/brew-api/pkg/utils"
+)
+
+const (
+	IMPORT_BATCH_SIZE     = 100
+	IMPORT_CONCURRENCY    = 10
+	IMPORT_MAX_LINE_SIZE  = 10 << 20
+	IMPORT_MAX_ERRORS     = 100
+	IMPORT_PUBLISH_PERIOD = time.Second
+	importMaybeDateFormat = "2006-01-02 15:04"
+)
+
+var importDateFormats = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	importMaybeDateFormat,
+	"2006-01-02",
+}
+
+// ImportKnowledges 从 NDJSON 流中批量导入知识，每行一条记录
+// 知识写入后立即返回，summary 与 embedding 在后台进行，进度通过 /knowledge/list/{spaceid} 推送
+func (l *KnowledgeLogic) ImportKnowledges(spaceID string, r io.Reader) (*types.KnowledgeImportResult, error) {
+	result := &types.KnowledgeImportResult{
+		ImportID: utils.GenRandomID(),
+	}
+	tracker := newImportTracker(l.core, spaceID, result.ImportID)
+
+	var (
+		batch      []types.Knowledge
+		duplicates []types.KnowledgeDuplicate
+		line       int
+		// 本次导入中已出现过的内容，hash -> knowledge id
+		seen      = make(map[string]string)
+		flagExact = l.core.Cfg().Dedup.ExactMode() == core.DEDUP_EXACT_FLAG
+	)
+	addError := func(line int, err error) {
+		result.Failed++
+		if len(result.Errors) < IMPORT_MAX_ERRORS {
+			result.Errors = append(result.Errors, types.KnowledgeImportError{
+				Line:  line,
+				Error: err.Error(),
+			})
+		}
+	}
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := l.core.Store().Transaction(l.ctx, func(ctx context.Context) error {
+			if err := l.core.Store().KnowledgeStore().BatchCreate(ctx, batch); err != nil {
+				return errors.New("KnowledgeLogic.ImportKnowledges.KnowledgeStore.BatchCreate", i18n.ERROR_INTERNAL, err)
+			}
+			if err := l.core.Store().KnowledgeDuplicateStore().BatchCreate(ctx, duplicates); err != nil {
+				return errors.New("KnowledgeLogic.ImportKnowledges.KnowledgeDuplicateStore.BatchCreate", i18n.ERROR_INTERNAL, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		result.Accepted += len(batch)
+		tracker.Add(batch)
+		batch, duplicates = nil, nil
+		return nil
+	}
+	defer func() {
+		tracker.Close(result.Failed)
+	}()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), IMPORT_MAX_LINE_SIZE)
+	for scanner.Scan() {
+		line++
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" {
+			continue
+		}
+
+		knowledge, err := l.newImportKnowledge(spaceID, raw)
+		if err != nil {
+			addError(line, err)
+			continue
+		}
+
+		duplicateID, ok := seen[knowledge.ContentHash]
+		if !ok {
+			exist, err := l.findKnowledgeByContentHash(spaceID, knowledge.ContentHash)
+			if err != nil {
+				return result, errors.Trace("KnowledgeLogic.ImportKnowledges", err)
+			}
+			if exist != nil {
+				duplicateID = exist.ID
+			}
+		}
+		if duplicateID != "" {
+			if !flagExact {
+				addError(line, fmt.Errorf("same content as knowledge %s", duplicateID))
+				continue
+			}
+			duplicates = append(duplicates, types.KnowledgeDuplicate{
+				SpaceID:     spaceID,
+				KnowledgeID: knowledge.ID,
+				DuplicateID: duplicateID,
+				Similarity:  1,
+				CreatedAt:   time.Now().Unix(),
+			})
+		} else {
+			seen[knowledge.ContentHash] = knowledge.ID
+		}
+
+		batch = append(batch, knowledge)
+		if len(batch) >= IMPORT_BATCH_SIZE {
+			if err = flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		addError(line+1, err)
+	}
+
+	if err := flush(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func (l *KnowledgeLogic) newImportKnowledge(spaceID, raw string) (types.Knowledge, error) {
+	var record types.KnowledgeImportRecord
+	if err := json.Unmarshal([]byte(raw), &record); err != nil {
+		return types.Knowledge{}, fmt.Errorf("invalid json, %w", err)
+	}
+
+	record.Content = strings.TrimSpace(record.Content)
+	if record.Content == "" {
+		return types.Knowledge{}, fmt.Errorf("content is required")
+	}
+
+	kind := types.KNOWLEDGE_KIND_TEXT
+	if record.Kind != "" {
+		kind = types.KindNewFromString(record.Kind)
+	}
+	switch kind {
+	case types.KNOWLEDGE_KIND_UNKNOWN:
+		return types.Knowledge{}, fmt.Errorf("unknown kind %s", record.Kind)
+	case types.KNOWLEDGE_KIND_URL:
+		return types.Knowledge{}, fmt.Errorf("kind url is not supported in bulk import")
+	}
+
+	if record.Resource == "" {
+		record.Resource = types.DEFAULT_RESOURCE
+	}
+
+	maybeDate := time.Now().Local().Format(importMaybeDateFormat)
+	if record.Date != "" {
+		date, err := parseImportDate(record.Date)
+		if err != nil {
+			return types.Knowledge{}, err
+		}
+		maybeDate = date.Local().Format(importMaybeDateFormat)
+	}
+
+	// 已经提供的标题与标签无需再由AI生成
+	var summary string
+	if record.Title != "" || len(record.Tags) > 0 {
+		fields := []string{"content"}
+		if record.Title == "" {
+			fields = append(fields, "title")
+		}
+		if len(record.Tags) == 0 {
+			fields = append(fields, "tags")
+		}
+		summary = strings.Join(fields, ",")
+	}
+
+	return types.Knowledge{
+		ID:          utils.GenRandomID(),
+		SpaceID:     spaceID,
+		UserID:      l.GetUserInfo().User,
+		Resource:    record.Resource,
+		Kind:        kind,
+		Title:       record.Title,
+		Tags:        record.Tags,
+		Content:     record.Content,
+		ContentHash: utils.ContentHash(record.Content),
+		Summary:     summary,
+		Stage:       types.KNOWLEDGE_STAGE_SUMMARIZE,
+		MaybeDate:   maybeDate,
+		CreatedAt:   time.Now().Unix(),
+		UpdatedAt:   time.Now().Unix(),
+	}, nil
+}
+
+func parseImportDate(s string) (time.Time, error) {
+	for _, layout := range importDateFormats {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported date format %s", s)
+}
+
+// importTracker 在后台驱动导入的知识完成处理流程，并节流推送导入进度
+type importTracker struct {
+	core    *core.Core
+	spaceID string
+	sem     chan struct{}
+	wg      sync.WaitGroup
+
+	mu          sync.Mutex
+	progress    types.KnowledgeImportProgress
+	lastPublish time.Time
+}
+
+func newImportTracker(core *core.Core, spaceID, importID string) *importTracker {
+	return &importTracker{
+		core:    core,
+		spaceID: spaceID,
+		sem:     make(chan struct{}, IMPORT_CONCURRENCY),
+		progress: types.KnowledgeImportProgress{
+			ImportID: importID,
+		},
+	}
+}
+
+// Add 将已写入的知识交给后台处理，不会阻塞调用方
+func (t *importTracker) Add(list []types.Knowledge) {
+	t.mu.Lock()
+	t.progress.Accepted += len(list)
+	t.mu.Unlock()
+	t.publish(false)
+
+	t.wg.Add(len(list))
+	go safe.Run(func() {
+		for _, v := range list {
+			t.sem <- struct{}{}
+			go safe.Run(func() {
+				defer func() {
+					<-t.sem
+					t.wg.Done()
+				}()
+				t.process(v)
+			})
+		}
+	})
+}
+
+func (t *importTracker) process(knowledge types.Knowledge) {
+	err := processKnowledge(context.Background(), t.core, knowledge)
+
+	t.mu.Lock()
+	if err != nil {
+		t.progress.Failed++
+	} else {
+		t.progress.Processed++
+	}
+	t.mu.Unlock()
+
+	if err != nil {
+		slog.Error("Failed to process imported knowledge",
+			slog.String("space_id", knowledge.SpaceID),
+			slog.String("knowledge_id", knowledge.ID),
+			slog.String("import_id", t.progress.ImportID),
+			slog.Any("error", err))
+	}
+	t.publish(false)
+}
+
+// Close 在所有记录都提交后调用，等待后台处理结束并推送最终进度
+func (t *importTracker) Close(failed int) {
+	t.mu.Lock()
+	t.progress.Failed += failed
+	t.mu.Unlock()
+
+	go safe.Run(func() {
+		t.wg.Wait()
+		t.mu.Lock()
+		t.progress.Finished = true
+		t.mu.Unlock()
+		t.publish(true)
+	})
+}
+
+func (t *importTracker) publish(force bool) {
+	t.mu.Lock()
+	if !force && time.Since(t.lastPublish) < IMPORT_PUBLISH_PERIOD {
+		t.mu.Unlock()
+		return
+	}
+	t.lastPublish = time.Now()
+	progress := t.progress
+	t.mu.Unlock()
+
+	if err := t.core.Srv().Tower().PublishKnowledgeImportProgress(t.spaceID, &progress); err != nil {
+		slog.Error("Failed to publish knowledge import progress",
+			slog.String("space_id", t.spaceID),
+			slog.String("import_id", progress.ImportID),
+			slog.String("error", err.Error()))
+	}
+}
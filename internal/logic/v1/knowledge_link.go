@@ -0,0 +1,209 @@
+package v1
+
+import (
+	"database/sql"
+	"sort"
+
+	"github.com/samber/lo"
+
+	"github.com/starbx/brew-api/pkg/errors"
+	"github.com/starbx/brew-api/pkg/i18n"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+// GetLinks 获取知识的链接、反向链接以及以该知识为中心、深度不超过 depth 的链接关系图
+func (l *KnowledgeLogic) GetLinks(spaceID, id string, depth int) (*types.KnowledgeLinks, error) {
+	if _, err := l.GetKnowledge(spaceID, id); err != nil {
+		return nil, errors.Trace("KnowledgeLogic.GetLinks", err)
+	}
+
+	if depth <= 0 {
+		depth = 1
+	} else if depth > types.MAX_LINK_GRAPH_DEPTH {
+		depth = types.MAX_LINK_GRAPH_DEPTH
+	}
+
+	outgoing, err := l.core.Store().KnowledgeLinkStore().ListOutgoing(l.ctx, spaceID, []string{id})
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("KnowledgeLogic.GetLinks.KnowledgeLinkStore.ListOutgoing", i18n.ERROR_INTERNAL, err)
+	}
+	incoming, err := l.core.Store().KnowledgeLinkStore().ListIncoming(l.ctx, spaceID, []string{id})
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("KnowledgeLogic.GetLinks.KnowledgeLinkStore.ListIncoming", i18n.ERROR_INTERNAL, err)
+	}
+
+	var (
+		targetIDs []string
+		sourceIDs []string
+		result    = &types.KnowledgeLinks{}
+	)
+	for _, v := range outgoing {
+		if v.TargetID == "" {
+			result.Unresolved = append(result.Unresolved, v.Title)
+			continue
+		}
+		targetIDs = append(targetIDs, v.TargetID)
+	}
+	for _, v := range incoming {
+		sourceIDs = append(sourceIDs, v.SourceID)
+	}
+	targetIDs, sourceIDs = lo.Uniq(targetIDs), lo.Uniq(sourceIDs)
+
+	graph, err := l.linkGraph(spaceID, id, depth)
+	if err != nil {
+		return nil, errors.Trace("KnowledgeLogic.GetLinks", err)
+	}
+
+	knowledges, err := l.core.Store().KnowledgeStore().ListLiteKnowledges(l.ctx, types.GetKnowledgeOptions{
+		SpaceID: spaceID,
+		IDs:     lo.Uniq(append(append(append([]string{}, targetIDs...), sourceIDs...), graph.nodes...)),
+	}, types.NO_PAGING, types.NO_PAGING)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("KnowledgeLogic.GetLinks.KnowledgeStore.ListLiteKnowledges", i18n.ERROR_INTERNAL, err)
+	}
+	knowledgeMap := lo.SliceToMap(knowledges, func(item *types.KnowledgeLite) (string, *types.KnowledgeLite) {
+		return item.ID, item
+	})
+
+	pick := func(ids []string) []*types.KnowledgeLite {
+		list := make([]*types.KnowledgeLite, 0, len(ids))
+		for _, v := range ids {
+			if item, ok := knowledgeMap[v]; ok {
+				list = append(list, item)
+			}
+		}
+		return list
+	}
+
+	result.Outgoing = pick(targetIDs)
+	result.Backlinks = pick(sourceIDs)
+	result.Graph.Nodes = pick(graph.nodes)
+	for _, v := range graph.edges {
+		if knowledgeMap[v.Source] != nil && knowledgeMap[v.Target] != nil {
+			result.Graph.Edges = append(result.Graph.Edges, v)
+		}
+	}
+	return result, nil
+}
+
+type linkGraph struct {
+	nodes []string
+	edges []types.KnowledgeGraphEdge
+}
+
+// linkGraph 从 id 出发沿链接与反向链接逐层展开，节点数量不超过 MAX_LINK_GRAPH_NODES
+func (l *KnowledgeLogic) linkGraph(spaceID, id string, depth int) (*linkGraph, error) {
+	var (
+		graph   = &linkGraph{nodes: []string{id}}
+		visited = map[string]bool{id: true}
+		edges   = make(map[types.KnowledgeGraphEdge]bool)
+		layer   = []string{id}
+	)
+
+	for i := 0; i < depth && len(layer) > 0; i++ {
+		outgoing, err := l.core.Store().KnowledgeLinkStore().ListOutgoing(l.ctx, spaceID, layer)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, errors.New("KnowledgeLogic.linkGraph.KnowledgeLinkStore.ListOutgoing", i18n.ERROR_INTERNAL, err)
+		}
+		incoming, err := l.core.Store().KnowledgeLinkStore().ListIncoming(l.ctx, spaceID, layer)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, errors.New("KnowledgeLogic.linkGraph.KnowledgeLinkStore.ListIncoming", i18n.ERROR_INTERNAL, err)
+		}
+
+		var next []string
+		for _, v := range append(outgoing, incoming...) {
+			if v.TargetID == "" {
+				continue
+			}
+			for _, node := range []string{v.SourceID, v.TargetID} {
+				if visited[node] || len(graph.nodes) >= types.MAX_LINK_GRAPH_NODES {
+					continue
+				}
+				visited[node] = true
+				graph.nodes = append(graph.nodes, node)
+				next = append(next, node)
+			}
+			edge := types.KnowledgeGraphEdge{Source: v.SourceID, Target: v.TargetID}
+			if visited[edge.Source] && visited[edge.Target] && !edges[edge] {
+				edges[edge] = true
+				graph.edges = append(graph.edges, edge)
+			}
+		}
+		layer = next
+	}
+	return graph, nil
+}
+
+// linkedKnowledges 获取与命中知识存在链接关系、但本身未被命中的知识，最多 limit 条，
+// 按命中知识的顺序依次取其链接与反向链接，返回关联知识以及各关联知识对应的命中知识ID
+func (l *KnowledgeLogic) linkedKnowledges(opts types.GetKnowledgeOptions, knowledges []*types.Knowledge, limit uint64) ([]*types.Knowledge, map[string]string, error) {
+	if limit == 0 || len(knowledges) == 0 {
+		return nil, nil, nil
+	}
+
+	var (
+		ids      []string
+		included = make(map[string]bool)
+		from     = make(map[string]string)
+	)
+	for _, v := range knowledges {
+		ids = append(ids, v.ID)
+		included[v.ID] = true
+	}
+
+	outgoing, err := l.core.Store().KnowledgeLinkStore().ListOutgoing(l.ctx, opts.SpaceID, ids)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, nil, errors.New("KnowledgeLogic.linkedKnowledges.KnowledgeLinkStore.ListOutgoing", i18n.ERROR_INTERNAL, err)
+	}
+	incoming, err := l.core.Store().KnowledgeLinkStore().ListIncoming(l.ctx, opts.SpaceID, ids)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, nil, errors.New("KnowledgeLogic.linkedKnowledges.KnowledgeLinkStore.ListIncoming", i18n.ERROR_INTERNAL, err)
+	}
+
+	neighbours := make(map[string][]string)
+	for _, v := range outgoing {
+		if v.TargetID != "" {
+			neighbours[v.SourceID] = append(neighbours[v.SourceID], v.TargetID)
+		}
+	}
+	for _, v := range incoming {
+		neighbours[v.TargetID] = append(neighbours[v.TargetID], v.SourceID)
+	}
+
+	var linkedIDs []string
+	for _, id := range ids {
+		for _, v := range neighbours[id] {
+			if included[v] {
+				continue
+			}
+			included[v] = true
+			from[v] = id
+			linkedIDs = append(linkedIDs, v)
+		}
+	}
+	if len(linkedIDs) == 0 {
+		return nil, nil, nil
+	}
+	linkedIDs = lo.Slice(linkedIDs, 0, types.MAX_LINK_GRAPH_NODES)
+
+	// 关联知识需要满足与检索相同的用户与资源范围
+	opts.IDs = linkedIDs
+	list, err := l.core.Store().KnowledgeStore().ListKnowledges(l.ctx, opts, types.NO_PAGING, types.NO_PAGING)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, nil, errors.New("KnowledgeLogic.linkedKnowledges.KnowledgeStore.ListKnowledges", i18n.ERROR_INTERNAL, err)
+	}
+
+	// 已归档的知识不再参与检索
+	list = lo.Filter(list, func(item *types.Knowledge, _ int) bool {
+		return item.Stage != types.KNOWLEDGE_STAGE_ARCHIVED
+	})
+
+	order := make(map[string]int, len(linkedIDs))
+	for i, v := range linkedIDs {
+		order[v] = i
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return order[list[i].ID] < order[list[j].ID]
+	})
+	return lo.Slice(list, 0, int(limit)), from, nil
+}
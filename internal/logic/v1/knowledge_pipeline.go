@@ -0,0 +1,149 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/samber/lo"
+
+	"github.com/starbx/brew-api/internal/logic/v1/process"
+	"github.com/starbx/brew-api/pkg/errors"
+	"github.com/starbx/brew-api/pkg/i18n"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+// ListProcessJobs 分页获取空间中处理中、失败或已取消的知识任务，包含最后一次失败的原因
+func (l *KnowledgeLogic) ListProcessJobs(opts types.GetKnowledgeJobOptions, page, pageSize uint64) ([]types.KnowledgeJobDetail, int64, error) {
+	list, err := l.core.Store().KnowledgeJobStore().List(l.ctx, opts, page, pageSize)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, 0, errors.New("KnowledgeLogic.ListProcessJobs.KnowledgeJobStore.List", i18n.ERROR_INTERNAL, err)
+	}
+
+	total, err := l.core.Store().KnowledgeJobStore().Total(l.ctx, opts)
+	if err != nil {
+		return nil, 0, errors.New("KnowledgeLogic.ListProcessJobs.KnowledgeJobStore.Total", i18n.ERROR_INTERNAL, err)
+	}
+
+	if len(list) == 0 {
+		return nil, total, nil
+	}
+
+	knowledges, err := l.core.Store().KnowledgeStore().ListLiteKnowledges(l.ctx, types.GetKnowledgeOptions{
+		SpaceID: opts.SpaceID,
+		IDs: lo.Map(list, func(item types.KnowledgeJob, _ int) string {
+			return item.KnowledgeID
+		}),
+	}, types.NO_PAGING, types.NO_PAGING)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, 0, errors.New("KnowledgeLogic.ListProcessJobs.KnowledgeStore.ListLiteKnowledges", i18n.ERROR_INTERNAL, err)
+	}
+
+	knowledgeMap := lo.SliceToMap(knowledges, func(item *types.KnowledgeLite) (string, *types.KnowledgeLite) {
+		return item.ID, item
+	})
+
+	result := make([]types.KnowledgeJobDetail, 0, len(list))
+	for _, v := range list {
+		knowledge, ok := knowledgeMap[v.KnowledgeID]
+		if !ok {
+			continue
+		}
+		result = append(result, types.KnowledgeJobDetail{
+			Knowledge: knowledge,
+			Job:       &v,
+		})
+	}
+	return result, total, nil
+}
+
+// getProcessingKnowledge 获取仍处于 summary 或 embedding 阶段的知识
+func (l *KnowledgeLogic) getProcessingKnowledge(spaceID, id string) (*types.Knowledge, error) {
+	knowledge, err := l.core.Store().KnowledgeStore().GetKnowledge(l.ctx, spaceID, id)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("KnowledgeLogic.getProcessingKnowledge.KnowledgeStore.GetKnowledge", i18n.ERROR_INTERNAL, err)
+	}
+	if knowledge == nil {
+		return nil, errors.New("KnowledgeLogic.getProcessingKnowledge.KnowledgeStore.GetKnowledge.nil", i18n.ERROR_NOTFOUND, nil).Code(http.StatusNotFound)
+	}
+	if knowledge.Stage != types.KNOWLEDGE_STAGE_SUMMARIZE && knowledge.Stage != types.KNOWLEDGE_STAGE_EMBEDDING {
+		return nil, errors.New("KnowledgeLogic.getProcessingKnowledge.Stage", i18n.ERROR_INVALIDARGUMENT, fmt.Errorf("knowledge is not processing, stage %s", knowledge.Stage)).Code(http.StatusBadRequest)
+	}
+	return knowledge, nil
+}
+
+// RetryProcess 清空重试次数并从知识当前所处的阶段重新处理
+func (l *KnowledgeLogic) RetryProcess(spaceID, id string) error {
+	knowledge, err := l.getProcessingKnowledge(spaceID, id)
+	if err != nil {
+		return errors.Trace("KnowledgeLogic.RetryProcess", err)
+	}
+
+	return l.core.Store().Transaction(l.ctx, func(ctx context.Context) error {
+		if err := l.core.Store().KnowledgeStore().SetRetryTimes(ctx, spaceID, id, 0); err != nil {
+			return errors.New("KnowledgeLogic.RetryProcess.KnowledgeStore.SetRetryTimes", i18n.ERROR_INTERNAL, err)
+		}
+		if err := process.Enqueue(ctx, l.core, *knowledge); err != nil {
+			return errors.New("KnowledgeLogic.RetryProcess.process.Enqueue", i18n.ERROR_INTERNAL, err)
+		}
+		return nil
+	})
+}
+
+// RetryFailedProcesses 将空间中全部处理失败的知识重新放回队列，返回重试的数量
+func (l *KnowledgeLogic) RetryFailedProcesses(spaceID string) (int, error) {
+	var total int
+	err := l.core.Store().Transaction(l.ctx, func(ctx context.Context) error {
+		ids, err := l.core.Store().KnowledgeJobStore().RequeueFailed(ctx, spaceID)
+		if err != nil && err != sql.ErrNoRows {
+			return errors.New("KnowledgeLogic.RetryFailedProcesses.KnowledgeJobStore.RequeueFailed", i18n.ERROR_INTERNAL, err)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if err = l.core.Store().KnowledgeStore().ResetRetryTimes(ctx, spaceID, ids); err != nil {
+			return errors.New("KnowledgeLogic.RetryFailedProcesses.KnowledgeStore.ResetRetryTimes", i18n.ERROR_INTERNAL, err)
+		}
+		total = len(ids)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// ResetProcessRetryTimes 清空知识与任务的重试次数，不改变任务状态
+func (l *KnowledgeLogic) ResetProcessRetryTimes(spaceID, id string) error {
+	if _, err := l.getProcessingKnowledge(spaceID, id); err != nil {
+		return errors.Trace("KnowledgeLogic.ResetProcessRetryTimes", err)
+	}
+
+	return l.core.Store().Transaction(l.ctx, func(ctx context.Context) error {
+		if err := l.core.Store().KnowledgeStore().SetRetryTimes(ctx, spaceID, id, 0); err != nil {
+			return errors.New("KnowledgeLogic.ResetProcessRetryTimes.KnowledgeStore.SetRetryTimes", i18n.ERROR_INTERNAL, err)
+		}
+		if err := l.core.Store().KnowledgeJobStore().ResetAttempts(ctx, spaceID, id); err != nil {
+			return errors.New("KnowledgeLogic.ResetProcessRetryTimes.KnowledgeJobStore.ResetAttempts", i18n.ERROR_INTERNAL, err)
+		}
+		return nil
+	})
+}
+
+// CancelProcess 取消知识的处理任务，知识停留在当前阶段，可通过 RetryProcess 重新处理
+func (l *KnowledgeLogic) CancelProcess(spaceID, id string) error {
+	job, err := l.core.Store().KnowledgeJobStore().Get(l.ctx, id)
+	if err != nil && err != sql.ErrNoRows {
+		return errors.New("KnowledgeLogic.CancelProcess.KnowledgeJobStore.Get", i18n.ERROR_INTERNAL, err)
+	}
+	if job == nil || job.SpaceID != spaceID {
+		return errors.New("KnowledgeLogic.CancelProcess.KnowledgeJobStore.Get.nil", i18n.ERROR_NOTFOUND, nil).Code(http.StatusNotFound)
+	}
+
+	if err = l.core.Store().KnowledgeJobStore().Cancel(l.ctx, spaceID, id); err != nil {
+		return errors.New("KnowledgeLogic.CancelProcess.KnowledgeJobStore.Cancel", i18n.ERROR_INTERNAL, err)
+	}
+	return nil
+}
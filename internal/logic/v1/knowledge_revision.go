@@ -0,0 +1,110 @@
+package v1
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/starbx/brew-api/pkg/errors"
+	"github.com/starbx/brew-api/pkg/i18n"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+// ListRevisions 分页获取知识的历史版本，最新的修改排在最前
+func (l *KnowledgeLogic) ListRevisions(spaceID, knowledgeID string, page, pageSize uint64) ([]types.KnowledgeRevision, int64, error) {
+	if _, err := l.GetKnowledge(spaceID, knowledgeID); err != nil {
+		return nil, 0, errors.Trace("KnowledgeLogic.ListRevisions", err)
+	}
+
+	list, err := l.core.Store().KnowledgeRevisionStore().ListRevisions(l.ctx, spaceID, knowledgeID, page, pageSize)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, 0, errors.New("KnowledgeLogic.ListRevisions.KnowledgeRevisionStore.ListRevisions", i18n.ERROR_INTERNAL, err)
+	}
+
+	total, err := l.core.Store().KnowledgeRevisionStore().Total(l.ctx, spaceID, knowledgeID)
+	if err != nil {
+		return nil, 0, errors.New("KnowledgeLogic.ListRevisions.KnowledgeRevisionStore.Total", i18n.ERROR_INTERNAL, err)
+	}
+	return list, total, nil
+}
+
+func (l *KnowledgeLogic) getRevision(spaceID, knowledgeID, id string) (*types.KnowledgeRevision, error) {
+	revision, err := l.core.Store().KnowledgeRevisionStore().GetRevision(l.ctx, spaceID, knowledgeID, id)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("KnowledgeLogic.getRevision.KnowledgeRevisionStore.GetRevision", i18n.ERROR_INTERNAL, err)
+	}
+	if revision == nil {
+		return nil, errors.New("KnowledgeLogic.getRevision.KnowledgeRevisionStore.GetRevision.nil", i18n.ERROR_NOTFOUND, nil).Code(http.StatusNotFound)
+	}
+	return revision, nil
+}
+
+// DiffRevisions 对比两个版本的标题、标签与内容，to 为空时与知识的当前内容对比
+func (l *KnowledgeLogic) DiffRevisions(spaceID, knowledgeID, from, to string) (*types.KnowledgeRevisionDiff, error) {
+	fromRevision, err := l.getRevision(spaceID, knowledgeID, from)
+	if err != nil {
+		return nil, errors.Trace("KnowledgeLogic.DiffRevisions.from", err)
+	}
+
+	var toTitle, toContent string
+	var toTags []string
+	if to == "" {
+		knowledge, err := l.GetKnowledge(spaceID, knowledgeID)
+		if err != nil {
+			return nil, errors.Trace("KnowledgeLogic.DiffRevisions.current", err)
+		}
+		toTitle, toTags, toContent = knowledge.Title, knowledge.Tags, knowledge.Content
+	} else {
+		toRevision, err := l.getRevision(spaceID, knowledgeID, to)
+		if err != nil {
+			return nil, errors.Trace("KnowledgeLogic.DiffRevisions.to", err)
+		}
+		toTitle, toTags, toContent = toRevision.Title, toRevision.Tags, toRevision.Content
+	}
+
+	toName := "current"
+	if to != "" {
+		toName = to
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(revisionText(fromRevision.Title, fromRevision.Tags, fromRevision.Content)),
+		B:        difflib.SplitLines(revisionText(toTitle, toTags, toContent)),
+		FromFile: from,
+		ToFile:   toName,
+		Context:  3,
+	})
+	if err != nil {
+		return nil, errors.New("KnowledgeLogic.DiffRevisions.GetUnifiedDiffString", i18n.ERROR_INTERNAL, err)
+	}
+
+	return &types.KnowledgeRevisionDiff{
+		From: from,
+		To:   to,
+		Diff: diff,
+	}, nil
+}
+
+// revisionText 将知识的标题、标签与内容拼接为用于对比的文本
+func revisionText(title string, tags []string, content string) string {
+	return fmt.Sprintf("title: %s\ntags: %s\n\n%s\n", title, strings.Join(tags, ", "), content)
+}
+
+// RestoreRevision 将知识恢复为指定版本的内容，恢复本身也会产生一条修订记录，并重新执行知识处理流程
+func (l *KnowledgeLogic) RestoreRevision(spaceID, knowledgeID, id string) error {
+	revision, err := l.getRevision(spaceID, knowledgeID, id)
+	if err != nil {
+		return errors.Trace("KnowledgeLogic.RestoreRevision", err)
+	}
+
+	if err = l.Update(spaceID, knowledgeID, types.UpdateKnowledgeArgs{
+		Title:   revision.Title,
+		Tags:    revision.Tags,
+		Content: revision.Content,
+	}); err != nil {
+		return errors.Trace("KnowledgeLogic.RestoreRevision", err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package v1_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+func TestKnowledgeRevision(t *testing.T) {
+	if os.Getenv("BREW_API_POSTGRESQL_DSN") == "" {
+		t.Skip("BREW_API_POSTGRESQL_DSN is not set")
+	}
+	logic := setupKnowledgeLogic()
+
+	id, err := logic.InsertContent(spaceid, types.DEFAULT_RESOURCE, types.KNOWLEDGE_KIND_TEXT, "version one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logic.Delete(spaceid, id)
+
+	assert.NoError(t, logic.Update(spaceid, id, types.UpdateKnowledgeArgs{Title: "second", Content: "version two"}))
+	assert.NoError(t, logic.Update(spaceid, id, types.UpdateKnowledgeArgs{Title: "third", Content: "version three"}))
+
+	// 最新的修改排在最前，每条记录保存的是被覆盖前的内容
+	list, total, err := logic.ListRevisions(spaceid, id, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(2), total)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, "version two", list[0].Content)
+		assert.Equal(t, "second", list[0].Title)
+		assert.Equal(t, "version one", list[1].Content)
+		user := logic.GetUserInfo().User
+		assert.Equal(t, user, list[1].UserID)
+		assert.Equal(t, user, list[1].ReplacedBy)
+	}
+
+	diff, err := logic.DiffRevisions(spaceid, id, list[1].ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, diff.Diff, "-version one")
+	assert.Contains(t, diff.Diff, "+version three")
+
+	diff, err = logic.DiffRevisions(spaceid, id, list[1].ID, list[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, diff.Diff, "+version two")
+
+	_, err = logic.DiffRevisions(spaceid, id, "not-exist", "")
+	assert.Error(t, err)
+
+	// 恢复同样产生一条修订记录
+	assert.NoError(t, logic.RestoreRevision(spaceid, id, list[1].ID))
+	knowledge, err := logic.GetKnowledge(spaceid, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "version one", knowledge.Content)
+
+	list, total, err = logic.ListRevisions(spaceid, id, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(3), total)
+	assert.Equal(t, "version three", list[0].Content)
+}
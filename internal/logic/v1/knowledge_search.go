@@ -0,0 +1,173 @@
+package v1
+
+import (
+	"database/sql"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/pgvector/pgvector-go"
+	"github.com/samber/lo"
+
+	"github.com/starbx/brew-api/internal/core/srv"
+	"github.com/starbx/brew-api/pkg/mark"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+// scopedResource 检索限定在单个资源时返回该资源，否则返回 nil
+func (l *KnowledgeLogic) scopedResource(spaceID string, resource *types.ResourceQuery) (*types.Resource, error) {
+	if resource == nil || len(resource.Include) != 1 {
+		return nil, nil
+	}
+	res, err := l.core.Store().ResourceStore().GetResource(l.ctx, spaceID, resource.Include[0])
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return res, nil
+}
+
+// retrievalOptions 合并服务默认配置、空间以及资源的检索配置，后者优先
+func (l *KnowledgeLogic) retrievalOptions(spaceID string, resource *types.Resource, defaults types.RetrievalOptions) (types.RetrievalOptions, error) {
+	opts := defaults
+	opts.KeywordWeight, opts.VectorWeight = l.core.Cfg().Search.Weights()
+
+	space, err := l.core.Store().SpaceStore().GetSpace(l.ctx, spaceID)
+	if err != nil && err != sql.ErrNoRows {
+		return opts, err
+	}
+	if space != nil {
+		opts = opts.Apply(space.Retrieval)
+	}
+
+	if resource != nil {
+		opts = opts.Apply(resource.Retrieval)
+	}
+	return opts, nil
+}
+
+// searchVectors 按检索配置进行混合检索，全文检索的权重为0或没有查询文本时只进行向量检索
+func (l *KnowledgeLogic) searchVectors(opts types.GetVectorsOptions, retrieval types.RetrievalOptions, text string, embedding pgvector.Vector) ([]types.QueryResult, error) {
+	// 切换 embedding 模型期间可能同时存在多个模型的向量，只比较查询模型生成的向量
+	opts.Model = l.core.Srv().AI().QueryEmbeddingModel()
+	if retrieval.KeywordWeight <= 0 || strings.TrimSpace(text) == "" {
+		return l.core.Store().VectorStore().Query(l.ctx, opts, embedding, retrieval.TopK)
+	}
+
+	return l.core.Store().VectorStore().HybridQuery(l.ctx, opts, types.HybridQuery{
+		Text:             text,
+		Embedding:        embedding,
+		TextSearchConfig: l.core.Store().TextSearchConfig(),
+		KeywordWeight:    retrieval.KeywordWeight,
+		VectorWeight:     retrieval.VectorWeight,
+		RRFK:             l.core.Cfg().Search.RRFConstant(),
+	}, retrieval.TopK)
+}
+
+// rerankRefs 使用配置的 reranker 对候选分块重新排序并只保留相关性最高且得分不低于 minScore 的部分
+// 问题与候选分块都按 detector 替换敏感信息后才发送给 reranker，未配置重排或重排失败时返回 false，由调用方使用默认的筛选规则
+func (l *KnowledgeLogic) rerankRefs(spaceID, query string, detector *mark.Detector, refs []types.QueryResult, minScore float32) ([]types.QueryResult, bool) {
+	reranker := l.core.Srv().Reranker()
+	if reranker == nil || len(refs) == 0 {
+		return refs, false
+	}
+
+	ids := lo.Map(refs, func(item types.QueryResult, _ int) string {
+		return item.ID
+	})
+	chunks, err := l.core.Store().KnowledgeChunkStore().ListByIDs(l.ctx, spaceID, ids)
+	if err != nil {
+		slog.Error("failed to get chunks for rerank", slog.String("space_id", spaceID), slog.String("error", err.Error()))
+		return refs, false
+	}
+
+	chunkMap := lo.SliceToMap(chunks, func(item types.KnowledgeChunk) (string, string) {
+		return item.ID, item.Chunk
+	})
+	var docs []srv.RerankDocument
+	for _, v := range refs {
+		if content, ok := chunkMap[v.ID]; ok {
+			docs = append(docs, srv.RerankDocument{
+				ID:      v.ID,
+				Content: content,
+			})
+		}
+	}
+
+	results, err := srv.NewMaskedReranker(reranker, detector).Rerank(l.ctx, query, docs, l.core.Srv().RerankTopK())
+	if err != nil {
+		slog.Error("failed to rerank passages", slog.String("space_id", spaceID), slog.String("query", query), slog.String("error", err.Error()))
+		return refs, false
+	}
+
+	refMap := lo.SliceToMap(refs, func(item types.QueryResult) (string, types.QueryResult) {
+		return item.ID, item
+	})
+	reranked := make([]types.QueryResult, 0, len(results))
+	for _, v := range results {
+		if v.Score < minScore {
+			continue
+		}
+		if ref, ok := refMap[v.ID]; ok {
+			reranked = append(reranked, ref)
+		}
+	}
+	return reranked, true
+}
+
+// transcriptOffsets 为音视频知识的候选分块填充起止时间，并按知识返回相关片段的时间，用于在回答中引用
+func (l *KnowledgeLogic) transcriptOffsets(spaceID string, refs []types.QueryResult, knowledges []*types.Knowledge) map[string][]types.ChunkTimestamp {
+	media := make(map[string]bool)
+	for _, v := range knowledges {
+		if v.Kind == types.KNOWLEDGE_KIND_VIDEO {
+			media[v.ID] = true
+		}
+	}
+	var ids []string
+	for _, v := range refs {
+		if media[v.KnowledgeID] {
+			ids = append(ids, v.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	chunks, err := l.core.Store().KnowledgeChunkStore().ListByIDs(l.ctx, spaceID, ids)
+	if err != nil {
+		slog.Error("failed to get chunks for transcript offsets", slog.String("space_id", spaceID), slog.String("error", err.Error()))
+		return nil
+	}
+	timestamps := make(map[string]*types.ChunkTimestamp)
+	for _, v := range chunks {
+		if v.Meta.Timestamp != nil {
+			timestamps[v.ID] = v.Meta.Timestamp
+		}
+	}
+
+	offsets := make(map[string][]types.ChunkTimestamp)
+	for i, v := range refs {
+		if ts := timestamps[v.ID]; ts != nil {
+			refs[i].Timestamp = ts
+			offsets[v.KnowledgeID] = append(offsets[v.KnowledgeID], *ts)
+		}
+	}
+	for k := range offsets {
+		sort.Slice(offsets[k], func(i, j int) bool {
+			return offsets[k][i].Start < offsets[k][j].Start
+		})
+	}
+	return offsets
+}
+
+// sortKnowledgesByRefs 按候选分块的顺序排列知识，使相关性高的内容在提示词中靠前
+func sortKnowledgesByRefs(knowledges []*types.Knowledge, refs []types.QueryResult) {
+	order := make(map[string]int)
+	for i, v := range refs {
+		if _, ok := order[v.KnowledgeID]; !ok {
+			order[v.KnowledgeID] = i
+		}
+	}
+	sort.SliceStable(knowledges, func(i, j int) bool {
+		return order[knowledges[i].ID] < order[knowledges[j].ID]
+	})
+}
@@ -0,0 +1,55 @@
+package v1
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/samber/lo"
+
+	"github.com/starbx/brew-api/pkg/errors"
+	"github.com/starbx/brew-api/pkg/i18n"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+// ListTags 获取空间中的所有标签及其使用次数
+func (l *KnowledgeLogic) ListTags(spaceID string) ([]types.TagCount, error) {
+	list, err := l.core.Store().KnowledgeStore().ListTags(l.ctx, spaceID)
+	if err != nil {
+		return nil, errors.New("KnowledgeLogic.ListTags.KnowledgeStore.ListTags", i18n.ERROR_INTERNAL, err)
+	}
+	return list, nil
+}
+
+// RenameTag 将空间中所有知识的标签 from 重命名为 to，若知识已包含 to 则两者合并为一个
+func (l *KnowledgeLogic) RenameTag(spaceID, from, to string) (int64, error) {
+	affected, err := l.replaceTags(spaceID, []string{from}, to)
+	if err != nil {
+		return 0, errors.Trace("KnowledgeLogic.RenameTag", err)
+	}
+	return affected, nil
+}
+
+// MergeTags 将空间中的多个标签合并为 to
+func (l *KnowledgeLogic) MergeTags(spaceID string, tags []string, to string) (int64, error) {
+	affected, err := l.replaceTags(spaceID, tags, to)
+	if err != nil {
+		return 0, errors.Trace("KnowledgeLogic.MergeTags", err)
+	}
+	return affected, nil
+}
+
+func (l *KnowledgeLogic) replaceTags(spaceID string, from []string, to string) (int64, error) {
+	to = strings.TrimSpace(to)
+	from = lo.Uniq(lo.Without(lo.Map(from, func(item string, _ int) string {
+		return strings.TrimSpace(item)
+	}), ""))
+	if to == "" || len(from) == 0 || (len(from) == 1 && from[0] == to) {
+		return 0, errors.New("KnowledgeLogic.replaceTags.args", i18n.ERROR_INVALIDARGUMENT, nil).Code(http.StatusBadRequest)
+	}
+
+	affected, err := l.core.Store().KnowledgeStore().ReplaceTags(l.ctx, spaceID, from, to)
+	if err != nil {
+		return 0, errors.New("KnowledgeLogic.replaceTags.KnowledgeStore.ReplaceTags", i18n.ERROR_INTERNAL, err)
+	}
+	return affected, nil
+}
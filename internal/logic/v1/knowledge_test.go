@@ -51,7 +51,7 @@ func TestKnowledgeInsert(t *testing.T) {
 func TestKnowledgeQuery(t *testing.T) {
 	logic := setupKnowledgeLogic()
 
-	res, err := logic.Query(spaceid, nil, "我昨天做了哪些工作")
+	res, err := logic.Query(spaceid, nil, nil, "我昨天做了哪些工作")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,46 @@
+package process
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/starbx/brew-api/internal/core"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+// DeleteKnowledge 删除知识及其关联的修订、原始文件、链接与分享，以及参与检索的数据，需要在事务中调用
+func DeleteKnowledge(ctx context.Context, app *core.Core, spaceID, id string) error {
+	if err := app.Store().KnowledgeStore().Delete(ctx, spaceID, id); err != nil {
+		return fmt.Errorf("failed to delete knowledge, %w", err)
+	}
+	if err := app.Store().KnowledgeRevisionStore().BatchDelete(ctx, spaceID, id); err != nil {
+		return fmt.Errorf("failed to delete knowledge revisions, %w", err)
+	}
+	if err := app.Store().BlobStore().Delete(ctx, spaceID, id); err != nil {
+		return fmt.Errorf("failed to delete knowledge blob, %w", err)
+	}
+	if err := app.Store().KnowledgeLinkStore().DeleteByKnowledge(ctx, spaceID, id); err != nil {
+		return fmt.Errorf("failed to delete knowledge links, %w", err)
+	}
+	if err := app.Store().ShareTokenStore().DeleteByObject(ctx, spaceID, types.SHARE_KIND_KNOWLEDGE, id); err != nil {
+		return fmt.Errorf("failed to delete knowledge shares, %w", err)
+	}
+	return clearKnowledgeIndex(ctx, app, spaceID, id)
+}
+
+// clearKnowledgeIndex 删除知识参与检索的分块、向量、重复记录与处理任务，删除与归档共用
+func clearKnowledgeIndex(ctx context.Context, app *core.Core, spaceID, id string) error {
+	if err := app.Store().KnowledgeChunkStore().BatchDelete(ctx, spaceID, id); err != nil {
+		return fmt.Errorf("failed to delete knowledge chunks, %w", err)
+	}
+	if err := app.Store().VectorStore().BatchDelete(ctx, spaceID, id); err != nil {
+		return fmt.Errorf("failed to delete knowledge vectors, %w", err)
+	}
+	if err := app.Store().KnowledgeDuplicateStore().DeleteByKnowledge(ctx, spaceID, id); err != nil {
+		return fmt.Errorf("failed to delete duplicate records, %w", err)
+	}
+	if err := app.Store().KnowledgeJobStore().Delete(ctx, spaceID, id); err != nil {
+		return fmt.Errorf("failed to delete knowledge job, %w", err)
+	}
+	return nil
+}
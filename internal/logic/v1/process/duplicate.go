@@ -0,0 +1,83 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+const (
+	// 每条知识最多使用多少个分块的向量进行相似检索
+	DUPLICATE_CHECK_MAX_CHUNKS = 10
+	// 每个分块检索的相似分块数量
+	DUPLICATE_CHECK_LIMIT = 5
+)
+
+// detectDuplicates 使用知识各分块的向量检索空间中的相似分块，
+// 以各分块最高相似度的平均值作为两条知识的相似度，不低于阈值的记为疑似重复
+func (p *KnowledgeProcess) detectDuplicates(ctx context.Context, data types.Knowledge, vectors []types.Vector) error {
+	threshold := p.core.Cfg().Dedup.SimilarityThreshold()
+	if threshold > 1 || len(vectors) == 0 {
+		return nil
+	}
+
+	if len(vectors) > DUPLICATE_CHECK_MAX_CHUNKS {
+		vectors = vectors[:DUPLICATE_CHECK_MAX_CHUNKS]
+	}
+
+	scores := make(map[string]float32)
+	for _, v := range vectors {
+		// 排除自身的分块，否则同一知识的其他分块会占满检索结果
+		results, err := p.core.Store().VectorStore().Query(ctx, types.GetVectorsOptions{
+			SpaceID:            data.SpaceID,
+			Model:              v.Model,
+			ExcludeKnowledgeID: data.ID,
+		}, v.Embedding, DUPLICATE_CHECK_LIMIT)
+		if err != nil {
+			return fmt.Errorf("failed to query similar vectors, %w", err)
+		}
+
+		best := make(map[string]float32)
+		for _, r := range results {
+			// Query 返回的是余弦距离
+			if similarity := 1 - r.Cos; similarity > best[r.KnowledgeID] {
+				best[r.KnowledgeID] = similarity
+			}
+		}
+		for id, similarity := range best {
+			scores[id] += similarity
+		}
+	}
+
+	var duplicates []types.KnowledgeDuplicate
+	for id, total := range scores {
+		similarity := total / float32(len(vectors))
+		if similarity < threshold {
+			continue
+		}
+		duplicates = append(duplicates, types.KnowledgeDuplicate{
+			SpaceID:     data.SpaceID,
+			KnowledgeID: data.ID,
+			DuplicateID: id,
+			Similarity:  similarity,
+			CreatedAt:   time.Now().Unix(),
+		})
+	}
+	sort.Slice(duplicates, func(i, j int) bool {
+		return duplicates[i].DuplicateID < duplicates[j].DuplicateID
+	})
+
+	// 内容修改后重新检测，旧的检测结果不再有效
+	return p.core.Store().Transaction(ctx, func(ctx context.Context) error {
+		if err := p.core.Store().KnowledgeDuplicateStore().DeleteByKnowledge(ctx, data.SpaceID, data.ID); err != nil {
+			return fmt.Errorf("failed to delete old duplicate records, %w", err)
+		}
+		if err := p.core.Store().KnowledgeDuplicateStore().BatchCreate(ctx, duplicates); err != nil {
+			return fmt.Errorf("failed to record duplicates, %w", err)
+		}
+		return nil
+	})
+}
@@ -3,9 +3,10 @@ package process
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"log/slog"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/holdno/firetower/protocol"
@@ -13,6 +14,8 @@ import (
 
 	"github.com/starbx/brew-api/internal/core"
 	"github.com/starbx/brew-api/internal/core/srv"
+	"github.com/starbx/brew-api/pkg/ai"
+	"github.com/starbx/brew-api/pkg/chunk"
 	"github.com/starbx/brew-api/pkg/mark"
 	"github.com/starbx/brew-api/pkg/safe"
 	"github.com/starbx/brew-api/pkg/types"
@@ -23,15 +26,18 @@ var (
 	knowledgeProcess *KnowledgeProcess
 )
 
+// StartKnowledgeProcess 启动知识处理流水线的 worker，任务保存在 postgres 中，多个实例可以同时消费
 func StartKnowledgeProcess(core *core.Core, concurrency int) context.CancelFunc {
 	ctx, cancel := context.WithCancel(context.Background())
+	if concurrency <= 0 {
+		concurrency = core.Cfg().Process.WorkerCount()
+	}
 	knowledgeProcess = &KnowledgeProcess{
-		concurrency:   concurrency,
-		ctx:           ctx,
-		core:          core,
-		SummaryChan:   make(chan *SummaryRequest, 100000),
-		EmbeddingChan: make(chan *EmbeddingRequest, 100000),
-		processingMap: make(map[string]struct{}),
+		concurrency: concurrency,
+		ctx:         ctx,
+		core:        core,
+		chunker:     newChunker(core.Cfg().Chunk),
+		notify:      make(chan struct{}, concurrency),
 	}
 
 	go safe.Run(knowledgeProcess.Start)
@@ -51,203 +57,247 @@ func StartKnowledgeProcess(core *core.Core, concurrency int) context.CancelFunc
 	return cancel
 }
 
+// Flush 为处理中但没有任务的知识补充任务，如队列上线前遗留的知识或重新 embedding 的导入数据
 func (p *KnowledgeProcess) Flush() {
 	ctx, cancel := context.WithTimeout(p.ctx, time.Second*10)
 	defer cancel()
-	list, err := p.core.Store().KnowledgeStore().ListProcessingKnowledges(ctx, 3, 1, 20)
-	if err != nil && err != sql.ErrNoRows {
-		slog.Error("Failed to list processing knowledges", slog.String("error", err.Error()))
+	total, err := p.core.Store().KnowledgeJobStore().EnqueueMissing(ctx, p.core.Cfg().Process.Attempts())
+	if err != nil {
+		slog.Error("Failed to enqueue processing knowledges", slog.String("error", err.Error()))
 		return
 	}
 
-	if len(list) > 0 {
-		slog.Info("KnowledgeProcess flush", slog.Int("length", len(list)))
-	}
-
-	for _, v := range list {
-		if v.RetryTimes > 3 {
-			continue
-		}
-		switch v.Stage {
-		case types.KNOWLEDGE_STAGE_SUMMARIZE:
-			NewSummaryRequest(v)
-		case types.KNOWLEDGE_STAGE_EMBEDDING:
-			NewEmbeddingRequest(v)
-		}
+	if total > 0 {
+		slog.Info("KnowledgeProcess flush", slog.Int64("length", total))
+		p.wakeup()
 	}
 }
 
 type KnowledgeProcess struct {
-	concurrency   int
-	ctx           context.Context
-	core          *core.Core
-	SummaryChan   chan *SummaryRequest
-	EmbeddingChan chan *EmbeddingRequest
-	mu            sync.Mutex
-	processingMap map[string]struct{}
+	concurrency int
+	ctx         context.Context
+	core        *core.Core
+	chunker     *chunk.Chunker
+	// notify 本实例写入新任务时唤醒空闲的 worker，其他实例写入的任务通过轮询获取
+	notify chan struct{}
 }
 
+// JOB_POLL_INTERVAL 没有可领取的任务时的轮询间隔
+const JOB_POLL_INTERVAL = time.Second * 3
+
 func (p *KnowledgeProcess) Start() {
-	for range 10 {
-		go safe.Run(func() {
-			p.ProcessSummary()
-		})
-	}
-	for range 10 {
-		go safe.Run(func() {
-			p.ProcessEmbedding()
-		})
+	for range p.concurrency {
+		go safe.Run(p.work)
 	}
 }
 
-type SummaryRequest struct {
-	ctx      context.Context
-	data     types.Knowledge
-	response chan SummaryResponse
+func (p *KnowledgeProcess) wakeup() {
+	for range p.concurrency {
+		select {
+		case p.notify <- struct{}{}:
+		default:
+			return
+		}
+	}
 }
 
-type SummaryResponse struct {
-	Err error
+func (p *KnowledgeProcess) work() {
+	for {
+		if p.ctx.Err() != nil {
+			return
+		}
+
+		job, err := p.core.Store().KnowledgeJobStore().Lease(p.ctx, utils.GenRandomID(), p.core.Cfg().Process.Visibility())
+		if err != nil {
+			if err != sql.ErrNoRows {
+				slog.Error("Failed to lease knowledge job", slog.String("error", err.Error()))
+			}
+			select {
+			case <-p.ctx.Done():
+				return
+			case <-p.notify:
+			case <-time.After(JOB_POLL_INTERVAL):
+			}
+			continue
+		}
+
+		p.runJob(job)
+	}
 }
 
-func (p *KnowledgeProcess) CheckProcess(id string, handler func()) {
-	p.mu.Lock()
-	if _, exist := p.processingMap[id]; exist {
-		p.mu.Unlock()
-		return
+// runJob 执行任务当前所处的阶段，summary 完成后任务进入 embedding 阶段，embedding 完成后任务被删除
+func (p *KnowledgeProcess) runJob(job *types.KnowledgeJob) {
+	logAttrs := []any{
+		slog.String("space_id", job.SpaceID),
+		slog.String("knowledge_id", job.KnowledgeID),
+		slog.String("stage", job.Stage.String()),
+		slog.Int("attempts", job.Attempts),
+		slog.String("component", "KnowledgeProcess.runJob"),
 	}
-	p.processingMap[id] = struct{}{}
-	p.mu.Unlock()
 
-	handler()
-	p.mu.Lock()
-	delete(p.processingMap, id)
-	p.mu.Unlock()
-}
+	data, err := p.core.Store().KnowledgeStore().GetKnowledge(p.ctx, job.SpaceID, job.KnowledgeID)
+	if err != nil && err != sql.ErrNoRows {
+		p.failJob(job, err)
+		return
+	}
 
-func NewSummaryRequest(data types.Knowledge) chan SummaryResponse {
-	if knowledgeProcess == nil || knowledgeProcess.ctx.Err() != nil {
-		slog.Error("Knowledge Process not working", slog.String("error", knowledgeProcess.ctx.Err().Error()),
-			slog.String("space_id", data.SpaceID), slog.String("knowledge_id", data.ID))
-		return nil
+	// 知识已被删除或已不需要处理
+	if data == nil || (data.Stage != types.KNOWLEDGE_STAGE_SUMMARIZE && data.Stage != types.KNOWLEDGE_STAGE_EMBEDDING) {
+		if _, err = p.core.Store().KnowledgeJobStore().Complete(p.ctx, job.KnowledgeID, job.LockID); err != nil {
+			slog.Error("Failed to complete knowledge job", append(logAttrs, slog.String("error", err.Error()))...)
+		}
+		return
 	}
 
-	resp := make(chan SummaryResponse, 1)
-	knowledgeProcess.SummaryChan <- &SummaryRequest{
-		ctx:      context.Background(),
-		data:     data,
-		response: resp,
+	switch data.Stage {
+	case types.KNOWLEDGE_STAGE_SUMMARIZE:
+		if err = p.processSummary(p.ctx, *data); err != nil {
+			p.failJob(job, err)
+			return
+		}
+		if _, err = p.core.Store().KnowledgeJobStore().Advance(p.ctx, job.KnowledgeID, job.LockID, types.KNOWLEDGE_STAGE_EMBEDDING); err != nil {
+			slog.Error("Failed to advance knowledge job", append(logAttrs, slog.String("error", err.Error()))...)
+			return
+		}
+		p.wakeup()
+	case types.KNOWLEDGE_STAGE_EMBEDDING:
+		if err = p.processEmbedding(p.ctx, *data); err != nil {
+			p.failJob(job, err)
+			return
+		}
+		if _, err = p.core.Store().KnowledgeJobStore().Complete(p.ctx, job.KnowledgeID, job.LockID); err != nil {
+			slog.Error("Failed to complete knowledge job", append(logAttrs, slog.String("error", err.Error()))...)
+		}
 	}
-	return resp
 }
 
-func NewEmbeddingRequest(data types.Knowledge) chan EmbeddingResponse {
-	if knowledgeProcess == nil || knowledgeProcess.ctx.Err() != nil {
-		slog.Error("Knowledge Process not working", slog.String("error", knowledgeProcess.ctx.Err().Error()),
-			slog.String("space_id", data.SpaceID), slog.String("knowledge_id", data.ID))
-		return nil
+// failJob 记录知识的重试次数并按指数退避安排重试，尝试次数用尽后将任务标记为失败
+func (p *KnowledgeProcess) failJob(job *types.KnowledgeJob, cause error) {
+	logAttrs := []any{
+		slog.String("space_id", job.SpaceID),
+		slog.String("knowledge_id", job.KnowledgeID),
+		slog.String("stage", job.Stage.String()),
+		slog.Int("attempts", job.Attempts),
+		slog.String("component", "KnowledgeProcess.failJob"),
 	}
 
-	resp := make(chan EmbeddingResponse, 1)
-	knowledgeProcess.EmbeddingChan <- &EmbeddingRequest{
-		ctx:      context.Background(),
-		data:     data,
-		response: resp,
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	if err := p.core.Store().KnowledgeStore().SetRetryTimes(ctx, job.SpaceID, job.KnowledgeID, job.Attempts); err != nil {
+		slog.Error("Failed to set knowledge process retry times", append(logAttrs, slog.String("error", err.Error()))...)
 	}
-	return resp
-}
 
-func (p *KnowledgeProcess) ProcessSummary() {
-	for {
-		select {
-		case <-p.ctx.Done():
+	cfg := p.core.Cfg().Process
+	if job.Attempts < cfg.Attempts() {
+		runAt := time.Now().Add(cfg.BackoffDelay(job.Attempts)).Unix()
+		ok, err := p.core.Store().KnowledgeJobStore().Retry(ctx, job.KnowledgeID, job.LockID, runAt, cause.Error())
+		if err != nil {
+			slog.Error("Failed to schedule knowledge job retry", append(logAttrs, slog.String("error", err.Error()))...)
 			return
-		case req := <-p.SummaryChan:
-			if req == nil {
-				continue
-			}
-
-			p.CheckProcess(req.data.ID, func() {
-				p.processSummary(req)
-			})
 		}
+		if ok {
+			publishProcessFailedMessage(p.core.Srv().Tower(), job, types.KNOWLEDGE_JOB_STATUS_PENDING, cause)
+		}
+		return
 	}
-}
 
-func (p *KnowledgeProcess) ProcessEmbedding() {
-	for {
-		select {
-		case <-p.ctx.Done():
-			return
-		case req := <-p.EmbeddingChan:
-			if req == nil {
-				continue
-			}
+	slog.Error("Knowledge job failed", append(logAttrs, slog.String("error", cause.Error()))...)
+	ok, err := p.core.Store().KnowledgeJobStore().Fail(ctx, job.KnowledgeID, job.LockID, cause.Error())
+	if err != nil {
+		slog.Error("Failed to mark knowledge job as failed", append(logAttrs, slog.String("error", err.Error()))...)
+		return
+	}
+	if ok {
+		publishProcessFailedMessage(p.core.Srv().Tower(), job, types.KNOWLEDGE_JOB_STATUS_FAILED, cause)
+	}
+}
 
-			p.CheckProcess(req.data.ID, func() {
-				p.processEmbedding(req)
-			})
-		}
+// Enqueue 为知识创建处理任务，在事务中调用时任务与知识一同提交
+func Enqueue(ctx context.Context, app *core.Core, data types.Knowledge) error {
+	stage := data.Stage
+	if stage != types.KNOWLEDGE_STAGE_EMBEDDING {
+		stage = types.KNOWLEDGE_STAGE_SUMMARIZE
 	}
+	if err := app.Store().KnowledgeJobStore().Enqueue(ctx, data.SpaceID, data.ID, stage); err != nil {
+		return err
+	}
+	Wakeup()
+	return nil
 }
 
-type EmbeddingRequest struct {
-	ctx      context.Context
-	data     types.Knowledge
-	response chan EmbeddingResponse
+// Wakeup 唤醒空闲的处理协程，在事务中写入任务时需要在提交后再次调用，提交前任务对处理协程不可见
+func Wakeup() {
+	if knowledgeProcess != nil {
+		knowledgeProcess.wakeup()
+	}
 }
 
-type EmbeddingResponse struct {
-	Err error
+// Wait 等待知识完成处理，处理失败时返回最后一次失败的原因
+func Wait(ctx context.Context, app *core.Core, spaceID, knowledgeID string) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		data, err := app.Store().KnowledgeStore().GetKnowledge(ctx, spaceID, knowledgeID)
+		if err != nil {
+			return err
+		}
+		if data.Stage == types.KNOWLEDGE_STAGE_DONE {
+			return nil
+		}
+
+		job, err := app.Store().KnowledgeJobStore().Get(ctx, knowledgeID)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if job != nil && job.Status == types.KNOWLEDGE_JOB_STATUS_FAILED {
+			return fmt.Errorf("knowledge process failed at stage %s, %s", job.Stage, job.LastError)
+		}
+		if job != nil && job.Status == types.KNOWLEDGE_JOB_STATUS_CANCELED {
+			return fmt.Errorf("knowledge process canceled at stage %s", job.Stage)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
 }
 
-func (p *KnowledgeProcess) processEmbedding(req *EmbeddingRequest) {
+func (p *KnowledgeProcess) processEmbedding(ctx context.Context, data types.Knowledge) (err error) {
 	logAttrs := []any{
-		slog.String("space_id", req.data.SpaceID),
-		slog.String("knowledge_id", req.data.ID),
+		slog.String("space_id", data.SpaceID),
+		slog.String("knowledge_id", data.ID),
 		slog.String("component", "KnowledgeProcess.processEmbedding"),
 	}
 
 	slog.Info("Receive new embedding request",
 		logAttrs...)
 
-	var err error
 	defer func() {
 		slog.Info("Embedding finished",
 			logAttrs...)
-		if req.response != nil {
-			req.response <- EmbeddingResponse{
-				Err: err,
-			}
-			close(req.response)
-		}
-		if err != nil {
-			ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-			defer cancel()
-			if err = p.core.Store().KnowledgeStore().SetRetryTimes(ctx, req.data.SpaceID, req.data.ID, req.data.RetryTimes+1); err != nil {
-				slog.Error("Failed to set knowledge process retry times", slog.String("stage", types.KNOWLEDGE_STAGE_EMBEDDING.String()),
-					slog.String("error", err.Error()),
-					slog.String("space_id", req.data.SpaceID),
-					slog.String("knowledge_id", req.data.ID),
-					slog.String("component", "KnowledgeProcess.processEmbedding"))
-			}
-		}
 	}()
 
-	// if req.data.Summary == "" {
+	// if data.Summary == "" {
 	// 	err = errors.New("empty summary")
 	// 	return
 	// }
 
-	sw := mark.NewSensitiveWork()
-	// content := sw.Do(req.data.Summary)
-
-	ctx, cancel := context.WithTimeout(req.ctx, time.Minute*5)
+	ctx, cancel := context.WithTimeout(ctx, time.Minute*5)
 	defer cancel()
-	chunksData, err := p.core.Store().KnowledgeChunkStore().List(ctx, req.data.SpaceID, req.data.ID)
+
+	detector, err := PrivacyDetector(ctx, p.core, data.SpaceID)
+	if err != nil {
+		slog.Error("Failed to get privacy detector", append(logAttrs, slog.String("error", err.Error()))...)
+		return err
+	}
+	sw := mark.NewSensitiveWork().WithDetector(detector)
+	chunksData, err := p.core.Store().KnowledgeChunkStore().List(ctx, data.SpaceID, data.ID)
 	if err != nil {
 		slog.Error("Failed to list knowledge chuns", append(logAttrs, slog.String("error", err.Error()))...)
-		return
+		return err
 	}
 
 	var (
@@ -262,7 +312,7 @@ func (p *KnowledgeProcess) processEmbedding(req *EmbeddingRequest) {
 			KnowledgeID:    v.KnowledgeID,
 			SpaceID:        v.SpaceID,
 			UserID:         v.UserID,
-			Resource:       req.data.Resource,
+			Resource:       data.Resource,
 			OriginalLength: v.OriginalLength,
 			// Embedding:   pgvector.NewVector(vector),
 			CreatedAt: time.Now().Unix(),
@@ -273,23 +323,27 @@ func (p *KnowledgeProcess) processEmbedding(req *EmbeddingRequest) {
 	vectorResults, err := p.core.Srv().AI().EmbeddingForDocument(ctx, "", chunks)
 	if err != nil {
 		slog.Error("Failed to embedding for document", append(logAttrs, slog.String("error", err.Error()))...)
-		return
+		return err
 	}
 
 	if len(vectorResults) != len(vectors) {
+		err = fmt.Errorf("embedding results count %d not matched chunks count %d", len(vectorResults), len(vectors))
 		slog.Error("Embedding results count not matched chunks count", append(logAttrs, slog.String("error", err.Error()))...)
-		return
+		return err
 	}
 
+	model := p.core.Srv().AI().EmbeddingModel()
 	for i, v := range vectorResults {
 		vectors[i].Embedding = pgvector.NewVector(v)
+		vectors[i].Model = model
+		vectors[i].Dim = len(v)
 	}
 
-	err = p.core.Store().Transaction(req.ctx, func(ctx context.Context) error {
-		ctx, cancel := context.WithTimeout(req.ctx, time.Minute)
+	err = p.core.Store().Transaction(ctx, func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, time.Minute)
 		defer cancel()
 
-		// exist, err := p.core.Store().VectorStore().GetVector(ctx, req.data.SpaceID, req.data.ID)
+		// exist, err := p.core.Store().VectorStore().GetVector(ctx, data.SpaceID, data.ID)
 		// if err != nil && err != sql.ErrNoRows {
 		// 	slog.Error("Failed to check the existence of knowledge", append(logAttrs, slog.String("error", err.Error()))...)
 		// 	return err
@@ -297,25 +351,25 @@ func (p *KnowledgeProcess) processEmbedding(req *EmbeddingRequest) {
 
 		// if exist == nil {
 		// 	err = p.core.Store().VectorStore().Create(ctx, types.Vector{
-		// 		ID:        req.data.ID,
-		// 		SpaceID:   req.data.SpaceID,
-		// 		UserID:    req.data.UserID,
+		// 		ID:        data.ID,
+		// 		SpaceID:   data.SpaceID,
+		// 		UserID:    data.UserID,
 		// 		Embedding: pgvector.NewVector(vector),
-		// 		Resource:  req.data.Resource,
+		// 		Resource:  data.Resource,
 		// 	})
 		// 	if err != nil {
 		// 		slog.Error("Failed to insert vector data into vector store", append(logAttrs, slog.String("error", err.Error()))...)
 		// 		return err
 		// 	}
 		// } else {
-		// 	err = p.core.Store().VectorStore().Update(ctx, req.data.SpaceID, req.data.ID, pgvector.NewVector(vector))
+		// 	err = p.core.Store().VectorStore().Update(ctx, data.SpaceID, data.ID, pgvector.NewVector(vector))
 		// 	if err != nil {
 		// 		slog.Error("Failed to update vector data", append(logAttrs, slog.String("error", err.Error()))...)
 		// 		return err
 		// 	}
 		// }
 
-		err := p.core.Store().VectorStore().BatchDelete(ctx, req.data.SpaceID, req.data.ID)
+		err := p.core.Store().VectorStore().BatchDelete(ctx, data.SpaceID, data.ID)
 		if err != nil && err != sql.ErrNoRows {
 			slog.Error("Failed to check the existence of knowledge", append(logAttrs, slog.String("error", err.Error()))...)
 			return err
@@ -327,89 +381,317 @@ func (p *KnowledgeProcess) processEmbedding(req *EmbeddingRequest) {
 			return err
 		}
 
-		if err = p.core.Store().KnowledgeStore().FinishedStageEmbedding(ctx, req.data.SpaceID, req.data.ID); err != nil {
+		if err = p.core.Store().KnowledgeStore().FinishedStageEmbedding(ctx, data.SpaceID, data.ID); err != nil {
 			slog.Error("Failed to set knowledge finished embedding stage", append(logAttrs, slog.String("error", err.Error()))...)
 			return err
 		}
 
-		publishStageChangedMessage(p.core.Srv().Tower(), req.data.SpaceID, req.data.ID, types.KNOWLEDGE_STAGE_DONE)
+		publishStageChangedMessage(p.core.Srv().Tower(), data.SpaceID, data.ID, types.KNOWLEDGE_STAGE_DONE)
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	// 模型的第一批向量写入后建立索引，已建立时直接返回
+	if ierr := p.core.Store().VectorStore().EnsureIndex(ctx, model); ierr != nil {
+		slog.Error("Failed to create vector index", append(logAttrs, slog.String("error", ierr.Error()))...)
+	}
+
+	// 重复检测失败不影响知识的处理结果
+	if derr := p.detectDuplicates(ctx, data, vectors); derr != nil {
+		slog.Error("Failed to detect duplicate knowledges", append(logAttrs, slog.String("error", derr.Error()))...)
+	}
+	return nil
 }
 
-func (p *KnowledgeProcess) processSummary(req *SummaryRequest) {
+func newChunker(cfg core.Chunk) *chunk.Chunker {
+	size, overlap := cfg.Size, cfg.Overlap
+	if size <= 0 {
+		size, overlap = chunk.DEFAULT_CHUNK_SIZE, chunk.DEFAULT_CHUNK_OVERLAP
+	}
+	chunker, err := chunk.NewTiktoken(size, overlap)
+	if err != nil {
+		slog.Error("Failed to setup local chunker, fallback to llm chunk", slog.String("error", err.Error()))
+		return nil
+	}
+	return chunker
+}
+
+// chunkMode 获取知识的分块方式，resource 上的配置优先于全局配置
+func (p *KnowledgeProcess) chunkMode(ctx context.Context, data types.Knowledge) string {
+	if p.chunker == nil {
+		return types.CHUNK_MODE_LLM
+	}
+
+	mode := p.core.Cfg().Chunk.Mode
+	resource, err := p.core.Store().ResourceStore().GetResource(ctx, data.SpaceID, data.Resource)
+	if err != nil && err != sql.ErrNoRows {
+		slog.Error("Failed to get knowledge resource", slog.String("space_id", data.SpaceID), slog.String("resource", data.Resource), slog.String("error", err.Error()))
+	}
+	if resource != nil && resource.ChunkMode != "" {
+		mode = resource.ChunkMode
+	}
+	return mode
+}
+
+type sensitiveWorker interface {
+	Do(text string) string
+	Undo(text string) string
+}
+
+// chunkContents 返回分块保存的内容，masked 表示分块基于替换了敏感信息的内容
+// 本地分块基于原文，先经过同样的替换再统一还原，两种分块方式保存的内容一致，识别出的敏感信息都保存为 $hidden[原文]
+func chunkContents(sw sensitiveWorker, chunks []string, masked bool) []string {
+	contents := make([]string, 0, len(chunks))
+	for _, v := range chunks {
+		if !masked {
+			v = sw.Do(v)
+		}
+		contents = append(contents, sw.Undo(v))
+	}
+	return contents
+}
+
+// SUMMARY_MAX_TOKENS 本地分块时，交由AI生成标题与标签的内容长度上限
+const SUMMARY_MAX_TOKENS = 4096
+
+// localChunk 使用本地 chunker 进行分块，AI仅用于生成标题与标签
+func (p *KnowledgeProcess) localChunk(ctx context.Context, data types.Knowledge, detector *mark.Detector) (ai.ChunkResult, error) {
+	result := ai.ChunkResult{
+		Chunks: p.chunker.Split(data.Content),
+	}
+	return p.summarizeHead(ctx, data, detector, result)
+}
+
+// transcriptChunk 按转写片段的边界分块并记录每个分块的起止时间，内容中没有时间标记时返回 nil
+// 分块不交由AI完成，以免丢失时间信息，AI仅用于生成标题与标签
+func (p *KnowledgeProcess) transcriptChunk(ctx context.Context, data types.Knowledge, detector *mark.Detector) (ai.ChunkResult, []types.ChunkMeta, error) {
+	segments := ai.ParseTranscript(data.Content, data.Meta.Duration)
+	if len(segments) == 0 || p.chunker == nil {
+		return ai.ChunkResult{}, nil, nil
+	}
+
+	lines := make([]string, 0, len(segments))
+	for _, v := range segments {
+		lines = append(lines, "["+ai.FormatTimestamp(v.Start)+"] "+v.Text)
+	}
+
+	var (
+		result ai.ChunkResult
+		metas  []types.ChunkMeta
+	)
+	for _, v := range p.chunker.Group(lines) {
+		result.Chunks = append(result.Chunks, strings.Join(lines[v[0]:v[1]], "\n"))
+		metas = append(metas, types.ChunkMeta{
+			Timestamp: &types.ChunkTimestamp{
+				Start: segments[v[0]].Start,
+				End:   segments[v[1]-1].End,
+			},
+		})
+	}
+
+	result, err := p.summarizeHead(ctx, data, detector, result)
+	return result, metas, err
+}
+
+// summarizeHead 由AI根据内容的开头部分生成标题与标签，知识的标题与标签均无需更新时跳过
+func (p *KnowledgeProcess) summarizeHead(ctx context.Context, data types.Knowledge, detector *mark.Detector, result ai.ChunkResult) (ai.ChunkResult, error) {
+	if data.Summary != "" && !strings.Contains(data.Summary, "title") && !strings.Contains(data.Summary, "tags") {
+		return result, nil
+	}
+
+	head := data.Content
+	if counter, err := chunk.TiktokenCounter(); err == nil {
+		if chunks := chunk.New(SUMMARY_MAX_TOKENS, 0, counter).Split(head); len(chunks) > 0 {
+			head = chunks[0]
+		}
+	}
+	sw := mark.NewSensitiveWork().WithDetector(detector)
+	head = sw.Do(head)
+	summary, err := p.core.Srv().AI().Summarize(ctx, &head)
+	if err != nil {
+		return result, err
+	}
+
+	result.Title = sw.Undo(summary.Title)
+	result.Tags = summary.Tags
+	result.DateTime = summary.DateTime
+	result.Token = summary.Token
+	return result, nil
+}
+
+// describeImage 使用视觉模型识别图片知识的原图，并将识别结果保存为知识内容
+// 图片会以原样发送给模型，无法像文本一样替换其中的敏感信息
+func (p *KnowledgeProcess) describeImage(ctx context.Context, data *types.Knowledge) (*ai.ImageResult, error) {
+	if data.Meta.Blob == "" {
+		return nil, fmt.Errorf("image of knowledge %s is missing", data.ID)
+	}
+	raw, err := p.core.Store().BlobStore().Get(ctx, data.SpaceID, data.Meta.Blob)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image, %w", err)
+	}
+
+	result, err := p.core.Srv().AI().DescribeImage(ctx, data.Meta.MimeType, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	// 内容先行保存，重试时无需再次识别
+	data.Content = result.Content()
+	if err = p.core.Store().KnowledgeStore().Update(ctx, data.SpaceID, data.ID, types.UpdateKnowledgeArgs{
+		Content: data.Content,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to save image content, %w", err)
+	}
+	return &result, nil
+}
+
+// transcribeMedia 将音视频知识的原始文件转写为带时间标记的文字，并保存为知识内容
+func (p *KnowledgeProcess) transcribeMedia(ctx context.Context, data *types.Knowledge) error {
+	if data.Meta.Blob == "" {
+		return fmt.Errorf("media of knowledge %s is missing", data.ID)
+	}
+	raw, err := p.core.Store().BlobStore().Get(ctx, data.SpaceID, data.Meta.Blob)
+	if err != nil {
+		return fmt.Errorf("failed to get media, %w", err)
+	}
+
+	// 转写服务根据文件后缀判断格式
+	result, err := p.core.Srv().AI().Transcribe(ctx, data.Meta.Filename, raw)
+	if err != nil {
+		return err
+	}
+
+	// 内容先行保存，重试时无需再次转写
+	data.Content = result.Content()
+	data.Meta.Duration = result.Duration
+	if err = p.core.Store().KnowledgeStore().Update(ctx, data.SpaceID, data.ID, types.UpdateKnowledgeArgs{
+		Content: data.Content,
+		Meta:    &data.Meta,
+	}); err != nil {
+		return fmt.Errorf("failed to save transcript, %w", err)
+	}
+	return nil
+}
+
+func (p *KnowledgeProcess) processSummary(ctx context.Context, data types.Knowledge) (err error) {
 	logAttrs := []any{
-		slog.String("space_id", req.data.SpaceID),
-		slog.String("knowledge_id", req.data.ID),
+		slog.String("space_id", data.SpaceID),
+		slog.String("knowledge_id", data.ID),
 		slog.String("component", "KnowledgeProcess.processSummary"),
 	}
 
 	slog.Info("Receive new summary request",
 		logAttrs...)
 
-	sw := mark.NewSensitiveWork()
-	content := sw.Do(req.data.Content)
-
-	var err error
 	defer func() {
 		slog.Info("Summary finished",
 			logAttrs...)
-		if req.response != nil {
-			req.response <- SummaryResponse{
-				Err: err,
-			}
-			close(req.response)
-		}
-		if err != nil {
-			ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-			defer cancel()
-			if err = p.core.Store().KnowledgeStore().SetRetryTimes(ctx, req.data.SpaceID, req.data.ID, req.data.RetryTimes+1); err != nil {
-				slog.Error("Failed to set knowledge process retry times",
-					append(logAttrs,
-						slog.String("stage", types.KNOWLEDGE_STAGE_SUMMARIZE.String()),
-						slog.String("error", err.Error()))...)
-			}
-		}
 	}()
 
-	ctx, cancel := context.WithTimeout(req.ctx, time.Minute*5)
+	ctx, cancel := context.WithTimeout(ctx, time.Minute*5)
 	defer cancel()
-	summary, err := p.core.Srv().AI().Chunk(ctx, &content)
+
+	// 图片知识的内容尚未识别时，先由视觉模型生成描述与图中文字，之后与文本知识一样分块
+	var image *ai.ImageResult
+	if data.Kind == types.KNOWLEDGE_KIND_IMAGE && data.Content == "" {
+		if image, err = p.describeImage(ctx, &data); err != nil {
+			slog.Error("Failed to describe image", append(logAttrs, slog.String("error", err.Error()))...)
+			return err
+		}
+	}
+	if data.Kind == types.KNOWLEDGE_KIND_VIDEO && data.Content == "" {
+		if err = p.transcribeMedia(ctx, &data); err != nil {
+			slog.Error("Failed to transcribe media", append(logAttrs, slog.String("error", err.Error()))...)
+			return err
+		}
+	}
+
+	detector, err := PrivacyDetector(ctx, p.core, data.SpaceID)
+	if err != nil {
+		slog.Error("Failed to get privacy detector", append(logAttrs, slog.String("error", err.Error()))...)
+		return err
+	}
+	sw := mark.NewSensitiveWork().WithDetector(detector)
+	content := sw.Do(data.Content)
+
+	var (
+		summary ai.ChunkResult
+		metas   []types.ChunkMeta
+		// llmChunked AI 分块的结果基于替换了敏感信息的内容
+		llmChunked bool
+	)
+	if data.Kind == types.KNOWLEDGE_KIND_VIDEO {
+		summary, metas, err = p.transcriptChunk(ctx, data, detector)
+	}
+	if err == nil && metas == nil {
+		if p.chunkMode(ctx, data) == types.CHUNK_MODE_LOCAL {
+			summary, err = p.localChunk(ctx, data, detector)
+		} else {
+			summary, err = p.core.Srv().AI().Chunk(ctx, &content)
+			llmChunked = true
+		}
+	}
 	if err != nil {
 		slog.Error("Failed to summarize knowledge", append(logAttrs, slog.String("error", err.Error()))...)
-		return
+		return err
 	}
 
-	slog.Debug("Knowledge summary result", slog.String("knowledge_id", req.data.ID), slog.String("space_id", req.data.SpaceID), slog.Any("result", summary))
+	slog.Debug("Knowledge summary result", slog.String("knowledge_id", data.ID), slog.String("space_id", data.SpaceID), slog.Any("result", summary))
+
+	// 视觉模型直接看到了图片，其给出的标题、标签与时间更准确
+	if image != nil {
+		if image.Title != "" {
+			summary.Title = image.Title
+		}
+		if len(image.Tags) > 0 {
+			summary.Tags = image.Tags
+		}
+		if image.DateTime != "" {
+			summary.DateTime = image.DateTime
+		}
+	}
 
 	if summary.DateTime == "" {
-		summary.DateTime = req.data.MaybeDate
+		summary.DateTime = data.MaybeDate
+	}
+
+	if llmChunked {
+		summary.Title = sw.Undo(summary.Title)
 	}
 
 	if len(summary.Chunks) == 0 {
-		summary.Chunks = append(summary.Chunks, req.data.Content)
-		// summary.Summary = req.data.Content
+		summary.Chunks = append(summary.Chunks, data.Content)
+		llmChunked = false
+		// summary.Summary = data.Content
 	} else {
 		// summary.Summary = sw.Undo(summary.Summary)
 	}
+	summary.Chunks = chunkContents(sw, summary.Chunks, llmChunked)
 
 	var chunks []types.KnowledgeChunk
-	for _, v := range summary.Chunks {
+	for i, v := range summary.Chunks {
+		var meta types.ChunkMeta
+		if i < len(metas) {
+			meta = metas[i]
+		}
 		chunks = append(chunks, types.KnowledgeChunk{
 			ID:             utils.GenRandomID(),
-			SpaceID:        req.data.SpaceID,
-			KnowledgeID:    req.data.ID,
-			UserID:         req.data.UserID,
-			Chunk:          sw.Undo(v),
-			OriginalLength: len([]rune(req.data.Content)),
+			SpaceID:        data.SpaceID,
+			KnowledgeID:    data.ID,
+			UserID:         data.UserID,
+			Chunk:          v,
+			OriginalLength: len([]rune(data.Content)),
+			Meta:           meta,
 			UpdatedAt:      time.Now().Unix(),
 			CreatedAt:      time.Now().Unix(),
 		})
 	}
 
-	if req.data.Summary != "" {
+	if data.Summary != "" {
 		needToUpdate := make(map[string]bool)
-		for _, v := range strings.Split(req.data.Summary, ",") {
+		for _, v := range strings.Split(data.Summary, ",") {
 			needToUpdate[v] = true
 		}
 
@@ -428,29 +710,60 @@ func (p *KnowledgeProcess) processSummary(req *SummaryRequest) {
 	// 	summary.Summary = fmt.Sprintf("%s\n%s\n%s", summary.Title, strings.Join(summary.Tags, ","), summary.Summary)
 	// }
 
-	p.core.Store().Transaction(req.ctx, func(ctx context.Context) error {
+	return p.core.Store().Transaction(ctx, func(ctx context.Context) error {
 		if len(chunks) > 0 {
-			if err = p.core.Store().KnowledgeChunkStore().BatchDelete(req.ctx, req.data.SpaceID, req.data.ID); err != nil {
+			if err = p.core.Store().KnowledgeChunkStore().BatchDelete(ctx, data.SpaceID, data.ID); err != nil {
 				slog.Error("Failed to pre-delete knowledge chunks", append(logAttrs, slog.String("error", err.Error()))...)
 				return err
 			}
 
-			if err = p.core.Store().KnowledgeChunkStore().BatchCreate(req.ctx, chunks); err != nil {
+			if err = p.core.Store().KnowledgeChunkStore().BatchCreate(ctx, chunks); err != nil {
 				slog.Error("Failed to create knowledge chunks", append(logAttrs, slog.String("error", err.Error()))...)
 				return err
 			}
 		}
 
-		if err = p.core.Store().KnowledgeStore().FinishedStageSummarize(req.ctx, req.data.SpaceID, req.data.ID, summary); err != nil {
+		if err = p.core.Store().KnowledgeStore().FinishedStageSummarize(ctx, data.SpaceID, data.ID, summary); err != nil {
 			slog.Error("Failed to set finished summary stage", append(logAttrs, slog.String("error", err.Error()))...)
 			return err
 		}
 
-		publishStageChangedMessage(p.core.Srv().Tower(), req.data.SpaceID, req.data.ID, types.KNOWLEDGE_STAGE_EMBEDDING)
+		title := data.Title
+		if summary.Title != "" {
+			title = summary.Title
+		}
+		if err = p.syncLinks(ctx, data, title); err != nil {
+			slog.Error("Failed to sync knowledge links", append(logAttrs, slog.String("error", err.Error()))...)
+			return err
+		}
+
+		publishStageChangedMessage(p.core.Srv().Tower(), data.SpaceID, data.ID, types.KNOWLEDGE_STAGE_EMBEDDING)
 		return nil
 	})
 }
 
+// publishProcessFailedMessage 通知空间成员知识处理失败，status 为 Pending 时任务会在稍后重试
+func publishProcessFailedMessage(tower *srv.Tower, job *types.KnowledgeJob, status types.KnowledgeJobStatus, cause error) {
+	fire := tower.NewFire(protocol.SourceSystem, tower.Pusher())
+	fire.Message = protocol.TopicMessage[srv.PublishData]{
+		Topic: "/knowledge/list/" + job.SpaceID,
+		Type:  protocol.PublishOperation,
+		Data: srv.PublishData{
+			Version: "v1",
+			Subject: "process_failed",
+			Data: map[string]string{
+				"knowledge_id": job.KnowledgeID,
+				"stage":        job.Stage.String(),
+				"status":       status.String(),
+				"attempts":     strconv.Itoa(job.Attempts),
+				"error":        cause.Error(),
+			},
+		},
+	}
+
+	tower.Publish(fire)
+}
+
 func publishStageChangedMessage(tower *srv.Tower, spaceID, knowledgeID string, stage types.KnowledgeStage) {
 	fire := tower.NewFire(protocol.SourceSystem, tower.Pusher())
 	fire.Message = protocol.TopicMessage[srv.PublishData]{
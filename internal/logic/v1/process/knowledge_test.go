@@ -0,0 +1,34 @@
+package process
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/starbx/brew-api/pkg/mark"
+)
+
+func TestChunkContentsAcrossModes(t *testing.T) {
+	detector, err := mark.NewDetector([]string{mark.PII_PHONE}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "call 13812345678 when the disk is full\n\nthe vpn password is $hidden[s3cret]"
+	split := func(text string) []string {
+		return strings.Split(text, "\n\n")
+	}
+
+	// AI 分块时模型看到的是替换后的内容，返回的分块同样是替换后的内容
+	llmSW := mark.NewSensitiveWork().WithDetector(detector)
+	llm := chunkContents(llmSW, split(llmSW.Do(content)), true)
+
+	// 本地分块直接基于原文
+	local := chunkContents(mark.NewSensitiveWork().WithDetector(detector), split(content), false)
+
+	assert.Equal(t, llm, local)
+	assert.Equal(t, []string{
+		"call $hidden[13812345678] when the disk is full",
+		"the vpn password is $hidden[s3cret]",
+	}, local)
+}
@@ -0,0 +1,78 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/starbx/brew-api/pkg/mark"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+// syncLinks 解析知识内容中的 [[knowledge-id]] / [[title]] 链接并写入链接表，
+// 同时将空间中尚未关联、且标题与该知识一致的链接关联到该知识，需要在事务中调用
+func (p *KnowledgeProcess) syncLinks(ctx context.Context, data types.Knowledge, title string) error {
+	targets := mark.ParseWikiLinks(data.Content, types.MAX_KNOWLEDGE_LINKS)
+
+	var links []types.KnowledgeLink
+	if len(targets) > 0 {
+		byID, err := p.core.Store().KnowledgeStore().ListLiteKnowledges(ctx, types.GetKnowledgeOptions{
+			SpaceID: data.SpaceID,
+			IDs:     targets,
+		}, types.NO_PAGING, types.NO_PAGING)
+		if err != nil {
+			return fmt.Errorf("failed to list linked knowledges by id, %w", err)
+		}
+		byTitle, err := p.core.Store().KnowledgeStore().ListLiteKnowledges(ctx, types.GetKnowledgeOptions{
+			SpaceID: data.SpaceID,
+			Titles:  targets,
+		}, types.NO_PAGING, types.NO_PAGING)
+		if err != nil {
+			return fmt.Errorf("failed to list linked knowledges by title, %w", err)
+		}
+
+		ids := make(map[string]bool)
+		for _, v := range byID {
+			ids[v.ID] = true
+		}
+		// 存在同名知识时取 ID 最小的一条，保证每次解析结果一致
+		sort.Slice(byTitle, func(i, j int) bool {
+			return byTitle[i].ID < byTitle[j].ID
+		})
+		titles := make(map[string]string)
+		for _, v := range byTitle {
+			if _, exist := titles[v.Title]; !exist {
+				titles[v.Title] = v.ID
+			}
+		}
+
+		for _, target := range targets {
+			switch {
+			case ids[target]:
+				links = append(links, types.KnowledgeLink{TargetID: target})
+			case titles[target] != "":
+				links = append(links, types.KnowledgeLink{TargetID: titles[target], Title: target})
+			default:
+				links = append(links, types.KnowledgeLink{Title: target})
+			}
+		}
+		// 忽略指向自身的链接
+		for i := len(links) - 1; i >= 0; i-- {
+			if links[i].TargetID == data.ID {
+				links = append(links[:i], links[i+1:]...)
+			}
+		}
+	}
+
+	if err := p.core.Store().KnowledgeLinkStore().Replace(ctx, data.SpaceID, data.ID, links); err != nil {
+		return fmt.Errorf("failed to replace knowledge links, %w", err)
+	}
+
+	if title == "" {
+		return nil
+	}
+	if _, err := p.core.Store().KnowledgeLinkStore().ResolveTitle(ctx, data.SpaceID, title, data.ID); err != nil {
+		return fmt.Errorf("failed to resolve title links, %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package process
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/starbx/brew-api/internal/core"
+	"github.com/starbx/brew-api/pkg/mark"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+// BuildPrivacyDetector 合并服务与空间的配置创建敏感信息识别器，空间未设置识别类型时使用服务的默认类型
+func BuildPrivacyDetector(cfg core.Privacy, settings types.PrivacySettings) (*mark.Detector, error) {
+	if settings.Disabled {
+		return nil, nil
+	}
+	kinds := cfg.Detect
+	if len(settings.Detect) > 0 {
+		kinds = settings.Detect
+	}
+	return mark.NewDetector(kinds, append(append([]string{}, cfg.Patterns...), settings.Patterns...))
+}
+
+// PRIVACY_DETECTOR_CACHE_TTL 空间识别器的缓存时间，其他实例修改配置后最多经过该时间生效
+const PRIVACY_DETECTOR_CACHE_TTL = time.Minute
+
+type cachedDetector struct {
+	detector *mark.Detector
+	expireAt time.Time
+}
+
+var detectorCache = struct {
+	mu    sync.Mutex
+	items map[string]cachedDetector
+}{items: make(map[string]cachedDetector)}
+
+// PrivacyDetector 获取空间生效的敏感信息识别器，未启用自动识别时返回 nil
+// 识别器按空间缓存，避免每次请求都读取空间配置并重新编译正则
+func PrivacyDetector(ctx context.Context, app *core.Core, spaceID string) (*mark.Detector, error) {
+	detectorCache.mu.Lock()
+	cached, ok := detectorCache.items[spaceID]
+	detectorCache.mu.Unlock()
+	if ok && time.Now().Before(cached.expireAt) {
+		return cached.detector, nil
+	}
+
+	space, err := app.Store().SpaceStore().GetSpace(ctx, spaceID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get space, %w", err)
+	}
+
+	var settings types.PrivacySettings
+	if space != nil {
+		settings = space.Privacy
+	}
+	detector, err := BuildPrivacyDetector(app.Cfg().Privacy, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	detectorCache.mu.Lock()
+	detectorCache.items[spaceID] = cachedDetector{detector: detector, expireAt: time.Now().Add(PRIVACY_DETECTOR_CACHE_TTL)}
+	detectorCache.mu.Unlock()
+	return detector, nil
+}
+
+// ForgetPrivacyDetector 清除空间识别器的缓存，空间修改配置或删除后调用
+func ForgetPrivacyDetector(spaceID string) {
+	detectorCache.mu.Lock()
+	delete(detectorCache.items, spaceID)
+	detectorCache.mu.Unlock()
+}
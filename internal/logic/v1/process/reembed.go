@@ -0,0 +1,167 @@
+package process
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/pgvector/pgvector-go"
+	"github.com/samber/lo"
+
+	"github.com/starbx/brew-api/internal/core"
+	"github.com/starbx/brew-api/internal/core/srv"
+	"github.com/starbx/brew-api/pkg/mark"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+const (
+	// 每批重新生成向量的分块数量
+	REEMBED_BATCH_SIZE = 32
+)
+
+// Reembed 使用指定 driver 的模型为已完成处理的知识分块生成向量，与原有模型的向量共存直到 Prune
+// 进度由数据本身记录，已生成目标模型向量的分块会被跳过，中断后再次执行即可继续
+// 切换模型的步骤：配置新 driver 后执行 Reembed，再将 embedding.query 与 embedding.document 切换到新 driver，
+// 再次执行 Reembed 补齐切换期间写入的知识，最后使用 Prune 删除旧模型的向量
+func Reembed(ctx context.Context, app *core.Core, opts types.ReembedOptions, report func(types.ReembedProgress)) (types.ReembedProgress, error) {
+	var progress types.ReembedProgress
+
+	embedder, model, err := app.Srv().AI().DocumentEmbedding(opts.Driver)
+	if err != nil {
+		return progress, err
+	}
+	progress.Model = model
+
+	if opts.AdoptLegacy {
+		if progress.Adopted, err = app.Store().VectorStore().AdoptLegacy(ctx, opts.SpaceID, model); err != nil {
+			return progress, fmt.Errorf("failed to adopt legacy vectors, %w", err)
+		}
+	}
+
+	if progress.Total, err = app.Store().KnowledgeChunkStore().TotalWithoutVector(ctx, opts.SpaceID, model); err != nil {
+		return progress, fmt.Errorf("failed to count chunks without vector, %w", err)
+	}
+	report(progress)
+
+	var (
+		cursor   string
+		previous map[string]bool
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return progress, ctx.Err()
+		default:
+		}
+
+		chunks, err := app.Store().KnowledgeChunkStore().ListWithoutVector(ctx, opts.SpaceID, model, cursor, REEMBED_BATCH_SIZE)
+		if err != nil && err != sql.ErrNoRows {
+			return progress, fmt.Errorf("failed to list chunks without vector, %w", err)
+		}
+		if len(chunks) == 0 {
+			break
+		}
+
+		// 与上一批完全相同说明游标没有前进，继续执行只会重复生成同一批向量
+		ids := lo.Map(chunks, func(item types.KnowledgeChunk, _ int) string {
+			return item.ID
+		})
+		if lo.EveryBy(ids, func(id string) bool { return previous[id] }) {
+			return progress, fmt.Errorf("reembed made no progress after chunk %s", cursor)
+		}
+		previous = lo.SliceToMap(ids, func(id string) (string, bool) { return id, true })
+		cursor = ids[len(ids)-1]
+
+		// 使用 postgres 以外的向量存储时，ListWithoutVector 无法排除已生成向量的分块
+		exist, err := app.Store().VectorStore().ExistIDs(ctx, model, ids)
+		if err != nil {
+			return progress, fmt.Errorf("failed to check existing vectors, %w", err)
+		}
+		pending := lo.Reject(chunks, func(item types.KnowledgeChunk, _ int) bool {
+			return lo.Contains(exist, item.ID)
+		})
+		progress.Skipped += int64(len(chunks) - len(pending))
+
+		if len(pending) > 0 {
+			if err = reembedChunks(ctx, app, embedder, model, pending); err != nil {
+				return progress, err
+			}
+			progress.Embedded += int64(len(pending))
+		}
+		report(progress)
+	}
+
+	// 切换模型前为新模型建立索引，建立失败时检索仍可进行，只是需要扫描全表
+	if err = app.Store().VectorStore().EnsureIndex(ctx, model); err != nil {
+		slog.Error("Failed to create vector index", slog.String("model", model), slog.String("error", err.Error()))
+	}
+
+	if opts.Prune {
+		if progress.Pruned, err = app.Store().VectorStore().PruneModels(ctx, opts.SpaceID, model); err != nil {
+			return progress, fmt.Errorf("failed to prune vectors of other models, %w", err)
+		}
+		report(progress)
+	}
+	return progress, nil
+}
+
+func reembedChunks(ctx context.Context, app *core.Core, embedder srv.EmbeddingAI, model string, chunks []types.KnowledgeChunk) error {
+	knowledges, err := app.Store().KnowledgeStore().ListLiteKnowledges(ctx, types.GetKnowledgeOptions{
+		IDs: lo.Uniq(lo.Map(chunks, func(item types.KnowledgeChunk, _ int) string {
+			return item.KnowledgeID
+		})),
+	}, types.NO_PAGING, types.NO_PAGING)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to list knowledges of chunks, %w", err)
+	}
+	resources := lo.SliceToMap(knowledges, func(item *types.KnowledgeLite) (string, string) {
+		return item.ID, item.Resource
+	})
+
+	// 分块可能来自不同的空间，按各自空间的配置识别敏感信息
+	detectors := make(map[string]*mark.Detector)
+	contents := make([]string, 0, len(chunks))
+	for _, v := range chunks {
+		detector, ok := detectors[v.SpaceID]
+		if !ok {
+			if detector, err = PrivacyDetector(ctx, app, v.SpaceID); err != nil {
+				return err
+			}
+			detectors[v.SpaceID] = detector
+		}
+		contents = append(contents, mark.NewSensitiveWork().WithDetector(detector).Do(v.Chunk))
+	}
+
+	results, err := embedder.EmbeddingForDocument(ctx, "", contents)
+	if err != nil {
+		return fmt.Errorf("failed to embedding chunks, %w", err)
+	}
+	if len(results) != len(chunks) {
+		return fmt.Errorf("embedding results count %d not matched chunks count %d", len(results), len(chunks))
+	}
+
+	now := time.Now().Unix()
+	vectors := make([]types.Vector, 0, len(chunks))
+	for i, v := range chunks {
+		vectors = append(vectors, types.Vector{
+			ID:             v.ID,
+			KnowledgeID:    v.KnowledgeID,
+			SpaceID:        v.SpaceID,
+			UserID:         v.UserID,
+			Resource:       resources[v.KnowledgeID],
+			Embedding:      pgvector.NewVector(results[i]),
+			Model:          model,
+			Dim:            len(results[i]),
+			OriginalLength: v.OriginalLength,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		})
+	}
+
+	if err = app.Store().VectorStore().BatchCreate(ctx, vectors); err != nil {
+		return fmt.Errorf("failed to create vectors, %w", err)
+	}
+	return nil
+}
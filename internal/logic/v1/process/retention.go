@@ -0,0 +1,92 @@
+package process
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/starbx/brew-api/internal/core"
+	"github.com/starbx/brew-api/pkg/safe"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+const (
+	// 每批处理的过期知识数量
+	RETENTION_BATCH_SIZE = 100
+)
+
+// StartRetentionProcess 定时清理超出所属资源保留周期的知识
+func StartRetentionProcess(app *core.Core) context.CancelFunc {
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg := app.Cfg().Retention
+
+	go safe.Run(func() {
+		ticker := time.NewTicker(cfg.IntervalDuration())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				total, err := ApplyRetention(ctx, app, time.Now().Unix())
+				if err != nil {
+					slog.Error("Failed to apply resource retention", slog.String("error", err.Error()))
+				}
+				if total > 0 {
+					slog.Info("Resource retention applied", slog.String("mode", cfg.RetentionMode()), slog.Int("length", total))
+				}
+			}
+		}
+	})
+	return cancel
+}
+
+// ApplyRetention 按配置归档或删除在 now 时已超出所属资源保留周期的知识，返回处理的数量
+func ApplyRetention(ctx context.Context, app *core.Core, now int64) (int, error) {
+	mode := app.Cfg().Retention.RetentionMode()
+
+	total := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return total, nil
+		default:
+		}
+
+		list, err := app.Store().KnowledgeStore().ListLiteKnowledges(ctx, types.GetKnowledgeOptions{
+			ExpiredAt: now,
+		}, 1, RETENTION_BATCH_SIZE)
+		if err != nil && err != sql.ErrNoRows {
+			return total, fmt.Errorf("failed to list expired knowledges, %w", err)
+		}
+		if len(list) == 0 {
+			return total, nil
+		}
+
+		for _, v := range list {
+			if err = expireKnowledge(ctx, app, mode, v.SpaceID, v.ID); err != nil {
+				return total, fmt.Errorf("failed to expire knowledge %s, %w", v.ID, err)
+			}
+			total++
+		}
+
+		if len(list) < RETENTION_BATCH_SIZE {
+			return total, nil
+		}
+	}
+}
+
+// expireKnowledge 归档时保留知识内容，只删除参与检索的分块与向量
+func expireKnowledge(ctx context.Context, app *core.Core, mode, spaceID, id string) error {
+	return app.Store().Transaction(ctx, func(ctx context.Context) error {
+		if mode == core.RETENTION_MODE_DELETE {
+			return DeleteKnowledge(ctx, app, spaceID, id)
+		}
+		if err := app.Store().KnowledgeStore().Archive(ctx, spaceID, id); err != nil {
+			return fmt.Errorf("failed to archive knowledge, %w", err)
+		}
+		return clearKnowledgeIndex(ctx, app, spaceID, id)
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/starbx/brew-api/internal/core"
+	"github.com/starbx/brew-api/pkg/ai"
 	"github.com/starbx/brew-api/pkg/errors"
 	"github.com/starbx/brew-api/pkg/i18n"
 	"github.com/starbx/brew-api/pkg/types"
@@ -30,7 +31,16 @@ func NewResourceLogic(ctx context.Context, core *core.Core) *ResourceLogic {
 	return l
 }
 
-func (l *ResourceLogic) CreateResource(spaceID, id, title, desc, prompt string, cycle int) error {
+func checkChunkMode(mode string) error {
+	switch mode {
+	case "", types.CHUNK_MODE_LLM, types.CHUNK_MODE_LOCAL:
+		return nil
+	default:
+		return errors.New("ResourceLogic.checkChunkMode", i18n.ERROR_INVALIDARGUMENT, fmt.Errorf("unknown chunk mode %s", mode)).Code(http.StatusBadRequest)
+	}
+}
+
+func (l *ResourceLogic) CreateResource(spaceID, id, title, desc, prompt, chunkMode string, cycle int, retrieval *types.RetrievalSettings) error {
 	if !utils.IsAlphabetic(id) {
 		return errors.New("ResourceLogic.CreateResource.ID.IsAlphabetic", i18n.ERROR_INVALIDARGUMENT, fmt.Errorf("resource id is not alphabetic")).Code(http.StatusBadRequest)
 	}
@@ -42,6 +52,21 @@ func (l *ResourceLogic) CreateResource(spaceID, id, title, desc, prompt string,
 		return errors.New("ResourceLogic.CreateResource.InvalidWord", i18n.ERROR_EXIST, nil).Code(http.StatusForbidden)
 	}
 
+	if err := checkChunkMode(chunkMode); err != nil {
+		return errors.Trace("ResourceLogic.CreateResource", err)
+	}
+
+	if err := ai.ValidatePromptTemplate(prompt); err != nil {
+		return errors.New("ResourceLogic.CreateResource.ai.ValidatePromptTemplate", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest)
+	}
+
+	if retrieval == nil {
+		retrieval = &types.RetrievalSettings{}
+	}
+	if err := retrieval.Validate(); err != nil {
+		return errors.New("ResourceLogic.CreateResource.retrieval.Validate", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest)
+	}
+
 	exist, err := l.core.Store().ResourceStore().GetResource(l.ctx, spaceID, id)
 	if err != nil && err != sql.ErrNoRows {
 		return errors.New("ResourceLogic.CreateResource.ResourceStore.GetResource", i18n.ERROR_INTERNAL, err)
@@ -58,7 +83,9 @@ func (l *ResourceLogic) CreateResource(spaceID, id, title, desc, prompt string,
 		Title:       title,
 		Description: desc,
 		Prompt:      prompt,
+		ChunkMode:   chunkMode,
 		Cycle:       cycle,
+		Retrieval:   *retrieval,
 		CreatedAt:   time.Now().Unix(),
 	})
 	if err != nil {
@@ -76,12 +103,35 @@ func (l *ResourceLogic) Delete(spaceID, id string) error {
 	return nil
 }
 
-func (l *ResourceLogic) Update(spaceID, id, title, desc, prompt string, cycle int) error {
-	err := l.core.Store().ResourceStore().Update(l.ctx, spaceID, id, title, desc, prompt, cycle)
-	if err != nil {
-		return errors.New("ResourceLogic.Update.ResourceStore.Update", i18n.ERROR_INTERNAL, err)
+// Update 更新资源信息，retrieval 为空时保留原有的检索配置
+func (l *ResourceLogic) Update(spaceID, id, title, desc, prompt, chunkMode string, cycle int, retrieval *types.RetrievalSettings) error {
+	if err := checkChunkMode(chunkMode); err != nil {
+		return errors.Trace("ResourceLogic.Update", err)
 	}
-	return nil
+
+	if err := ai.ValidatePromptTemplate(prompt); err != nil {
+		return errors.New("ResourceLogic.Update.ai.ValidatePromptTemplate", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest)
+	}
+
+	if retrieval != nil {
+		if err := retrieval.Validate(); err != nil {
+			return errors.New("ResourceLogic.Update.retrieval.Validate", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest)
+		}
+	}
+
+	return l.core.Store().Transaction(l.ctx, func(ctx context.Context) error {
+		err := l.core.Store().ResourceStore().Update(ctx, spaceID, id, title, desc, prompt, chunkMode, cycle)
+		if err != nil {
+			return errors.New("ResourceLogic.Update.ResourceStore.Update", i18n.ERROR_INTERNAL, err)
+		}
+
+		if retrieval != nil {
+			if err = l.core.Store().ResourceStore().UpdateRetrieval(ctx, spaceID, id, *retrieval); err != nil {
+				return errors.New("ResourceLogic.Update.ResourceStore.UpdateRetrieval", i18n.ERROR_INTERNAL, err)
+			}
+		}
+		return nil
+	})
 }
 
 func (l *ResourceLogic) ListSpaceResources(spaceID string) ([]types.Resource, error) {
@@ -112,3 +162,26 @@ func (l *ResourceLogic) GetResource(spaceID, id string) (*types.Resource, error)
 	}
 	return data, nil
 }
+
+// RetentionReport 预览空间中已超出所属资源保留周期、下次清理时将被归档或删除的知识
+func (l *ResourceLogic) RetentionReport(spaceID string, page, pageSize uint64) (*types.RetentionReport, error) {
+	opts := types.GetKnowledgeOptions{
+		SpaceID:   spaceID,
+		ExpiredAt: time.Now().Unix(),
+	}
+	list, err := l.core.Store().KnowledgeStore().ListLiteKnowledges(l.ctx, opts, page, pageSize)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("ResourceLogic.RetentionReport.KnowledgeStore.ListLiteKnowledges", i18n.ERROR_INTERNAL, err)
+	}
+
+	total, err := l.core.Store().KnowledgeStore().Total(l.ctx, opts)
+	if err != nil {
+		return nil, errors.New("ResourceLogic.RetentionReport.KnowledgeStore.Total", i18n.ERROR_INTERNAL, err)
+	}
+
+	return &types.RetentionReport{
+		Mode:  l.core.Cfg().Retention.RetentionMode(),
+		Total: total,
+		List:  list,
+	}, nil
+}
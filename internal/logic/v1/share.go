@@ -0,0 +1,312 @@
+package v1
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/starbx/brew-api/internal/core"
+	"github.com/starbx/brew-api/internal/core/srv"
+	"github.com/starbx/brew-api/pkg/errors"
+	"github.com/starbx/brew-api/pkg/i18n"
+	"github.com/starbx/brew-api/pkg/mark"
+	"github.com/starbx/brew-api/pkg/security"
+	"github.com/starbx/brew-api/pkg/types"
+	"github.com/starbx/brew-api/pkg/utils"
+)
+
+// SHARE_TOKEN_SIZE 分享token的随机字节数
+const SHARE_TOKEN_SIZE = 24
+
+// logic for unlogin，查看分享的内容无需登录
+type ShareLogic struct {
+	ctx  context.Context
+	core *core.Core
+}
+
+func NewShareLogic(ctx context.Context, core *core.Core) *ShareLogic {
+	return &ShareLogic{
+		ctx:  ctx,
+		core: core,
+	}
+}
+
+// getShare 获取可用的分享并校验访问密码，已过期、已撤销的分享与不存在的分享一样返回 404
+func (l *ShareLogic) getShare(token, password string) (*types.ShareToken, error) {
+	share, err := l.core.Store().ShareTokenStore().Get(l.ctx, token)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("ShareLogic.getShare.ShareTokenStore.Get", i18n.ERROR_INTERNAL, err)
+	}
+	if share == nil || share.Expired(time.Now().Unix()) {
+		return nil, errors.New("ShareLogic.getShare.nil", i18n.ERROR_NOTFOUND, nil).Code(http.StatusNotFound)
+	}
+
+	// 查看分享无需登录，比较哈希时耗时与内容无关，避免逐字节猜测
+	if share.Password != "" && subtle.ConstantTimeCompare([]byte(share.Password), []byte(utils.GenUserPassword(share.Salt, password))) != 1 {
+		return nil, errors.New("ShareLogic.getShare.password", i18n.ERROR_LOGIC_SHARE_PASSWORD, nil).Code(http.StatusUnauthorized)
+	}
+	return share, nil
+}
+
+// masker 登录用户为作者本人时原样展示，否则隐藏 $hidden[] 中的内容
+func (l *ShareLogic) masker(authorID string) func(string) string {
+	if viewer, ok := InjectTokenClaim(l.ctx); ok && viewer.User != "" && viewer.User == authorID {
+		return func(s string) string { return s }
+	}
+	return mark.MaskHidden
+}
+
+// GetSharedContent 通过分享token获取分享的知识或会话
+func (l *ShareLogic) GetSharedContent(token, password string) (*types.SharedContent, error) {
+	share, err := l.getShare(token, password)
+	if err != nil {
+		return nil, errors.Trace("ShareLogic.GetSharedContent", err)
+	}
+
+	result := &types.SharedContent{
+		Kind:      share.Kind,
+		ExpiredAt: share.ExpiredAt,
+	}
+	switch share.Kind {
+	case types.SHARE_KIND_KNOWLEDGE:
+		knowledge, err := l.getKnowledge(share)
+		if err != nil {
+			return nil, errors.Trace("ShareLogic.GetSharedContent", err)
+		}
+		mask := l.masker(knowledge.UserID)
+		result.Knowledge = &types.SharedKnowledge{
+			ID:        knowledge.ID,
+			Kind:      knowledge.Kind,
+			Title:     mask(knowledge.Title),
+			Tags:      knowledge.Tags,
+			Content:   mask(knowledge.Content),
+			MaybeDate: knowledge.MaybeDate,
+			CreatedAt: knowledge.CreatedAt,
+			UpdatedAt: knowledge.UpdatedAt,
+		}
+		if knowledge.Meta.Blob != "" {
+			result.Knowledge.MimeType = knowledge.Meta.MimeType
+		}
+	case types.SHARE_KIND_SESSION:
+		session, err := l.getSession(share)
+		if err != nil {
+			return nil, errors.Trace("ShareLogic.GetSharedContent", err)
+		}
+		result.Session = session
+	default:
+		return nil, errors.New("ShareLogic.GetSharedContent.Kind", i18n.ERROR_NOTFOUND, nil).Code(http.StatusNotFound)
+	}
+	return result, nil
+}
+
+// GetSharedBlob 通过分享token获取分享的知识的原始文件
+func (l *ShareLogic) GetSharedBlob(token, password string) ([]byte, string, error) {
+	share, err := l.getShare(token, password)
+	if err != nil {
+		return nil, "", errors.Trace("ShareLogic.GetSharedBlob", err)
+	}
+	if share.Kind != types.SHARE_KIND_KNOWLEDGE {
+		return nil, "", errors.New("ShareLogic.GetSharedBlob.Kind", i18n.ERROR_NOTFOUND, nil).Code(http.StatusNotFound)
+	}
+
+	knowledge, err := l.getKnowledge(share)
+	if err != nil {
+		return nil, "", errors.Trace("ShareLogic.GetSharedBlob", err)
+	}
+	if knowledge.Meta.Blob == "" {
+		return nil, "", errors.New("ShareLogic.GetSharedBlob", i18n.ERROR_NOTFOUND, nil).Code(http.StatusNotFound)
+	}
+
+	data, err := l.core.Store().BlobStore().Get(l.ctx, share.SpaceID, knowledge.Meta.Blob)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", errors.New("ShareLogic.GetSharedBlob.BlobStore.Get", i18n.ERROR_NOTFOUND, nil).Code(http.StatusNotFound)
+		}
+		return nil, "", errors.New("ShareLogic.GetSharedBlob.BlobStore.Get", i18n.ERROR_INTERNAL, err)
+	}
+	return data, knowledge.Meta.MimeType, nil
+}
+
+func (l *ShareLogic) getKnowledge(share *types.ShareToken) (*types.Knowledge, error) {
+	knowledge, err := l.core.Store().KnowledgeStore().GetKnowledge(l.ctx, share.SpaceID, share.ObjectID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("ShareLogic.getKnowledge.KnowledgeStore.GetKnowledge", i18n.ERROR_INTERNAL, err)
+	}
+	if knowledge == nil {
+		return nil, errors.New("ShareLogic.getKnowledge.KnowledgeStore.GetKnowledge.nil", i18n.ERROR_NOTFOUND, nil).Code(http.StatusNotFound)
+	}
+	return knowledge, nil
+}
+
+func (l *ShareLogic) getSession(share *types.ShareToken) (*types.SharedSession, error) {
+	session, err := l.core.Store().ChatSessionStore().GetChatSession(l.ctx, share.SpaceID, share.ObjectID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("ShareLogic.getSession.ChatSessionStore.GetChatSession", i18n.ERROR_INTERNAL, err)
+	}
+	if session == nil {
+		return nil, errors.New("ShareLogic.getSession.ChatSessionStore.GetChatSession.nil", i18n.ERROR_NOTFOUND, nil).Code(http.StatusNotFound)
+	}
+
+	list, err := l.core.Store().ChatMessageStore().ListSessionMessage(l.ctx, share.SpaceID, share.ObjectID, "", 1, types.MAX_SHARE_SESSION_MESSAGES)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("ShareLogic.getSession.ChatMessageStore.ListSessionMessage", i18n.ERROR_INTERNAL, err)
+	}
+
+	mask := l.masker(session.UserID)
+	result := &types.SharedSession{
+		ID:        session.ID,
+		Title:     mask(session.Title),
+		CreatedAt: session.CreatedAt,
+		Messages:  make([]*types.SharedMessage, 0, len(list)),
+	}
+	// ListSessionMessage 按发送时间倒序返回，展示时按对话顺序排列
+	for i := len(list) - 1; i >= 0; i-- {
+		v := list[i]
+		// 生成中或生成失败的回答不展示
+		if v.Role == types.USER_ROLE_ASSISTANT && v.Complete != types.MESSAGE_PROGRESS_COMPLETE {
+			continue
+		}
+		result.Messages = append(result.Messages, &types.SharedMessage{
+			ID:       v.ID,
+			Role:     v.Role,
+			Message:  mask(v.Message),
+			SendTime: v.SendTime,
+		})
+	}
+	return result, nil
+}
+
+type AuthedShareLogic struct {
+	ctx  context.Context
+	core *core.Core
+	UserInfo
+}
+
+func NewAuthedShareLogic(ctx context.Context, core *core.Core) *AuthedShareLogic {
+	return &AuthedShareLogic{
+		ctx:      ctx,
+		core:     core,
+		UserInfo: setupUserInfo(ctx, core),
+	}
+}
+
+// CreateShare 为知识或会话创建公开只读的分享链接，知识需要编辑权限，会话只能由发起人分享
+// password 为空时无需密码即可查看，expiredAt 为0时永不过期
+func (l *AuthedShareLogic) CreateShare(spaceID string, kind types.ShareKind, objectID, password string, expiredAt int64) (*types.ShareToken, error) {
+	if expiredAt != 0 && expiredAt <= time.Now().Unix() {
+		return nil, errors.New("AuthedShareLogic.CreateShare.expiredAt", i18n.ERROR_INVALIDARGUMENT, nil).Code(http.StatusBadRequest)
+	}
+
+	user := l.GetUserInfo()
+	switch kind {
+	case types.SHARE_KIND_KNOWLEDGE:
+		knowledge, err := l.core.Store().KnowledgeStore().GetKnowledge(l.ctx, spaceID, objectID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, errors.New("AuthedShareLogic.CreateShare.KnowledgeStore.GetKnowledge", i18n.ERROR_INTERNAL, err)
+		}
+		if knowledge == nil {
+			return nil, errors.New("AuthedShareLogic.CreateShare.KnowledgeStore.GetKnowledge.nil", i18n.ERROR_NOTFOUND, nil).Code(http.StatusNotFound)
+		}
+		if err = l.core.Srv().RBAC().Check(user, l.lazyRolerFromKnowledgeID(spaceID, objectID), srv.PermissionEdit); err != nil {
+			return nil, errors.Trace("AuthedShareLogic.CreateShare", err)
+		}
+	case types.SHARE_KIND_SESSION:
+		session, err := l.core.Store().ChatSessionStore().GetChatSession(l.ctx, spaceID, objectID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, errors.New("AuthedShareLogic.CreateShare.ChatSessionStore.GetChatSession", i18n.ERROR_INTERNAL, err)
+		}
+		if session == nil {
+			return nil, errors.New("AuthedShareLogic.CreateShare.ChatSessionStore.GetChatSession.nil", i18n.ERROR_NOTFOUND, nil).Code(http.StatusNotFound)
+		}
+		if session.UserID != user.User {
+			return nil, errors.New("AuthedShareLogic.CreateShare.session.UserID", i18n.ERROR_PERMISSION_DENIED, nil).Code(http.StatusForbidden)
+		}
+	default:
+		return nil, errors.New("AuthedShareLogic.CreateShare.kind", i18n.ERROR_INVALIDARGUMENT, nil).Code(http.StatusBadRequest)
+	}
+
+	token, err := security.GenRandomToken(SHARE_TOKEN_SIZE)
+	if err != nil {
+		return nil, errors.New("AuthedShareLogic.CreateShare.GenRandomToken", i18n.ERROR_INTERNAL, err)
+	}
+
+	share := types.ShareToken{
+		Token:     token,
+		SpaceID:   spaceID,
+		Kind:      kind,
+		ObjectID:  objectID,
+		UserID:    user.User,
+		ExpiredAt: expiredAt,
+		CreatedAt: time.Now().Unix(),
+	}
+	if password != "" {
+		share.Salt = utils.RandomStr(10)
+		share.Password = utils.GenUserPassword(share.Salt, password)
+		share.Protected = true
+	}
+
+	if err = l.core.Store().ShareTokenStore().Create(l.ctx, share); err != nil {
+		return nil, errors.New("AuthedShareLogic.CreateShare.ShareTokenStore.Create", i18n.ERROR_INTERNAL, err)
+	}
+	return &share, nil
+}
+
+// isSpaceAdmin 空间管理员可以查看与撤销空间中的全部分享
+func (l *AuthedShareLogic) isSpaceAdmin(spaceID string) (bool, error) {
+	userSpace, err := l.core.Store().UserSpaceStore().GetUserSpaceRole(l.ctx, l.GetUserInfo().User, spaceID)
+	if err != nil && err != sql.ErrNoRows {
+		return false, errors.New("AuthedShareLogic.isSpaceAdmin.UserSpaceStore.GetUserSpaceRole", i18n.ERROR_INTERNAL, err)
+	}
+	return userSpace != nil && l.core.Srv().RBAC().CheckPermission(userSpace.Role, srv.PermissionAdmin), nil
+}
+
+// ListShares 获取空间中的分享，空间管理员可以看到全部分享，其他成员只能看到自己创建的分享
+func (l *AuthedShareLogic) ListShares(spaceID string, kind types.ShareKind, objectID string) ([]types.ShareToken, error) {
+	isAdmin, err := l.isSpaceAdmin(spaceID)
+	if err != nil {
+		return nil, errors.Trace("AuthedShareLogic.ListShares", err)
+	}
+
+	list, err := l.core.Store().ShareTokenStore().List(l.ctx, spaceID, kind, objectID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("AuthedShareLogic.ListShares.ShareTokenStore.List", i18n.ERROR_INTERNAL, err)
+	}
+
+	result := make([]types.ShareToken, 0, len(list))
+	for _, v := range list {
+		if !isAdmin && v.UserID != l.GetUserInfo().User {
+			continue
+		}
+		v.Protected = v.Password != ""
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+// RevokeShare 撤销分享，撤销后链接立即失效，只有分享的创建者与空间管理员可以撤销
+func (l *AuthedShareLogic) RevokeShare(spaceID, token string) error {
+	share, err := l.core.Store().ShareTokenStore().Get(l.ctx, token)
+	if err != nil && err != sql.ErrNoRows {
+		return errors.New("AuthedShareLogic.RevokeShare.ShareTokenStore.Get", i18n.ERROR_INTERNAL, err)
+	}
+	if share == nil || share.SpaceID != spaceID {
+		return errors.New("AuthedShareLogic.RevokeShare.ShareTokenStore.Get.nil", i18n.ERROR_NOTFOUND, nil).Code(http.StatusNotFound)
+	}
+
+	if share.UserID != l.GetUserInfo().User {
+		isAdmin, err := l.isSpaceAdmin(spaceID)
+		if err != nil {
+			return errors.Trace("AuthedShareLogic.RevokeShare", err)
+		}
+		if !isAdmin {
+			return errors.New("AuthedShareLogic.RevokeShare.isSpaceAdmin", i18n.ERROR_PERMISSION_DENIED, nil).Code(http.StatusForbidden)
+		}
+	}
+
+	if err = l.core.Store().ShareTokenStore().Delete(l.ctx, spaceID, token); err != nil {
+		return errors.New("AuthedShareLogic.RevokeShare.ShareTokenStore.Delete", i18n.ERROR_INTERNAL, err)
+	}
+	return nil
+}
@@ -3,11 +3,13 @@ package v1
 import (
 	"context"
 	"database/sql"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/starbx/brew-api/internal/core"
 	"github.com/starbx/brew-api/internal/core/srv"
+	"github.com/starbx/brew-api/internal/logic/v1/archive"
 	"github.com/starbx/brew-api/pkg/errors"
 	"github.com/starbx/brew-api/pkg/i18n"
 	"github.com/starbx/brew-api/pkg/types"
@@ -216,6 +218,22 @@ func (l *SpaceLogic) DeleteUserSpace(spaceID string) error {
 			return errors.New("SpaceLogic.DeleteUserSpace.VectorStore.DeleteAll", i18n.ERROR_INTERNAL, err)
 		}
 
+		if err := l.core.Store().KnowledgeRevisionStore().DeleteAll(ctx, spaceID); err != nil {
+			return errors.New("SpaceLogic.DeleteUserSpace.KnowledgeRevisionStore.DeleteAll", i18n.ERROR_INTERNAL, err)
+		}
+
+		if err := l.core.Store().KnowledgeDuplicateStore().DeleteAll(ctx, spaceID); err != nil {
+			return errors.New("SpaceLogic.DeleteUserSpace.KnowledgeDuplicateStore.DeleteAll", i18n.ERROR_INTERNAL, err)
+		}
+
+		if err := l.core.Store().KnowledgeLinkStore().DeleteAll(ctx, spaceID); err != nil {
+			return errors.New("SpaceLogic.DeleteUserSpace.KnowledgeLinkStore.DeleteAll", i18n.ERROR_INTERNAL, err)
+		}
+
+		if err := l.core.Store().ShareTokenStore().DeleteAll(ctx, spaceID); err != nil {
+			return errors.New("SpaceLogic.DeleteUserSpace.ShareTokenStore.DeleteAll", i18n.ERROR_INTERNAL, err)
+		}
+
 		if err := l.core.Store().ChatSessionStore().DeleteAll(ctx, spaceID); err != nil {
 			return errors.New("SpaceLogic.DeleteUserSpace.ChatSessionStore.DeleteAll", i18n.ERROR_INTERNAL, err)
 		}
@@ -227,6 +245,14 @@ func (l *SpaceLogic) DeleteUserSpace(spaceID string) error {
 		if err := l.core.Store().ChatMessageExtStore().DeleteAll(ctx, spaceID); err != nil {
 			return errors.New("SpaceLogic.DeleteUserSpace.ChatMessageExtStore.DeleteAll", i18n.ERROR_INTERNAL, err)
 		}
+
+		if err := l.core.Store().BlobStore().DeleteAll(ctx, spaceID); err != nil {
+			return errors.New("SpaceLogic.DeleteUserSpace.BlobStore.DeleteAll", i18n.ERROR_INTERNAL, err)
+		}
+
+		if err := l.core.Store().SpaceKeyStore().DeleteAll(ctx, spaceID); err != nil {
+			return errors.New("SpaceLogic.DeleteUserSpace.SpaceKeyStore.DeleteAll", i18n.ERROR_INTERNAL, err)
+		}
 		return nil
 	})
 }
@@ -269,3 +295,51 @@ func (l *SpaceLogic) ListUserSpace() ([]types.UserSpaceDetail, error) {
 
 	return result, nil
 }
+
+// ExportSpace 将空间中的全部数据导出为备份文件写入 w
+func (l *SpaceLogic) ExportSpace(spaceID string, w io.Writer) error {
+	user := l.GetUserInfo()
+
+	userSpace, err := l.core.Store().UserSpaceStore().GetUserSpaceRole(l.ctx, user.User, spaceID)
+	if err != nil && err != sql.ErrNoRows {
+		return errors.New("SpaceLogic.ExportSpace.UserSpaceStore.GetUserSpaceRole", i18n.ERROR_INTERNAL, err)
+	}
+
+	if userSpace == nil || !l.core.Srv().RBAC().CheckPermission(userSpace.Role, srv.PermissionAdmin) {
+		return errors.New("SpaceLogic.ExportSpace.RBAC.CheckPermission", i18n.ERROR_PERMISSION_DENIED, nil).Code(http.StatusForbidden)
+	}
+
+	if err = archive.Export(l.ctx, l.core, spaceID, w); err != nil {
+		return errors.New("SpaceLogic.ExportSpace.archive.Export", i18n.ERROR_INTERNAL, err)
+	}
+	return nil
+}
+
+// ImportSpace 从备份文件中恢复空间，spaceID 为空时恢复为当前用户的新空间
+func (l *SpaceLogic) ImportSpace(spaceID string, r io.Reader, reuseVectors bool) (*types.SpaceImportResult, error) {
+	user := l.GetUserInfo()
+
+	if spaceID != "" {
+		userSpace, err := l.core.Store().UserSpaceStore().GetUserSpaceRole(l.ctx, user.User, spaceID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, errors.New("SpaceLogic.ImportSpace.UserSpaceStore.GetUserSpaceRole", i18n.ERROR_INTERNAL, err)
+		}
+
+		if userSpace == nil || !l.core.Srv().RBAC().CheckPermission(userSpace.Role, srv.PermissionAdmin) {
+			return nil, errors.New("SpaceLogic.ImportSpace.RBAC.CheckPermission", i18n.ERROR_PERMISSION_DENIED, nil).Code(http.StatusForbidden)
+		}
+	}
+
+	result, err := archive.Import(l.ctx, l.core, r, types.SpaceImportOptions{
+		SpaceID:      spaceID,
+		UserID:       user.User,
+		ReuseVectors: reuseVectors,
+	})
+	if err != nil {
+		if archive.IsInvalidArchive(err) {
+			return nil, errors.New("SpaceLogic.ImportSpace.archive.Import", i18n.ERROR_LOGIC_SPACE_ARCHIVE_INVALID, err).Code(http.StatusBadRequest)
+		}
+		return nil, errors.New("SpaceLogic.ImportSpace.archive.Import", i18n.ERROR_INTERNAL, err)
+	}
+	return result, nil
+}
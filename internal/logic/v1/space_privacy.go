@@ -0,0 +1,52 @@
+package v1
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/starbx/brew-api/internal/core/srv"
+	"github.com/starbx/brew-api/internal/logic/v1/process"
+	"github.com/starbx/brew-api/pkg/errors"
+	"github.com/starbx/brew-api/pkg/i18n"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+// GetPrivacySettings 获取空间的敏感信息识别配置，未设置识别类型时使用服务的默认配置
+func (l *SpaceLogic) GetPrivacySettings(spaceID string) (*types.PrivacySettings, error) {
+	space, err := l.core.Store().SpaceStore().GetSpace(l.ctx, spaceID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("SpaceLogic.GetPrivacySettings.SpaceStore.GetSpace", i18n.ERROR_INTERNAL, err)
+	}
+	if space == nil {
+		return nil, errors.New("SpaceLogic.GetPrivacySettings.SpaceStore.GetSpace.nil", i18n.ERROR_NOTFOUND, nil).Code(http.StatusNotFound)
+	}
+	return &space.Privacy, nil
+}
+
+// UpdatePrivacySettings 更新空间的敏感信息识别配置
+func (l *SpaceLogic) UpdatePrivacySettings(spaceID string, settings types.PrivacySettings) error {
+	user := l.GetUserInfo()
+
+	userSpace, err := l.core.Store().UserSpaceStore().GetUserSpaceRole(l.ctx, user.User, spaceID)
+	if err != nil && err != sql.ErrNoRows {
+		return errors.New("SpaceLogic.UpdatePrivacySettings.UserSpaceStore.GetUserSpaceRole", i18n.ERROR_INTERNAL, err)
+	}
+
+	if userSpace == nil || !l.core.Srv().RBAC().CheckPermission(userSpace.Role, srv.PermissionAdmin) {
+		return errors.New("SpaceLogic.UpdatePrivacySettings.RBAC.CheckPermission", i18n.ERROR_PERMISSION_DENIED, nil).Code(http.StatusForbidden)
+	}
+
+	if err = settings.Validate(); err != nil {
+		return errors.New("SpaceLogic.UpdatePrivacySettings.Validate", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest)
+	}
+	// 未知的类型与无法编译的正则
+	if _, err = process.BuildPrivacyDetector(l.core.Cfg().Privacy, settings); err != nil {
+		return errors.New("SpaceLogic.UpdatePrivacySettings.process.BuildPrivacyDetector", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest)
+	}
+
+	if err = l.core.Store().SpaceStore().UpdatePrivacy(l.ctx, spaceID, settings); err != nil {
+		return errors.New("SpaceLogic.UpdatePrivacySettings.SpaceStore.UpdatePrivacy", i18n.ERROR_INTERNAL, err)
+	}
+	process.ForgetPrivacyDetector(spaceID)
+	return nil
+}
@@ -0,0 +1,46 @@
+package v1
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/starbx/brew-api/internal/core/srv"
+	"github.com/starbx/brew-api/pkg/errors"
+	"github.com/starbx/brew-api/pkg/i18n"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+// GetRetrievalSettings 获取空间的检索配置，未设置的项为空，使用服务的默认配置
+func (l *SpaceLogic) GetRetrievalSettings(spaceID string) (*types.RetrievalSettings, error) {
+	space, err := l.core.Store().SpaceStore().GetSpace(l.ctx, spaceID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("SpaceLogic.GetRetrievalSettings.SpaceStore.GetSpace", i18n.ERROR_INTERNAL, err)
+	}
+	if space == nil {
+		return nil, errors.New("SpaceLogic.GetRetrievalSettings.SpaceStore.GetSpace.nil", i18n.ERROR_NOTFOUND, nil).Code(http.StatusNotFound)
+	}
+	return &space.Retrieval, nil
+}
+
+// UpdateRetrievalSettings 更新空间的检索配置
+func (l *SpaceLogic) UpdateRetrievalSettings(spaceID string, settings types.RetrievalSettings) error {
+	user := l.GetUserInfo()
+
+	userSpace, err := l.core.Store().UserSpaceStore().GetUserSpaceRole(l.ctx, user.User, spaceID)
+	if err != nil && err != sql.ErrNoRows {
+		return errors.New("SpaceLogic.UpdateRetrievalSettings.UserSpaceStore.GetUserSpaceRole", i18n.ERROR_INTERNAL, err)
+	}
+
+	if userSpace == nil || !l.core.Srv().RBAC().CheckPermission(userSpace.Role, srv.PermissionAdmin) {
+		return errors.New("SpaceLogic.UpdateRetrievalSettings.RBAC.CheckPermission", i18n.ERROR_PERMISSION_DENIED, nil).Code(http.StatusForbidden)
+	}
+
+	if err = settings.Validate(); err != nil {
+		return errors.New("SpaceLogic.UpdateRetrievalSettings.Validate", i18n.ERROR_INVALIDARGUMENT, err).Code(http.StatusBadRequest)
+	}
+
+	if err = l.core.Store().SpaceStore().UpdateRetrieval(l.ctx, spaceID, settings); err != nil {
+		return errors.New("SpaceLogic.UpdateRetrievalSettings.SpaceStore.UpdateRetrieval", i18n.ERROR_INTERNAL, err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package filestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/starbx/brew-api/internal/store"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+var _ store.BlobStore = (*BlobStore)(nil)
+
+// BlobStore 将文件保存在本地目录，路径为 {root}/{space_id}/{id}，适用于单实例部署
+// 文件内容不会被加密，启用加密存储时应使用默认的 postgres 存储
+type BlobStore struct {
+	root string
+}
+
+// NewBlobStore 创建本地文件存储，root 不存在时自动创建
+func NewBlobStore(root string) (*BlobStore, error) {
+	if root == "" {
+		return nil, errors.New("path of blob store is required")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &BlobStore{root: root}, nil
+}
+
+func (s *BlobStore) GetTable(...interface{}) string {
+	return types.TABLE_BLOB.Name()
+}
+
+// path 拼接文件路径，id 来自调用方，需要避免跳出 root 目录
+func (s *BlobStore) path(spaceID string, id ...string) (string, error) {
+	for _, v := range append([]string{spaceID}, id...) {
+		if v == "" || v == "." || v == ".." || strings.ContainsAny(v, `/\`) {
+			return "", fmt.Errorf("invalid blob path %q", v)
+		}
+	}
+	return filepath.Join(append([]string{s.root, spaceID}, id...)...), nil
+}
+
+// Put 保存文件，先写入临时文件再重命名，避免读到写入一半的内容
+func (s *BlobStore) Put(ctx context.Context, spaceID, id string, data []byte) error {
+	path, err := s.path(spaceID, id)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Get 获取文件内容，不存在时返回 sql.ErrNoRows，与 postgres 存储保持一致
+func (s *BlobStore) Get(ctx context.Context, spaceID, id string) ([]byte, error) {
+	path, err := s.path(spaceID, id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, sql.ErrNoRows
+	}
+	return data, err
+}
+
+// Delete 删除文件，文件不存在时不返回错误
+func (s *BlobStore) Delete(ctx context.Context, spaceID, id string) error {
+	path, err := s.path(spaceID, id)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// DeleteAll 删除空间的全部文件
+func (s *BlobStore) DeleteAll(ctx context.Context, spaceID string) error {
+	path, err := s.path(spaceID)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(path)
+}
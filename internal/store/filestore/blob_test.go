@@ -0,0 +1,37 @@
+package filestore
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlobStore(t *testing.T) {
+	s, err := NewBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	assert.NoError(t, s.Put(ctx, "space", "image", []byte("first")))
+	assert.NoError(t, s.Put(ctx, "space", "image", []byte("second")))
+
+	data, err := s.Get(ctx, "space", "image")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("second"), data)
+
+	assert.NoError(t, s.Delete(ctx, "space", "image"))
+	assert.NoError(t, s.Delete(ctx, "space", "image"))
+	_, err = s.Get(ctx, "space", "image")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	assert.NoError(t, s.Put(ctx, "space", "other", []byte("data")))
+	assert.NoError(t, s.DeleteAll(ctx, "space"))
+	_, err = s.Get(ctx, "space", "other")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	assert.Error(t, s.Put(ctx, "space", "../escape", []byte("data")))
+	assert.Error(t, s.Put(ctx, "..", "image", []byte("data")))
+}
@@ -0,0 +1,105 @@
+package memstore_test
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pgvector/pgvector-go"
+
+	"github.com/starbx/brew-api/internal/core"
+	"github.com/starbx/brew-api/internal/store"
+	"github.com/starbx/brew-api/internal/store/memstore"
+	"github.com/starbx/brew-api/internal/store/sqlstore"
+	"github.com/starbx/brew-api/pkg/types"
+	"github.com/starbx/brew-api/pkg/utils"
+)
+
+const (
+	benchDim     = 1024
+	benchVectors = 10000
+	benchModel   = "bench:model"
+)
+
+func randomVector(rng *rand.Rand, dim int) []float32 {
+	vec := make([]float32, dim)
+	for i := range vec {
+		vec[i] = rng.Float32()*2 - 1
+	}
+	return vec
+}
+
+func fillBenchVectors(b *testing.B, s store.VectorStore, spaceID string) {
+	rng := rand.New(rand.NewSource(1))
+	now := time.Now().Unix()
+	batch := make([]types.Vector, 0, 500)
+	for i := range benchVectors {
+		id := strconv.Itoa(i)
+		batch = append(batch, types.Vector{
+			ID:          spaceID + "-" + id,
+			KnowledgeID: id,
+			SpaceID:     spaceID,
+			Resource:    types.DEFAULT_RESOURCE,
+			UserID:      "bench",
+			Model:       benchModel,
+			Dim:         benchDim,
+			Embedding:   pgvector.NewVector(randomVector(rng, benchDim)),
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		})
+		if len(batch) == cap(batch) {
+			if err := s.BatchCreate(context.Background(), batch); err != nil {
+				b.Fatal(err)
+			}
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		if err := s.BatchCreate(context.Background(), batch); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchQuery(b *testing.B, s store.VectorStore, spaceID string) {
+	rng := rand.New(rand.NewSource(2))
+	queries := make([]pgvector.Vector, 100)
+	for i := range queries {
+		queries[i] = pgvector.NewVector(randomVector(rng, benchDim))
+	}
+
+	opts := types.GetVectorsOptions{SpaceID: spaceID, Model: benchModel}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Query(context.Background(), opts, queries[i%len(queries)], 20); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMemoryQuery(b *testing.B) {
+	s, err := memstore.NewVectorStore(nil, "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	fillBenchVectors(b, s, "bench")
+	benchQuery(b, s, "bench")
+}
+
+// BenchmarkPGVectorQuery 使用相同的数据对比 pgvector，需要设置 BREW_API_POSTGRESQL_DSN
+func BenchmarkPGVectorQuery(b *testing.B) {
+	cfg := core.PGConfig{}
+	cfg.FromENV()
+	if cfg.DSN == "" {
+		b.Skip("BREW_API_POSTGRESQL_DSN is not set")
+	}
+
+	s := sqlstore.MustSetup(cfg)().VectorStore()
+	spaceID := "bench-" + utils.GenRandomID()
+	defer s.DeleteAll(context.Background(), spaceID)
+
+	fillBenchVectors(b, s, spaceID)
+	benchQuery(b, s, spaceID)
+}
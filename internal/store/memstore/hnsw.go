@@ -0,0 +1,290 @@
+package memstore
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	// HNSW_M 每个节点在非底层保留的邻居数量，底层为其两倍
+	HNSW_M = 16
+	// HNSW_EF_CONSTRUCTION 构建索引时的候选集大小
+	HNSW_EF_CONSTRUCTION = 200
+	// HNSW_EF_SEARCH 检索时的最小候选集大小
+	HNSW_EF_SEARCH = 64
+)
+
+// hnsw 使用余弦距离的 HNSW 索引，写入的向量需要预先归一化
+// 删除只做标记，被删除的节点仍参与图的导航，但不会出现在检索结果中
+type hnsw struct {
+	m              int
+	mMax0          int
+	efConstruction int
+	levelMult      float64
+	rng            *rand.Rand
+
+	nodes    []*hnswNode
+	entry    int
+	maxLevel int
+	deleted  int
+}
+
+type hnswNode struct {
+	key     string
+	vec     []float32
+	friends [][]int
+	deleted bool
+}
+
+type candidate struct {
+	node int
+	dist float32
+}
+
+func newHNSW() *hnsw {
+	return &hnsw{
+		m:              HNSW_M,
+		mMax0:          HNSW_M * 2,
+		efConstruction: HNSW_EF_CONSTRUCTION,
+		levelMult:      1 / math.Log(float64(HNSW_M)),
+		rng:            rand.New(rand.NewSource(1)),
+		entry:          -1,
+	}
+}
+
+// Len 返回索引中未被删除的节点数量
+func (h *hnsw) Len() int {
+	return len(h.nodes) - h.deleted
+}
+
+func (h *hnsw) randomLevel() int {
+	return int(math.Floor(-math.Log(1-h.rng.Float64()) * h.levelMult))
+}
+
+func (h *hnsw) maxFriends(level int) int {
+	if level == 0 {
+		return h.mMax0
+	}
+	return h.m
+}
+
+func (h *hnsw) distance(q []float32, node int) float32 {
+	return cosineDistance(q, h.nodes[node].vec)
+}
+
+// Insert 写入归一化后的向量，返回节点编号
+func (h *hnsw) Insert(key string, vec []float32) int {
+	level := h.randomLevel()
+	id := len(h.nodes)
+	h.nodes = append(h.nodes, &hnswNode{
+		key:     key,
+		vec:     vec,
+		friends: make([][]int, level+1),
+	})
+
+	if h.entry < 0 {
+		h.entry, h.maxLevel = id, level
+		return id
+	}
+
+	cur := h.entry
+	for l := h.maxLevel; l > level; l-- {
+		cur = h.greedy(vec, cur, l)
+	}
+
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		candidates := h.searchLayer(vec, []int{cur}, h.efConstruction, l)
+		neighbors := candidates
+		if len(neighbors) > h.m {
+			neighbors = neighbors[:h.m]
+		}
+
+		for _, nb := range neighbors {
+			h.nodes[id].friends[l] = append(h.nodes[id].friends[l], nb.node)
+			h.link(nb.node, id, l)
+		}
+		cur = candidates[0].node
+	}
+
+	if level > h.maxLevel {
+		h.entry, h.maxLevel = id, level
+	}
+	return id
+}
+
+// link 为 node 添加邻居，超出上限时只保留距离最近的邻居
+func (h *hnsw) link(node, friend, level int) {
+	n := h.nodes[node]
+	n.friends[level] = append(n.friends[level], friend)
+	limit := h.maxFriends(level)
+	if len(n.friends[level]) <= limit {
+		return
+	}
+
+	list := make([]candidate, 0, len(n.friends[level]))
+	for _, f := range n.friends[level] {
+		list = append(list, candidate{node: f, dist: h.distance(n.vec, f)})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].dist < list[j].dist
+	})
+
+	n.friends[level] = n.friends[level][:0]
+	for _, c := range list[:limit] {
+		n.friends[level] = append(n.friends[level], c.node)
+	}
+}
+
+// Delete 标记节点已删除
+func (h *hnsw) Delete(node int) {
+	if node < 0 || node >= len(h.nodes) || h.nodes[node].deleted {
+		return
+	}
+	h.nodes[node].deleted = true
+	h.deleted++
+}
+
+// greedy 在指定层上从 cur 出发贪心地移动到距离 q 最近的节点
+func (h *hnsw) greedy(q []float32, cur, level int) int {
+	curDist := h.distance(q, cur)
+	for changed := true; changed; {
+		changed = false
+		for _, f := range h.nodes[cur].friends[level] {
+			if d := h.distance(q, f); d < curDist {
+				cur, curDist, changed = f, d, true
+			}
+		}
+	}
+	return cur
+}
+
+// searchLayer 在指定层上检索距离 q 最近的 ef 个节点，按距离升序返回
+func (h *hnsw) searchLayer(q []float32, entries []int, ef, level int) []candidate {
+	visited := make(map[int]struct{}, ef*4)
+	candidates := &minHeap{}
+	results := &maxHeap{}
+	for _, e := range entries {
+		visited[e] = struct{}{}
+		c := candidate{node: e, dist: h.distance(q, e)}
+		heap.Push(candidates, c)
+		heap.Push(results, c)
+	}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(candidate)
+		if c.dist > (*results)[0].dist && results.Len() >= ef {
+			break
+		}
+		for _, f := range h.nodes[c.node].friends[level] {
+			if _, ok := visited[f]; ok {
+				continue
+			}
+			visited[f] = struct{}{}
+
+			d := h.distance(q, f)
+			if results.Len() < ef || d < (*results)[0].dist {
+				heap.Push(candidates, candidate{node: f, dist: d})
+				heap.Push(results, candidate{node: f, dist: d})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	list := make([]candidate, results.Len())
+	for i := len(list) - 1; i >= 0; i-- {
+		list[i] = heap.Pop(results).(candidate)
+	}
+	return list
+}
+
+// Search 返回距离 q 最近且满足 accept 的至多 k 个节点，按距离升序返回
+// 满足条件的节点较少时逐步扩大候选集，直到候选集覆盖整个索引
+func (h *hnsw) Search(q []float32, k int, accept func(node int) bool) []candidate {
+	if h.entry < 0 || k <= 0 {
+		return nil
+	}
+
+	cur := h.entry
+	for l := h.maxLevel; l > 0; l-- {
+		cur = h.greedy(q, cur, l)
+	}
+
+	ef := max(HNSW_EF_SEARCH, k)
+	for {
+		var result []candidate
+		for _, c := range h.searchLayer(q, []int{cur}, ef, 0) {
+			if h.nodes[c.node].deleted || (accept != nil && !accept(c.node)) {
+				continue
+			}
+			result = append(result, c)
+			if len(result) == k {
+				return result
+			}
+		}
+		if ef >= len(h.nodes) {
+			return result
+		}
+		ef *= 2
+	}
+}
+
+func cosineDistance(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 2
+	}
+	var dot float32
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return 1 - dot
+}
+
+// normalize 返回归一化后的向量副本，零向量原样返回
+func normalize(vec []float32) []float32 {
+	var sum float64
+	for _, v := range vec {
+		sum += float64(v) * float64(v)
+	}
+	res := make([]float32, len(vec))
+	if sum == 0 {
+		copy(res, vec)
+		return res
+	}
+	norm := float32(math.Sqrt(sum))
+	for i, v := range vec {
+		res[i] = v / norm
+	}
+	return res
+}
+
+type minHeap []candidate
+
+func (h minHeap) Len() int           { return len(h) }
+func (h minHeap) Less(i, j int) bool { return h[i].dist < h[j].dist }
+func (h minHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *minHeap) Push(x any)        { *h = append(*h, x.(candidate)) }
+func (h *minHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+type maxHeap []candidate
+
+func (h maxHeap) Len() int           { return len(h) }
+func (h maxHeap) Less(i, j int) bool { return h[i].dist > h[j].dist }
+func (h maxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *maxHeap) Push(x any)        { *h = append(*h, x.(candidate)) }
+func (h *maxHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
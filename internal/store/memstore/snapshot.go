@@ -0,0 +1,165 @@
+package memstore
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pgvector/pgvector-go"
+
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+// SNAPSHOT_VERSION 快照格式的版本，格式不兼容时递增
+const SNAPSHOT_VERSION = 1
+
+// snapshot 只保存向量数据，索引在加载时重建
+type snapshot struct {
+	Version int
+	Vectors []snapshotVector
+}
+
+type snapshotVector struct {
+	ID             string
+	KnowledgeID    string
+	SpaceID        string
+	Resource       string
+	UserID         string
+	Model          string
+	Dim            int
+	Embedding      []float32
+	OriginalLength int
+	CreatedAt      int64
+	UpdatedAt      int64
+}
+
+// Snapshot 将全部向量写入快照文件，先写入临时文件再替换，避免中断时损坏已有的快照
+func (s *VectorStore) Snapshot() error {
+	if s.path == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	data := snapshot{
+		Version: SNAPSHOT_VERSION,
+		Vectors: make([]snapshotVector, 0, len(s.records)),
+	}
+	for _, v := range s.records {
+		data.Vectors = append(data.Vectors, snapshotVector{
+			ID:             v.ID,
+			KnowledgeID:    v.KnowledgeID,
+			SpaceID:        v.SpaceID,
+			Resource:       v.Resource,
+			UserID:         v.UserID,
+			Model:          v.Model,
+			Dim:            v.Dim,
+			Embedding:      v.Embedding.Slice(),
+			OriginalLength: v.OriginalLength,
+			CreatedAt:      v.CreatedAt,
+			UpdatedAt:      v.UpdatedAt,
+		})
+	}
+	s.dirty = false
+	s.mu.Unlock()
+
+	if err := writeSnapshot(s.path, data); err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func writeSnapshot(path string, data snapshot) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file, %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err = gob.NewEncoder(tmp).Encode(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to encode snapshot, %w", err)
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync snapshot file, %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close snapshot file, %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// load 从快照文件恢复向量并重建索引，文件不存在时视为空数据
+func (s *VectorStore) load(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot file, %w", err)
+	}
+	defer f.Close()
+
+	var data snapshot
+	if err = gob.NewDecoder(f).Decode(&data); err != nil {
+		return fmt.Errorf("failed to decode snapshot, %w", err)
+	}
+	if data.Version != SNAPSHOT_VERSION {
+		return fmt.Errorf("unsupported snapshot version %d", data.Version)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range data.Vectors {
+		s.insert(types.Vector{
+			ID:             v.ID,
+			KnowledgeID:    v.KnowledgeID,
+			SpaceID:        v.SpaceID,
+			Resource:       v.Resource,
+			UserID:         v.UserID,
+			Model:          v.Model,
+			Dim:            v.Dim,
+			Embedding:      pgvector.NewVector(v.Embedding),
+			OriginalLength: v.OriginalLength,
+			CreatedAt:      v.CreatedAt,
+			UpdatedAt:      v.UpdatedAt,
+		})
+	}
+	s.dirty = false
+	return nil
+}
+
+// StartSnapshot 定时将有变更的数据写入快照文件，ctx 结束时再写入一次
+func (s *VectorStore) StartSnapshot(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.snapshotIfDirty()
+			return
+		case <-ticker.C:
+			s.snapshotIfDirty()
+		}
+	}
+}
+
+func (s *VectorStore) snapshotIfDirty() {
+	s.mu.RLock()
+	dirty := s.dirty
+	s.mu.RUnlock()
+	if !dirty {
+		return
+	}
+	if err := s.Snapshot(); err != nil {
+		slog.Error("Failed to snapshot memory vector store", slog.String("path", s.path), slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,409 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pgvector/pgvector-go"
+
+	"github.com/starbx/brew-api/internal/store"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+var _ store.VectorStore = (*VectorStore)(nil)
+
+// VectorStore 基于内存 HNSW 索引的向量存储，适用于小规模的自托管部署与不依赖 postgres 的单元测试
+// 每个 embedding 模型单独建立索引，写入不参与数据库事务，通过 Snapshot 持久化到磁盘
+type VectorStore struct {
+	mu      sync.RWMutex
+	records map[string]*record
+	indexes map[string]*hnsw
+	dirty   bool
+
+	// knowledges 用于按知识的元数据与保留周期过滤，为空时忽略 GetVectorsOptions 中的 Filter 与 ExcludeExpiredAt
+	knowledges store.KnowledgeStore
+	path       string
+}
+
+type record struct {
+	types.Vector
+	node int
+}
+
+func recordKey(model, id string) string {
+	return model + "/" + id
+}
+
+// NewVectorStore 创建内存向量存储，path 不为空时从该快照文件恢复数据
+func NewVectorStore(knowledges store.KnowledgeStore, path string) (*VectorStore, error) {
+	s := &VectorStore{
+		records:    make(map[string]*record),
+		indexes:    make(map[string]*hnsw),
+		knowledges: knowledges,
+		path:       path,
+	}
+	if path != "" {
+		if err := s.load(path); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *VectorStore) GetTable(...interface{}) string {
+	return types.TABLE_VECTORS.Name()
+}
+
+// insert 写入一条向量，调用方需要持有写锁
+func (s *VectorStore) insert(data types.Vector) {
+	if data.CreatedAt == 0 {
+		data.CreatedAt = time.Now().Unix()
+	}
+	if data.UpdatedAt == 0 {
+		data.UpdatedAt = time.Now().Unix()
+	}
+	if data.Dim == 0 {
+		data.Dim = len(data.Embedding.Slice())
+	}
+
+	idx := s.indexes[data.Model]
+	if idx == nil {
+		idx = newHNSW()
+		s.indexes[data.Model] = idx
+	}
+
+	key := recordKey(data.Model, data.ID)
+	s.records[key] = &record{
+		Vector: data,
+		node:   idx.Insert(key, normalize(data.Embedding.Slice())),
+	}
+	s.dirty = true
+}
+
+// remove 删除一条向量，被删除的节点超过一半时重建索引，调用方需要持有写锁
+func (s *VectorStore) remove(key string) {
+	r, ok := s.records[key]
+	if !ok {
+		return
+	}
+	delete(s.records, key)
+	s.dirty = true
+
+	idx := s.indexes[r.Model]
+	idx.Delete(r.node)
+	if idx.Len() == 0 {
+		delete(s.indexes, r.Model)
+		return
+	}
+	if idx.deleted > idx.Len() {
+		s.rebuild(r.Model)
+	}
+}
+
+func (s *VectorStore) rebuild(model string) {
+	idx := newHNSW()
+	keys := make([]string, 0, len(s.records))
+	for k, v := range s.records {
+		if v.Model == model {
+			keys = append(keys, k)
+		}
+	}
+	// 保证相同数据重建出的索引一致
+	sort.Strings(keys)
+	for _, k := range keys {
+		r := s.records[k]
+		r.node = idx.Insert(k, normalize(r.Embedding.Slice()))
+	}
+	s.indexes[model] = idx
+}
+
+// Create 创建新的文本向量记录
+func (s *VectorStore) Create(ctx context.Context, data types.Vector) error {
+	return s.BatchCreate(ctx, []types.Vector{data})
+}
+
+// BatchCreate 批量创建新的文本向量记录，与数据库一致，同一模型下的id重复时全部不写入
+func (s *VectorStore) BatchCreate(ctx context.Context, datas []types.Vector) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range datas {
+		if _, ok := s.records[recordKey(v.Model, v.ID)]; ok {
+			return fmt.Errorf("vector %s of model %s already exists", v.ID, v.Model)
+		}
+	}
+	for _, v := range datas {
+		s.insert(v)
+	}
+	return nil
+}
+
+// GetVector 根据ID获取文本向量记录
+func (s *VectorStore) GetVector(ctx context.Context, spaceID, knowledgeID, id string) (*types.Vector, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, v := range s.records {
+		if v.ID == id && v.SpaceID == spaceID && v.KnowledgeID == knowledgeID {
+			res := v.Vector
+			return &res, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// Update 更新文本向量记录
+func (s *VectorStore) Update(ctx context.Context, spaceID, knowledgeID, id string, vector pgvector.Vector) error {
+	return s.deleteWhere(func(v *types.Vector) bool {
+		return v.ID == id && v.SpaceID == spaceID && v.KnowledgeID == knowledgeID
+	}, func(v types.Vector) {
+		v.Embedding = vector
+		v.Dim = len(vector.Slice())
+		v.UpdatedAt = time.Now().Unix()
+		s.insert(v)
+	})
+}
+
+// Delete 删除文本向量记录
+func (s *VectorStore) Delete(ctx context.Context, spaceID, knowledgeID, id string) error {
+	return s.deleteWhere(func(v *types.Vector) bool {
+		return v.ID == id && v.SpaceID == spaceID && v.KnowledgeID == knowledgeID
+	}, nil)
+}
+
+func (s *VectorStore) BatchDelete(ctx context.Context, spaceID, knowledgeID string) error {
+	return s.deleteWhere(func(v *types.Vector) bool {
+		return v.SpaceID == spaceID && v.KnowledgeID == knowledgeID
+	}, nil)
+}
+
+func (s *VectorStore) DeleteAll(ctx context.Context, spaceID string) error {
+	return s.deleteWhere(func(v *types.Vector) bool {
+		return v.SpaceID == spaceID
+	}, nil)
+}
+
+// PruneModels 删除空间（spaceID 为空时为全部空间）中不是由 model 生成的向量，返回删除的数量
+func (s *VectorStore) PruneModels(ctx context.Context, spaceID, model string) (int64, error) {
+	var total int64
+	err := s.deleteWhere(func(v *types.Vector) bool {
+		return v.Model != model && (spaceID == "" || v.SpaceID == spaceID)
+	}, func(types.Vector) {
+		total++
+	})
+	return total, err
+}
+
+// AdoptLegacy 将没有记录模型的向量标记为由 model 生成，返回标记的数量
+func (s *VectorStore) AdoptLegacy(ctx context.Context, spaceID, model string) (int64, error) {
+	var total int64
+	err := s.deleteWhere(func(v *types.Vector) bool {
+		return v.Model == "" && (spaceID == "" || v.SpaceID == spaceID)
+	}, func(v types.Vector) {
+		v.Model = model
+		s.insert(v)
+		total++
+	})
+	return total, err
+}
+
+// EnsureIndex 每个模型的向量在写入时即加入各自的 HNSW 索引，无需额外处理
+func (s *VectorStore) EnsureIndex(ctx context.Context, model string) error {
+	return nil
+}
+
+// ExistIDs 返回 ids 中已有 model 生成的向量的ID
+func (s *VectorStore) ExistIDs(ctx context.Context, model string, ids []string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var res []string
+	for _, id := range ids {
+		if _, ok := s.records[recordKey(model, id)]; ok {
+			res = append(res, id)
+		}
+	}
+	return res, nil
+}
+
+// deleteWhere 删除满足 match 的向量，then 不为空时以被删除的向量依次调用
+func (s *VectorStore) deleteWhere(match func(v *types.Vector) bool, then func(v types.Vector)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed []types.Vector
+	for k, v := range s.records {
+		if match(&v.Vector) {
+			removed = append(removed, v.Vector)
+			s.remove(k)
+		}
+	}
+	if then != nil {
+		for _, v := range removed {
+			then(v)
+		}
+	}
+	return nil
+}
+
+// ListBwVectors 分页获取文本向量记录列表
+func (s *VectorStore) ListVectors(ctx context.Context, opts types.GetVectorsOptions, page, pageSize uint64) ([]types.Vector, error) {
+	accept, err := s.matcher(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	var res []types.Vector
+	for _, v := range s.records {
+		if accept(&v.Vector) {
+			res = append(res, v.Vector)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].CreatedAt != res[j].CreatedAt {
+			return res[i].CreatedAt > res[j].CreatedAt
+		}
+		return res[i].ID < res[j].ID
+	})
+
+	if pageSize == types.NO_PAGING {
+		return res, nil
+	}
+	if page == 0 {
+		page = 1
+	}
+	start := (page - 1) * pageSize
+	if start >= uint64(len(res)) {
+		return nil, nil
+	}
+	return res[start:min(start+pageSize, uint64(len(res)))], nil
+}
+
+// Query 返回与 vectors 余弦距离最近的 limit 个向量，opts.Model 为空时在维度相同的全部模型中检索
+func (s *VectorStore) Query(ctx context.Context, opts types.GetVectorsOptions, vectors pgvector.Vector, limit uint64) ([]types.QueryResult, error) {
+	accept, err := s.matcher(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	q := normalize(vectors.Slice())
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var res []types.QueryResult
+	for model, idx := range s.indexes {
+		if opts.Model != "" && model != opts.Model {
+			continue
+		}
+		if len(idx.nodes) == 0 || len(idx.nodes[0].vec) != len(q) {
+			continue
+		}
+
+		for _, c := range idx.Search(q, int(limit), func(node int) bool {
+			return accept(&s.records[idx.nodes[node].key].Vector)
+		}) {
+			r := s.records[idx.nodes[c.node].key]
+			res = append(res, types.QueryResult{
+				ID:             r.ID,
+				KnowledgeID:    r.KnowledgeID,
+				Cos:            c.dist,
+				OriginalLength: r.OriginalLength,
+			})
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Cos < res[j].Cos
+	})
+	if uint64(len(res)) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+// HybridQuery 内存存储不保存分块原文，无法进行全文检索，只按向量检索的排名计算融合得分
+func (s *VectorStore) HybridQuery(ctx context.Context, opts types.GetVectorsOptions, query types.HybridQuery, limit uint64) ([]types.QueryResult, error) {
+	res, err := s.Query(ctx, opts, query.Embedding, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range res {
+		res[i].Score = query.VectorWeight / float32(query.RRFK+i+1)
+	}
+	return res, nil
+}
+
+// matcher 将 GetVectorsOptions 转换为过滤函数，知识元数据与保留周期的条件通过 KnowledgeStore 预先查询出知识id
+// 未配置 KnowledgeStore 时（如不依赖 postgres 的单机场景）视为没有元数据与保留周期的限制
+func (s *VectorStore) matcher(ctx context.Context, opts types.GetVectorsOptions) (func(v *types.Vector) bool, error) {
+	var allowed, expired map[string]struct{}
+	if !opts.Filter.IsEmpty() && s.knowledges != nil {
+		list, err := s.knowledges.ListLiteKnowledges(ctx, types.GetKnowledgeOptions{
+			SpaceID: opts.SpaceID,
+			Filter:  opts.Filter,
+		}, types.NO_PAGING, types.NO_PAGING)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		allowed = knowledgeIDSet(list)
+	}
+	if opts.ExcludeExpiredAt > 0 && s.knowledges != nil {
+		list, err := s.knowledges.ListLiteKnowledges(ctx, types.GetKnowledgeOptions{
+			SpaceID:   opts.SpaceID,
+			ExpiredAt: opts.ExcludeExpiredAt,
+		}, types.NO_PAGING, types.NO_PAGING)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		expired = knowledgeIDSet(list)
+	}
+
+	return func(v *types.Vector) bool {
+		if opts.ID != "" && v.ID != opts.ID {
+			return false
+		}
+		if opts.KnowledgeID != "" && v.KnowledgeID != opts.KnowledgeID {
+			return false
+		}
+		if opts.ExcludeKnowledgeID != "" && v.KnowledgeID == opts.ExcludeKnowledgeID {
+			return false
+		}
+		if opts.SpaceID != "" && v.SpaceID != opts.SpaceID {
+			return false
+		}
+		if opts.UserID != "" && v.UserID != opts.UserID {
+			return false
+		}
+		if opts.Model != "" && v.Model != opts.Model {
+			return false
+		}
+		if opts.Resource != nil && !opts.Resource.Match(v.Resource) {
+			return false
+		}
+		if allowed != nil {
+			if _, ok := allowed[v.KnowledgeID]; !ok {
+				return false
+			}
+		}
+		if _, ok := expired[v.KnowledgeID]; ok {
+			return false
+		}
+		return true
+	}, nil
+}
+
+func knowledgeIDSet(list []*types.KnowledgeLite) map[string]struct{} {
+	res := make(map[string]struct{}, len(list))
+	for _, v := range list {
+		res[v.ID] = struct{}{}
+	}
+	return res
+}
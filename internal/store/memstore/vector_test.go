@@ -0,0 +1,215 @@
+package memstore
+
+import (
+	"context"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/pgvector/pgvector-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+func randomVector(rng *rand.Rand, dim int) []float32 {
+	vec := make([]float32, dim)
+	for i := range vec {
+		vec[i] = rng.Float32()*2 - 1
+	}
+	return vec
+}
+
+func TestVectorStoreQuery(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewVectorStore(nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.BatchCreate(ctx, []types.Vector{
+		{ID: "a", KnowledgeID: "k1", SpaceID: "s1", Resource: "knowledge", Model: "m1", Embedding: pgvector.NewVector([]float32{1, 0, 0})},
+		{ID: "b", KnowledgeID: "k1", SpaceID: "s1", Resource: "note", Model: "m1", Embedding: pgvector.NewVector([]float32{1, 1, 0})},
+		{ID: "c", KnowledgeID: "k2", SpaceID: "s1", Resource: "knowledge", Model: "m1", Embedding: pgvector.NewVector([]float32{0, 1, 0})},
+		{ID: "d", KnowledgeID: "k3", SpaceID: "s2", Resource: "knowledge", Model: "m1", Embedding: pgvector.NewVector([]float32{1, 0, 0})},
+		{ID: "a", KnowledgeID: "k1", SpaceID: "s1", Resource: "knowledge", Model: "m2", Embedding: pgvector.NewVector([]float32{0, 0, 1, 0})},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func(res []types.QueryResult) []string {
+		var list []string
+		for _, v := range res {
+			list = append(list, v.ID)
+		}
+		return list
+	}
+
+	res, err := s.Query(ctx, types.GetVectorsOptions{SpaceID: "s1", Model: "m1"}, pgvector.NewVector([]float32{1, 0, 0}), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, ids(res))
+	assert.InDelta(t, 0, res[0].Cos, 1e-6)
+
+	res, err = s.Query(ctx, types.GetVectorsOptions{SpaceID: "s1", Model: "m1", Resource: &types.ResourceQuery{Include: []string{"knowledge"}}}, pgvector.NewVector([]float32{1, 0, 0}), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "c"}, ids(res))
+
+	res, err = s.Query(ctx, types.GetVectorsOptions{SpaceID: "s1", Model: "m1", Resource: &types.ResourceQuery{Exclude: []string{"knowledge"}}}, pgvector.NewVector([]float32{1, 0, 0}), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b"}, ids(res))
+
+	res, err = s.Query(ctx, types.GetVectorsOptions{SpaceID: "s1", Model: "m1", ExcludeKnowledgeID: "k1"}, pgvector.NewVector([]float32{1, 0, 0}), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c"}, ids(res))
+
+	res, err = s.Query(ctx, types.GetVectorsOptions{Model: "m2"}, pgvector.NewVector([]float32{0, 0, 1, 0}), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, ids(res))
+
+	// 未配置 KnowledgeStore 时忽略元数据与保留周期的过滤
+	res, err = s.Query(ctx, types.GetVectorsOptions{SpaceID: "s1", Model: "m1", Filter: &types.KnowledgeFilter{Tags: []string{"go"}}}, pgvector.NewVector([]float32{1, 0, 0}), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, ids(res))
+
+	assert.Error(t, s.Create(ctx, types.Vector{ID: "a", Model: "m1", Embedding: pgvector.NewVector([]float32{1, 0, 0})}))
+
+	exist, err := s.ExistIDs(ctx, "m2", []string{"a", "b", "none"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, exist)
+
+	assert.NoError(t, s.BatchDelete(ctx, "s1", "k1"))
+	res, err = s.Query(ctx, types.GetVectorsOptions{SpaceID: "s1"}, pgvector.NewVector([]float32{1, 0, 0}), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c"}, ids(res))
+
+	pruned, err := s.PruneModels(ctx, "", "m1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), pruned)
+
+	assert.NoError(t, s.DeleteAll(ctx, "s2"))
+	list, err := s.ListVectors(ctx, types.GetVectorsOptions{}, types.NO_PAGING, types.NO_PAGING)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+}
+
+// TestVectorStoreRelevanceWithoutKnowledges 使用 GetRelevanceKnowledges 的检索条件，确认不依赖 postgres 时也能召回
+func TestVectorStoreRelevanceWithoutKnowledges(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewVectorStore(nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.BatchCreate(ctx, []types.Vector{
+		{ID: "a", KnowledgeID: "k1", SpaceID: "s1", Resource: types.DEFAULT_RESOURCE, Model: "m1", Embedding: pgvector.NewVector([]float32{1, 0, 0})},
+		{ID: "b", KnowledgeID: "k2", SpaceID: "s1", Resource: types.DEFAULT_RESOURCE, Model: "m1", Embedding: pgvector.NewVector([]float32{0, 1, 0})},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := types.GetVectorsOptions{
+		SpaceID:          "s1",
+		Resource:         &types.ResourceQuery{Include: []string{types.DEFAULT_RESOURCE}},
+		Model:            "m1",
+		Filter:           &types.KnowledgeFilter{Tags: []string{"go"}},
+		ExcludeExpiredAt: time.Now().Unix(),
+	}
+	res, err := s.HybridQuery(ctx, opts, types.HybridQuery{
+		Embedding:    pgvector.NewVector([]float32{1, 0, 0}),
+		VectorWeight: 1,
+		RRFK:         60,
+	}, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, res, 2) {
+		assert.Equal(t, "k1", res[0].KnowledgeID)
+	}
+}
+
+func TestVectorStoreSnapshot(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vectors.snapshot")
+
+	s, err := NewVectorStore(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, s.BatchCreate(ctx, []types.Vector{
+		{ID: "a", KnowledgeID: "k1", SpaceID: "s1", Model: "m1", Embedding: pgvector.NewVector([]float32{1, 0, 0}), OriginalLength: 10},
+		{ID: "b", KnowledgeID: "k2", SpaceID: "s1", Embedding: pgvector.NewVector([]float32{0, 1, 0})},
+	}))
+	assert.NoError(t, s.Snapshot())
+
+	restored, err := NewVectorStore(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := restored.GetVector(ctx, "s1", "k1", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "m1", v.Model)
+	assert.Equal(t, 3, v.Dim)
+	assert.Equal(t, 10, v.OriginalLength)
+	assert.Equal(t, []float32{1, 0, 0}, v.Embedding.Slice())
+
+	adopted, err := restored.AdoptLegacy(ctx, "s1", "m1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), adopted)
+
+	res, err := restored.Query(ctx, types.GetVectorsOptions{Model: "m1"}, pgvector.NewVector([]float32{0, 1, 0}), 1)
+	assert.NoError(t, err)
+	assert.Len(t, res, 1)
+	assert.Equal(t, "b", res[0].ID)
+}
+
+func TestHNSWRecall(t *testing.T) {
+	const (
+		dim     = 32
+		total   = 2000
+		queries = 50
+		k       = 10
+	)
+	rng := rand.New(rand.NewSource(42))
+	idx := newHNSW()
+	for range total {
+		idx.Insert("", normalize(randomVector(rng, dim)))
+	}
+	// 删除部分节点，确认检索结果中不包含已删除的节点
+	for i := 0; i < total; i += 10 {
+		idx.Delete(i)
+	}
+
+	var hits int
+	for range queries {
+		q := normalize(randomVector(rng, dim))
+
+		var exact []candidate
+		for i, n := range idx.nodes {
+			if !n.deleted {
+				exact = append(exact, candidate{node: i, dist: cosineDistance(q, n.vec)})
+			}
+		}
+		sort.Slice(exact, func(i, j int) bool {
+			return exact[i].dist < exact[j].dist
+		})
+		truth := make(map[int]struct{}, k)
+		for _, c := range exact[:k] {
+			truth[c.node] = struct{}{}
+		}
+
+		res := idx.Search(q, k, nil)
+		assert.Len(t, res, k)
+		for _, c := range res {
+			assert.False(t, idx.nodes[c.node].deleted)
+			if _, ok := truth[c.node]; ok {
+				hits++
+			}
+		}
+	}
+
+	recall := float64(hits) / float64(queries*k)
+	t.Logf("recall@%d: %.3f", k, recall)
+	assert.GreaterOrEqual(t, recall, 0.9)
+}
@@ -0,0 +1,143 @@
+package qdrantstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// QDRANT_SCROLL_LIMIT 每次 scroll 请求获取的点数量
+const QDRANT_SCROLL_LIMIT = 256
+
+// apiError qdrant 返回的非 2xx 响应
+type apiError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("qdrant responded with status %d, %s", e.StatusCode, e.Message)
+}
+
+func isNotFound(err error) bool {
+	var e *apiError
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+func isConflict(err error) bool {
+	var e *apiError
+	return errors.As(err, &e) && (e.StatusCode == http.StatusConflict || strings.Contains(e.Message, "already exists"))
+}
+
+type apiResponse struct {
+	Result json.RawMessage `json:"result"`
+	Status any             `json:"status"`
+}
+
+// do 发送请求并将响应中的 result 字段解析到 result，result 为空时忽略响应内容
+func (s *VectorStore) do(ctx context.Context, method, path string, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(s.endpoint, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.apiKey != "" {
+		req.Header.Set("api-key", s.apiKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to request qdrant, %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &apiError{StatusCode: resp.StatusCode, Message: string(raw)}
+	}
+	if result == nil {
+		return nil
+	}
+
+	var res apiResponse
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("failed to decode qdrant response, %w", err)
+	}
+	if err = json.Unmarshal(res.Result, result); err != nil {
+		return fmt.Errorf("failed to decode qdrant result, %w", err)
+	}
+	return nil
+}
+
+type filter struct {
+	Must    []condition `json:"must,omitempty"`
+	MustNot []condition `json:"must_not,omitempty"`
+}
+
+type condition struct {
+	Key     string   `json:"key,omitempty"`
+	Match   *match   `json:"match,omitempty"`
+	IsEmpty *isEmpty `json:"is_empty,omitempty"`
+}
+
+type match struct {
+	Value string   `json:"value,omitempty"`
+	Any   []string `json:"any,omitempty"`
+}
+
+type isEmpty struct {
+	Key string `json:"key"`
+}
+
+func matchValue(key, value string) condition {
+	return condition{Key: key, Match: &match{Value: value}}
+}
+
+func matchAny(key string, values []string) condition {
+	return condition{Key: key, Match: &match{Any: values}}
+}
+
+// payload 与向量一同保存的字段，维度由所在的 collection 决定
+type payload struct {
+	ID             string `json:"id"`
+	KnowledgeID    string `json:"knowledge_id"`
+	SpaceID        string `json:"space_id"`
+	Resource       string `json:"resource"`
+	UserID         string `json:"user_id"`
+	Model          string `json:"model,omitempty"`
+	OriginalLength int    `json:"original_length"`
+	CreatedAt      int64  `json:"created_at"`
+	UpdatedAt      int64  `json:"updated_at"`
+}
+
+type point struct {
+	ID      string    `json:"id"`
+	Vector  []float32 `json:"vector,omitempty"`
+	Payload payload   `json:"payload"`
+	Score   float32   `json:"score,omitempty"`
+}
+
+// pointID qdrant 的点id只支持整数与uuid，由模型与分块id生成固定的uuid，同一分块的不同模型向量互不覆盖
+func pointID(model, id string) string {
+	sum := sha1.Sum([]byte(model + "/" + id))
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
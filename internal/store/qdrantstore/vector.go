@@ -0,0 +1,549 @@
+package qdrantstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pgvector/pgvector-go"
+	"github.com/samber/lo"
+
+	"github.com/starbx/brew-api/internal/store"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+var _ store.VectorStore = (*VectorStore)(nil)
+
+// 需要建立 payload 索引的字段，用于过滤
+var keywordFields = []string{"id", "knowledge_id", "space_id", "user_id", "resource", "model"}
+
+// VectorStore 基于 qdrant HTTP API 的向量存储，每种向量维度对应一个名为 {prefix}_{dim} 的 collection
+// 使用余弦距离，写入不参与数据库事务
+type VectorStore struct {
+	endpoint string
+	apiKey   string
+	prefix   string
+	client   *http.Client
+
+	// knowledges 用于按知识的元数据与保留周期过滤
+	knowledges store.KnowledgeStore
+
+	mu          sync.Mutex
+	collections map[int]struct{}
+}
+
+func NewVectorStore(endpoint, apiKey, prefix string, client *http.Client, knowledges store.KnowledgeStore) *VectorStore {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &VectorStore{
+		endpoint:    endpoint,
+		apiKey:      apiKey,
+		prefix:      prefix,
+		client:      client,
+		knowledges:  knowledges,
+		collections: make(map[int]struct{}),
+	}
+}
+
+func (s *VectorStore) GetTable(...interface{}) string {
+	return s.prefix
+}
+
+func (s *VectorStore) collectionName(dim int) string {
+	return fmt.Sprintf("%s_%d", s.prefix, dim)
+}
+
+// listCollections 返回当前前缀下全部 collection 对应的向量维度
+func (s *VectorStore) listCollections(ctx context.Context) ([]int, error) {
+	var res struct {
+		Collections []struct {
+			Name string `json:"name"`
+		} `json:"collections"`
+	}
+	if err := s.do(ctx, http.MethodGet, "/collections", nil, &res); err != nil {
+		return nil, fmt.Errorf("failed to list collections, %w", err)
+	}
+
+	var dims []int
+	for _, v := range res.Collections {
+		suffix, ok := strings.CutPrefix(v.Name, s.prefix+"_")
+		if !ok {
+			continue
+		}
+		if dim, err := strconv.Atoi(suffix); err == nil && dim > 0 {
+			dims = append(dims, dim)
+		}
+	}
+	sort.Ints(dims)
+	return dims, nil
+}
+
+// ensureCollection 维度对应的 collection 不存在时创建，并为过滤字段建立索引
+func (s *VectorStore) ensureCollection(ctx context.Context, dim int) error {
+	s.mu.Lock()
+	_, ok := s.collections[dim]
+	s.mu.Unlock()
+	if ok {
+		return nil
+	}
+
+	name := s.collectionName(dim)
+	err := s.do(ctx, http.MethodGet, "/collections/"+name, nil, nil)
+	if isNotFound(err) {
+		err = s.createCollection(ctx, name, dim)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.collections[dim] = struct{}{}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *VectorStore) createCollection(ctx context.Context, name string, dim int) error {
+	err := s.do(ctx, http.MethodPut, "/collections/"+name, map[string]any{
+		"vectors": map[string]any{
+			"size":     dim,
+			"distance": "Cosine",
+		},
+	}, nil)
+	// 其他实例可能同时创建了该 collection
+	if err != nil && !isConflict(err) {
+		return fmt.Errorf("failed to create collection %s, %w", name, err)
+	}
+
+	for _, field := range keywordFields {
+		if err = s.do(ctx, http.MethodPut, "/collections/"+name+"/index?wait=true", map[string]any{
+			"field_name":   field,
+			"field_schema": "keyword",
+		}, nil); err != nil {
+			return fmt.Errorf("failed to create index of %s, %w", field, err)
+		}
+	}
+	return nil
+}
+
+// Create 创建新的文本向量记录
+func (s *VectorStore) Create(ctx context.Context, data types.Vector) error {
+	return s.BatchCreate(ctx, []types.Vector{data})
+}
+
+// BatchCreate 按维度分组写入，同一模型下id相同的向量会被覆盖
+func (s *VectorStore) BatchCreate(ctx context.Context, datas []types.Vector) error {
+	groups := make(map[int][]point)
+	for _, v := range datas {
+		vec := v.Embedding.Slice()
+		if len(vec) == 0 {
+			return fmt.Errorf("embedding of vector %s is empty", v.ID)
+		}
+		groups[len(vec)] = append(groups[len(vec)], toPoint(v))
+	}
+
+	for dim, points := range groups {
+		if err := s.ensureCollection(ctx, dim); err != nil {
+			return err
+		}
+		if err := s.do(ctx, http.MethodPut, "/collections/"+s.collectionName(dim)+"/points?wait=true", map[string]any{
+			"points": points,
+		}, nil); err != nil {
+			return fmt.Errorf("failed to upsert points, %w", err)
+		}
+	}
+	return nil
+}
+
+func toPoint(v types.Vector) point {
+	now := time.Now().Unix()
+	if v.CreatedAt == 0 {
+		v.CreatedAt = now
+	}
+	if v.UpdatedAt == 0 {
+		v.UpdatedAt = now
+	}
+	return point{
+		ID:     pointID(v.Model, v.ID),
+		Vector: v.Embedding.Slice(),
+		Payload: payload{
+			ID:             v.ID,
+			KnowledgeID:    v.KnowledgeID,
+			SpaceID:        v.SpaceID,
+			Resource:       v.Resource,
+			UserID:         v.UserID,
+			Model:          v.Model,
+			OriginalLength: v.OriginalLength,
+			CreatedAt:      v.CreatedAt,
+			UpdatedAt:      v.UpdatedAt,
+		},
+	}
+}
+
+func toVector(p point, dim int) types.Vector {
+	return types.Vector{
+		ID:             p.Payload.ID,
+		KnowledgeID:    p.Payload.KnowledgeID,
+		SpaceID:        p.Payload.SpaceID,
+		Resource:       p.Payload.Resource,
+		UserID:         p.Payload.UserID,
+		Model:          p.Payload.Model,
+		Dim:            dim,
+		Embedding:      pgvector.NewVector(p.Vector),
+		OriginalLength: p.Payload.OriginalLength,
+		CreatedAt:      p.Payload.CreatedAt,
+		UpdatedAt:      p.Payload.UpdatedAt,
+	}
+}
+
+// scroll 获取 collection 中满足 f 的全部点
+func (s *VectorStore) scroll(ctx context.Context, dim int, f filter, withVector bool) ([]point, error) {
+	var (
+		list   []point
+		offset any
+	)
+	for {
+		var res struct {
+			Points         []point `json:"points"`
+			NextPageOffset any     `json:"next_page_offset"`
+		}
+		if err := s.do(ctx, http.MethodPost, "/collections/"+s.collectionName(dim)+"/points/scroll", map[string]any{
+			"filter":       f,
+			"limit":        QDRANT_SCROLL_LIMIT,
+			"offset":       offset,
+			"with_payload": true,
+			"with_vector":  withVector,
+		}, &res); err != nil {
+			return nil, fmt.Errorf("failed to scroll points, %w", err)
+		}
+		list = append(list, res.Points...)
+		if res.NextPageOffset == nil {
+			return list, nil
+		}
+		offset = res.NextPageOffset
+	}
+}
+
+// listVectors 获取全部 collection 中满足 f 的向量
+func (s *VectorStore) listVectors(ctx context.Context, f filter, withVector bool) ([]types.Vector, error) {
+	dims, err := s.listCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []types.Vector
+	for _, dim := range dims {
+		points, err := s.scroll(ctx, dim, f, withVector)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range points {
+			list = append(list, toVector(p, dim))
+		}
+	}
+	return list, nil
+}
+
+// deleteWhere 删除全部 collection 中满足 f 的点，返回删除的数量
+func (s *VectorStore) deleteWhere(ctx context.Context, f filter) (int64, error) {
+	dims, err := s.listCollections(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, dim := range dims {
+		count, err := s.count(ctx, dim, f)
+		if err != nil {
+			return total, err
+		}
+		if count == 0 {
+			continue
+		}
+		if err = s.do(ctx, http.MethodPost, "/collections/"+s.collectionName(dim)+"/points/delete?wait=true", map[string]any{
+			"filter": f,
+		}, nil); err != nil {
+			return total, fmt.Errorf("failed to delete points, %w", err)
+		}
+		total += count
+	}
+	return total, nil
+}
+
+func (s *VectorStore) count(ctx context.Context, dim int, f filter) (int64, error) {
+	var res struct {
+		Count int64 `json:"count"`
+	}
+	if err := s.do(ctx, http.MethodPost, "/collections/"+s.collectionName(dim)+"/points/count", map[string]any{
+		"filter": f,
+		"exact":  true,
+	}, &res); err != nil {
+		return 0, fmt.Errorf("failed to count points, %w", err)
+	}
+	return res.Count, nil
+}
+
+func vectorFilter(spaceID, knowledgeID, id string) filter {
+	f := filter{Must: []condition{matchValue("space_id", spaceID)}}
+	if knowledgeID != "" {
+		f.Must = append(f.Must, matchValue("knowledge_id", knowledgeID))
+	}
+	if id != "" {
+		f.Must = append(f.Must, matchValue("id", id))
+	}
+	return f
+}
+
+// GetVector 根据ID获取文本向量记录
+func (s *VectorStore) GetVector(ctx context.Context, spaceID, knowledgeID, id string) (*types.Vector, error) {
+	list, err := s.listVectors(ctx, vectorFilter(spaceID, knowledgeID, id), true)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &list[0], nil
+}
+
+// Update 更新文本向量记录，维度变化时移动到对应的 collection
+func (s *VectorStore) Update(ctx context.Context, spaceID, knowledgeID, id string, vector pgvector.Vector) error {
+	f := vectorFilter(spaceID, knowledgeID, id)
+	list, err := s.listVectors(ctx, f, false)
+	if err != nil || len(list) == 0 {
+		return err
+	}
+	if _, err = s.deleteWhere(ctx, f); err != nil {
+		return err
+	}
+
+	for i := range list {
+		list[i].Embedding = vector
+		list[i].Dim = len(vector.Slice())
+		list[i].UpdatedAt = time.Now().Unix()
+	}
+	return s.BatchCreate(ctx, list)
+}
+
+// Delete 删除文本向量记录
+func (s *VectorStore) Delete(ctx context.Context, spaceID, knowledgeID, id string) error {
+	_, err := s.deleteWhere(ctx, vectorFilter(spaceID, knowledgeID, id))
+	return err
+}
+
+func (s *VectorStore) BatchDelete(ctx context.Context, spaceID, knowledgeID string) error {
+	_, err := s.deleteWhere(ctx, vectorFilter(spaceID, knowledgeID, ""))
+	return err
+}
+
+func (s *VectorStore) DeleteAll(ctx context.Context, spaceID string) error {
+	_, err := s.deleteWhere(ctx, vectorFilter(spaceID, "", ""))
+	return err
+}
+
+// PruneModels 删除空间（spaceID 为空时为全部空间）中不是由 model 生成的向量，返回删除的数量
+func (s *VectorStore) PruneModels(ctx context.Context, spaceID, model string) (int64, error) {
+	f := filter{MustNot: []condition{matchValue("model", model)}}
+	if spaceID != "" {
+		f.Must = append(f.Must, matchValue("space_id", spaceID))
+	}
+	return s.deleteWhere(ctx, f)
+}
+
+// AdoptLegacy 将没有记录模型的向量标记为由 model 生成，返回标记的数量
+func (s *VectorStore) AdoptLegacy(ctx context.Context, spaceID, model string) (int64, error) {
+	// 点id由模型与分块id生成，修改模型需要以新的id重新写入
+	f := filter{Must: []condition{{IsEmpty: &isEmpty{Key: "model"}}}}
+	if spaceID != "" {
+		f.Must = append(f.Must, matchValue("space_id", spaceID))
+	}
+
+	list, err := s.listVectors(ctx, f, true)
+	if err != nil || len(list) == 0 {
+		return 0, err
+	}
+	for i := range list {
+		list[i].Model = model
+	}
+	if err = s.BatchCreate(ctx, list); err != nil {
+		return 0, err
+	}
+	return s.deleteWhere(ctx, f)
+}
+
+// EnsureIndex qdrant 在写入时自动维护向量索引，无需额外处理
+func (s *VectorStore) EnsureIndex(ctx context.Context, model string) error {
+	return nil
+}
+
+// ExistIDs 返回 ids 中已有 model 生成的向量的ID
+func (s *VectorStore) ExistIDs(ctx context.Context, model string, ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	list, err := s.listVectors(ctx, filter{Must: []condition{matchValue("model", model), matchAny("id", ids)}}, false)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(list))
+	for _, v := range list {
+		res = append(res, v.ID)
+	}
+	return res, nil
+}
+
+// ListVectors 分页获取文本向量记录列表，在全部 collection 中查询后按创建时间倒序排列
+func (s *VectorStore) ListVectors(ctx context.Context, opts types.GetVectorsOptions, page, pageSize uint64) ([]types.Vector, error) {
+	f, empty, err := s.buildFilter(ctx, opts)
+	if err != nil || empty {
+		return nil, err
+	}
+
+	list, err := s.listVectors(ctx, f, true)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreatedAt != list[j].CreatedAt {
+			return list[i].CreatedAt > list[j].CreatedAt
+		}
+		return list[i].ID < list[j].ID
+	})
+
+	if pageSize == types.NO_PAGING {
+		return list, nil
+	}
+	if page == 0 {
+		page = 1
+	}
+	start := (page - 1) * pageSize
+	if start >= uint64(len(list)) {
+		return nil, nil
+	}
+	return list[start:min(start+pageSize, uint64(len(list)))], nil
+}
+
+// Query 在与 vectors 维度相同的 collection 中检索余弦距离最近的 limit 个向量
+func (s *VectorStore) Query(ctx context.Context, opts types.GetVectorsOptions, vectors pgvector.Vector, limit uint64) ([]types.QueryResult, error) {
+	f, empty, err := s.buildFilter(ctx, opts)
+	if err != nil || empty {
+		return nil, err
+	}
+
+	var points []point
+	err = s.do(ctx, http.MethodPost, "/collections/"+s.collectionName(len(vectors.Slice()))+"/points/search", map[string]any{
+		"vector":       vectors.Slice(),
+		"filter":       f,
+		"limit":        limit,
+		"with_payload": true,
+	}, &points)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to search points, %w", err)
+	}
+
+	res := make([]types.QueryResult, 0, len(points))
+	for _, p := range points {
+		res = append(res, types.QueryResult{
+			ID:          p.Payload.ID,
+			KnowledgeID: p.Payload.KnowledgeID,
+			// qdrant 返回余弦相似度，转换为与 pgvector 一致的余弦距离
+			Cos:            1 - p.Score,
+			OriginalLength: p.Payload.OriginalLength,
+		})
+	}
+	return res, nil
+}
+
+// HybridQuery qdrant 中不保存分块原文，无法进行全文检索，只按向量检索的排名计算融合得分
+func (s *VectorStore) HybridQuery(ctx context.Context, opts types.GetVectorsOptions, query types.HybridQuery, limit uint64) ([]types.QueryResult, error) {
+	res, err := s.Query(ctx, opts, query.Embedding, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range res {
+		res[i].Score = query.VectorWeight / float32(query.RRFK+i+1)
+	}
+	return res, nil
+}
+
+// buildFilter 将 GetVectorsOptions 转换为 qdrant 的 payload 过滤条件，知识元数据与保留周期的条件通过 KnowledgeStore 预先查询出知识id
+// 按知识元数据过滤后没有任何知识时 empty 为 true
+func (s *VectorStore) buildFilter(ctx context.Context, opts types.GetVectorsOptions) (f filter, empty bool, err error) {
+	if opts.ID != "" {
+		f.Must = append(f.Must, matchValue("id", opts.ID))
+	}
+	if opts.KnowledgeID != "" {
+		f.Must = append(f.Must, matchValue("knowledge_id", opts.KnowledgeID))
+	}
+	if opts.ExcludeKnowledgeID != "" {
+		f.MustNot = append(f.MustNot, matchValue("knowledge_id", opts.ExcludeKnowledgeID))
+	}
+	if opts.SpaceID != "" {
+		f.Must = append(f.Must, matchValue("space_id", opts.SpaceID))
+	}
+	if opts.UserID != "" {
+		f.Must = append(f.Must, matchValue("user_id", opts.UserID))
+	}
+	if opts.Model != "" {
+		f.Must = append(f.Must, matchValue("model", opts.Model))
+	}
+	if opts.Resource != nil {
+		if len(opts.Resource.Include) > 0 {
+			f.Must = append(f.Must, matchAny("resource", opts.Resource.Include))
+		} else if len(opts.Resource.Exclude) > 0 {
+			f.MustNot = append(f.MustNot, matchAny("resource", opts.Resource.Exclude))
+		}
+	}
+
+	if (!opts.Filter.IsEmpty() || opts.ExcludeExpiredAt > 0) && s.knowledges == nil {
+		return f, false, fmt.Errorf("knowledge filter is not supported without knowledge store")
+	}
+
+	if !opts.Filter.IsEmpty() {
+		ids, err := s.knowledgeIDs(ctx, types.GetKnowledgeOptions{
+			SpaceID: opts.SpaceID,
+			Filter:  opts.Filter,
+		})
+		if err != nil {
+			return f, false, err
+		}
+		if len(ids) == 0 {
+			return f, true, nil
+		}
+		f.Must = append(f.Must, matchAny("knowledge_id", ids))
+	}
+	if opts.ExcludeExpiredAt > 0 {
+		ids, err := s.knowledgeIDs(ctx, types.GetKnowledgeOptions{
+			SpaceID:   opts.SpaceID,
+			ExpiredAt: opts.ExcludeExpiredAt,
+		})
+		if err != nil {
+			return f, false, err
+		}
+		if len(ids) > 0 {
+			f.MustNot = append(f.MustNot, matchAny("knowledge_id", ids))
+		}
+	}
+	return f, false, nil
+}
+
+func (s *VectorStore) knowledgeIDs(ctx context.Context, opts types.GetKnowledgeOptions) ([]string, error) {
+	list, err := s.knowledges.ListLiteKnowledges(ctx, opts, types.NO_PAGING, types.NO_PAGING)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return lo.Map(list, func(item *types.KnowledgeLite, _ int) string {
+		return item.ID
+	}), nil
+}
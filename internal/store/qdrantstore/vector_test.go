@@ -0,0 +1,316 @@
+package qdrantstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pgvector/pgvector-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+// fakeQdrant 实现测试用到的 qdrant HTTP API 子集
+type fakeQdrant struct {
+	t           *testing.T
+	mu          sync.Mutex
+	collections map[string]*fakeCollection
+}
+
+type fakeCollection struct {
+	dim    int
+	points map[string]point
+}
+
+func newFakeQdrant(t *testing.T) *httptest.Server {
+	f := &fakeQdrant{t: t, collections: make(map[string]*fakeCollection)}
+	return httptest.NewServer(f)
+}
+
+func (f *fakeQdrant) reply(w http.ResponseWriter, result any) {
+	json.NewEncoder(w).Encode(map[string]any{"result": result, "status": "ok"})
+}
+
+func (f *fakeQdrant) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	assert.Equal(f.t, "secret", r.Header.Get("api-key"))
+
+	var body struct {
+		Vectors struct {
+			Size int `json:"size"`
+		} `json:"vectors"`
+		Points  []point   `json:"points"`
+		Vector  []float32 `json:"vector"`
+		Filter  filter    `json:"filter"`
+		Limit   int       `json:"limit"`
+		Offset  *int      `json:"offset"`
+		WithVec bool      `json:"with_vector"`
+	}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 1 {
+		var names []map[string]string
+		for name := range f.collections {
+			names = append(names, map[string]string{"name": name})
+		}
+		f.reply(w, map[string]any{"collections": names})
+		return
+	}
+
+	c, ok := f.collections[parts[1]]
+	if len(parts) == 2 {
+		switch {
+		case r.Method == http.MethodPut && ok:
+			w.WriteHeader(http.StatusConflict)
+		case r.Method == http.MethodPut:
+			f.collections[parts[1]] = &fakeCollection{dim: body.Vectors.Size, points: make(map[string]point)}
+			f.reply(w, true)
+		case ok:
+			f.reply(w, map[string]any{"status": "green"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+		return
+	}
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch strings.Join(parts[2:], "/") {
+	case "index":
+		f.reply(w, true)
+	case "points":
+		for _, p := range body.Points {
+			assert.Len(f.t, p.Vector, c.dim)
+			c.points[p.ID] = p
+		}
+		f.reply(w, true)
+	case "points/delete":
+		for id, p := range c.points {
+			if body.Filter.match(p.Payload) {
+				delete(c.points, id)
+			}
+		}
+		f.reply(w, true)
+	case "points/count":
+		var count int
+		for _, p := range c.points {
+			if body.Filter.match(p.Payload) {
+				count++
+			}
+		}
+		f.reply(w, map[string]int{"count": count})
+	case "points/scroll":
+		var list []point
+		for _, p := range c.points {
+			if body.Filter.match(p.Payload) {
+				if !body.WithVec {
+					p.Vector = nil
+				}
+				list = append(list, p)
+			}
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+		start := 0
+		if body.Offset != nil {
+			start = *body.Offset
+		}
+		end := min(start+body.Limit, len(list))
+		var next any
+		if end < len(list) {
+			next = end
+		}
+		f.reply(w, map[string]any{"points": list[start:end], "next_page_offset": next})
+	case "points/search":
+		var list []point
+		for _, p := range c.points {
+			if body.Filter.match(p.Payload) {
+				p.Score = cosine(body.Vector, p.Vector)
+				p.Vector = nil
+				list = append(list, p)
+			}
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Score > list[j].Score })
+		f.reply(w, list[:min(body.Limit, len(list))])
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f filter) match(p payload) bool {
+	raw, _ := json.Marshal(p)
+	fields := map[string]any{}
+	json.Unmarshal(raw, &fields)
+
+	check := func(c condition) bool {
+		if c.IsEmpty != nil {
+			v, ok := fields[c.IsEmpty.Key]
+			return !ok || v == ""
+		}
+		v, ok := fields[c.Key].(string)
+		if !ok {
+			return false
+		}
+		if len(c.Match.Any) > 0 {
+			for _, item := range c.Match.Any {
+				if item == v {
+					return true
+				}
+			}
+			return false
+		}
+		return c.Match.Value == v
+	}
+	for _, c := range f.Must {
+		if !check(c) {
+			return false
+		}
+	}
+	for _, c := range f.MustNot {
+		if check(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func cosine(a, b []float32) float32 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	return float32(dot / (math.Sqrt(na) * math.Sqrt(nb)))
+}
+
+func TestVectorStore(t *testing.T) {
+	server := newFakeQdrant(t)
+	defer server.Close()
+
+	ctx := context.Background()
+	s := NewVectorStore(server.URL, "secret", "test", server.Client(), nil)
+
+	err := s.BatchCreate(ctx, []types.Vector{
+		{ID: "a", KnowledgeID: "k1", SpaceID: "s1", Resource: "knowledge", Model: "m1", Embedding: pgvector.NewVector([]float32{1, 0, 0}), OriginalLength: 7},
+		{ID: "b", KnowledgeID: "k1", SpaceID: "s1", Resource: "note", Model: "m1", Embedding: pgvector.NewVector([]float32{1, 1, 0})},
+		{ID: "c", KnowledgeID: "k2", SpaceID: "s1", Resource: "knowledge", Model: "m1", Embedding: pgvector.NewVector([]float32{0, 1, 0})},
+		{ID: "d", KnowledgeID: "k3", SpaceID: "s2", Resource: "knowledge", Model: "m1", Embedding: pgvector.NewVector([]float32{1, 0, 0})},
+		{ID: "a", KnowledgeID: "k1", SpaceID: "s1", Resource: "knowledge", Model: "m2", Embedding: pgvector.NewVector([]float32{0, 0, 1, 0})},
+		{ID: "e", KnowledgeID: "k4", SpaceID: "s1", Resource: "knowledge", Embedding: pgvector.NewVector([]float32{0, 0, 1})},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func(res []types.QueryResult) []string {
+		var list []string
+		for _, v := range res {
+			list = append(list, v.ID)
+		}
+		return list
+	}
+
+	res, err := s.Query(ctx, types.GetVectorsOptions{SpaceID: "s1", Model: "m1"}, pgvector.NewVector([]float32{1, 0, 0}), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, ids(res))
+	assert.InDelta(t, 0, res[0].Cos, 1e-6)
+	assert.Equal(t, 7, res[0].OriginalLength)
+
+	res, err = s.Query(ctx, types.GetVectorsOptions{SpaceID: "s1", Model: "m1", Resource: &types.ResourceQuery{Exclude: []string{"knowledge"}}}, pgvector.NewVector([]float32{1, 0, 0}), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b"}, ids(res))
+
+	res, err = s.Query(ctx, types.GetVectorsOptions{Model: "m2"}, pgvector.NewVector([]float32{0, 0, 1, 0}), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, ids(res))
+
+	// 不存在该维度的 collection
+	res, err = s.Query(ctx, types.GetVectorsOptions{}, pgvector.NewVector([]float32{1, 0}), 10)
+	assert.NoError(t, err)
+	assert.Empty(t, res)
+
+	v, err := s.GetVector(ctx, "s1", "k1", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, v.Dim)
+	assert.Equal(t, "m1", v.Model)
+	assert.Equal(t, []float32{1, 0, 0}, v.Embedding.Slice())
+
+	_, err = s.GetVector(ctx, "s1", "k1", "none")
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	exist, err := s.ExistIDs(ctx, "m1", []string{"a", "c", "e", "none"})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "c"}, exist)
+
+	adopted, err := s.AdoptLegacy(ctx, "s1", "m1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), adopted)
+
+	list, err := s.ListVectors(ctx, types.GetVectorsOptions{SpaceID: "s1", Model: "m1"}, types.NO_PAGING, types.NO_PAGING)
+	assert.NoError(t, err)
+	assert.Len(t, list, 4)
+
+	assert.NoError(t, s.BatchDelete(ctx, "s1", "k1"))
+	res, err = s.Query(ctx, types.GetVectorsOptions{SpaceID: "s1"}, pgvector.NewVector([]float32{1, 1, 0}), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "e"}, ids(res))
+
+	assert.NoError(t, s.Create(ctx, types.Vector{ID: "f", KnowledgeID: "k5", SpaceID: "s2", Model: "m2", Embedding: pgvector.NewVector([]float32{0, 1, 0, 0})}))
+	pruned, err := s.PruneModels(ctx, "s2", "m1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), pruned)
+
+	assert.NoError(t, s.DeleteAll(ctx, "s2"))
+	list, err = s.ListVectors(ctx, types.GetVectorsOptions{}, 1, 1)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+}
+
+func TestVectorStoreScroll(t *testing.T) {
+	server := newFakeQdrant(t)
+	defer server.Close()
+
+	ctx := context.Background()
+	s := NewVectorStore(server.URL, "secret", "test", server.Client(), nil)
+
+	var datas []types.Vector
+	for i := range QDRANT_SCROLL_LIMIT + 10 {
+		datas = append(datas, types.Vector{
+			ID:          fmt.Sprintf("v%d", i),
+			KnowledgeID: "k1",
+			SpaceID:     "s1",
+			Model:       "m1",
+			Embedding:   pgvector.NewVector([]float32{1, float32(i)}),
+		})
+	}
+	assert.NoError(t, s.BatchCreate(ctx, datas))
+
+	list, err := s.ListVectors(ctx, types.GetVectorsOptions{SpaceID: "s1"}, types.NO_PAGING, types.NO_PAGING)
+	assert.NoError(t, err)
+	assert.Len(t, list, len(datas))
+
+	_, err = s.Query(ctx, types.GetVectorsOptions{Filter: &types.KnowledgeFilter{Tags: []string{"go"}}}, pgvector.NewVector([]float32{1, 0}), 10)
+	assert.Error(t, err)
+}
+
+func TestPointID(t *testing.T) {
+	assert.Equal(t, pointID("m1", "a"), pointID("m1", "a"))
+	assert.NotEqual(t, pointID("m1", "a"), pointID("m2", "a"))
+	assert.Len(t, pointID("m1", "a"), 36)
+}
@@ -0,0 +1,102 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/starbx/brew-api/pkg/register"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+func init() {
+	register.RegisterFunc(registerKey{}, func() {
+		provider.stores.BlobStore = NewBlobStore(provider)
+	})
+}
+
+// BlobStore 处理 bw_blob 表的操作，启用加密存储时文件内容同样使用空间的数据密钥加密
+type BlobStore struct {
+	CommonFields
+}
+
+// NewBlobStore 创建一个新的 BlobStore 实例
+func NewBlobStore(provider SqlProviderAchieve) *BlobStore {
+	repo := &BlobStore{}
+	repo.SetProvider(provider)
+	repo.SetTable(types.TABLE_BLOB)
+	repo.SetAllColumns("space_id", "id", "data", "created_at")
+	return repo
+}
+
+// Put 保存文件，已存在时覆盖
+func (s *BlobStore) Put(ctx context.Context, spaceID, id string, data []byte) error {
+	if encryptor := s.provider.Encryptor(); encryptor != nil {
+		encrypted, err := encryptor.Encrypt(ctx, spaceID, string(data))
+		if err != nil {
+			return err
+		}
+		data = []byte(encrypted)
+	}
+
+	query := sq.Insert(s.GetTable()).
+		Columns("space_id", "id", "data", "created_at").
+		Values(spaceID, id, data, time.Now().Unix()).
+		Suffix("ON CONFLICT (space_id, id) DO UPDATE SET data = EXCLUDED.data, created_at = EXCLUDED.created_at")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// Get 获取文件内容，不存在时返回 sql.ErrNoRows
+func (s *BlobStore) Get(ctx context.Context, spaceID, id string) ([]byte, error) {
+	query := sq.Select("data").From(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "id": id})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	var data []byte
+	if err = s.GetReplica(ctx).Get(&data, queryString, args...); err != nil {
+		return nil, err
+	}
+
+	plaintext, err := s.provider.Encryptor().Decrypt(ctx, spaceID, string(data))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(plaintext), nil
+}
+
+// Delete 删除文件
+func (s *BlobStore) Delete(ctx context.Context, spaceID, id string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "id": id})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// DeleteAll 删除空间的全部文件
+func (s *BlobStore) DeleteAll(ctx context.Context, spaceID string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
@@ -0,0 +1,14 @@
+-- 创建表 bw_blob
+CREATE TABLE bw_blob (
+    space_id VARCHAR(32) NOT NULL, -- 空间ID
+    id VARCHAR(32) NOT NULL, -- 文件ID，与所属知识的ID相同
+    data BYTEA NOT NULL, -- 文件内容
+    created_at BIGINT NOT NULL, -- 创建时间
+    PRIMARY KEY (space_id, id)
+);
+
+-- 为字段添加注释
+COMMENT ON COLUMN bw_blob.space_id IS '空间ID';
+COMMENT ON COLUMN bw_blob.id IS '文件ID，与所属知识的ID相同';
+COMMENT ON COLUMN bw_blob.data IS '文件内容，启用加密存储时为加密后的内容';
+COMMENT ON COLUMN bw_blob.created_at IS '创建时间';
@@ -29,13 +29,27 @@ func NewChatMessageStore(provider SqlProviderAchieve) *ChatMessageStore {
 	return repo
 }
 
+// decrypt 解密消息内容
+func (s *ChatMessageStore) decrypt(ctx context.Context, list ...*types.ChatMessage) error {
+	for _, v := range list {
+		if err := s.provider.Encryptor().decryptFields(ctx, v.SpaceID, &v.Message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *ChatMessageStore) Create(ctx context.Context, data *types.ChatMessage) error {
 	if data.SendTime == 0 {
 		data.SendTime = time.Now().Unix()
 	}
+	message, err := s.provider.Encryptor().Encrypt(ctx, data.SpaceID, data.Message)
+	if err != nil {
+		return err
+	}
 	query := sq.Insert(s.GetTable()).
 		Columns("id", "space_id", "user_id", "role", "message", "msg_type", "send_time", "session_id", "complete", "sequence", "msg_block").
-		Values(data.ID, data.SpaceID, data.UserID, data.Role, data.Message, data.MsgType, data.SendTime, data.SessionID, data.Complete, data.Sequence, data.MsgBlock)
+		Values(data.ID, data.SpaceID, data.UserID, data.Role, message, data.MsgType, data.SendTime, data.SessionID, data.Complete, data.Sequence, data.MsgBlock)
 
 	queryString, args, err := query.ToSql()
 	if err != nil {
@@ -60,11 +74,18 @@ func (s *ChatMessageStore) GetOne(ctx context.Context, id string) (*types.ChatMe
 	if err := s.GetReplica(ctx).Get(&msg, queryString, args...); err != nil {
 		return nil, err
 	}
+	if err = s.decrypt(ctx, &msg); err != nil {
+		return nil, err
+	}
 	return &msg, nil
 }
 
 func (s *ChatMessageStore) RewriteMessage(ctx context.Context, spaceID, sessionID, id string, message json.RawMessage, complete int32) error {
-	query := sq.Update(s.GetTable()).Set("message", message).Set("complete", complete).Where(sq.Eq{"space_id": spaceID, "session_id": sessionID, "id": id})
+	encrypted, err := s.provider.Encryptor().Encrypt(ctx, spaceID, string(message))
+	if err != nil {
+		return err
+	}
+	query := sq.Update(s.GetTable()).Set("message", encrypted).Set("complete", complete).Where(sq.Eq{"space_id": spaceID, "session_id": sessionID, "id": id})
 	queryString, args, err := query.ToSql()
 	if err != nil {
 		return errorSqlBuild(err)
@@ -77,6 +98,9 @@ func (s *ChatMessageStore) RewriteMessage(ctx context.Context, spaceID, sessionI
 }
 
 func (s *ChatMessageStore) AppendMessage(ctx context.Context, spaceID, sessionID, id string, message json.RawMessage, complete int32) error {
+	if s.provider.Encryptor() != nil {
+		return s.appendEncryptedMessage(ctx, spaceID, sessionID, id, message, complete)
+	}
 	query := sq.Update(s.GetTable()).Set("message", sq.Expr("message || ?", message)).Set("complete", complete).Where(sq.Eq{"space_id": spaceID, "session_id": sessionID, "id": id})
 	queryString, args, err := query.ToSql()
 	if err != nil {
@@ -90,6 +114,25 @@ func (s *ChatMessageStore) AppendMessage(ctx context.Context, spaceID, sessionID
 	return nil
 }
 
+// appendEncryptedMessage 密文无法在数据库中直接拼接，读取后解密拼接再重新写入
+// 同一条消息的追加由生成回答的协程顺序执行，不存在并发写入
+func (s *ChatMessageStore) appendEncryptedMessage(ctx context.Context, spaceID, sessionID, id string, message json.RawMessage, complete int32) error {
+	query := sq.Select("message").From(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "session_id": sessionID, "id": id})
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	var current string
+	if err = s.QueryMaster(ctx).Get(&current, queryString, args...); err != nil {
+		return err
+	}
+	if current, err = s.provider.Encryptor().Decrypt(ctx, spaceID, current); err != nil {
+		return err
+	}
+	return s.RewriteMessage(ctx, spaceID, sessionID, id, json.RawMessage(current+string(message)), complete)
+}
+
 func (s *ChatMessageStore) UpdateMessageCompleteStatus(ctx context.Context, sessionID, id string, complete int32) error {
 	query := sq.Update(s.GetTable()).Set("complete", complete).Where(sq.Eq{"session_id": sessionID, "id": id})
 	queryString, args, err := query.ToSql()
@@ -144,6 +187,9 @@ func (s *ChatMessageStore) ListSessionMessageUpToGivenID(ctx context.Context, sp
 	if err = s.GetReplica(ctx).Select(&list, queryString, args...); err != nil {
 		return nil, err
 	}
+	if err = s.decrypt(ctx, list...); err != nil {
+		return nil, err
+	}
 	return list, nil
 }
 
@@ -164,6 +210,9 @@ func (s *ChatMessageStore) ListSessionMessage(ctx context.Context, spaceID, sess
 	if err = s.GetReplica(ctx).Select(&list, queryString, args...); err != nil {
 		return nil, err
 	}
+	if err = s.decrypt(ctx, list...); err != nil {
+		return nil, err
+	}
 	return list, nil
 }
 
@@ -210,6 +259,9 @@ func (s *ChatMessageStore) GetMessagesByIDs(ctx context.Context, msgIDs []string
 	if err = s.GetReplica(ctx).Select(&msgs, queryString, args...); err != nil {
 		return nil, err
 	}
+	if err = s.decrypt(ctx, msgs...); err != nil {
+		return nil, err
+	}
 
 	return msgs, nil
 }
@@ -225,6 +277,9 @@ func (s *ChatMessageStore) GetSessionLatestUserMessage(ctx context.Context, spac
 	if err := s.GetReplica(ctx).Get(&msg, queryString, args...); err != nil {
 		return nil, err
 	}
+	if err = s.decrypt(ctx, &msg); err != nil {
+		return nil, err
+	}
 	return &msg, nil
 }
 
@@ -239,6 +294,9 @@ func (s *ChatMessageStore) GetSessionLatestMessage(ctx context.Context, spaceID,
 	if err := s.GetReplica(ctx).Get(&msg, queryString, args...); err != nil {
 		return nil, err
 	}
+	if err = s.decrypt(ctx, &msg); err != nil {
+		return nil, err
+	}
 	return &msg, nil
 }
 
@@ -152,6 +152,22 @@ func (s *ChatSessionStore) List(ctx context.Context, spaceID, userID string, pag
 	return list, nil
 }
 
+// ListSpaceSessions 分页获取空间下所有用户的会话
+func (s *ChatSessionStore) ListSpaceSessions(ctx context.Context, spaceID string, page, pageSize uint64) ([]types.ChatSession, error) {
+	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).Where(sq.Eq{"space_id": spaceID}).Limit(pageSize).Offset((page - 1) * pageSize).OrderBy("created_at, id")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	var list []types.ChatSession
+	if err = s.GetReplica(ctx).Select(&list, queryString, args...); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
 func (s *ChatSessionStore) Total(ctx context.Context, spaceID, userID string) (int64, error) {
 	query := sq.Select("COUNT(*)").From(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "user_id": userID})
 
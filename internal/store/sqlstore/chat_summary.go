@@ -62,3 +62,19 @@ func (s *ChatSummaryStore) Create(ctx context.Context, data types.ChatSummary) e
 	}
 	return nil
 }
+
+// ListSessionSummaries 获取会话下的全部摘要
+func (s *ChatSummaryStore) ListSessionSummaries(ctx context.Context, sessionID string) ([]types.ChatSummary, error) {
+	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).Where(sq.Eq{"session_id": sessionID}).OrderBy("created_at")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	var list []types.ChatSummary
+	if err = s.GetReplica(ctx).Select(&list, queryString, args...); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
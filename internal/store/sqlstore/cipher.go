@@ -0,0 +1,435 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/starbx/brew-api/pkg/security"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+const (
+	// ENCRYPTED_PREFIX 加密内容的前缀，完整格式为 $enc$v1${数据密钥版本}${base64(nonce+密文)}，不带前缀的内容视为明文
+	ENCRYPTED_PREFIX = "$enc$v1$"
+	// PLAINTEXT_PREFIX 未启用加密时，以 ENCRYPTED_PREFIX 或该前缀开头的明文写入前会加上该前缀，以免被当作密文读取
+	PLAINTEXT_PREFIX = "$plain$"
+	// SPACE_KEY_CACHE_TTL 数据密钥在进程内的缓存时间，轮换后其他实例最迟在该时间后开始使用新版本
+	SPACE_KEY_CACHE_TTL = 5 * time.Minute
+)
+
+// encryptedColumn 需要加密存储的列，reset 为重新加密时需要一并更新的列，binary 表示列的类型为 BYTEA
+type encryptedColumn struct {
+	table  string
+	column string
+	reset  map[string]any
+	binary bool
+}
+
+func (c encryptedColumn) value(v string) any {
+	if c.binary {
+		return []byte(v)
+	}
+	return v
+}
+
+var encryptedColumns = []encryptedColumn{
+	// 内容哈希可用于比对明文，加密后清空
+	{table: types.TABLE_KNOWLEDGE.Name(), column: "content", reset: map[string]any{"content_hash": ""}},
+	// 加密后的分块不再生成全文检索向量，以免分词结果泄露内容
+	{table: types.TABLE_KNOWLEDGE_CHUNK.Name(), column: "chunk", reset: map[string]any{"tsv": nil}},
+	{table: types.TABLE_KNOWLEDGE_REVISION.Name(), column: "content"},
+	{table: types.TABLE_CHAT_MESSAGE.Name(), column: "message"},
+	{table: types.TABLE_BLOB.Name(), column: "data", binary: true},
+}
+
+type spaceKeys struct {
+	latest   int
+	keys     map[int][]byte
+	expireAt time.Time
+}
+
+// Encryptor 对空间内容进行信封加密，每个空间使用独立的数据密钥，数据密钥由主密钥包裹后存储在 bw_space_key
+// 空间第一次写入内容时生成数据密钥，以空间ID作为附加认证数据，密文无法被挪用到其他空间
+// 为空时不加密，读取时遇到密文原样返回
+type Encryptor struct {
+	CommonFields
+	keyring *security.Keyring
+
+	mu    sync.Mutex
+	cache map[string]*spaceKeys
+}
+
+func NewEncryptor(provider SqlProviderAchieve, keyring *security.Keyring) *Encryptor {
+	e := &Encryptor{
+		keyring: keyring,
+		cache:   make(map[string]*spaceKeys),
+	}
+	e.SetProvider(provider)
+	return e
+}
+
+// loadKeys 获取空间的数据密钥，缓存过期或 force 时从数据库重新读取
+// 数据密钥的读写不参与调用方的事务，避免事务回滚后缓存中留下未持久化的密钥
+func (e *Encryptor) loadKeys(ctx context.Context, spaceID string, force bool) (*spaceKeys, error) {
+	e.mu.Lock()
+	cached, ok := e.cache[spaceID]
+	e.mu.Unlock()
+	if ok && !force && time.Now().Before(cached.expireAt) {
+		return cached, nil
+	}
+
+	list, err := e.provider.store().SpaceKeyStore.List(context.Background(), spaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &spaceKeys{keys: make(map[int][]byte), expireAt: time.Now().Add(SPACE_KEY_CACHE_TTL)}
+	for _, v := range list {
+		key, err := e.keyring.Unwrap(v.WrappedKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unwrap data key %d of space %s, %w", v.Version, spaceID, err)
+		}
+		res.keys[v.Version] = key
+		res.latest = max(res.latest, v.Version)
+	}
+
+	e.mu.Lock()
+	e.cache[spaceID] = res
+	e.mu.Unlock()
+	return res, nil
+}
+
+// createKey 为空间生成指定版本的数据密钥，其他实例已生成该版本时使用已有的密钥
+func (e *Encryptor) createKey(ctx context.Context, spaceID string, version int) (*spaceKeys, bool, error) {
+	key, err := security.GenDataKey()
+	if err != nil {
+		return nil, false, err
+	}
+	wrapped, err := e.keyring.Wrap(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	created, err := e.provider.store().SpaceKeyStore.Create(context.Background(), types.SpaceKey{
+		SpaceID:    spaceID,
+		Version:    version,
+		WrappedKey: wrapped,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	keys, err := e.loadKeys(ctx, spaceID, true)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, ok := keys.keys[version]; !ok {
+		return nil, false, fmt.Errorf("data key %d of space %s not found", version, spaceID)
+	}
+	return keys, created, nil
+}
+
+// Encrypt 使用空间最新版本的数据密钥加密内容，空内容不加密
+func (e *Encryptor) Encrypt(ctx context.Context, spaceID, plaintext string) (string, error) {
+	if plaintext == "" {
+		return plaintext, nil
+	}
+	if e == nil {
+		return escapePlaintext(plaintext), nil
+	}
+
+	keys, err := e.loadKeys(ctx, spaceID, false)
+	if err != nil {
+		return "", err
+	}
+	if keys.latest == 0 {
+		if keys, _, err = e.createKey(ctx, spaceID, 1); err != nil {
+			return "", err
+		}
+	}
+	return e.seal(spaceID, keys.latest, keys.keys[keys.latest], plaintext)
+}
+
+func (e *Encryptor) seal(spaceID string, version int, key []byte, plaintext string) (string, error) {
+	sealed, err := security.Seal(key, []byte(plaintext), []byte(spaceID))
+	if err != nil {
+		return "", err
+	}
+	return ENCRYPTED_PREFIX + strconv.Itoa(version) + "$" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// ContentHash 返回可以落库的内容哈希，启用加密时返回空
+// 未加盐的哈希可以通过比对猜测出明文，加密后不再保存，相同内容的去重随之失效
+func (e *Encryptor) ContentHash(hash string) string {
+	if e != nil {
+		return ""
+	}
+	return hash
+}
+
+// escapePlaintext 为可能被误认为密文的明文加上 PLAINTEXT_PREFIX，读取时由 Decrypt 去除
+func escapePlaintext(plaintext string) string {
+	if strings.HasPrefix(plaintext, ENCRYPTED_PREFIX) || strings.HasPrefix(plaintext, PLAINTEXT_PREFIX) {
+		return PLAINTEXT_PREFIX + plaintext
+	}
+	return plaintext
+}
+
+// parseEncrypted 解析密文的数据密钥版本与内容，格式不符时返回 false
+func parseEncrypted(value string) (int, []byte, bool) {
+	rawVersion, data, ok := strings.Cut(strings.TrimPrefix(value, ENCRYPTED_PREFIX), "$")
+	if !ok {
+		return 0, nil, false
+	}
+	version, err := strconv.Atoi(rawVersion)
+	if err != nil {
+		return 0, nil, false
+	}
+	sealed, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return 0, nil, false
+	}
+	return version, sealed, true
+}
+
+// Decrypt 解密 Encrypt 的结果，明文内容原样返回，以兼容启用加密前写入的数据
+// 未启用加密或格式不符的内容同样视为明文，单条内容无法解析时不影响同一列表中的其他内容
+func (e *Encryptor) Decrypt(ctx context.Context, spaceID, value string) (string, error) {
+	if strings.HasPrefix(value, PLAINTEXT_PREFIX) {
+		return strings.TrimPrefix(value, PLAINTEXT_PREFIX), nil
+	}
+	if e == nil || !strings.HasPrefix(value, ENCRYPTED_PREFIX) {
+		return value, nil
+	}
+
+	version, sealed, ok := parseEncrypted(value)
+	if !ok {
+		return value, nil
+	}
+
+	keys, err := e.loadKeys(ctx, spaceID, false)
+	if err != nil {
+		return "", err
+	}
+	key, ok := keys.keys[version]
+	if !ok {
+		// 其他实例轮换了密钥
+		if keys, err = e.loadKeys(ctx, spaceID, true); err != nil {
+			return "", err
+		}
+		if key, ok = keys.keys[version]; !ok {
+			return "", fmt.Errorf("data key %d of space %s not found", version, spaceID)
+		}
+	}
+
+	plaintext, err := security.Open(key, sealed, []byte(spaceID))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// decryptFields 依次解密同一空间的多个字段
+func (e *Encryptor) decryptFields(ctx context.Context, spaceID string, fields ...*string) error {
+	for _, v := range fields {
+		plaintext, err := e.Decrypt(ctx, spaceID, *v)
+		if err != nil {
+			return err
+		}
+		*v = plaintext
+	}
+	return nil
+}
+
+// Rotate 为空间生成新版本的数据密钥，之后写入的内容使用新版本加密，已有内容需要通过 Reencrypt 重新加密
+func (e *Encryptor) Rotate(ctx context.Context, spaceID string) (int, error) {
+	keys, err := e.loadKeys(ctx, spaceID, true)
+	if err != nil {
+		return 0, err
+	}
+
+	version := keys.latest + 1
+	_, created, err := e.createKey(ctx, spaceID, version)
+	if err != nil {
+		return 0, err
+	}
+	if !created {
+		return 0, fmt.Errorf("data key %d of space %s is being created by others", version, spaceID)
+	}
+	return version, nil
+}
+
+// Rewrap 使用当前主密钥重新包裹全部数据密钥，完成后即可从配置中移除旧的主密钥
+func (e *Encryptor) Rewrap(ctx context.Context) (int64, error) {
+	const pageSize = 100
+	var rewrapped int64
+	for page := uint64(1); ; page++ {
+		list, err := e.provider.store().SpaceKeyStore.ListAll(ctx, page, pageSize)
+		if err != nil {
+			return rewrapped, err
+		}
+
+		for _, v := range list {
+			if e.keyring.IsCurrent(v.WrappedKey) {
+				continue
+			}
+			key, err := e.keyring.Unwrap(v.WrappedKey)
+			if err != nil {
+				return rewrapped, fmt.Errorf("failed to unwrap data key %d of space %s, %w", v.Version, v.SpaceID, err)
+			}
+			wrapped, err := e.keyring.Wrap(key)
+			if err != nil {
+				return rewrapped, err
+			}
+			if err = e.provider.store().SpaceKeyStore.UpdateWrappedKey(ctx, v.SpaceID, v.Version, wrapped); err != nil {
+				return rewrapped, err
+			}
+			rewrapped++
+		}
+
+		if len(list) < pageSize {
+			return rewrapped, nil
+		}
+	}
+}
+
+// Reencrypt 将空间中不是以最新版本数据密钥加密的内容（包括明文）重新加密，可以中断后重新执行
+// prune 为 true 且全部内容都已使用最新版本时删除旧版本的数据密钥
+// 其他实例可能在 SPACE_KEY_CACHE_TTL 内仍使用旧版本写入，轮换后应等待该时间再执行 prune
+func (e *Encryptor) Reencrypt(ctx context.Context, spaceID string, batch uint64, prune bool, progress func(table string, rows int64)) (types.ReencryptResult, error) {
+	result := types.ReencryptResult{SpaceID: spaceID, Rows: make(map[string]int64)}
+
+	keys, err := e.loadKeys(ctx, spaceID, true)
+	if err != nil {
+		return result, err
+	}
+	if keys.latest == 0 {
+		if keys, _, err = e.createKey(ctx, spaceID, 1); err != nil {
+			return result, err
+		}
+	}
+	result.Version = keys.latest
+
+	for _, c := range encryptedColumns {
+		rows, err := e.reencryptColumn(ctx, spaceID, c, keys, batch, func(rows int64) {
+			if progress != nil {
+				progress(c.table, rows)
+			}
+		})
+		result.Rows[c.table] = rows
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if !prune {
+		return result, nil
+	}
+
+	for _, c := range encryptedColumns {
+		remain, err := e.countOutdated(ctx, spaceID, c, keys.latest)
+		if err != nil {
+			return result, err
+		}
+		if remain > 0 {
+			return result, fmt.Errorf("%d rows of %s are still not encrypted by data key %d, try again later", remain, c.table, keys.latest)
+		}
+	}
+
+	if result.Pruned, err = e.provider.store().SpaceKeyStore.DeleteBefore(ctx, spaceID, keys.latest); err != nil {
+		return result, err
+	}
+	_, err = e.loadKeys(ctx, spaceID, true)
+	return result, err
+}
+
+// outdated 不是以 version 版本数据密钥加密的非空内容
+func outdated(query sq.SelectBuilder, spaceID string, c encryptedColumn, version int) sq.SelectBuilder {
+	return query.From(c.table).
+		Where(sq.Eq{"space_id": spaceID}).
+		Where(sq.NotEq{c.column: c.value("")}).
+		Where(sq.NotLike{c.column: c.value(ENCRYPTED_PREFIX + strconv.Itoa(version) + "$%")})
+}
+
+func (e *Encryptor) countOutdated(ctx context.Context, spaceID string, c encryptedColumn, version int) (int64, error) {
+	queryString, args, err := outdated(sq.Select("COUNT(*)"), spaceID, c, version).ToSql()
+	if err != nil {
+		return 0, errorSqlBuild(err)
+	}
+
+	var total int64
+	if err = e.QueryMaster(ctx).Get(&total, queryString, args...); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+func (e *Encryptor) reencryptColumn(ctx context.Context, spaceID string, c encryptedColumn, keys *spaceKeys, batch uint64, progress func(rows int64)) (int64, error) {
+	var (
+		total  int64
+		lastID string
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		query := outdated(sq.Select("id", c.column+" AS value"), spaceID, c, keys.latest).
+			Where(sq.Gt{"id": lastID}).
+			OrderBy("id").
+			Limit(batch)
+		queryString, args, err := query.ToSql()
+		if err != nil {
+			return total, errorSqlBuild(err)
+		}
+
+		var list []struct {
+			ID    string `db:"id"`
+			Value string `db:"value"`
+		}
+		if err = e.QueryMaster(ctx).Select(&list, queryString, args...); err != nil && err != sql.ErrNoRows {
+			return total, err
+		}
+
+		for _, v := range list {
+			lastID = v.ID
+			plaintext, err := e.Decrypt(ctx, spaceID, v.Value)
+			if err != nil {
+				return total, fmt.Errorf("failed to decrypt %s %s, %w", c.table, v.ID, err)
+			}
+			encrypted, err := e.seal(spaceID, keys.latest, keys.keys[keys.latest], plaintext)
+			if err != nil {
+				return total, err
+			}
+
+			// 内容在此期间被修改时跳过，新写入的内容已使用最新版本加密
+			update := sq.Update(c.table).
+				Set(c.column, c.value(encrypted)).
+				SetMap(c.reset).
+				Where(sq.Eq{"space_id": spaceID, "id": v.ID, c.column: c.value(v.Value)})
+			queryString, args, err := update.ToSql()
+			if err != nil {
+				return total, errorSqlBuild(err)
+			}
+			res, err := e.GetMaster(ctx).Exec(queryString, args...)
+			if err != nil {
+				return total, err
+			}
+			affected, _ := res.RowsAffected()
+			total += affected
+		}
+
+		progress(total)
+		if uint64(len(list)) < batch {
+			return total, nil
+		}
+	}
+}
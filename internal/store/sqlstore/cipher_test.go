@@ -0,0 +1,212 @@
+package sqlstore
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/starbx/brew-api/pkg/security"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+// fakeSpaceKeyStore 内存中的 SpaceKeyStore，用于不依赖数据库测试 Encryptor
+type fakeSpaceKeyStore struct {
+	mu   sync.Mutex
+	keys []types.SpaceKey
+}
+
+func (s *fakeSpaceKeyStore) GetTable(...interface{}) string {
+	return types.TABLE_SPACE_KEY.Name()
+}
+
+func (s *fakeSpaceKeyStore) Create(ctx context.Context, data types.SpaceKey) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range s.keys {
+		if v.SpaceID == data.SpaceID && v.Version == data.Version {
+			return false, nil
+		}
+	}
+	s.keys = append(s.keys, data)
+	return true, nil
+}
+
+func (s *fakeSpaceKeyStore) List(ctx context.Context, spaceID string) ([]types.SpaceKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []types.SpaceKey
+	for _, v := range s.keys {
+		if v.SpaceID == spaceID {
+			res = append(res, v)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+	return res, nil
+}
+
+func (s *fakeSpaceKeyStore) ListAll(ctx context.Context, page, pageSize uint64) ([]types.SpaceKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	start := min(int((page-1)*pageSize), len(s.keys))
+	end := min(start+int(pageSize), len(s.keys))
+	return append([]types.SpaceKey{}, s.keys[start:end]...), nil
+}
+
+func (s *fakeSpaceKeyStore) UpdateWrappedKey(ctx context.Context, spaceID string, version int, wrappedKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, v := range s.keys {
+		if v.SpaceID == spaceID && v.Version == version {
+			s.keys[i].WrappedKey = wrappedKey
+		}
+	}
+	return nil
+}
+
+func (s *fakeSpaceKeyStore) DeleteBefore(ctx context.Context, spaceID string, version int) (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeSpaceKeyStore) DeleteAll(ctx context.Context, spaceID string) error {
+	return nil
+}
+
+type fakeProvider struct {
+	stores *Stores
+}
+
+func (p *fakeProvider) GetMaster() *sqlx.DB                       { return nil }
+func (p *fakeProvider) GetReplica() *sqlx.DB                      { return nil }
+func (p *fakeProvider) store() *Stores                            { return p.stores }
+func (p *fakeProvider) GetDBName() (string, error)                { return "", nil }
+func (p *fakeProvider) GetTxFromCtx(ctx context.Context) *sqlx.Tx { return nil }
+func (p *fakeProvider) TextSearchConfig() string                  { return DEFAULT_TEXT_SEARCH_CONFIG }
+func (p *fakeProvider) Encryptor() *Encryptor                     { return nil }
+
+func newTestEncryptor(t *testing.T, keys *fakeSpaceKeyStore, masterKeys ...[]byte) *Encryptor {
+	keyring, err := security.NewKeyring(masterKeys[0], masterKeys[1:]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewEncryptor(&fakeProvider{stores: &Stores{SpaceKeyStore: keys}}, keyring)
+}
+
+func TestEncryptor(t *testing.T) {
+	ctx := context.Background()
+	master, _ := security.GenDataKey()
+	keys := &fakeSpaceKeyStore{}
+	e := newTestEncryptor(t, keys, master)
+
+	encrypted, err := e.Encrypt(ctx, "s1", "个人笔记")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encrypted, ENCRYPTED_PREFIX+"1$"))
+	assert.NotContains(t, encrypted, "个人笔记")
+
+	plaintext, err := e.Decrypt(ctx, "s1", encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "个人笔记", plaintext)
+
+	// 密文不能在其他空间解密
+	_, err = e.Decrypt(ctx, "s2", encrypted)
+	assert.Error(t, err)
+
+	// 明文与空内容原样返回
+	plaintext, err = e.Decrypt(ctx, "s1", "legacy")
+	assert.NoError(t, err)
+	assert.Equal(t, "legacy", plaintext)
+	empty, err := e.Encrypt(ctx, "s1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "", empty)
+
+	// 轮换后使用新版本加密，旧版本仍可解密，其他实例读取新版本密文时重新加载密钥
+	other := newTestEncryptor(t, keys, master)
+	_, err = other.Decrypt(ctx, "s1", encrypted)
+	assert.NoError(t, err)
+
+	version, err := e.Rotate(ctx, "s1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, version)
+
+	rotated, err := e.Encrypt(ctx, "s1", "个人笔记")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(rotated, ENCRYPTED_PREFIX+"2$"))
+	plaintext, err = other.Decrypt(ctx, "s1", rotated)
+	assert.NoError(t, err)
+	assert.Equal(t, "个人笔记", plaintext)
+
+	// 未启用加密时无法解密，密文原样返回
+	var disabled *Encryptor
+	plaintext, err = disabled.Decrypt(ctx, "s1", encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, encrypted, plaintext)
+	plaintext, err = disabled.Encrypt(ctx, "s1", "note")
+	assert.NoError(t, err)
+	assert.Equal(t, "note", plaintext)
+
+	// 启用加密时不保存内容哈希
+	assert.Equal(t, "", e.ContentHash("hash"))
+	assert.Equal(t, "hash", disabled.ContentHash("hash"))
+}
+
+func TestEncryptorPlaintextWithPrefix(t *testing.T) {
+	ctx := context.Background()
+	master, _ := security.GenDataKey()
+	e := newTestEncryptor(t, &fakeSpaceKeyStore{}, master)
+	var disabled *Encryptor
+
+	for _, text := range []string{
+		ENCRYPTED_PREFIX + "1$aGVsbG8=",
+		ENCRYPTED_PREFIX + "not encrypted",
+		PLAINTEXT_PREFIX + "note",
+	} {
+		// 未启用加密时写入的明文与前缀冲突，读取时仍为原文
+		stored, err := disabled.Encrypt(ctx, "s1", text)
+		assert.NoError(t, err)
+		plaintext, err := disabled.Decrypt(ctx, "s1", stored)
+		assert.NoError(t, err)
+		assert.Equal(t, text, plaintext)
+
+		// 启用加密后读取，以及重新加密后读取
+		plaintext, err = e.Decrypt(ctx, "s1", stored)
+		assert.NoError(t, err)
+		assert.Equal(t, text, plaintext)
+		encrypted, err := e.Encrypt(ctx, "s1", plaintext)
+		assert.NoError(t, err)
+		plaintext, err = e.Decrypt(ctx, "s1", encrypted)
+		assert.NoError(t, err)
+		assert.Equal(t, text, plaintext)
+	}
+
+	// 加上转义前写入的明文格式不符，视为明文
+	plaintext, err := e.Decrypt(ctx, "s1", ENCRYPTED_PREFIX+"not encrypted")
+	assert.NoError(t, err)
+	assert.Equal(t, ENCRYPTED_PREFIX+"not encrypted", plaintext)
+}
+
+func TestEncryptorRewrap(t *testing.T) {
+	ctx := context.Background()
+	oldMaster, _ := security.GenDataKey()
+	newMaster, _ := security.GenDataKey()
+	keys := &fakeSpaceKeyStore{}
+
+	encrypted, err := newTestEncryptor(t, keys, oldMaster).Encrypt(ctx, "s1", "note")
+	assert.NoError(t, err)
+
+	// 主密钥轮换后，旧主密钥包裹的数据密钥需要重新包裹
+	e := newTestEncryptor(t, keys, newMaster, oldMaster)
+	rewrapped, err := e.Rewrap(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rewrapped)
+
+	plaintext, err := newTestEncryptor(t, keys, newMaster).Decrypt(ctx, "s1", encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "note", plaintext)
+
+	_, err = newTestEncryptor(t, keys, oldMaster).Decrypt(ctx, "s1", encrypted)
+	assert.ErrorIs(t, err, security.ErrUnknownMasterKey)
+}
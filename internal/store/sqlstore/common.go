@@ -20,6 +20,8 @@ type SqlProviderAchieve interface {
 	store() *Stores
 	GetDBName() (string, error)
 	GetTxFromCtx(ctx context.Context) *sqlx.Tx
+	TextSearchConfig() string
+	Encryptor() *Encryptor
 }
 
 type GetTableFunc func([]interface{}) string
@@ -92,6 +94,23 @@ func (c *CommonFields) GetMaster(ctx context.Context) Master {
 	}
 }
 
+// QueryMaster 在主库上执行查询，用于 UPDATE ... RETURNING 等需要读取写入结果的语句
+func (c *CommonFields) QueryMaster(ctx context.Context) Replica {
+	if ctx == nil {
+		return c.provider.GetMaster()
+	}
+
+	tx := c.provider.GetTxFromCtx(ctx)
+	if tx != nil {
+		return tx
+	}
+
+	return &dbWithContext{
+		db:  c.provider.GetMaster(),
+		ctx: ctx,
+	}
+}
+
 type Replica interface {
 	Get(dest interface{}, query string, args ...interface{}) error
 	Select(dest interface{}, query string, args ...interface{}) error
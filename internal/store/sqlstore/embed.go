@@ -4,5 +4,5 @@ package sqlstore
 // 	"embed"
 // )
 
-// //go:embed access_token.sql chat_message_ext.sql chat_message.sql chat_session.sql chat_summary.sql knowledge_chunk.sql knowledge.sql resource.sql space.sql user_space.sql user.sql vectors.sql
+// //go:embed access_token.sql blob.sql chat_message_ext.sql chat_message.sql chat_session.sql chat_summary.sql knowledge_chunk.sql knowledge_revision.sql knowledge_duplicate.sql knowledge_job.sql knowledge_link.sql knowledge.sql resource.sql share_token.sql space.sql space_key.sql user_space.sql user.sql vectors.sql
 // var CreateTableFiles embed.FS
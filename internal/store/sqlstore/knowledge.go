@@ -29,7 +29,7 @@ func NewKnowledgeStore(provider SqlProviderAchieve) *KnowledgeStore {
 	store := &KnowledgeStore{}
 	store.SetProvider(provider)
 	store.SetTable(types.TABLE_KNOWLEDGE)
-	store.SetAllColumns("id", "title", "user_id", "space_id", "tags", "content", "resource", "kind", "summary", "maybe_date", "stage", "retry_times", "created_at", "updated_at")
+	store.SetAllColumns("id", "title", "user_id", "space_id", "tags", "content", "resource", "kind", "summary", "maybe_date", "meta", "content_hash", "stage", "retry_times", "created_at", "updated_at")
 	return store
 }
 
@@ -41,9 +41,13 @@ func (s *KnowledgeStore) Create(ctx context.Context, data types.Knowledge) error
 	if data.UpdatedAt == 0 {
 		data.UpdatedAt = time.Now().Unix()
 	}
+	content, err := s.provider.Encryptor().Encrypt(ctx, data.SpaceID, data.Content)
+	if err != nil {
+		return err
+	}
 	query := sq.Insert(s.GetTable()).
-		Columns("id", "title", "user_id", "space_id", "tags", "content", "resource", "kind", "summary", "maybe_date", "stage", "retry_times", "created_at", "updated_at").
-		Values(data.ID, data.Title, data.UserID, data.SpaceID, pq.Array(data.Tags), data.Content, data.Resource, data.Kind, data.Summary, data.MaybeDate, data.Stage, data.RetryTimes, data.CreatedAt, data.UpdatedAt)
+		Columns("id", "title", "user_id", "space_id", "tags", "content", "resource", "kind", "summary", "maybe_date", "meta", "content_hash", "stage", "retry_times", "created_at", "updated_at").
+		Values(data.ID, data.Title, data.UserID, data.SpaceID, pq.Array(data.Tags), content, data.Resource, data.Kind, data.Summary, data.MaybeDate, data.Meta, s.provider.Encryptor().ContentHash(data.ContentHash), data.Stage, data.RetryTimes, data.CreatedAt, data.UpdatedAt)
 
 	queryString, args, err := query.ToSql()
 	if err != nil {
@@ -57,6 +61,38 @@ func (s *KnowledgeStore) Create(ctx context.Context, data types.Knowledge) error
 	return nil
 }
 
+// BatchCreate 批量创建知识记录
+func (s *KnowledgeStore) BatchCreate(ctx context.Context, data []types.Knowledge) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	query := sq.Insert(s.GetTable()).
+		Columns("id", "title", "user_id", "space_id", "tags", "content", "resource", "kind", "summary", "maybe_date", "meta", "content_hash", "stage", "retry_times", "created_at", "updated_at")
+
+	for _, item := range data {
+		if item.CreatedAt == 0 {
+			item.CreatedAt = time.Now().Unix()
+		}
+		if item.UpdatedAt == 0 {
+			item.UpdatedAt = time.Now().Unix()
+		}
+		content, err := s.provider.Encryptor().Encrypt(ctx, item.SpaceID, item.Content)
+		if err != nil {
+			return err
+		}
+		query = query.Values(item.ID, item.Title, item.UserID, item.SpaceID, pq.Array(item.Tags), content, item.Resource, item.Kind, item.Summary, item.MaybeDate, item.Meta, s.provider.Encryptor().ContentHash(item.ContentHash), item.Stage, item.RetryTimes, item.CreatedAt, item.UpdatedAt)
+	}
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
 // GetKnowledge 根据ID获取知识记录
 func (s *KnowledgeStore) GetKnowledge(ctx context.Context, spaceID string, id string) (*types.Knowledge, error) {
 	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "id": id})
@@ -70,6 +106,28 @@ func (s *KnowledgeStore) GetKnowledge(ctx context.Context, spaceID string, id st
 	if err = s.GetReplica(ctx).Get(&res, queryString, args...); err != nil {
 		return nil, err
 	}
+	if err = s.provider.Encryptor().decryptFields(ctx, res.SpaceID, &res.Content); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetKnowledgeForUpdate 在事务中读取知识记录并加行锁，事务结束前其他修改会等待
+func (s *KnowledgeStore) GetKnowledgeForUpdate(ctx context.Context, spaceID string, id string) (*types.Knowledge, error) {
+	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "id": id}).Suffix("FOR UPDATE")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	var res types.Knowledge
+	if err = s.QueryMaster(ctx).Get(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	if err = s.provider.Encryptor().decryptFields(ctx, res.SpaceID, &res.Content); err != nil {
+		return nil, err
+	}
 	return &res, nil
 }
 
@@ -115,6 +173,22 @@ func (s *KnowledgeStore) FinishedStageEmbedding(ctx context.Context, spaceID, id
 	return err
 }
 
+// Archive 将知识标记为已归档
+func (s *KnowledgeStore) Archive(ctx context.Context, spaceID, id string) error {
+	query := sq.Update(s.GetTable()).
+		Set("stage", types.KNOWLEDGE_STAGE_ARCHIVED).
+		Set("updated_at", time.Now().Unix()).
+		Where(sq.Eq{"space_id": spaceID, "id": id})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
 // Update 更新知识记录
 func (s *KnowledgeStore) Update(ctx context.Context, spaceID, id string, data types.UpdateKnowledgeArgs) error {
 	query := sq.Update(s.GetTable()).
@@ -127,7 +201,11 @@ func (s *KnowledgeStore) Update(ctx context.Context, spaceID, id string, data ty
 	}
 
 	if data.Content != "" {
-		query = query.Set("content", data.Content)
+		content, err := s.provider.Encryptor().Encrypt(ctx, spaceID, data.Content)
+		if err != nil {
+			return err
+		}
+		query = query.Set("content", content)
 	}
 
 	if data.Stage != 0 {
@@ -150,6 +228,14 @@ func (s *KnowledgeStore) Update(ctx context.Context, spaceID, id string, data ty
 		query = query.Set("summary", data.Summary)
 	}
 
+	if data.ContentHash != "" {
+		query = query.Set("content_hash", s.provider.Encryptor().ContentHash(data.ContentHash))
+	}
+
+	if data.Meta != nil {
+		query = query.Set("meta", *data.Meta)
+	}
+
 	queryString, args, err := query.ToSql()
 	if err != nil {
 		return errorSqlBuild(err)
@@ -174,6 +260,22 @@ func (s *KnowledgeStore) SetRetryTimes(ctx context.Context, spaceID, id string,
 	return err
 }
 
+// ResetRetryTimes 批量清空知识的处理重试次数
+func (s *KnowledgeStore) ResetRetryTimes(ctx context.Context, spaceID string, ids []string) error {
+	query := sq.Update(s.GetTable()).
+		Set("retry_times", 0).
+		Set("updated_at", time.Now().Unix()).
+		Where(sq.Eq{"space_id": spaceID, "id": ids})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
 // Delete 删除知识记录
 func (s *KnowledgeStore) Delete(ctx context.Context, spaceID, id string) error {
 	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "id": id})
@@ -215,11 +317,16 @@ func (s *KnowledgeStore) ListFailedKnowledges(ctx context.Context, stage types.K
 	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
 		return nil, err
 	}
+	for i := range res {
+		if err = s.provider.Encryptor().decryptFields(ctx, res[i].SpaceID, &res[i].Content); err != nil {
+			return nil, err
+		}
+	}
 	return res, nil
 }
 
 func (s *KnowledgeStore) ListProcessingKnowledges(ctx context.Context, retryTimes int, page, pageSize uint64) ([]types.Knowledge, error) {
-	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).Where(sq.And{sq.NotEq{"stage": types.KNOWLEDGE_STAGE_DONE}, sq.Lt{"retry_times": retryTimes}})
+	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).Where(sq.And{sq.NotEq{"stage": []types.KnowledgeStage{types.KNOWLEDGE_STAGE_DONE, types.KNOWLEDGE_STAGE_ARCHIVED}}, sq.Lt{"retry_times": retryTimes}})
 	if page != 0 || pageSize != 0 {
 		query = query.Limit(pageSize).Offset((page - 1) * pageSize)
 	}
@@ -233,6 +340,11 @@ func (s *KnowledgeStore) ListProcessingKnowledges(ctx context.Context, retryTime
 	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
 		return nil, err
 	}
+	for i := range res {
+		if err = s.provider.Encryptor().decryptFields(ctx, res[i].SpaceID, &res[i].Content); err != nil {
+			return nil, err
+		}
+	}
 	return res, nil
 }
 
@@ -257,7 +369,7 @@ func (s *KnowledgeStore) ListLiteKnowledges(ctx context.Context, opts types.GetK
 
 // ListKnowledges 分页获取知识记录列表
 func (s *KnowledgeStore) ListKnowledges(ctx context.Context, opts types.GetKnowledgeOptions, page, pageSize uint64) ([]*types.Knowledge, error) {
-	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).OrderBy("updated_at DESC")
+	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).OrderBy("updated_at DESC", "id")
 	if page != 0 || pageSize != 0 {
 		query = query.Limit(pageSize).Offset((page - 1) * pageSize)
 	}
@@ -272,6 +384,11 @@ func (s *KnowledgeStore) ListKnowledges(ctx context.Context, opts types.GetKnowl
 	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
 		return nil, err
 	}
+	for _, v := range res {
+		if err = s.provider.Encryptor().decryptFields(ctx, v.SpaceID, &v.Content); err != nil {
+			return nil, err
+		}
+	}
 	return res, nil
 }
 
@@ -290,3 +407,51 @@ func (s *KnowledgeStore) Total(ctx context.Context, opts types.GetKnowledgeOptio
 	}
 	return total, nil
 }
+
+// ListTags 统计空间中所有标签的使用次数，按使用次数倒序排列
+func (s *KnowledgeStore) ListTags(ctx context.Context, spaceID string) ([]types.TagCount, error) {
+	query := sq.Select("tag", "COUNT(*) AS count").
+		From(s.GetTable()+", unnest(tags) AS tag").
+		Where(sq.Eq{"space_id": spaceID}).
+		GroupBy("tag").
+		OrderBy("count DESC", "tag ASC")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	var res []types.TagCount
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ReplaceTags 将空间中所有知识的 from 标签替换为 to，保持标签原有的顺序并去除替换后重复的标签，返回受影响的知识数量
+func (s *KnowledgeStore) ReplaceTags(ctx context.Context, spaceID string, from []string, to string) (int64, error) {
+	replaced := sq.Expr(`ARRAY(
+		SELECT t FROM (
+			SELECT CASE WHEN u.t = ANY(?) THEN ? ELSE u.t END AS t, MIN(u.i) AS i
+			FROM unnest(tags) WITH ORDINALITY AS u(t, i)
+			GROUP BY 1
+		) AS r ORDER BY r.i
+	)`, pq.Array(from), to)
+
+	query := sq.Update(s.GetTable()).
+		Set("tags", replaced).
+		Set("updated_at", time.Now().Unix()).
+		Where(sq.Eq{"space_id": spaceID}).
+		Where(sq.Expr("tags && ?", pq.Array(from)))
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return 0, errorSqlBuild(err)
+	}
+
+	res, err := s.GetMaster(ctx).Exec(queryString, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -11,6 +11,8 @@ CREATE TABLE bw_knowledge (
     content TEXT NOT NULL,
     summary TEXT NOT NULL,
     maybe_date VARCHAR(20) NOT NULL,
+    meta JSONB NOT NULL DEFAULT '{}',
+    content_hash VARCHAR(64) NOT NULL DEFAULT '',
     retry_times SMALLINT NOT NULL DEFAULT 0,
     created_at BIGINT NOT NULL,
     updated_at BIGINT NOT NULL
@@ -21,17 +23,21 @@ COMMENT ON COLUMN bw_knowledge.id IS '唯一标识';
 COMMENT ON COLUMN bw_knowledge.space_id IS '空间ID';
 COMMENT ON COLUMN bw_knowledge.user_id IS '作者ID';
 COMMENT ON COLUMN bw_knowledge.kind IS '知识类型';
-COMMENT ON COLUMN bw_knowledge.stage IS 'ai flow stage';
+COMMENT ON COLUMN bw_knowledge.stage IS 'ai flow stage，4为超出资源保留周期后归档';
 COMMENT ON COLUMN bw_knowledge.tags IS '标签列表，使用数组存储';
 COMMENT ON COLUMN bw_knowledge.resource IS '资源类型/knowledge/context';
 COMMENT ON COLUMN bw_knowledge.title IS '内容标题';
 COMMENT ON COLUMN bw_knowledge.content IS '知识内容';
 COMMENT ON COLUMN bw_knowledge.summary IS '知识内容';
 COMMENT ON COLUMN bw_knowledge.maybe_date IS 'AI分析出的事件发生时间 / 创建时间';
+COMMENT ON COLUMN bw_knowledge.meta IS '知识附加信息，如来源地址';
+COMMENT ON COLUMN bw_knowledge.content_hash IS '归一化后内容的sha256，用于识别重复知识，启用内容加密时为空';
 COMMENT ON COLUMN bw_knowledge.retry_times IS '流水线相关动作重试次数';
 COMMENT ON COLUMN bw_knowledge.created_at IS '创建时间';
 COMMENT ON COLUMN bw_knowledge.updated_at IS '更新时间';
 
 -- 创建索引
 CREATE INDEX idx_bw_knowledge_main ON bw_knowledge (space_id, resource);
-CREATE INDEX idx_bw_knowledge_retry ON bw_knowledge (stage, retry_times);
\ No newline at end of file
+CREATE INDEX idx_bw_knowledge_retry ON bw_knowledge (stage, retry_times);
+CREATE INDEX idx_bw_knowledge_content_hash ON bw_knowledge (space_id, content_hash);
+CREATE INDEX idx_bw_knowledge_tags ON bw_knowledge USING GIN (tags);
\ No newline at end of file
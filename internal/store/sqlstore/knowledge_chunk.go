@@ -26,10 +26,28 @@ func NewKnowledgeChunkStore(provider SqlProviderAchieve) *KnowledgeChunkStore {
 	repo := &KnowledgeChunkStore{}
 	repo.SetProvider(provider)
 	repo.SetTable(types.TABLE_KNOWLEDGE_CHUNK)
-	repo.SetAllColumns("id", "knowledge_id", "space_id", "user_id", "chunk", "original_length", "updated_at", "created_at")
+	repo.SetAllColumns("id", "knowledge_id", "space_id", "user_id", "chunk", "original_length", "meta", "updated_at", "created_at")
 	return repo
 }
 
+// tsvector 生成知识片段的全文检索向量，启用加密时不生成，以免分词结果泄露内容
+func (s *KnowledgeChunkStore) tsvector(chunk string) any {
+	if s.provider.Encryptor() != nil {
+		return nil
+	}
+	return sq.Expr("to_tsvector(?::regconfig, ?)", s.provider.TextSearchConfig(), chunk)
+}
+
+// decrypt 解密知识片段内容
+func (s *KnowledgeChunkStore) decrypt(ctx context.Context, list []types.KnowledgeChunk) error {
+	for i := range list {
+		if err := s.provider.Encryptor().decryptFields(ctx, list[i].SpaceID, &list[i].Chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Create 创建新的知识片段记录
 func (s *KnowledgeChunkStore) Create(ctx context.Context, data types.KnowledgeChunk) error {
 	if data.CreatedAt == 0 {
@@ -38,9 +56,13 @@ func (s *KnowledgeChunkStore) Create(ctx context.Context, data types.KnowledgeCh
 	if data.UpdatedAt == 0 {
 		data.UpdatedAt = time.Now().Unix()
 	}
+	chunk, err := s.provider.Encryptor().Encrypt(ctx, data.SpaceID, data.Chunk)
+	if err != nil {
+		return err
+	}
 	query := sq.Insert(s.GetTable()).
-		Columns("id", "knowledge_id", "space_id", "user_id", "chunk", "original_length", "updated_at", "created_at").
-		Values(data.ID, data.KnowledgeID, data.SpaceID, data.UserID, data.Chunk, data.OriginalLength, data.UpdatedAt, data.CreatedAt)
+		Columns("id", "knowledge_id", "space_id", "user_id", "chunk", "original_length", "meta", "tsv", "updated_at", "created_at").
+		Values(data.ID, data.KnowledgeID, data.SpaceID, data.UserID, chunk, data.OriginalLength, data.Meta, s.tsvector(data.Chunk), data.UpdatedAt, data.CreatedAt)
 
 	queryString, args, err := query.ToSql()
 	if err != nil {
@@ -60,7 +82,7 @@ func (s *KnowledgeChunkStore) BatchCreate(ctx context.Context, data []types.Know
 	}
 
 	query := sq.Insert(s.GetTable()).
-		Columns("id", "knowledge_id", "space_id", "user_id", "chunk", "original_length", "updated_at", "created_at")
+		Columns("id", "knowledge_id", "space_id", "user_id", "chunk", "original_length", "meta", "tsv", "updated_at", "created_at")
 
 	// 遍历数据，构建批量插入的 values
 	for _, item := range data {
@@ -70,7 +92,11 @@ func (s *KnowledgeChunkStore) BatchCreate(ctx context.Context, data []types.Know
 		if item.UpdatedAt == 0 {
 			item.UpdatedAt = time.Now().Unix()
 		}
-		query = query.Values(item.ID, item.KnowledgeID, item.SpaceID, item.UserID, item.Chunk, item.OriginalLength, item.UpdatedAt, item.CreatedAt)
+		chunk, err := s.provider.Encryptor().Encrypt(ctx, item.SpaceID, item.Chunk)
+		if err != nil {
+			return err
+		}
+		query = query.Values(item.ID, item.KnowledgeID, item.SpaceID, item.UserID, chunk, item.OriginalLength, item.Meta, s.tsvector(item.Chunk), item.UpdatedAt, item.CreatedAt)
 	}
 
 	queryString, args, err := query.ToSql()
@@ -97,13 +123,21 @@ func (s *KnowledgeChunkStore) Get(ctx context.Context, spaceID, knowledgeID, id
 	if err := s.GetReplica(ctx).Get(&res, queryString, args...); err != nil {
 		return nil, err
 	}
+	if err = s.provider.Encryptor().decryptFields(ctx, spaceID, &res.Chunk); err != nil {
+		return nil, err
+	}
 	return &res, nil
 }
 
 // Update 更新知识片段记录
 func (s *KnowledgeChunkStore) Update(ctx context.Context, spaceID, knowledgeID, id, chunk string) error {
+	encrypted, err := s.provider.Encryptor().Encrypt(ctx, spaceID, chunk)
+	if err != nil {
+		return err
+	}
 	query := sq.Update(s.GetTable()).
-		Set("chunk", chunk).
+		Set("chunk", encrypted).
+		Set("tsv", s.tsvector(chunk)).
 		Set("updated_at", time.Now().Unix()).
 		Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID, "id": id})
 
@@ -156,5 +190,77 @@ func (s *KnowledgeChunkStore) List(ctx context.Context, spaceID, knowledgeID str
 	if err := s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
 		return nil, err
 	}
+	if err = s.decrypt(ctx, res); err != nil {
+		return nil, err
+	}
 	return res, nil
 }
+
+// ListByIDs 根据ID批量获取知识片段
+func (s *KnowledgeChunkStore) ListByIDs(ctx context.Context, spaceID string, ids []string) ([]types.KnowledgeChunk, error) {
+	query := sq.Select(s.GetAllColumns()...).
+		From(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "id": ids})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	var res []types.KnowledgeChunk
+	if err := s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	if err = s.decrypt(ctx, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// withoutVector 已完成处理但还没有 model 生成的向量的知识片段
+func (s *KnowledgeChunkStore) withoutVector(query sq.SelectBuilder, spaceID, model string) sq.SelectBuilder {
+	knowledges := sq.Select("id").From(types.TABLE_KNOWLEDGE.Name()).Where(sq.Eq{"stage": types.KNOWLEDGE_STAGE_DONE})
+	if spaceID != "" {
+		query = query.Where(sq.Eq{"space_id": spaceID})
+		knowledges = knowledges.Where(sq.Eq{"space_id": spaceID})
+	}
+	return query.Where(sq.Expr("knowledge_id IN (?)", knowledges)).
+		Where(sq.Expr("NOT EXISTS (SELECT 1 FROM "+types.TABLE_VECTORS.Name()+" AS v WHERE v.id = "+s.GetTable()+".id AND v.model = ?)", model))
+}
+
+// ListWithoutVector 按ID升序获取空间（spaceID 为空时为全部空间）中 afterID 之后还没有 model 生成的向量的知识片段
+func (s *KnowledgeChunkStore) ListWithoutVector(ctx context.Context, spaceID, model, afterID string, limit uint64) ([]types.KnowledgeChunk, error) {
+	query := s.withoutVector(sq.Select(s.GetAllColumns()...).From(s.GetTable()), spaceID, model).OrderBy("id").Limit(limit)
+	if afterID != "" {
+		query = query.Where(sq.Gt{"id": afterID})
+	}
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	var res []types.KnowledgeChunk
+	if err := s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	if err = s.decrypt(ctx, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// TotalWithoutVector 获取空间（spaceID 为空时为全部空间）中还没有 model 生成的向量的知识片段数量
+func (s *KnowledgeChunkStore) TotalWithoutVector(ctx context.Context, spaceID, model string) (int64, error) {
+	query := s.withoutVector(sq.Select("COUNT(*)").From(s.GetTable()), spaceID, model)
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return 0, errorSqlBuild(err)
+	}
+
+	var total int64
+	if err = s.GetReplica(ctx).Get(&total, queryString, args...); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
@@ -6,6 +6,8 @@ CREATE TABLE bw_knowledge_chunk (
     user_id VARCHAR(32) NOT NULL, -- 用户ID
     chunk TEXT NOT NULL, -- 知识片段
     original_length INT NOT NULL DEFAULT 0, -- 关联知识点长度
+    meta JSONB NOT NULL DEFAULT '{}', -- 片段附加信息
+    tsv TSVECTOR, -- 全文检索向量
     updated_at BIGINT NOT NULL DEFAULT 0, -- 更新时间
     created_at BIGINT NOT NULL DEFAULT 0 -- 创建时间
 );
@@ -13,6 +15,7 @@ CREATE TABLE bw_knowledge_chunk (
 -- 创建索引
 CREATE INDEX idx_bw_knowledge_chunk_space_id_knowledge ON bw_knowledge_chunk (space_id,knowledge_id);
 CREATE INDEX idx_bw_knowledge_chunk_space_user_id ON bw_knowledge_chunk (space_id,user_id);
+CREATE INDEX idx_bw_knowledge_chunk_tsv ON bw_knowledge_chunk USING GIN (tsv);
 
 -- 为字段添加注释
 COMMENT ON COLUMN bw_knowledge_chunk.id IS '主键，自增ID';
@@ -21,4 +24,6 @@ COMMENT ON COLUMN bw_knowledge_chunk.space_id IS '空间ID';
 COMMENT ON COLUMN bw_knowledge_chunk.user_id IS '用户ID';
 COMMENT ON COLUMN bw_knowledge_chunk.chunk IS '知识片段';
 COMMENT ON COLUMN bw_knowledge_chunk.original_length IS '关联知识点长度';
+COMMENT ON COLUMN bw_knowledge_chunk.meta IS '片段附加信息，如音视频转写内容的起止时间';
+COMMENT ON COLUMN bw_knowledge_chunk.tsv IS '全文检索向量，写入时按服务配置的 text search config 生成';
 COMMENT ON COLUMN bw_knowledge_chunk.created_at IS '创建时间';
@@ -0,0 +1,123 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/starbx/brew-api/pkg/register"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+func init() {
+	register.RegisterFunc(registerKey{}, func() {
+		provider.stores.KnowledgeDuplicateStore = NewKnowledgeDuplicateStore(provider)
+	})
+}
+
+// KnowledgeDuplicateStore 处理 bw_knowledge_duplicate 表的操作
+type KnowledgeDuplicateStore struct {
+	CommonFields
+}
+
+// NewKnowledgeDuplicateStore 创建一个新的 KnowledgeDuplicateStore 实例
+func NewKnowledgeDuplicateStore(provider SqlProviderAchieve) *KnowledgeDuplicateStore {
+	repo := &KnowledgeDuplicateStore{}
+	repo.SetProvider(provider)
+	repo.SetTable(types.TABLE_KNOWLEDGE_DUPLICATE)
+	repo.SetAllColumns("space_id", "knowledge_id", "duplicate_id", "similarity", "created_at")
+	return repo
+}
+
+// BatchCreate 批量记录重复关系，已存在的记录会被忽略
+func (s *KnowledgeDuplicateStore) BatchCreate(ctx context.Context, data []types.KnowledgeDuplicate) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	query := sq.Insert(s.GetTable()).
+		Columns("space_id", "knowledge_id", "duplicate_id", "similarity", "created_at").
+		Suffix("ON CONFLICT DO NOTHING")
+
+	for _, item := range data {
+		if item.CreatedAt == 0 {
+			item.CreatedAt = time.Now().Unix()
+		}
+		query = query.Values(item.SpaceID, item.KnowledgeID, item.DuplicateID, item.Similarity, item.CreatedAt)
+	}
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// List 分页获取空间中的重复记录，相似度高的排在最前
+func (s *KnowledgeDuplicateStore) List(ctx context.Context, spaceID string, page, pageSize uint64) ([]types.KnowledgeDuplicate, error) {
+	query := sq.Select(s.GetAllColumns()...).
+		From(s.GetTable()).
+		Where(sq.Eq{"space_id": spaceID}).
+		OrderBy("similarity DESC", "created_at DESC")
+	if page != types.NO_PAGING || pageSize != types.NO_PAGING {
+		query = query.Limit(pageSize).Offset((page - 1) * pageSize)
+	}
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	var res []types.KnowledgeDuplicate
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Total 获取空间中的重复记录总数
+func (s *KnowledgeDuplicateStore) Total(ctx context.Context, spaceID string) (int64, error) {
+	query := sq.Select("COUNT(*)").From(s.GetTable()).Where(sq.Eq{"space_id": spaceID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return 0, errorSqlBuild(err)
+	}
+
+	var total int64
+	if err = s.GetReplica(ctx).Get(&total, queryString, args...); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// DeleteByKnowledge 删除与知识相关的全部重复记录
+func (s *KnowledgeDuplicateStore) DeleteByKnowledge(ctx context.Context, spaceID, knowledgeID string) error {
+	query := sq.Delete(s.GetTable()).
+		Where(sq.Eq{"space_id": spaceID}).
+		Where(sq.Or{sq.Eq{"knowledge_id": knowledgeID}, sq.Eq{"duplicate_id": knowledgeID}})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// DeleteAll 删除空间下的全部重复记录
+func (s *KnowledgeDuplicateStore) DeleteAll(ctx context.Context, spaceID string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
@@ -0,0 +1,19 @@
+-- 创建表 bw_knowledge_duplicate
+CREATE TABLE bw_knowledge_duplicate (
+    space_id VARCHAR(32) NOT NULL, -- 空间ID
+    knowledge_id VARCHAR(32) NOT NULL, -- 后写入的知识ID
+    duplicate_id VARCHAR(32) NOT NULL, -- 与之重复的已有知识ID
+    similarity REAL NOT NULL, -- 相似度
+    created_at BIGINT NOT NULL, -- 创建时间
+    PRIMARY KEY (space_id, knowledge_id, duplicate_id)
+);
+
+-- 创建索引
+CREATE INDEX idx_bw_knowledge_duplicate_duplicate_id ON bw_knowledge_duplicate (space_id, duplicate_id);
+
+-- 为字段添加注释
+COMMENT ON COLUMN bw_knowledge_duplicate.space_id IS '空间ID';
+COMMENT ON COLUMN bw_knowledge_duplicate.knowledge_id IS '后写入的知识ID';
+COMMENT ON COLUMN bw_knowledge_duplicate.duplicate_id IS '与之重复的已有知识ID';
+COMMENT ON COLUMN bw_knowledge_duplicate.similarity IS '相似度，内容完全相同时为1';
+COMMENT ON COLUMN bw_knowledge_duplicate.created_at IS '创建时间';
@@ -0,0 +1,317 @@
+package sqlstore
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/starbx/brew-api/pkg/register"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+func init() {
+	register.RegisterFunc(registerKey{}, func() {
+		provider.stores.KnowledgeJobStore = NewKnowledgeJobStore(provider)
+	})
+}
+
+// KnowledgeJobStore 处理 bw_knowledge_job 表的操作
+type KnowledgeJobStore struct {
+	CommonFields
+}
+
+// NewKnowledgeJobStore 创建一个新的 KnowledgeJobStore 实例
+func NewKnowledgeJobStore(provider SqlProviderAchieve) *KnowledgeJobStore {
+	repo := &KnowledgeJobStore{}
+	repo.SetProvider(provider)
+	repo.SetTable(types.TABLE_KNOWLEDGE_JOB)
+	repo.SetAllColumns("knowledge_id", "space_id", "stage", "status", "attempts", "run_at", "lock_id", "locked_until", "last_error", "created_at", "updated_at")
+	return repo
+}
+
+// Enqueue 为知识创建指定阶段的任务，已存在的任务会被重置，正在执行的租约随之失效
+func (s *KnowledgeJobStore) Enqueue(ctx context.Context, spaceID, knowledgeID string, stage types.KnowledgeStage) error {
+	now := time.Now().Unix()
+	query := sq.Insert(s.GetTable()).
+		Columns("knowledge_id", "space_id", "stage", "status", "attempts", "run_at", "lock_id", "locked_until", "last_error", "created_at", "updated_at").
+		Values(knowledgeID, spaceID, stage, types.KNOWLEDGE_JOB_STATUS_PENDING, 0, now, "", 0, "", now, now).
+		Suffix("ON CONFLICT (knowledge_id) DO UPDATE SET stage = EXCLUDED.stage, status = EXCLUDED.status, attempts = 0, run_at = EXCLUDED.run_at, lock_id = '', locked_until = 0, last_error = '', updated_at = EXCLUDED.updated_at")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// EnqueueMissing 为处理中但没有任务的知识补充任务，用于恢复队列上线前遗留或写入任务失败的知识
+// 重试次数已达到 retryTimes 的知识直接记为失败任务，以便在流水线管理中查看与重试
+func (s *KnowledgeJobStore) EnqueueMissing(ctx context.Context, retryTimes int) (int64, error) {
+	now := time.Now().Unix()
+	knowledges := sq.Select("k.id", "k.space_id", "k.stage").
+		Column(sq.Expr("CASE WHEN k.retry_times >= ? THEN ?::SMALLINT ELSE ?::SMALLINT END", retryTimes, types.KNOWLEDGE_JOB_STATUS_FAILED, types.KNOWLEDGE_JOB_STATUS_PENDING)).
+		Column("k.retry_times").
+		Column(sq.Expr("?::BIGINT", now)).
+		Columns("''", "0", "''").
+		Column(sq.Expr("?::BIGINT", now)).
+		Column(sq.Expr("?::BIGINT", now)).
+		From(types.TABLE_KNOWLEDGE.Name() + " AS k").
+		Where(sq.Eq{"k.stage": []types.KnowledgeStage{types.KNOWLEDGE_STAGE_SUMMARIZE, types.KNOWLEDGE_STAGE_EMBEDDING}}).
+		Where(sq.Expr("NOT EXISTS (SELECT 1 FROM " + s.GetTable() + " AS j WHERE j.knowledge_id = k.id)"))
+
+	query := sq.Insert(s.GetTable()).
+		Columns("knowledge_id", "space_id", "stage", "status", "attempts", "run_at", "lock_id", "locked_until", "last_error", "created_at", "updated_at").
+		Select(knowledges).
+		Suffix("ON CONFLICT (knowledge_id) DO NOTHING")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return 0, errorSqlBuild(err)
+	}
+
+	res, err := s.GetMaster(ctx).Exec(queryString, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Lease 领取一个到期的任务，visibility 秒内未提交结果的任务会被其他 worker 重新领取
+// 使用 FOR UPDATE SKIP LOCKED 保证多个实例同时领取时互不阻塞且不会重复领取
+func (s *KnowledgeJobStore) Lease(ctx context.Context, lockID string, visibility int64) (*types.KnowledgeJob, error) {
+	now := time.Now().Unix()
+	candidate := sq.Select("knowledge_id").From(s.GetTable()).
+		Where(sq.Eq{"status": types.KNOWLEDGE_JOB_STATUS_PENDING}).
+		Where(sq.LtOrEq{"run_at": now}).
+		Where(sq.Lt{"locked_until": now}).
+		OrderBy("run_at").
+		Limit(1).
+		Suffix("FOR UPDATE SKIP LOCKED").
+		PlaceholderFormat(sq.Question)
+
+	// 子查询嵌套在 UPDATE 中时占位符会被重复编号，统一使用 ? 生成后再转换
+	query := sq.Update(s.GetTable()).PlaceholderFormat(sq.Question).
+		Set("lock_id", lockID).
+		Set("locked_until", now+visibility).
+		Set("attempts", sq.Expr("attempts + 1")).
+		Set("updated_at", now).
+		Where(sq.Expr("knowledge_id IN (?)", candidate)).
+		Suffix("RETURNING " + strings.Join(s.GetAllColumns(), ", "))
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+	if queryString, err = sq.Dollar.ReplacePlaceholders(queryString); err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	var res types.KnowledgeJob
+	if err = s.QueryMaster(ctx).Get(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Advance 当前阶段完成后进入下一阶段，租约已失效时不做修改
+func (s *KnowledgeJobStore) Advance(ctx context.Context, knowledgeID, lockID string, stage types.KnowledgeStage) (bool, error) {
+	now := time.Now().Unix()
+	query := sq.Update(s.GetTable()).
+		Set("stage", stage).
+		Set("attempts", 0).
+		Set("run_at", now).
+		Set("lock_id", "").
+		Set("locked_until", 0).
+		Set("last_error", "").
+		Set("updated_at", now).
+		Where(sq.Eq{"knowledge_id": knowledgeID, "lock_id": lockID})
+
+	return s.execWithLease(ctx, query)
+}
+
+// Complete 全部阶段完成后删除任务，租约已失效时不做修改
+func (s *KnowledgeJobStore) Complete(ctx context.Context, knowledgeID, lockID string) (bool, error) {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"knowledge_id": knowledgeID, "lock_id": lockID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return false, errorSqlBuild(err)
+	}
+
+	res, err := s.GetMaster(ctx).Exec(queryString, args...)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// Retry 释放租约并在 runAt 之后重试
+func (s *KnowledgeJobStore) Retry(ctx context.Context, knowledgeID, lockID string, runAt int64, lastError string) (bool, error) {
+	query := sq.Update(s.GetTable()).
+		Set("run_at", runAt).
+		Set("lock_id", "").
+		Set("locked_until", 0).
+		Set("last_error", lastError).
+		Set("updated_at", time.Now().Unix()).
+		Where(sq.Eq{"knowledge_id": knowledgeID, "lock_id": lockID})
+
+	return s.execWithLease(ctx, query)
+}
+
+// Fail 重试次数用尽，任务不再自动处理
+func (s *KnowledgeJobStore) Fail(ctx context.Context, knowledgeID, lockID, lastError string) (bool, error) {
+	query := sq.Update(s.GetTable()).
+		Set("status", types.KNOWLEDGE_JOB_STATUS_FAILED).
+		Set("lock_id", "").
+		Set("locked_until", 0).
+		Set("last_error", lastError).
+		Set("updated_at", time.Now().Unix()).
+		Where(sq.Eq{"knowledge_id": knowledgeID, "lock_id": lockID})
+
+	return s.execWithLease(ctx, query)
+}
+
+func (s *KnowledgeJobStore) execWithLease(ctx context.Context, query sq.UpdateBuilder) (bool, error) {
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return false, errorSqlBuild(err)
+	}
+
+	res, err := s.GetMaster(ctx).Exec(queryString, args...)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// Get 获取知识当前的任务
+func (s *KnowledgeJobStore) Get(ctx context.Context, knowledgeID string) (*types.KnowledgeJob, error) {
+	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).Where(sq.Eq{"knowledge_id": knowledgeID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	var res types.KnowledgeJob
+	if err = s.GetReplica(ctx).Get(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Delete 删除知识的任务
+func (s *KnowledgeJobStore) Delete(ctx context.Context, spaceID, knowledgeID string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// List 分页获取任务，最近更新的排在最前
+func (s *KnowledgeJobStore) List(ctx context.Context, opts types.GetKnowledgeJobOptions, page, pageSize uint64) ([]types.KnowledgeJob, error) {
+	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).OrderBy("updated_at DESC")
+	opts.Apply(&query)
+	if page != types.NO_PAGING || pageSize != types.NO_PAGING {
+		query = query.Limit(pageSize).Offset((page - 1) * pageSize)
+	}
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	var res []types.KnowledgeJob
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Total 获取符合条件的任务总数
+func (s *KnowledgeJobStore) Total(ctx context.Context, opts types.GetKnowledgeJobOptions) (int64, error) {
+	query := sq.Select("COUNT(*)").From(s.GetTable())
+	opts.Apply(&query)
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return 0, errorSqlBuild(err)
+	}
+
+	var total int64
+	if err = s.GetReplica(ctx).Get(&total, queryString, args...); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// ResetAttempts 清空任务的尝试次数，不改变任务状态
+func (s *KnowledgeJobStore) ResetAttempts(ctx context.Context, spaceID, knowledgeID string) error {
+	query := sq.Update(s.GetTable()).
+		Set("attempts", 0).
+		Set("updated_at", time.Now().Unix()).
+		Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// Cancel 取消任务，正在执行的租约随之失效，执行结果不会再推进任务
+func (s *KnowledgeJobStore) Cancel(ctx context.Context, spaceID, knowledgeID string) error {
+	query := sq.Update(s.GetTable()).
+		Set("status", types.KNOWLEDGE_JOB_STATUS_CANCELED).
+		Set("lock_id", "").
+		Set("locked_until", 0).
+		Set("updated_at", time.Now().Unix()).
+		Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// RequeueFailed 将空间中全部失败的任务重新放回队列，返回对应的知识id
+func (s *KnowledgeJobStore) RequeueFailed(ctx context.Context, spaceID string) ([]string, error) {
+	now := time.Now().Unix()
+	query := sq.Update(s.GetTable()).
+		Set("status", types.KNOWLEDGE_JOB_STATUS_PENDING).
+		Set("attempts", 0).
+		Set("run_at", now).
+		Set("lock_id", "").
+		Set("locked_until", 0).
+		Set("last_error", "").
+		Set("updated_at", now).
+		Where(sq.Eq{"space_id": spaceID, "status": types.KNOWLEDGE_JOB_STATUS_FAILED}).
+		Suffix("RETURNING knowledge_id")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	var res []string
+	if err = s.QueryMaster(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,33 @@
+-- 创建表 bw_knowledge_job
+CREATE TABLE bw_knowledge_job (
+    knowledge_id VARCHAR(32) PRIMARY KEY, -- 知识ID
+    space_id VARCHAR(32) NOT NULL, -- 空间ID
+    stage SMALLINT NOT NULL, -- 待处理的阶段
+    status SMALLINT NOT NULL, -- 任务状态
+    attempts INT NOT NULL DEFAULT 0, -- 当前阶段已尝试的次数
+    run_at BIGINT NOT NULL, -- 最早可执行时间
+    lock_id VARCHAR(32) NOT NULL DEFAULT '', -- 租约标识
+    locked_until BIGINT NOT NULL DEFAULT 0, -- 租约到期时间
+    last_error TEXT NOT NULL DEFAULT '', -- 最近一次失败的原因
+    created_at BIGINT NOT NULL, -- 创建时间
+    updated_at BIGINT NOT NULL -- 更新时间
+);
+
+-- 创建索引
+CREATE INDEX idx_bw_knowledge_job_run_at ON bw_knowledge_job (status, run_at);
+
+-- 为字段添加注释
+COMMENT ON COLUMN bw_knowledge_job.knowledge_id IS '知识ID，每条知识同时只有一个任务';
+COMMENT ON COLUMN bw_knowledge_job.space_id IS '空间ID';
+COMMENT ON COLUMN bw_knowledge_job.stage IS '待处理的阶段，1 summarize，2 embedding';
+COMMENT ON COLUMN bw_knowledge_job.status IS '任务状态，1 待处理，2 重试次数用尽，3 已取消';
+COMMENT ON COLUMN bw_knowledge_job.attempts IS '当前阶段已尝试的次数';
+COMMENT ON COLUMN bw_knowledge_job.run_at IS '最早可执行时间，失败后按指数退避推迟';
+COMMENT ON COLUMN bw_knowledge_job.lock_id IS '租约标识，只有持有租约的worker可以提交结果';
+COMMENT ON COLUMN bw_knowledge_job.locked_until IS '租约到期时间，到期未提交的任务会被其他worker重新领取';
+COMMENT ON COLUMN bw_knowledge_job.last_error IS '最近一次失败的原因';
+COMMENT ON COLUMN bw_knowledge_job.created_at IS '创建时间';
+COMMENT ON COLUMN bw_knowledge_job.updated_at IS '更新时间';
+
+-- 添加表注释
+COMMENT ON TABLE bw_knowledge_job IS '知识处理任务队列';
@@ -0,0 +1,153 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/starbx/brew-api/pkg/register"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+func init() {
+	register.RegisterFunc(registerKey{}, func() {
+		provider.stores.KnowledgeLinkStore = NewKnowledgeLinkStore(provider)
+	})
+}
+
+// KnowledgeLinkStore 处理 bw_knowledge_link 表的操作
+type KnowledgeLinkStore struct {
+	CommonFields
+}
+
+// NewKnowledgeLinkStore 创建一个新的 KnowledgeLinkStore 实例
+func NewKnowledgeLinkStore(provider SqlProviderAchieve) *KnowledgeLinkStore {
+	repo := &KnowledgeLinkStore{}
+	repo.SetProvider(provider)
+	repo.SetTable(types.TABLE_KNOWLEDGE_LINK)
+	repo.SetAllColumns("space_id", "source_id", "target_id", "title", "created_at")
+	return repo
+}
+
+// Replace 使用 links 替换知识当前的全部链接，需要在事务中调用
+func (s *KnowledgeLinkStore) Replace(ctx context.Context, spaceID, sourceID string, links []types.KnowledgeLink) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "source_id": sourceID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+	if _, err = s.GetMaster(ctx).Exec(queryString, args...); err != nil {
+		return err
+	}
+	if len(links) == 0 {
+		return nil
+	}
+
+	insert := sq.Insert(s.GetTable()).
+		Columns("space_id", "source_id", "target_id", "title", "created_at").
+		Suffix("ON CONFLICT DO NOTHING")
+	for _, item := range links {
+		if item.CreatedAt == 0 {
+			item.CreatedAt = time.Now().Unix()
+		}
+		insert = insert.Values(spaceID, sourceID, item.TargetID, item.Title, item.CreatedAt)
+	}
+
+	queryString, args, err = insert.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// ListOutgoing 获取知识中的链接，包含尚未关联到知识的标题链接
+func (s *KnowledgeLinkStore) ListOutgoing(ctx context.Context, spaceID string, sourceIDs []string) ([]types.KnowledgeLink, error) {
+	return s.list(ctx, sq.Eq{"space_id": spaceID, "source_id": sourceIDs})
+}
+
+// ListIncoming 获取引用了知识的链接，即反向链接
+func (s *KnowledgeLinkStore) ListIncoming(ctx context.Context, spaceID string, targetIDs []string) ([]types.KnowledgeLink, error) {
+	return s.list(ctx, sq.Eq{"space_id": spaceID, "target_id": targetIDs})
+}
+
+func (s *KnowledgeLinkStore) list(ctx context.Context, where sq.Eq) ([]types.KnowledgeLink, error) {
+	query := sq.Select(s.GetAllColumns()...).
+		From(s.GetTable()).
+		Where(where).
+		OrderBy("created_at", "source_id", "target_id", "title")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	var res []types.KnowledgeLink
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ResolveTitle 将尚未关联的同名标题链接关联到知识，返回关联的链接数量
+func (s *KnowledgeLinkStore) ResolveTitle(ctx context.Context, spaceID, title, targetID string) (int64, error) {
+	query := sq.Update(s.GetTable()).
+		Set("target_id", targetID).
+		Where(sq.Eq{"space_id": spaceID, "target_id": "", "title": title}).
+		Where(sq.NotEq{"source_id": targetID}).
+		// 同一来源中已关联到该知识的同名链接不重复关联，以免主键冲突
+		Where(sq.Expr("NOT EXISTS (SELECT 1 FROM "+s.GetTable()+" AS l WHERE l.space_id = ? AND l.source_id = "+s.GetTable()+".source_id AND l.target_id = ? AND l.title = ?)", spaceID, targetID, title))
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return 0, errorSqlBuild(err)
+	}
+
+	res, err := s.GetMaster(ctx).Exec(queryString, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteByKnowledge 删除知识中的链接，引用该知识的标题链接恢复为未关联状态，ID链接直接删除
+func (s *KnowledgeLinkStore) DeleteByKnowledge(ctx context.Context, spaceID, knowledgeID string) error {
+	queries := []sq.Sqlizer{
+		sq.Delete(s.GetTable()).
+			Where(sq.Eq{"space_id": spaceID}).
+			Where(sq.Or{sq.Eq{"source_id": knowledgeID}, sq.Eq{"target_id": knowledgeID, "title": ""}}),
+		// 同一来源中已有未关联的同名链接时，直接删除
+		sq.Delete(s.GetTable()).
+			Where(sq.Eq{"space_id": spaceID, "target_id": knowledgeID}).
+			Where(sq.Expr("EXISTS (SELECT 1 FROM "+s.GetTable()+" AS l WHERE l.space_id = ? AND l.source_id = "+s.GetTable()+".source_id AND l.target_id = '' AND l.title = "+s.GetTable()+".title)", spaceID)),
+		sq.Update(s.GetTable()).
+			Set("target_id", "").
+			Where(sq.Eq{"space_id": spaceID, "target_id": knowledgeID}),
+	}
+
+	for _, query := range queries {
+		queryString, args, err := query.ToSql()
+		if err != nil {
+			return errorSqlBuild(err)
+		}
+		if _, err = s.GetMaster(ctx).Exec(queryString, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteAll 删除空间下的全部链接
+func (s *KnowledgeLinkStore) DeleteAll(ctx context.Context, spaceID string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
@@ -0,0 +1,20 @@
+-- 创建表 bw_knowledge_link
+CREATE TABLE bw_knowledge_link (
+    space_id VARCHAR(32) NOT NULL, -- 空间ID
+    source_id VARCHAR(32) NOT NULL, -- 链接所在的知识ID
+    target_id VARCHAR(32) NOT NULL DEFAULT '', -- 被引用的知识ID
+    title TEXT NOT NULL DEFAULT '', -- 按标题引用时的标题
+    created_at BIGINT NOT NULL, -- 创建时间
+    PRIMARY KEY (space_id, source_id, target_id, title)
+);
+
+-- 创建索引
+CREATE INDEX idx_bw_knowledge_link_target_id ON bw_knowledge_link (space_id, target_id);
+CREATE INDEX idx_bw_knowledge_link_title ON bw_knowledge_link (space_id, title) WHERE target_id = '';
+
+-- 为字段添加注释
+COMMENT ON COLUMN bw_knowledge_link.space_id IS '空间ID';
+COMMENT ON COLUMN bw_knowledge_link.source_id IS '链接所在的知识ID';
+COMMENT ON COLUMN bw_knowledge_link.target_id IS '被引用的知识ID，按标题引用且暂无同名知识时为空';
+COMMENT ON COLUMN bw_knowledge_link.title IS '按标题引用时的标题，按ID引用时为空';
+COMMENT ON COLUMN bw_knowledge_link.created_at IS '创建时间';
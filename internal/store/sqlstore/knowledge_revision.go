@@ -0,0 +1,144 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+
+	"github.com/starbx/brew-api/pkg/register"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+func init() {
+	register.RegisterFunc(registerKey{}, func() {
+		provider.stores.KnowledgeRevisionStore = NewKnowledgeRevisionStore(provider)
+	})
+}
+
+// KnowledgeRevisionStore 处理 bw_knowledge_revision 表的操作
+type KnowledgeRevisionStore struct {
+	CommonFields
+}
+
+// NewKnowledgeRevisionStore 创建一个新的 KnowledgeRevisionStore 实例
+func NewKnowledgeRevisionStore(provider SqlProviderAchieve) *KnowledgeRevisionStore {
+	repo := &KnowledgeRevisionStore{}
+	repo.SetProvider(provider)
+	repo.SetTable(types.TABLE_KNOWLEDGE_REVISION)
+	repo.SetAllColumns("id", "knowledge_id", "space_id", "user_id", "replaced_by", "title", "tags", "content", "created_at")
+	return repo
+}
+
+// Create 创建新的修订记录
+func (s *KnowledgeRevisionStore) Create(ctx context.Context, data types.KnowledgeRevision) error {
+	if data.CreatedAt == 0 {
+		data.CreatedAt = time.Now().Unix()
+	}
+	content, err := s.provider.Encryptor().Encrypt(ctx, data.SpaceID, data.Content)
+	if err != nil {
+		return err
+	}
+	query := sq.Insert(s.GetTable()).
+		Columns("id", "knowledge_id", "space_id", "user_id", "replaced_by", "title", "tags", "content", "created_at").
+		Values(data.ID, data.KnowledgeID, data.SpaceID, data.UserID, data.ReplacedBy, data.Title, pq.Array(data.Tags), content, data.CreatedAt)
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// GetRevision 根据ID获取修订记录
+func (s *KnowledgeRevisionStore) GetRevision(ctx context.Context, spaceID, knowledgeID, id string) (*types.KnowledgeRevision, error) {
+	query := sq.Select(s.GetAllColumns()...).
+		From(s.GetTable()).
+		Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID, "id": id})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	var res types.KnowledgeRevision
+	if err = s.GetReplica(ctx).Get(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	if err = s.provider.Encryptor().decryptFields(ctx, spaceID, &res.Content); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListRevisions 分页获取知识的修订记录，最新的修订排在最前
+func (s *KnowledgeRevisionStore) ListRevisions(ctx context.Context, spaceID, knowledgeID string, page, pageSize uint64) ([]types.KnowledgeRevision, error) {
+	query := sq.Select(s.GetAllColumns()...).
+		From(s.GetTable()).
+		Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID}).
+		OrderBy("created_at DESC", "id DESC")
+	if page != types.NO_PAGING || pageSize != types.NO_PAGING {
+		query = query.Limit(pageSize).Offset((page - 1) * pageSize)
+	}
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	var res []types.KnowledgeRevision
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	for i := range res {
+		if err = s.provider.Encryptor().decryptFields(ctx, spaceID, &res[i].Content); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// Total 获取知识的修订记录总数
+func (s *KnowledgeRevisionStore) Total(ctx context.Context, spaceID, knowledgeID string) (int64, error) {
+	query := sq.Select("COUNT(*)").From(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return 0, errorSqlBuild(err)
+	}
+
+	var total int64
+	if err = s.GetReplica(ctx).Get(&total, queryString, args...); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// BatchDelete 删除知识的全部修订记录
+func (s *KnowledgeRevisionStore) BatchDelete(ctx context.Context, spaceID, knowledgeID string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "knowledge_id": knowledgeID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// DeleteAll 删除空间下的全部修订记录
+func (s *KnowledgeRevisionStore) DeleteAll(ctx context.Context, spaceID string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
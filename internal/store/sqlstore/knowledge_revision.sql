@@ -0,0 +1,26 @@
+-- 创建表 bw_knowledge_revision
+CREATE TABLE bw_knowledge_revision (
+    id VARCHAR(32) PRIMARY KEY, -- 修订记录ID
+    knowledge_id VARCHAR(32) NOT NULL, -- 知识ID
+    space_id VARCHAR(32) NOT NULL, -- 空间ID
+    user_id VARCHAR(32) NOT NULL, -- 快照内容的作者
+    replaced_by VARCHAR(32) NOT NULL DEFAULT '', -- 执行本次修改的用户
+    title TEXT NOT NULL, -- 修改前的标题
+    tags TEXT[], -- 修改前的标签
+    content TEXT NOT NULL, -- 修改前的内容
+    created_at BIGINT NOT NULL -- 修改时间
+);
+
+-- 创建索引
+CREATE INDEX idx_bw_knowledge_revision_space_id_knowledge ON bw_knowledge_revision (space_id,knowledge_id);
+
+-- 为字段添加注释
+COMMENT ON COLUMN bw_knowledge_revision.id IS '修订记录ID';
+COMMENT ON COLUMN bw_knowledge_revision.knowledge_id IS '知识ID';
+COMMENT ON COLUMN bw_knowledge_revision.space_id IS '空间ID';
+COMMENT ON COLUMN bw_knowledge_revision.user_id IS '快照内容的作者，即修改前知识的所属用户';
+COMMENT ON COLUMN bw_knowledge_revision.replaced_by IS '执行本次修改、覆盖该快照内容的用户';
+COMMENT ON COLUMN bw_knowledge_revision.title IS '修改前的标题';
+COMMENT ON COLUMN bw_knowledge_revision.tags IS '修改前的标签';
+COMMENT ON COLUMN bw_knowledge_revision.content IS '修改前的内容';
+COMMENT ON COLUMN bw_knowledge_revision.created_at IS '修改时间';
@@ -0,0 +1,20 @@
+-- 升级已有的 bw_vectors：记录生成向量的模型与维度，主键改为 (id, model)，向量列不再限定维度
+-- 可重复执行，执行后使用 reembed 命令的 --adopt-legacy 参数将已有向量标记为当前模型，并为该模型建立 hnsw 索引
+-- 如曾自行在 embedding 列上建立过索引，需要先删除，不限定维度的向量列无法建立索引
+BEGIN;
+
+ALTER TABLE bw_vectors ADD COLUMN IF NOT EXISTS model VARCHAR(128) NOT NULL DEFAULT '';
+ALTER TABLE bw_vectors ADD COLUMN IF NOT EXISTS dim INT NOT NULL DEFAULT 0;
+ALTER TABLE bw_vectors ALTER COLUMN embedding TYPE vector;
+UPDATE bw_vectors SET dim = vector_dims(embedding) WHERE dim = 0;
+
+ALTER TABLE bw_vectors DROP CONSTRAINT IF EXISTS bw_vectors_pkey;
+ALTER TABLE bw_vectors ADD CONSTRAINT bw_vectors_pkey PRIMARY KEY (id, model);
+
+COMMENT ON COLUMN bw_vectors.embedding IS '文本向量，存储经过编码后的文本向量表示，不限定维度以便不同模型的向量共存';
+COMMENT ON COLUMN bw_vectors.model IS '生成向量的模型标识，格式为 driver:model，同一分块在切换模型期间可以同时存在多个模型的向量';
+COMMENT ON COLUMN bw_vectors.dim IS '向量维度';
+
+CREATE INDEX IF NOT EXISTS idx_vectors_model_space_id ON bw_vectors (model, space_id);
+
+COMMIT;
@@ -26,11 +26,42 @@ func GetProvider() *Provider {
 type Provider struct {
 	*sqlstore.SqlProvider
 	stores *Stores
+
+	textSearchConfig string
+	encryptor        *Encryptor
+}
+
+const DEFAULT_TEXT_SEARCH_CONFIG = "simple"
+
+// SetTextSearchConfig 设置生成知识片段全文检索向量时使用的 text search config
+func (p *Provider) SetTextSearchConfig(name string) {
+	p.textSearchConfig = name
+}
+
+func (p *Provider) TextSearchConfig() string {
+	if p.textSearchConfig == "" {
+		return DEFAULT_TEXT_SEARCH_CONFIG
+	}
+	return p.textSearchConfig
+}
+
+// SetEncryptor 启用内容的加密存储，加密后的知识片段不再生成全文检索向量
+func (p *Provider) SetEncryptor(e *Encryptor) {
+	p.encryptor = e
+}
+
+// Encryptor 未启用加密时返回 nil
+func (p *Provider) Encryptor() *Encryptor {
+	return p.encryptor
 }
 
 type Stores struct {
 	store.KnowledgeStore
 	store.KnowledgeChunkStore
+	store.KnowledgeRevisionStore
+	store.KnowledgeDuplicateStore
+	store.KnowledgeLinkStore
+	store.KnowledgeJobStore
 	store.VectorStore
 	store.AccessTokenStore
 	store.UserSpaceStore
@@ -41,6 +72,9 @@ type Stores struct {
 	store.ChatMessageStore
 	store.ChatSummaryStore
 	store.ChatMessageExtStore
+	store.SpaceKeyStore
+	store.BlobStore
+	store.ShareTokenStore
 }
 
 func (s *Provider) batchExecStoreFuncs(fname string) {
@@ -69,14 +103,21 @@ func MustSetup(m sqlstore.ConnectConfig, s ...sqlstore.ConnectConfig) func() *Pr
 // func (p *Provider) Install() error {
 // 	for _, tableFile := range []string{
 // 		"access_token.sql",
+// 		"blob.sql",
 // 		"chat_message_ext.sql",
 // 		"chat_message.sql",
 // 		"chat_session.sql",
 // 		"chat_summary.sql",
 // 		"knowledge_chunk.sql",
+// 		"knowledge_revision.sql",
+// 		"knowledge_duplicate.sql",
+// 		"knowledge_job.sql",
+// 		"knowledge_link.sql",
 // 		"knowledge.sql",
 // 		"resource.sql",
+// 		"share_token.sql",
 // 		"space.sql",
+// 		"space_key.sql",
 // 		"user_space.sql",
 // 		"user.sql",
 // 		"vectors.sql",
@@ -105,6 +146,20 @@ func (p *Provider) VectorStore() store.VectorStore {
 	return p.stores.VectorStore
 }
 
+// SetVectorStore 替换默认的 pgvector 向量存储
+func (p *Provider) SetVectorStore(s store.VectorStore) {
+	p.stores.VectorStore = s
+}
+
+func (p *Provider) BlobStore() store.BlobStore {
+	return p.stores.BlobStore
+}
+
+// SetBlobStore 替换默认的 postgres 文件存储
+func (p *Provider) SetBlobStore(s store.BlobStore) {
+	p.stores.BlobStore = s
+}
+
 func (p *Provider) AccessTokenStore() store.AccessTokenStore {
 	return p.stores.AccessTokenStore
 }
@@ -129,6 +184,26 @@ func (p *Provider) KnowledgeChunkStore() store.KnowledgeChunkStore {
 	return p.stores.KnowledgeChunkStore
 }
 
+func (p *Provider) KnowledgeRevisionStore() store.KnowledgeRevisionStore {
+	return p.stores.KnowledgeRevisionStore
+}
+
+func (p *Provider) KnowledgeDuplicateStore() store.KnowledgeDuplicateStore {
+	return p.stores.KnowledgeDuplicateStore
+}
+
+func (p *Provider) KnowledgeLinkStore() store.KnowledgeLinkStore {
+	return p.stores.KnowledgeLinkStore
+}
+
+func (p *Provider) ShareTokenStore() store.ShareTokenStore {
+	return p.stores.ShareTokenStore
+}
+
+func (p *Provider) KnowledgeJobStore() store.KnowledgeJobStore {
+	return p.stores.KnowledgeJobStore
+}
+
 func (p *Provider) ChatSessionStore() store.ChatSessionStore {
 	return p.stores.ChatSessionStore
 }
@@ -144,3 +219,7 @@ func (p *Provider) ChatSummaryStore() store.ChatSummaryStore {
 func (p *Provider) ChatMessageExtStore() store.ChatMessageExtStore {
 	return p.stores.ChatMessageExtStore
 }
+
+func (p *Provider) SpaceKeyStore() store.SpaceKeyStore {
+	return p.stores.SpaceKeyStore
+}
@@ -26,7 +26,7 @@ func NewResourceStore(provider SqlProviderAchieve) *ResourceStore {
 	repo := &ResourceStore{}
 	repo.SetProvider(provider)
 	repo.SetTable(types.TABLE_RESOURCE) // 表名
-	repo.SetAllColumns("id", "title", "user_id", "space_id", "description", "created_at")
+	repo.SetAllColumns("id", "title", "user_id", "space_id", "description", "prompt", "cycle", "chunk_mode", "retrieval", "created_at")
 	return repo
 }
 
@@ -36,8 +36,8 @@ func (s *ResourceStore) Create(ctx context.Context, data types.Resource) error {
 		data.CreatedAt = time.Now().Unix()
 	}
 	query := sq.Insert(s.GetTable()).
-		Columns("id", "title", "user_id", "space_id", "description", "prompt", "cycle", "created_at").
-		Values(data.ID, data.Title, data.UserID, data.SpaceID, data.Description, data.Prompt, data.Cycle, data.CreatedAt)
+		Columns("id", "title", "user_id", "space_id", "description", "prompt", "cycle", "chunk_mode", "retrieval", "created_at").
+		Values(data.ID, data.Title, data.UserID, data.SpaceID, data.Description, data.Prompt, data.Cycle, data.ChunkMode, data.Retrieval, data.CreatedAt)
 
 	queryString, args, err := query.ToSql()
 	if err != nil {
@@ -68,11 +68,12 @@ func (s *ResourceStore) GetResource(ctx context.Context, spaceID, id string) (*t
 }
 
 // Update 更新资源记录
-func (s *ResourceStore) Update(ctx context.Context, spaceID, id, title, desc, prompt string, cycle int) error {
+func (s *ResourceStore) Update(ctx context.Context, spaceID, id, title, desc, prompt, chunkMode string, cycle int) error {
 	query := sq.Update(s.GetTable()).
 		Set("title", title).
 		Set("description", desc).
 		Set("prompt", prompt).
+		Set("chunk_mode", chunkMode).
 		Set("cycle", cycle).
 		Where(sq.Eq{"space_id": spaceID, "id": id})
 
@@ -85,6 +86,21 @@ func (s *ResourceStore) Update(ctx context.Context, spaceID, id, title, desc, pr
 	return err
 }
 
+// UpdateRetrieval 更新资源的检索配置
+func (s *ResourceStore) UpdateRetrieval(ctx context.Context, spaceID, id string, settings types.RetrievalSettings) error {
+	query := sq.Update(s.GetTable()).
+		Set("retrieval", settings).
+		Where(sq.Eq{"space_id": spaceID, "id": id})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
 // Delete 删除资源记录
 func (s *ResourceStore) Delete(ctx context.Context, spaceID, id string) error {
 	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID, "id": id})
@@ -5,7 +5,9 @@ CREATE TABLE bw_resource (
     space_id VARCHAR(32) NOT NULL,       -- 资源所属空间ID
     cycle int NOT NULL,                  -- cycle
     prompt TEXT NOT NULL,                -- 自定义prompt
+    chunk_mode VARCHAR(10) NOT NULL DEFAULT '', -- 分块方式
     description TEXT,                    -- 资源描述信息
+    retrieval JSONB NOT NULL DEFAULT '{}', -- 检索配置
     created_at BIGINT NOT NULL          -- 资源创建时间，UNIX时间戳
 );
 
@@ -20,6 +22,8 @@ COMMENT ON COLUMN bw_resource.space_id IS '资源所属空间ID';
 COMMENT ON COLUMN bw_resource.description IS '资源描述信息';
 COMMENT ON COLUMN bw_resource.cycle IS '资源周期';
 COMMENT ON COLUMN bw_resource.prompt IS '自定义prompt';
+COMMENT ON COLUMN bw_resource.chunk_mode IS '分块方式，llm/local，为空时使用全局配置';
+COMMENT ON COLUMN bw_resource.retrieval IS '检索配置，未设置的项使用空间的配置';
 COMMENT ON COLUMN bw_resource.created_at IS '资源创建时间，UNIX时间戳';
 
 -- 添加表注释
@@ -0,0 +1,117 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/starbx/brew-api/pkg/register"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+func init() {
+	register.RegisterFunc(registerKey{}, func() {
+		provider.stores.ShareTokenStore = NewShareTokenStore(provider)
+	})
+}
+
+// ShareTokenStore 处理 bw_share_token 表的操作
+type ShareTokenStore struct {
+	CommonFields
+}
+
+// NewShareTokenStore 创建一个新的 ShareTokenStore 实例
+func NewShareTokenStore(provider SqlProviderAchieve) *ShareTokenStore {
+	repo := &ShareTokenStore{}
+	repo.SetProvider(provider)
+	repo.SetTable(types.TABLE_SHARE_TOKEN)
+	repo.SetAllColumns("token", "space_id", "kind", "object_id", "user_id", "password", "salt", "expired_at", "created_at")
+	return repo
+}
+
+// Create 创建分享
+func (s *ShareTokenStore) Create(ctx context.Context, data types.ShareToken) error {
+	if data.CreatedAt == 0 {
+		data.CreatedAt = time.Now().Unix()
+	}
+	query := sq.Insert(s.GetTable()).
+		Columns("token", "space_id", "kind", "object_id", "user_id", "password", "salt", "expired_at", "created_at").
+		Values(data.Token, data.SpaceID, data.Kind, data.ObjectID, data.UserID, data.Password, data.Salt, data.ExpiredAt, data.CreatedAt)
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// Get 根据 token 获取分享
+func (s *ShareTokenStore) Get(ctx context.Context, token string) (*types.ShareToken, error) {
+	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).Where(sq.Eq{"token": token})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	var res types.ShareToken
+	if err = s.GetReplica(ctx).Get(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// List 获取空间中的分享，kind 与 objectID 不为空时只返回对应对象的分享，最新创建的排在最前
+func (s *ShareTokenStore) List(ctx context.Context, spaceID string, kind types.ShareKind, objectID string) ([]types.ShareToken, error) {
+	query := sq.Select(s.GetAllColumns()...).
+		From(s.GetTable()).
+		Where(sq.Eq{"space_id": spaceID}).
+		OrderBy("created_at DESC", "token")
+	if kind != "" {
+		query = query.Where(sq.Eq{"kind": kind})
+	}
+	if objectID != "" {
+		query = query.Where(sq.Eq{"object_id": objectID})
+	}
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	var res []types.ShareToken
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Delete 撤销分享
+func (s *ShareTokenStore) Delete(ctx context.Context, spaceID, token string) error {
+	return s.delete(ctx, sq.Eq{"space_id": spaceID, "token": token})
+}
+
+// DeleteByObject 撤销知识或会话的全部分享
+func (s *ShareTokenStore) DeleteByObject(ctx context.Context, spaceID string, kind types.ShareKind, objectID string) error {
+	return s.delete(ctx, sq.Eq{"space_id": spaceID, "kind": kind, "object_id": objectID})
+}
+
+// DeleteAll 删除空间下的全部分享
+func (s *ShareTokenStore) DeleteAll(ctx context.Context, spaceID string) error {
+	return s.delete(ctx, sq.Eq{"space_id": spaceID})
+}
+
+func (s *ShareTokenStore) delete(ctx context.Context, where sq.Eq) error {
+	query := sq.Delete(s.GetTable()).Where(where)
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
@@ -0,0 +1,26 @@
+-- 创建表 bw_share_token
+CREATE TABLE bw_share_token (
+    token VARCHAR(64) PRIMARY KEY, -- 分享token
+    space_id VARCHAR(32) NOT NULL, -- 空间ID
+    kind VARCHAR(16) NOT NULL, -- 分享的对象类型，knowledge 或 session
+    object_id VARCHAR(32) NOT NULL, -- 分享的知识ID或会话ID
+    user_id VARCHAR(32) NOT NULL, -- 创建分享的用户ID
+    password VARCHAR(255) NOT NULL DEFAULT '', -- 访问密码
+    salt VARCHAR(10) NOT NULL DEFAULT '', -- 访问密码盐值
+    expired_at BIGINT NOT NULL DEFAULT 0, -- 过期时间
+    created_at BIGINT NOT NULL -- 创建时间
+);
+
+-- 创建索引
+CREATE INDEX idx_bw_share_token_object ON bw_share_token (space_id, kind, object_id);
+
+-- 为字段添加注释
+COMMENT ON COLUMN bw_share_token.token IS '分享token，持有即可在未登录的情况下查看分享的内容';
+COMMENT ON COLUMN bw_share_token.space_id IS '空间ID';
+COMMENT ON COLUMN bw_share_token.kind IS '分享的对象类型，knowledge 或 session';
+COMMENT ON COLUMN bw_share_token.object_id IS '分享的知识ID或会话ID';
+COMMENT ON COLUMN bw_share_token.user_id IS '创建分享的用户ID';
+COMMENT ON COLUMN bw_share_token.password IS '访问密码，为空时无需密码';
+COMMENT ON COLUMN bw_share_token.salt IS '访问密码盐值';
+COMMENT ON COLUMN bw_share_token.expired_at IS '过期时间，Unix时间戳，为0时永不过期';
+COMMENT ON COLUMN bw_share_token.created_at IS '创建时间，Unix时间戳';
@@ -26,7 +26,7 @@ func NewSpaceStore(provider SqlProviderAchieve) *SpaceStore {
 	repo := &SpaceStore{}
 	repo.SetProvider(provider)
 	repo.SetTable(types.TABLE_SPACE)
-	repo.SetAllColumns("space_id", "title", "description", "created_at")
+	repo.SetAllColumns("space_id", "title", "description", "retrieval", "privacy", "created_at")
 	return repo
 }
 
@@ -36,8 +36,8 @@ func (s *SpaceStore) Create(ctx context.Context, data types.Space) error {
 		data.CreatedAt = time.Now().Unix()
 	}
 	query := sq.Insert(s.GetTable()).
-		Columns("space_id", "title", "description", "created_at").
-		Values(data.SpaceID, data.Title, data.Description, data.CreatedAt)
+		Columns("space_id", "title", "description", "retrieval", "privacy", "created_at").
+		Values(data.SpaceID, data.Title, data.Description, data.Retrieval, data.Privacy, data.CreatedAt)
 
 	queryString, args, err := query.ToSql()
 	if err != nil {
@@ -87,6 +87,36 @@ func (s *SpaceStore) Update(ctx context.Context, spaceID, title, desc string) er
 	return err
 }
 
+// UpdateRetrieval 更新空间的检索配置
+func (s *SpaceStore) UpdateRetrieval(ctx context.Context, spaceID string, settings types.RetrievalSettings) error {
+	query := sq.Update(s.GetTable()).
+		Set("retrieval", settings).
+		Where(sq.Eq{"space_id": spaceID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// UpdatePrivacy 更新空间的敏感信息识别配置
+func (s *SpaceStore) UpdatePrivacy(ctx context.Context, spaceID string, settings types.PrivacySettings) error {
+	query := sq.Update(s.GetTable()).
+		Set("privacy", settings).
+		Where(sq.Eq{"space_id": spaceID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
 func (s *SpaceStore) Delete(ctx context.Context, spaceID string) error {
 	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID})
 
@@ -2,6 +2,8 @@ CREATE TABLE bw_space (
     space_id VARCHAR(32) NOT NULL,  -- 空间的唯一标识
     title VARCHAR(32) NOT NULL,  -- 空间的唯一标识
     description TEXT NOT NULL, -- 用户在空间中的角色
+    retrieval JSONB NOT NULL DEFAULT '{}', -- 检索配置
+    privacy JSONB NOT NULL DEFAULT '{}', -- 敏感信息识别配置
     created_at BIGINT NOT NULL, -- 记录创建时间
     UNIQUE (space_id) -- 确保每个空间只有一个记录
 );
@@ -10,6 +12,8 @@ CREATE TABLE bw_space (
 COMMENT ON COLUMN bw_space.space_id IS '空间ID';
 COMMENT ON COLUMN bw_space.title IS '空间标题';
 COMMENT ON COLUMN bw_space.description IS '简介';
+COMMENT ON COLUMN bw_space.retrieval IS '检索配置，如混合检索的权重、召回数量与距离阈值';
+COMMENT ON COLUMN bw_space.privacy IS '敏感信息识别配置，如自动识别的类型与自定义正则';
 COMMENT ON COLUMN bw_space.created_at IS '创建时间，存储为时间戳';
 
 -- 创建 user_id 和 space_id 索引
@@ -0,0 +1,136 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/starbx/brew-api/pkg/register"
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+func init() {
+	register.RegisterFunc(registerKey{}, func() {
+		provider.stores.SpaceKeyStore = NewSpaceKeyStore(provider)
+	})
+}
+
+// SpaceKeyStore 处理 bw_space_key 表的操作
+type SpaceKeyStore struct {
+	CommonFields
+}
+
+// NewSpaceKeyStore 创建一个新的 SpaceKeyStore 实例
+func NewSpaceKeyStore(provider SqlProviderAchieve) *SpaceKeyStore {
+	repo := &SpaceKeyStore{}
+	repo.SetProvider(provider)
+	repo.SetTable(types.TABLE_SPACE_KEY)
+	repo.SetAllColumns("space_id", "version", "wrapped_key", "created_at")
+	return repo
+}
+
+// Create 创建数据密钥，同一版本已存在时不做修改并返回 false，用于多个实例同时为空间生成密钥的情况
+func (s *SpaceKeyStore) Create(ctx context.Context, data types.SpaceKey) (bool, error) {
+	if data.CreatedAt == 0 {
+		data.CreatedAt = time.Now().Unix()
+	}
+	query := sq.Insert(s.GetTable()).
+		Columns("space_id", "version", "wrapped_key", "created_at").
+		Values(data.SpaceID, data.Version, data.WrappedKey, data.CreatedAt).
+		Suffix("ON CONFLICT (space_id, version) DO NOTHING")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return false, errorSqlBuild(err)
+	}
+
+	res, err := s.GetMaster(ctx).Exec(queryString, args...)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// List 获取空间的全部数据密钥，按版本升序排列，从主库读取以免刚生成的密钥因同步延迟读取不到
+func (s *SpaceKeyStore) List(ctx context.Context, spaceID string) ([]types.SpaceKey, error) {
+	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).Where(sq.Eq{"space_id": spaceID}).OrderBy("version")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	var res []types.SpaceKey
+	if err = s.QueryMaster(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ListAll 分页获取全部空间的数据密钥
+func (s *SpaceKeyStore) ListAll(ctx context.Context, page, pageSize uint64) ([]types.SpaceKey, error) {
+	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).OrderBy("space_id", "version")
+	if page != types.NO_PAGING || pageSize != types.NO_PAGING {
+		query = query.Limit(pageSize).Offset((page - 1) * pageSize)
+	}
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	var res []types.SpaceKey
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// UpdateWrappedKey 更新数据密钥的包裹结果，用于主密钥轮换后重新包裹
+func (s *SpaceKeyStore) UpdateWrappedKey(ctx context.Context, spaceID string, version int, wrappedKey string) error {
+	query := sq.Update(s.GetTable()).
+		Set("wrapped_key", wrappedKey).
+		Where(sq.Eq{"space_id": spaceID, "version": version})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
+
+// DeleteBefore 删除空间中早于 version 的数据密钥，调用前需要确认已没有使用这些版本加密的内容
+func (s *SpaceKeyStore) DeleteBefore(ctx context.Context, spaceID string, version int) (int64, error) {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID}).Where(sq.Lt{"version": version})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return 0, errorSqlBuild(err)
+	}
+
+	res, err := s.GetMaster(ctx).Exec(queryString, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteAll 删除空间的全部数据密钥
+func (s *SpaceKeyStore) DeleteAll(ctx context.Context, spaceID string) error {
+	query := sq.Delete(s.GetTable()).Where(sq.Eq{"space_id": spaceID})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	_, err = s.GetMaster(ctx).Exec(queryString, args...)
+	return err
+}
@@ -0,0 +1,14 @@
+-- 创建表 bw_space_key
+CREATE TABLE bw_space_key (
+    space_id VARCHAR(32) NOT NULL, -- 空间ID
+    version INT NOT NULL, -- 数据密钥版本
+    wrapped_key TEXT NOT NULL, -- 主密钥包裹后的数据密钥
+    created_at BIGINT NOT NULL, -- 创建时间
+    PRIMARY KEY (space_id, version)
+);
+
+-- 为字段添加注释
+COMMENT ON COLUMN bw_space_key.space_id IS '空间ID';
+COMMENT ON COLUMN bw_space_key.version IS '数据密钥版本，每次轮换加一，密文中记录加密时使用的版本';
+COMMENT ON COLUMN bw_space_key.wrapped_key IS '主密钥包裹后的数据密钥，格式为 {主密钥指纹}${base64密文}';
+COMMENT ON COLUMN bw_space_key.created_at IS '创建时间';
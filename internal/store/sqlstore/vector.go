@@ -2,9 +2,14 @@ package sqlstore
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"sync"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
 	"github.com/pgvector/pgvector-go"
 
 	"github.com/starbx/brew-api/pkg/register"
@@ -17,8 +22,14 @@ func init() {
 	})
 }
 
+// HNSW_MAX_DIM pgvector 的 hnsw 索引支持的最大向量维度
+const HNSW_MAX_DIM = 2000
+
 type VectorStore struct {
 	CommonFields
+
+	// indexed 本进程中已确认建立了 hnsw 索引的模型
+	indexed sync.Map
 }
 
 // NewBwVectorStore 创建新的 BwVectorStore 实例
@@ -26,7 +37,7 @@ func NewVectorStore(provider SqlProviderAchieve) *VectorStore {
 	repo := &VectorStore{}
 	repo.SetProvider(provider)
 	repo.SetTable(types.TABLE_VECTORS)
-	repo.SetAllColumns("id", "knowledge_id", "space_id", "user_id", "embedding", "original_length", "created_at", "updated_at")
+	repo.SetAllColumns("id", "knowledge_id", "space_id", "user_id", "resource", "embedding", "model", "dim", "original_length", "created_at", "updated_at")
 	return repo
 }
 
@@ -39,8 +50,8 @@ func (s *VectorStore) Create(ctx context.Context, data types.Vector) error {
 		data.UpdatedAt = time.Now().Unix()
 	}
 	query := sq.Insert(s.GetTable()).
-		Columns("id", "knowledge_id", "space_id", "user_id", "resource", "embedding", "original_length", "created_at", "updated_at").
-		Values(data.ID, data.KnowledgeID, data.SpaceID, data.UserID, data.Resource, data.Embedding, data.OriginalLength, data.CreatedAt, data.UpdatedAt)
+		Columns("id", "knowledge_id", "space_id", "user_id", "resource", "embedding", "model", "dim", "original_length", "created_at", "updated_at").
+		Values(data.ID, data.KnowledgeID, data.SpaceID, data.UserID, data.Resource, data.Embedding, data.Model, data.Dim, data.OriginalLength, data.CreatedAt, data.UpdatedAt)
 
 	queryString, args, err := query.ToSql()
 	if err != nil {
@@ -59,7 +70,7 @@ func (s *VectorStore) Create(ctx context.Context, data types.Vector) error {
 // BatchCreate 批量创建新的文本向量记录
 func (s *VectorStore) BatchCreate(ctx context.Context, datas []types.Vector) error {
 	query := sq.Insert(s.GetTable()).
-		Columns("id", "knowledge_id", "space_id", "user_id", "resource", "embedding", "original_length", "created_at", "updated_at")
+		Columns("id", "knowledge_id", "space_id", "user_id", "resource", "embedding", "model", "dim", "original_length", "created_at", "updated_at")
 
 	for _, data := range datas {
 		if data.CreatedAt == 0 {
@@ -68,7 +79,7 @@ func (s *VectorStore) BatchCreate(ctx context.Context, datas []types.Vector) err
 		if data.UpdatedAt == 0 {
 			data.UpdatedAt = time.Now().Unix()
 		}
-		query = query.Values(data.ID, data.KnowledgeID, data.SpaceID, data.UserID, data.Resource, data.Embedding, data.OriginalLength, data.CreatedAt, data.UpdatedAt)
+		query = query.Values(data.ID, data.KnowledgeID, data.SpaceID, data.UserID, data.Resource, data.Embedding, data.Model, data.Dim, data.OriginalLength, data.CreatedAt, data.UpdatedAt)
 	}
 
 	queryString, args, err := query.ToSql()
@@ -152,6 +163,114 @@ func (s *VectorStore) DeleteAll(ctx context.Context, spaceID string) error {
 	return err
 }
 
+// PruneModels 删除空间（spaceID 为空时为全部空间）中不是由 model 生成的向量，返回删除的数量
+func (s *VectorStore) PruneModels(ctx context.Context, spaceID, model string) (int64, error) {
+	query := sq.Delete(s.GetTable()).Where(sq.NotEq{"model": model})
+	if spaceID != "" {
+		query = query.Where(sq.Eq{"space_id": spaceID})
+	}
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return 0, errorSqlBuild(err)
+	}
+
+	res, err := s.GetMaster(ctx).Exec(queryString, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// AdoptLegacy 将没有记录模型的向量标记为由 model 生成，用于升级前已存在的向量，返回标记的数量
+func (s *VectorStore) AdoptLegacy(ctx context.Context, spaceID, model string) (int64, error) {
+	query := sq.Update(s.GetTable()).
+		Set("model", model).
+		Set("dim", sq.Expr("vector_dims(embedding)")).
+		Where(sq.Eq{"model": ""})
+	if spaceID != "" {
+		query = query.Where(sq.Eq{"space_id": spaceID})
+	}
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return 0, errorSqlBuild(err)
+	}
+
+	res, err := s.GetMaster(ctx).Exec(queryString, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// EnsureIndex 为 model 生成的向量建立 hnsw 索引
+// 向量列不限定维度，索引建立在转换为该模型维度的表达式上并只包含该模型的向量，指定模型的检索使用相同的表达式以命中索引
+// 该模型还没有向量时跳过，索引已存在时不做任何处理，不能在事务中调用
+func (s *VectorStore) EnsureIndex(ctx context.Context, model string) error {
+	if model == "" {
+		return nil
+	}
+	if _, ok := s.indexed.Load(model); ok {
+		return nil
+	}
+
+	query := sq.Select("dim").From(s.GetTable()).Where(sq.Eq{"model": model}).Where(sq.Gt{"dim": 0}).Limit(1)
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return errorSqlBuild(err)
+	}
+
+	var dim int
+	if err = s.GetReplica(ctx).Get(&dim, queryString, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+	if dim > HNSW_MAX_DIM {
+		return fmt.Errorf("dimension %d of model %s exceeds the hnsw limit %d, similarity queries will scan the whole table", dim, model, HNSW_MAX_DIM)
+	}
+
+	// 索引名称有长度限制，模型标识中也可能包含不能用于名称的字符，DDL 不支持占位符
+	h := fnv.New32a()
+	h.Write([]byte(model))
+	_, err = s.GetMaster(ctx).Exec(fmt.Sprintf("CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_vectors_embedding_%x ON %s USING hnsw (%s vector_cosine_ops) WHERE model = %s",
+		h.Sum32(), s.GetTable(), embeddingColumn("embedding", model, dim), pq.QuoteLiteral(model)))
+	if err != nil {
+		return err
+	}
+	s.indexed.Store(model, struct{}{})
+	return nil
+}
+
+// embeddingColumn 指定模型时将向量列转换为该模型的维度，与 EnsureIndex 建立的索引表达式一致
+func embeddingColumn(column, model string, dim int) string {
+	if model == "" {
+		return column
+	}
+	return fmt.Sprintf("(%s::vector(%d))", column, dim)
+}
+
+// ExistIDs 返回 ids 中已有 model 生成的向量的ID
+func (s *VectorStore) ExistIDs(ctx context.Context, model string, ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	query := sq.Select("id").From(s.GetTable()).Where(sq.Eq{"model": model, "id": ids})
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	var res []string
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
 // ListBwVectors 分页获取文本向量记录列表
 func (s *VectorStore) ListVectors(ctx context.Context, opts types.GetVectorsOptions, page, pageSize uint64) ([]types.Vector, error) {
 	query := sq.Select(s.GetAllColumns()...).From(s.GetTable()).Limit(pageSize).Offset((page - 1) * pageSize).OrderBy("created_at DESC")
@@ -175,7 +294,8 @@ func (s *VectorStore) Query(ctx context.Context, opts types.GetVectorsOptions, v
 	// <#> - (negative) inner product
 	// <=> - cosine distance
 	// <+> - L1 distance (added in 0.7.0)
-	cosColum, vectorArgs, _ := sq.Expr("(embedding <=> ?) as cos", vectors).ToSql()
+	embedding := embeddingColumn("embedding", opts.Model, len(vectors.Slice()))
+	cosColum, vectorArgs, _ := sq.Expr(fmt.Sprintf("(%s <=> ?) as cos", embedding), vectors).ToSql()
 	query := sq.Select("id", "knowledge_id", "original_length", cosColum).From(s.GetTable()).Limit(limit).OrderBy("cos ASC")
 	opts.Apply(&query)
 
@@ -194,3 +314,65 @@ func (s *VectorStore) Query(ctx context.Context, opts types.GetVectorsOptions, v
 	}
 	return res, nil
 }
+
+// HybridQuery 分别取向量检索与全文检索排名靠前的候选分块，按 reciprocal rank fusion 融合两者的排名
+// 全文检索只在满足 opts 过滤条件的向量所对应的分块中进行，向量id与分块id相同
+// 查询文本中的词之间为"或"的关系，命中词越多的分块全文检索排名越靠前
+func (s *VectorStore) HybridQuery(ctx context.Context, opts types.GetVectorsOptions, query types.HybridQuery, limit uint64) ([]types.QueryResult, error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Question)
+
+	embedding := embeddingColumn("embedding", opts.Model, len(query.Embedding.Slice()))
+	vectorRank := builder.Select("id").
+		Column(sq.Expr(fmt.Sprintf("ROW_NUMBER() OVER (ORDER BY %s <=> ?) AS rank", embedding), query.Embedding)).
+		From(s.GetTable()).
+		OrderByClause(embedding+" <=> ?", query.Embedding).
+		Limit(limit)
+	opts.Apply(&vectorRank)
+
+	candidates := builder.Select("id").From(s.GetTable())
+	opts.Apply(&candidates)
+	candidatesSql, candidatesArgs, err := candidates.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	keywordRank := builder.Select("c.id").
+		Column("ROW_NUMBER() OVER (ORDER BY ts_rank_cd(c.tsv, q) DESC) AS rank").
+		From(fmt.Sprintf("%s c", types.TABLE_KNOWLEDGE_CHUNK.Name())).
+		JoinClause("CROSS JOIN replace(plainto_tsquery(?::regconfig, ?)::text, ' & ', ' | ')::tsquery q", query.TextSearchConfig, query.Text).
+		Where("c.tsv @@ q").
+		Where(fmt.Sprintf("c.id IN (%s)", candidatesSql), candidatesArgs...).
+		OrderBy("ts_rank_cd(c.tsv, q) DESC").
+		Limit(limit)
+
+	fused := builder.Select("v.id", "v.knowledge_id", "v.original_length").
+		Column(sq.Expr("(v.embedding <=> ?) AS cos", query.Embedding)).
+		Column(sq.Expr("COALESCE(?::float8 / (?::float8 + vr.rank), 0) + COALESCE(?::float8 / (?::float8 + kr.rank), 0) AS score",
+			query.VectorWeight, query.RRFK, query.KeywordWeight, query.RRFK)).
+		Column("kr.rank IS NOT NULL AS keyword_matched").
+		Prefix("WITH vector_rank AS (?), keyword_rank AS (?)", vectorRank, keywordRank).
+		From("(SELECT id FROM vector_rank UNION SELECT id FROM keyword_rank) ids").
+		Join(fmt.Sprintf("%s v ON v.id = ids.id", s.GetTable())).
+		LeftJoin("vector_rank vr ON vr.id = ids.id").
+		LeftJoin("keyword_rank kr ON kr.id = ids.id").
+		OrderBy("score DESC", "cos ASC").
+		Limit(limit)
+	if opts.Model != "" {
+		// 同一分块在切换模型期间存在多个模型的向量
+		fused = fused.Where(sq.Eq{"v.model": opts.Model})
+	}
+
+	queryString, args, err := fused.ToSql()
+	if err != nil {
+		return nil, errorSqlBuild(err)
+	}
+	if queryString, err = sq.Dollar.ReplacePlaceholders(queryString); err != nil {
+		return nil, errorSqlBuild(err)
+	}
+
+	var res []types.QueryResult
+	if err = s.GetReplica(ctx).Select(&res, queryString, args...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -1,25 +1,34 @@
 -- 创建表
 CREATE TABLE bw_vectors (
-    id VARCHAR(32) PRIMARY KEY,
+    id VARCHAR(32) NOT NULL,
     knowledge_id VARCHAR(32) NOT NULL,
     space_id VARCHAR(32) NOT NULL,
     user_id VARCHAR(32) NOT NULL,
     resource VARCHAR(32) NOT NULL,
-    embedding vector(1024) NOT NULL,
+    embedding vector NOT NULL,
+    model VARCHAR(128) NOT NULL DEFAULT '',
+    dim INT NOT NULL DEFAULT 0,
     original_length INT NOT NULL DEFAULT 0,
     created_at BIGINT NOT NULL,
-    updated_at BIGINT NOT NULL
+    updated_at BIGINT NOT NULL,
+    PRIMARY KEY (id, model)
 );
 
 -- 添加字段注释
 COMMENT ON COLUMN bw_vectors.id IS '主键，自增ID';
 COMMENT ON COLUMN bw_vectors.space_id IS '空间ID，用于标识所属空间';
 COMMENT ON COLUMN bw_vectors.user_id IS '用户ID，用于标识向量所属用户';
-COMMENT ON COLUMN bw_vectors.embedding IS '文本向量，存储经过编码后的文本向量表示';
+COMMENT ON COLUMN bw_vectors.embedding IS '文本向量，存储经过编码后的文本向量表示，不限定维度以便不同模型的向量共存';
+COMMENT ON COLUMN bw_vectors.model IS '生成向量的模型标识，格式为 driver:model，同一分块在切换模型期间可以同时存在多个模型的向量';
+COMMENT ON COLUMN bw_vectors.dim IS '向量维度';
 COMMENT ON COLUMN bw_vectors.resource IS '资源类型';
 COMMENT ON COLUMN bw_knowledge_chunk.original_length IS '关联知识点长度';
 COMMENT ON COLUMN bw_vectors.created_at IS '创建时间，UNIX时间戳';
 COMMENT ON COLUMN bw_vectors.updated_at IS '更新时间，UNIX时间戳';
 
 
-CREATE INDEX idx_vectors_space_id_resource_knowledge_id ON bw_vectors (space_id, resource, knowledge_id);
\ No newline at end of file
+CREATE INDEX idx_vectors_space_id_resource_knowledge_id ON bw_vectors (space_id, resource, knowledge_id);
+CREATE INDEX idx_vectors_model_space_id ON bw_vectors (model, space_id);
+-- 相似检索的 hnsw 索引按模型分别建立在 (embedding::vector(维度)) 上，由服务在该模型的向量第一次写入后自动创建
+-- 按空间等条件过滤时 hnsw 可能返回不足 limit 条结果，pgvector 0.8 以上建议设置 hnsw.iterative_scan = relaxed_order
+
@@ -16,8 +16,12 @@ type KnowledgeStore interface {
 	sqlstore.SqlCommons
 	// Create 创建新的知识记录
 	Create(ctx context.Context, data types.Knowledge) error
+	// BatchCreate 批量创建知识记录
+	BatchCreate(ctx context.Context, data []types.Knowledge) error
 	// GetKnowledge 根据ID获取知识记录
 	GetKnowledge(ctx context.Context, spaceID, id string) (*types.Knowledge, error)
+	// GetKnowledgeForUpdate 在事务中读取知识记录并加行锁
+	GetKnowledgeForUpdate(ctx context.Context, spaceID, id string) (*types.Knowledge, error)
 	// Update 更新知识记录
 	Update(ctx context.Context, spaceID, id string, data types.UpdateKnowledgeArgs) error
 	// Delete 删除知识记录
@@ -29,9 +33,16 @@ type KnowledgeStore interface {
 	ListLiteKnowledges(ctx context.Context, opts types.GetKnowledgeOptions, page, pageSize uint64) ([]*types.KnowledgeLite, error)
 	FinishedStageSummarize(ctx context.Context, spaceID, id string, summary ai.ChunkResult) error
 	FinishedStageEmbedding(ctx context.Context, spaceID, id string) error
+	// Archive 将知识标记为已归档
+	Archive(ctx context.Context, spaceID, id string) error
 	SetRetryTimes(ctx context.Context, spaceID, id string, retryTimes int) error
 	ListProcessingKnowledges(ctx context.Context, retryTimes int, page, pageSize uint64) ([]types.Knowledge, error)
+	ResetRetryTimes(ctx context.Context, spaceID string, ids []string) error
 	ListFailedKnowledges(ctx context.Context, stage types.KnowledgeStage, retryTimes int, page, pageSize uint64) ([]types.Knowledge, error)
+	// ListTags 统计空间中的标签及使用次数
+	ListTags(ctx context.Context, spaceID string) ([]types.TagCount, error)
+	// ReplaceTags 将空间中的一组标签替换为指定标签，用于重命名与合并
+	ReplaceTags(ctx context.Context, spaceID string, from []string, to string) (int64, error)
 }
 
 // KnowledgeChunkStore 定义 KnowledgeChunkStore 的接口
@@ -44,11 +55,79 @@ type KnowledgeChunkStore interface {
 	Delete(ctx context.Context, spaceID, knowledgeID, id string) error
 	BatchDelete(ctx context.Context, spaceID, knowledgeID string) error
 	List(ctx context.Context, spaceID, knowledgeID string) ([]types.KnowledgeChunk, error)
+	ListByIDs(ctx context.Context, spaceID string, ids []string) ([]types.KnowledgeChunk, error)
+	// ListWithoutVector 按ID升序获取 afterID 之后已完成处理但还没有 model 生成的向量的知识片段，用于切换 embedding 模型后重新生成向量
+	// 只能排除 postgres 中记录的向量，使用其他向量存储时需要再通过 VectorStore.ExistIDs 过滤
+	ListWithoutVector(ctx context.Context, spaceID, model, afterID string, limit uint64) ([]types.KnowledgeChunk, error)
+	TotalWithoutVector(ctx context.Context, spaceID, model string) (int64, error)
 }
 
-// TODO support other vector db
-// current only pg
-// next qdrant
+// KnowledgeRevisionStore 定义知识修订记录的接口
+type KnowledgeRevisionStore interface {
+	sqlstore.SqlCommons
+	Create(ctx context.Context, data types.KnowledgeRevision) error
+	GetRevision(ctx context.Context, spaceID, knowledgeID, id string) (*types.KnowledgeRevision, error)
+	ListRevisions(ctx context.Context, spaceID, knowledgeID string, page, pageSize uint64) ([]types.KnowledgeRevision, error)
+	Total(ctx context.Context, spaceID, knowledgeID string) (int64, error)
+	BatchDelete(ctx context.Context, spaceID, knowledgeID string) error
+	DeleteAll(ctx context.Context, spaceID string) error
+}
+
+// KnowledgeJobStore 定义知识处理任务队列的接口
+type KnowledgeJobStore interface {
+	sqlstore.SqlCommons
+	// Enqueue 为知识创建指定阶段的任务，已存在的任务会被重置
+	Enqueue(ctx context.Context, spaceID, knowledgeID string, stage types.KnowledgeStage) error
+	// EnqueueMissing 为处理中但没有任务的知识补充任务，重试次数已用尽的直接记为失败
+	EnqueueMissing(ctx context.Context, retryTimes int) (int64, error)
+	// Lease 领取一个到期的任务，没有可领取的任务时返回 sql.ErrNoRows
+	Lease(ctx context.Context, lockID string, visibility int64) (*types.KnowledgeJob, error)
+	Advance(ctx context.Context, knowledgeID, lockID string, stage types.KnowledgeStage) (bool, error)
+	Complete(ctx context.Context, knowledgeID, lockID string) (bool, error)
+	Retry(ctx context.Context, knowledgeID, lockID string, runAt int64, lastError string) (bool, error)
+	Fail(ctx context.Context, knowledgeID, lockID, lastError string) (bool, error)
+	Get(ctx context.Context, knowledgeID string) (*types.KnowledgeJob, error)
+	Delete(ctx context.Context, spaceID, knowledgeID string) error
+	List(ctx context.Context, opts types.GetKnowledgeJobOptions, page, pageSize uint64) ([]types.KnowledgeJob, error)
+	Total(ctx context.Context, opts types.GetKnowledgeJobOptions) (int64, error)
+	ResetAttempts(ctx context.Context, spaceID, knowledgeID string) error
+	Cancel(ctx context.Context, spaceID, knowledgeID string) error
+	RequeueFailed(ctx context.Context, spaceID string) ([]string, error)
+}
+
+// KnowledgeDuplicateStore 定义疑似重复知识记录的接口
+type KnowledgeDuplicateStore interface {
+	sqlstore.SqlCommons
+	BatchCreate(ctx context.Context, data []types.KnowledgeDuplicate) error
+	List(ctx context.Context, spaceID string, page, pageSize uint64) ([]types.KnowledgeDuplicate, error)
+	Total(ctx context.Context, spaceID string) (int64, error)
+	DeleteByKnowledge(ctx context.Context, spaceID, knowledgeID string) error
+	DeleteAll(ctx context.Context, spaceID string) error
+}
+
+// KnowledgeLinkStore 定义知识之间链接关系的接口
+type KnowledgeLinkStore interface {
+	sqlstore.SqlCommons
+	Replace(ctx context.Context, spaceID, sourceID string, links []types.KnowledgeLink) error
+	ListOutgoing(ctx context.Context, spaceID string, sourceIDs []string) ([]types.KnowledgeLink, error)
+	ListIncoming(ctx context.Context, spaceID string, targetIDs []string) ([]types.KnowledgeLink, error)
+	ResolveTitle(ctx context.Context, spaceID, title, targetID string) (int64, error)
+	DeleteByKnowledge(ctx context.Context, spaceID, knowledgeID string) error
+	DeleteAll(ctx context.Context, spaceID string) error
+}
+
+// ShareTokenStore 定义知识与会话公开分享链接的接口
+type ShareTokenStore interface {
+	sqlstore.SqlCommons
+	Create(ctx context.Context, data types.ShareToken) error
+	Get(ctx context.Context, token string) (*types.ShareToken, error)
+	List(ctx context.Context, spaceID string, kind types.ShareKind, objectID string) ([]types.ShareToken, error)
+	Delete(ctx context.Context, spaceID, token string) error
+	DeleteByObject(ctx context.Context, spaceID string, kind types.ShareKind, objectID string) error
+	DeleteAll(ctx context.Context, spaceID string) error
+}
+
+// VectorStore 默认使用 pgvector，也可通过配置切换为 memstore 或 qdrantstore
 type VectorStore interface {
 	sqlstore.SqlCommons
 	Create(ctx context.Context, data types.Vector) error
@@ -58,8 +137,27 @@ type VectorStore interface {
 	Delete(ctx context.Context, spaceID, knowledgeID, id string) error
 	BatchDelete(ctx context.Context, spaceID, knowledgeID string) error
 	DeleteAll(ctx context.Context, spaceID string) error
+	// PruneModels 删除不是由 model 生成的向量
+	PruneModels(ctx context.Context, spaceID, model string) (int64, error)
+	// AdoptLegacy 将没有记录模型的向量标记为由 model 生成
+	AdoptLegacy(ctx context.Context, spaceID, model string) (int64, error)
+	// ExistIDs 返回 ids 中已有 model 生成的向量的ID
+	ExistIDs(ctx context.Context, model string, ids []string) ([]string, error)
+	// EnsureIndex 确保 model 生成的向量建立了相似检索的索引
+	EnsureIndex(ctx context.Context, model string) error
 	ListVectors(ctx context.Context, opts types.GetVectorsOptions, page, pageSize uint64) ([]types.Vector, error)
 	Query(ctx context.Context, opts types.GetVectorsOptions, vectors pgvector.Vector, limit uint64) ([]types.QueryResult, error)
+	// HybridQuery 同时进行全文检索与向量检索，按融合得分排序
+	HybridQuery(ctx context.Context, opts types.GetVectorsOptions, query types.HybridQuery, limit uint64) ([]types.QueryResult, error)
+}
+
+// BlobStore 保存知识的原始文件，如图片知识的原图，默认存储在 postgres，也可通过配置切换为本地目录
+type BlobStore interface {
+	sqlstore.SqlCommons
+	Put(ctx context.Context, spaceID, id string, data []byte) error
+	Get(ctx context.Context, spaceID, id string) ([]byte, error)
+	Delete(ctx context.Context, spaceID, id string) error
+	DeleteAll(ctx context.Context, spaceID string) error
 }
 
 type AccessTokenStore interface {
@@ -88,6 +186,8 @@ type SpaceStore interface {
 	Create(ctx context.Context, data types.Space) error
 	GetSpace(ctx context.Context, spaceID string) (*types.Space, error)
 	Update(ctx context.Context, spaceID, title, desc string) error
+	UpdateRetrieval(ctx context.Context, spaceID string, settings types.RetrievalSettings) error
+	UpdatePrivacy(ctx context.Context, spaceID string, settings types.PrivacySettings) error
 	Delete(ctx context.Context, spaceID string) error
 	List(ctx context.Context, spaceIDs []string, page, pageSize uint64) ([]types.Space, error)
 }
@@ -96,7 +196,8 @@ type ResourceStore interface {
 	sqlstore.SqlCommons // 继承通用SQL操作
 	Create(ctx context.Context, data types.Resource) error
 	GetResource(ctx context.Context, spaceID, id string) (*types.Resource, error)
-	Update(ctx context.Context, spaceID, id, title, desc, prompt string, cycle int) error
+	Update(ctx context.Context, spaceID, id, title, desc, prompt, chunkMode string, cycle int) error
+	UpdateRetrieval(ctx context.Context, spaceID, id string, settings types.RetrievalSettings) error
 	Delete(ctx context.Context, spaceID, id string) error
 	ListResources(ctx context.Context, spaceID string, page, pageSize uint64) ([]types.Resource, error)
 }
@@ -122,6 +223,7 @@ type ChatSessionStore interface {
 	Delete(ctx context.Context, spaceID, sessionID string) error
 	DeleteAll(ctx context.Context, spaceID string) error
 	List(ctx context.Context, spaceID, userID string, page, pageSize uint64) ([]types.ChatSession, error)
+	ListSpaceSessions(ctx context.Context, spaceID string, page, pageSize uint64) ([]types.ChatSession, error)
 	Total(ctx context.Context, spaceID, userID string) (int64, error)
 }
 
@@ -148,6 +250,7 @@ type ChatSummaryStore interface {
 	sqlstore.SqlCommons
 	Create(ctx context.Context, data types.ChatSummary) error
 	GetChatSessionLatestSummary(ctx context.Context, sessionID string) (*types.ChatSummary, error)
+	ListSessionSummaries(ctx context.Context, sessionID string) ([]types.ChatSummary, error)
 }
 
 type ChatMessageExtStore interface {
@@ -159,3 +262,14 @@ type ChatMessageExtStore interface {
 	Delete(ctx context.Context, id string) error
 	DeleteAll(ctx context.Context, spaceID string) error
 }
+
+// SpaceKeyStore 空间数据密钥的存储，密钥以主密钥包裹后的形式保存
+type SpaceKeyStore interface {
+	sqlstore.SqlCommons
+	Create(ctx context.Context, data types.SpaceKey) (bool, error)
+	List(ctx context.Context, spaceID string) ([]types.SpaceKey, error)
+	ListAll(ctx context.Context, page, pageSize uint64) ([]types.SpaceKey, error)
+	UpdateWrappedKey(ctx context.Context, spaceID string, version int, wrappedKey string) error
+	DeleteBefore(ctx context.Context, spaceID string, version int) (int64, error)
+	DeleteAll(ctx context.Context, spaceID string) error
+}
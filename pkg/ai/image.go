@@ -0,0 +1,125 @@
+package ai
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+const PROMPT_DESCRIBE_IMAGE_EN = `
+You are helping the user build a searchable personal knowledge base from images such as whiteboard photos, receipts, screenshots and documents.
+Look at the image provided by the user and:
+1.Caption: Describe what the image shows in a few sentences, including the kind of image, the main objects, people, places and any diagrams or charts, so that the user can find it later by asking questions.
+2.Text: Transcribe all readable text in the image as faithfully as possible, keep the original language and line breaks, and use Markdown tables for tabular content such as receipt items. Leave it empty if there is no text.
+3.Title: Generate a short title for the image.
+4.Tags: Extract between 2 to 5 tags.
+5.Date: If a date or time appears in the image, such as the date of a receipt, format it as 'year-month-day hour:minute', otherwise leave it empty.
+Write the caption, title and tags in the same language as the main text in the image, or in English if there is no text.
+`
+
+const DescribeImageFuncName = "describe_image"
+
+type ImageResult struct {
+	Title    string   `json:"title"`
+	Caption  string   `json:"caption"`
+	Text     string   `json:"text"`
+	Tags     []string `json:"tags"`
+	DateTime string   `json:"date_time"`
+	Token    int
+}
+
+// Content 将图片的描述与识别出的文字合并为知识内容，用于后续的分块与embedding
+func (r ImageResult) Content() string {
+	content := strings.TrimSpace(r.Caption)
+	if text := strings.TrimSpace(r.Text); text != "" {
+		content += "\n\n" + text
+	}
+	return strings.TrimSpace(content)
+}
+
+// NewDescribeImageRequest 构造识别图片的多模态请求，图片以 data url 的形式随消息发送
+func NewDescribeImageRequest(model, mimeType string, image []byte) openai.ChatCompletionRequest {
+	params := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"title": {
+				Type:        jsonschema.String,
+				Description: "A short title of the image.",
+			},
+			"caption": {
+				Type:        jsonschema.String,
+				Description: "Description of what the image shows.",
+			},
+			"text": {
+				Type:        jsonschema.String,
+				Description: "All readable text in the image, empty if there is no text.",
+			},
+			"tags": {
+				Type:        jsonschema.Array,
+				Description: "Keywords to help the user categorize the image later.",
+				Items: &jsonschema.Definition{
+					Type: jsonschema.String,
+				},
+			},
+			"date_time": {
+				Type:        jsonschema.String,
+				Description: "The time shown in the image, formatted as 'year-month-day hour:minute'. If no time can be extracted, leave it empty.",
+			},
+		},
+		Required: []string{"title", "caption", "text", "tags"},
+	}
+
+	return openai.ChatCompletionRequest{
+		Model: model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: PROMPT_DESCRIBE_IMAGE_EN},
+			{Role: openai.ChatMessageRoleUser, MultiContent: []openai.ChatMessagePart{
+				{
+					Type: openai.ChatMessagePartTypeImageURL,
+					ImageURL: &openai.ChatMessageImageURL{
+						URL:    fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(image)),
+						Detail: openai.ImageURLDetailHigh,
+					},
+				},
+			}},
+		},
+		Tools: []openai.Tool{{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        DescribeImageFuncName,
+				Description: "Save the description and text of the image.",
+				Parameters:  params,
+			},
+		}},
+		ToolChoice: openai.ToolChoice{
+			Type:     openai.ToolTypeFunction,
+			Function: openai.ToolFunction{Name: DescribeImageFuncName},
+		},
+	}
+}
+
+// ParseDescribeImageResponse 解析 NewDescribeImageRequest 请求的结果
+func ParseDescribeImageResponse(resp openai.ChatCompletionResponse) (ImageResult, error) {
+	var result ImageResult
+	if len(resp.Choices) != 1 {
+		return result, fmt.Errorf("Completion error: len(choices):%v", len(resp.Choices))
+	}
+	for _, v := range resp.Choices[0].Message.ToolCalls {
+		if v.Function.Name != DescribeImageFuncName {
+			continue
+		}
+		if err := json.Unmarshal([]byte(v.Function.Arguments), &result); err != nil {
+			return result, fmt.Errorf("failed to unmarshal func call arguments of ImageResult, %w", err)
+		}
+	}
+	if result.Content() == "" {
+		return result, fmt.Errorf("nothing recognized from the image")
+	}
+
+	result.Token = resp.Usage.TotalTokens
+	return result, nil
+}
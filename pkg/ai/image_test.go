@@ -0,0 +1,41 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func Test_ParseDescribeImageResponse(t *testing.T) {
+	req := NewDescribeImageRequest("vision", "image/png", []byte("png"))
+	if url := req.Messages[1].MultiContent[0].ImageURL.URL; url != "data:image/png;base64,cG5n" {
+		t.Fatalf("unexpected image url %s", url)
+	}
+
+	resp := openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{{
+			Message: openai.ChatCompletionMessage{
+				ToolCalls: []openai.ToolCall{{
+					Function: openai.FunctionCall{
+						Name:      DescribeImageFuncName,
+						Arguments: `{"title":"Receipt","caption":"A coffee receipt.","text":"Latte 32.00","tags":["receipt"]}`,
+					},
+				}},
+			},
+		}},
+		Usage: openai.Usage{TotalTokens: 10},
+	}
+	result, err := ParseDescribeImageResponse(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Content() != "A coffee receipt.\n\nLatte 32.00" || result.Token != 10 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	resp.Choices[0].Message.ToolCalls[0].Function.Arguments = `{"title":"","caption":" ","text":"","tags":[]}`
+	if _, err = ParseDescribeImageResponse(resp); err == nil || !strings.Contains(err.Error(), "nothing recognized") {
+		t.Fatalf("expected error for empty result, got %v", err)
+	}
+}
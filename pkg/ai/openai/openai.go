@@ -38,6 +38,13 @@ func New(token, proxy string, model ai.ModelName) *Driver {
 		model.EmbeddingModel = string(openai.LargeEmbedding3)
 	}
 
+	if model.VisionModel == "" {
+		model.VisionModel = model.ChatModel
+	}
+	if model.TranscriptionModel == "" {
+		model.TranscriptionModel = openai.Whisper1
+	}
+
 	return &Driver{
 		client: openai.NewClientWithConfig(cfg),
 		model:  model,
@@ -428,3 +435,23 @@ func (s *Driver) Chunk(ctx context.Context, doc *string) (ai.ChunkResult, error)
 	result.Token = resp.Usage.TotalTokens
 	return result, nil
 }
+
+// DescribeImage 使用多模态模型生成图片的描述并识别其中的文字
+func (s *Driver) DescribeImage(ctx context.Context, mimeType string, image []byte) (ai.ImageResult, error) {
+	slog.Debug("DescribeImage", slog.String("driver", NAME))
+	resp, err := s.client.CreateChatCompletion(ctx, ai.NewDescribeImageRequest(s.model.VisionModel, mimeType, image))
+	if err != nil {
+		return ai.ImageResult{}, fmt.Errorf("Completion error: err:%w", err)
+	}
+	return ai.ParseDescribeImageResponse(resp)
+}
+
+// Transcribe 调用 /audio/transcriptions 将音视频转写为带时间信息的文字
+func (s *Driver) Transcribe(ctx context.Context, filename string, media []byte) (ai.TranscriptResult, error) {
+	slog.Debug("Transcribe", slog.String("driver", NAME))
+	resp, err := s.client.CreateTranscription(ctx, ai.NewTranscriptionRequest(s.model.TranscriptionModel, filename, media))
+	if err != nil {
+		return ai.TranscriptResult{}, fmt.Errorf("Transcription error: err:%w", err)
+	}
+	return ai.ParseTranscriptionResponse(resp)
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
@@ -33,10 +35,6 @@ func new() *openai.Driver {
 
 func Test_Embedding(t *testing.T) {
 	d := new()
-	f, err := os.Create("./vectors")
-	if err != nil {
-		t.Fatal(err)
-	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
 	defer cancel()
@@ -45,6 +43,13 @@ func Test_Embedding(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// 请求成功后再覆盖 vectors，避免请求失败时清空已提交的向量数据
+	f, err := os.Create("./vectors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
 	t.Log(len(res))
 
 	raw, err := json.Marshal(res)
@@ -138,3 +143,29 @@ pgvector/pgvector:pg16
 
 	t.Log(resp)
 }
+
+// Test_Transcribe 使用本地的假服务模拟 OpenAI 兼容的转写接口，无需真实的 token
+func Test_Transcribe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/audio/transcriptions", r.URL.Path)
+		assert.NoError(t, r.ParseMultipartForm(1<<20))
+		assert.Equal(t, "whisper-1", r.FormValue("model"))
+		assert.Equal(t, "verbose_json", r.FormValue("response_format"))
+		file, header, err := r.FormFile("file")
+		assert.NoError(t, err)
+		defer file.Close()
+		assert.Equal(t, "sync.mp3", header.Filename)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"language":"english","duration":12.5,"text":"hello","segments":[{"start":0,"end":4,"text":" Hello everyone."},{"start":4,"end":12.5,"text":" We ship on Friday."}]}`))
+	}))
+	defer server.Close()
+
+	d := openai.New("fake", server.URL, ai.ModelName{})
+	result, err := d.Transcribe(context.Background(), "sync.mp3", []byte("audio"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 12.5, result.Duration)
+	assert.Equal(t, "[00:00:00] Hello everyone.\n[00:00:04] We ship on Friday.", result.Content())
+}
@@ -37,6 +37,10 @@ func New(token, proxy string, model ai.ModelName) *Driver {
 		model.EmbeddingModel = "text-embedding-v3"
 	}
 
+	if model.VisionModel == "" {
+		model.VisionModel = "qwen-vl-max"
+	}
+
 	return &Driver{
 		client: openai.NewClientWithConfig(cfg),
 		model:  model,
@@ -374,3 +378,13 @@ func (s *Driver) Chunk(ctx context.Context, doc *string) (ai.ChunkResult, error)
 	result.Token = resp.Usage.TotalTokens
 	return result, nil
 }
+
+// DescribeImage 使用多模态模型生成图片的描述并识别其中的文字
+func (s *Driver) DescribeImage(ctx context.Context, mimeType string, image []byte) (ai.ImageResult, error) {
+	slog.Debug("DescribeImage", slog.String("driver", NAME))
+	resp, err := s.client.CreateChatCompletion(ctx, ai.NewDescribeImageRequest(s.model.VisionModel, mimeType, image))
+	if err != nil {
+		return ai.ImageResult{}, fmt.Errorf("Completion error: err:%w", err)
+	}
+	return ai.ParseDescribeImageResponse(resp)
+}
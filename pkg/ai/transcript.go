@@ -0,0 +1,117 @@
+package ai
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+type TranscriptSegment struct {
+	// Start End 片段在音视频中的起止时间，单位秒
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+type TranscriptResult struct {
+	Language string
+	// Duration 音视频时长，单位秒
+	Duration float64
+	Segments []TranscriptSegment
+}
+
+// Content 将转写结果格式化为知识内容，每个片段一行并以 [hh:mm:ss] 开头，用于在分块与回答时保留时间信息
+func (r TranscriptResult) Content() string {
+	s := strings.Builder{}
+	for _, v := range r.Segments {
+		text := strings.TrimSpace(v.Text)
+		if text == "" {
+			continue
+		}
+		s.WriteString("[")
+		s.WriteString(FormatTimestamp(v.Start))
+		s.WriteString("] ")
+		s.WriteString(text)
+		s.WriteString("\n")
+	}
+	return strings.TrimSpace(s.String())
+}
+
+// FormatTimestamp 将秒数格式化为 hh:mm:ss
+func FormatTimestamp(seconds float64) string {
+	total := int(seconds)
+	return fmt.Sprintf("%02d:%02d:%02d", total/3600, total%3600/60, total%60)
+}
+
+var transcriptLineRegexp = regexp.MustCompile(`^\[(\d{2,}):(\d{2}):(\d{2})\]\s?(.*)$`)
+
+// ParseTranscript 解析 TranscriptResult.Content 格式的内容，没有时间标记的行归入上一个片段
+// 片段的结束时间为下一个片段的开始时间，最后一个片段的结束时间为 duration，未知时与开始时间相同
+func ParseTranscript(content string, duration float64) []TranscriptSegment {
+	var segments []TranscriptSegment
+	for _, line := range strings.Split(content, "\n") {
+		match := transcriptLineRegexp.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			if line = strings.TrimSpace(line); line != "" && len(segments) > 0 {
+				segments[len(segments)-1].Text += "\n" + line
+			}
+			continue
+		}
+		h, _ := strconv.Atoi(match[1])
+		m, _ := strconv.Atoi(match[2])
+		sec, _ := strconv.Atoi(match[3])
+		segments = append(segments, TranscriptSegment{
+			Start: float64(h*3600 + m*60 + sec),
+			Text:  match[4],
+		})
+	}
+
+	for i := range segments {
+		if i+1 < len(segments) {
+			segments[i].End = segments[i+1].Start
+		} else {
+			segments[i].End = max(duration, segments[i].Start)
+		}
+	}
+	return segments
+}
+
+// NewTranscriptionRequest 构造 OpenAI 兼容的 /audio/transcriptions 请求，要求返回带时间信息的片段
+func NewTranscriptionRequest(model, filename string, media []byte) openai.AudioRequest {
+	return openai.AudioRequest{
+		Model:                  model,
+		FilePath:               filename,
+		Reader:                 bytes.NewReader(media),
+		Format:                 openai.AudioResponseFormatVerboseJSON,
+		TimestampGranularities: []openai.TranscriptionTimestampGranularity{openai.TranscriptionTimestampGranularitySegment},
+	}
+}
+
+// ParseTranscriptionResponse 解析 NewTranscriptionRequest 请求的结果，服务未返回片段时以全文作为一个片段
+func ParseTranscriptionResponse(resp openai.AudioResponse) (TranscriptResult, error) {
+	result := TranscriptResult{
+		Language: resp.Language,
+		Duration: resp.Duration,
+	}
+	for _, v := range resp.Segments {
+		result.Segments = append(result.Segments, TranscriptSegment{
+			Start: v.Start,
+			End:   v.End,
+			Text:  v.Text,
+		})
+	}
+	if len(result.Segments) == 0 && strings.TrimSpace(resp.Text) != "" {
+		result.Segments = append(result.Segments, TranscriptSegment{
+			End:  resp.Duration,
+			Text: resp.Text,
+		})
+	}
+	if result.Content() == "" {
+		return result, fmt.Errorf("nothing recognized from the media")
+	}
+	return result, nil
+}
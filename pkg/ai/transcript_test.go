@@ -0,0 +1,45 @@
+package ai
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/starbx/brew-api/pkg/types"
+)
+
+func Test_Transcript(t *testing.T) {
+	result := TranscriptResult{
+		Duration: 3700,
+		Segments: []TranscriptSegment{
+			{Start: 0, End: 4.2, Text: " Let's start the sync."},
+			{Start: 65.8, End: 70, Text: "We decided to ship on Friday."},
+			{Start: 3661, End: 3700, Text: "Thanks all."},
+		},
+	}
+	content := result.Content()
+	assert.Equal(t, "[00:00:00] Let's start the sync.\n[00:01:05] We decided to ship on Friday.\n[01:01:01] Thanks all.", content)
+
+	segments := ParseTranscript(content+"\nsee you", result.Duration)
+	assert.Equal(t, []TranscriptSegment{
+		{Start: 0, End: 65, Text: "Let's start the sync."},
+		{Start: 65, End: 3661, Text: "We decided to ship on Friday."},
+		{Start: 3661, End: 3700, Text: "Thanks all.\nsee you"},
+	}, segments)
+
+	assert.Nil(t, ParseTranscript("no timestamps here", 0))
+}
+
+func Test_ConvertPassageWithOffsets(t *testing.T) {
+	docs := []*types.PassageInfo{{
+		ID:       "k1",
+		Content:  "[00:01:05] We decided to ship on Friday.",
+		DateTime: "2026-10-13 10:00",
+		Offsets:  []types.ChunkTimestamp{{Start: 65, End: 130}, {Start: 600, End: 660.5}},
+	}}
+	assert.Contains(t, convertPassageToPromptTextEN(docs), "the relevant parts are at 00:01:05-00:02:10, 00:10:00-00:11:00")
+	assert.Contains(t, convertPassageToPromptTextCN(docs), "相关的片段位于00:01:05-00:02:10、00:10:00-00:11:00")
+
+	docs[0].Offsets = nil
+	assert.NotContains(t, convertPassageToPromptTextEN(docs), "Source")
+}
@@ -3,7 +3,9 @@ package ai
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -19,6 +21,10 @@ import (
 type ModelName struct {
 	ChatModel      string
 	EmbeddingModel string
+	// VisionModel 识别图片所使用的多模态模型
+	VisionModel string
+	// TranscriptionModel 音视频转写所使用的模型
+	TranscriptionModel string
 }
 
 type Query interface {
@@ -455,6 +461,40 @@ func BuildRAGPrompt(tpl string, docs Docs, driver Lang) string {
 	return tpl
 }
 
+var (
+	promptVarRegexp = regexp.MustCompile(`\{([a-z_]+)\}`)
+	// PromptVars 自定义提示词模板中可使用的变量
+	PromptVars = []string{"{time_range}", "{symbol}", "{relevant_passage}", "{lang}"}
+)
+
+// ValidatePromptTemplate 校验自定义的 RAG 提示词模板，模板中必须包含 {relevant_passage} 用于填充参考内容，且不能使用未知的变量
+func ValidatePromptTemplate(tpl string) error {
+	if strings.TrimSpace(tpl) == "" {
+		return nil
+	}
+	if !strings.Contains(tpl, "{relevant_passage}") {
+		return fmt.Errorf("prompt must contain {relevant_passage}")
+	}
+	for _, v := range promptVarRegexp.FindAllString(tpl, -1) {
+		known := false
+		for _, name := range PromptVars {
+			if v == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown prompt variable %s", v)
+		}
+	}
+	return nil
+}
+
+// ReplaceVarLang 将模板中的 {lang} 替换为用户提问所使用的语言
+func ReplaceVarLang(tpl, query string) string {
+	return strings.ReplaceAll(tpl, "{lang}", utils.WhatLang(query))
+}
+
 func ReplaceVarWithLang(tpl, lang string) string {
 	switch lang {
 	case MODEL_BASE_LANGUAGE_CN:
@@ -502,6 +542,15 @@ func NewDocs(list []*types.PassageInfo) Docs {
 
 var CurrentSymbols = strings.Join([]string{"$hidden[]"}, ",")
 
+// formatOffsets 将片段的起止时间格式化为 hh:mm:ss-hh:mm:ss 的列表
+func formatOffsets(offsets []types.ChunkTimestamp, sep string) string {
+	list := make([]string, 0, len(offsets))
+	for _, v := range offsets {
+		list = append(list, FormatTimestamp(v.Start)+"-"+FormatTimestamp(v.End))
+	}
+	return strings.Join(list, sep)
+}
+
 func convertPassageToPromptTextCN(docs []*types.PassageInfo) string {
 	s := strings.Builder{}
 	for i, v := range docs {
@@ -513,6 +562,15 @@ func convertPassageToPromptTextCN(docs []*types.PassageInfo) string {
 		s.WriteString("\n")
 		s.WriteString("ID：")
 		s.WriteString(v.ID)
+		if len(v.Offsets) > 0 {
+			s.WriteString("\n来源：录音转写，内容中的[时:分:秒]为录音中的时间，与问题相关的片段位于")
+			s.WriteString(formatOffsets(v.Offsets, "、"))
+			s.WriteString("，引用时请注明对应的时间")
+		}
+		if v.LinkedFrom != "" {
+			s.WriteString("\n关联自：")
+			s.WriteString(v.LinkedFrom)
+		}
 		s.WriteString("\n内容：")
 		s.WriteString(v.Content)
 		s.WriteString("\n")
@@ -532,6 +590,15 @@ func convertPassageToPromptTextEN(docs []*types.PassageInfo) string {
 		s.WriteString("\n")
 		s.WriteString("ID：")
 		s.WriteString(v.ID)
+		if len(v.Offsets) > 0 {
+			s.WriteString("\nSource：Recording transcript, [hh:mm:ss] in the content is the time in the recording, the relevant parts are at ")
+			s.WriteString(formatOffsets(v.Offsets, ", "))
+			s.WriteString(", cite the time when you use them")
+		}
+		if v.LinkedFrom != "" {
+			s.WriteString("\nLinked from：")
+			s.WriteString(v.LinkedFrom)
+		}
 		s.WriteString("\nContent：")
 		s.WriteString(v.Content)
 		s.WriteString("\n")
@@ -7,3 +7,17 @@ func Test_TimeTpl(t *testing.T) {
 
 	t.Log(tpl)
 }
+
+func Test_ValidatePromptTemplate(t *testing.T) {
+	cases := map[string]bool{
+		"": true,
+		"answer with {relevant_passage} in {lang}, now is {time_range} {symbol}": true,
+		"answer in action items":    false,
+		"{relevant_passage} {solt}": false,
+	}
+	for tpl, valid := range cases {
+		if err := ValidatePromptTemplate(tpl); (err == nil) != valid {
+			t.Fatalf("unexpected result of %q: %v", tpl, err)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package chunk
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+const (
+	DEFAULT_CHUNK_SIZE    = 512
+	DEFAULT_CHUNK_OVERLAP = 64
+
+	DEFAULT_ENCODING = "cl100k_base"
+)
+
+func init() {
+	// 使用内置的bpe文件，避免运行时从网络下载
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+// TokenCounter 计算一段文本的token数
+type TokenCounter func(s string) int
+
+var (
+	tiktokenOnce    sync.Once
+	tiktokenCounter TokenCounter
+	tiktokenErr     error
+)
+
+// TiktokenCounter 返回基于 cl100k_base 编码的 token 计数器
+func TiktokenCounter() (TokenCounter, error) {
+	tiktokenOnce.Do(func() {
+		tkm, err := tiktoken.GetEncoding(DEFAULT_ENCODING)
+		if err != nil {
+			tiktokenErr = err
+			return
+		}
+		tiktokenCounter = func(s string) int {
+			return len(tkm.Encode(s, nil, nil))
+		}
+	})
+	return tiktokenCounter, tiktokenErr
+}
+
+// Chunker 按标题、段落、句子的顺序切分文本，保证每个分块不超过 size 个token，
+// 相邻分块之间保留不超过 overlap 个token的重叠内容。同样的输入总是得到同样的输出
+type Chunker struct {
+	size    int
+	overlap int
+	count   TokenCounter
+}
+
+func New(size, overlap int, counter TokenCounter) *Chunker {
+	if size <= 0 {
+		size = DEFAULT_CHUNK_SIZE
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+	return &Chunker{
+		size:    size,
+		overlap: overlap,
+		count:   counter,
+	}
+}
+
+// NewTiktoken 创建使用 tiktoken 计算token数的Chunker
+func NewTiktoken(size, overlap int) (*Chunker, error) {
+	counter, err := TiktokenCounter()
+	if err != nil {
+		return nil, err
+	}
+	return New(size, overlap, counter), nil
+}
+
+// piece 是切分过程中的最小单元，sep 为与前一个单元拼接时使用的分隔符
+type piece struct {
+	text   string
+	sep    string
+	tokens int
+}
+
+const (
+	sepBlock    = "\n\n"
+	sepSentence = " "
+)
+
+var (
+	headingRegexp  = regexp.MustCompile(`^#{1,6}\s`)
+	sentenceRegexp = regexp.MustCompile(`[^.!?。！？；;\n]+(?:[.!?。！？；;]+["'”’)）]*|\n|$)`)
+)
+
+// Split 切分文本
+func (c *Chunker) Split(doc string) []string {
+	doc = strings.TrimSpace(strings.ReplaceAll(doc, "\r\n", "\n"))
+	if doc == "" {
+		return nil
+	}
+
+	var pieces []piece
+	for _, section := range splitSections(doc) {
+		pieces = append(pieces, c.splitBlock(section, sepBlock)...)
+	}
+	return c.merge(pieces)
+}
+
+// Group 将连续的文本行合并为不超过 size 个token的分组，返回每组的起止下标 [start, end)
+// 用于音视频转写等需要保持行边界的内容，单行超出 size 时独占一组，分组之间不保留重叠
+func (c *Chunker) Group(lines []string) [][2]int {
+	var (
+		groups [][2]int
+		start  int
+		tokens int
+	)
+	for i, line := range lines {
+		n := c.count(line)
+		if i > start && tokens+n > c.size {
+			groups = append(groups, [2]int{start, i})
+			start, tokens = i, 0
+		}
+		tokens += n
+	}
+	if start < len(lines) {
+		groups = append(groups, [2]int{start, len(lines)})
+	}
+	return groups
+}
+
+// splitBlock 将超出长度的文本块依次按段落、句子、字符进一步切分
+func (c *Chunker) splitBlock(text, sep string) []piece {
+	if tokens := c.count(text); tokens <= c.size {
+		return []piece{{text: text, sep: sep, tokens: tokens}}
+	}
+
+	var result []piece
+	if paragraphs := splitParagraphs(text); len(paragraphs) > 1 {
+		for i, p := range paragraphs {
+			s := sepBlock
+			if i == 0 {
+				s = sep
+			}
+			result = append(result, c.splitBlock(p, s)...)
+		}
+		return result
+	}
+
+	if sentences := splitSentences(text); len(sentences) > 1 {
+		for i, s := range sentences {
+			ps := sepSentence
+			if i == 0 {
+				ps = sep
+			}
+			result = append(result, c.splitBlock(s, ps)...)
+		}
+		return result
+	}
+
+	for i, s := range c.splitHard(text) {
+		ps := ""
+		if i == 0 {
+			ps = sep
+		}
+		result = append(result, piece{text: s, sep: ps, tokens: c.count(s)})
+	}
+	return result
+}
+
+// splitHard 对无法再按语义切分的文本按字符切分，每次取不超过长度限制的最长前缀
+func (c *Chunker) splitHard(text string) []string {
+	var result []string
+	runes := []rune(text)
+	for len(runes) > 0 {
+		lo, hi := 1, len(runes)
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			if c.count(string(runes[:mid])) <= c.size {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		result = append(result, string(runes[:lo]))
+		runes = runes[lo:]
+	}
+	return result
+}
+
+// merge 将切分后的单元尽可能合并到不超过 size 的分块中
+func (c *Chunker) merge(pieces []piece) []string {
+	var (
+		chunks  []string
+		current []piece
+		tokens  int
+	)
+
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		chunks = append(chunks, join(current))
+
+		// 从当前分块末尾保留不超过 overlap 的单元作为下一个分块的开头
+		var (
+			kept       []piece
+			keptTokens int
+		)
+		for i := len(current) - 1; i > 0; i-- {
+			if keptTokens+current[i].tokens > c.overlap {
+				break
+			}
+			keptTokens += current[i].tokens
+			kept = append([]piece{current[i]}, kept...)
+		}
+		current, tokens = kept, keptTokens
+	}
+
+	for _, p := range pieces {
+		if len(current) > 0 && tokens+p.tokens > c.size {
+			flush()
+			// 重叠部分加上新的单元仍超出限制时，放弃重叠
+			if tokens+p.tokens > c.size {
+				current, tokens = nil, 0
+			}
+		}
+		current = append(current, p)
+		tokens += p.tokens
+	}
+
+	if len(current) > 0 {
+		chunks = append(chunks, join(current))
+	}
+	return chunks
+}
+
+func join(pieces []piece) string {
+	var b strings.Builder
+	for i, p := range pieces {
+		if i != 0 {
+			b.WriteString(p.sep)
+		}
+		b.WriteString(p.text)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// splitSections 按markdown标题切分文本，标题与其下方的内容归为一节，代码块中的 # 不视为标题
+func splitSections(doc string) []string {
+	var (
+		sections []string
+		current  []string
+		inFence  bool
+	)
+	for _, line := range strings.Split(doc, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		}
+		if !inFence && headingRegexp.MatchString(trimmed) && len(current) > 0 {
+			if s := strings.TrimSpace(strings.Join(current, "\n")); s != "" {
+				sections = append(sections, s)
+			}
+			current = nil
+		}
+		current = append(current, line)
+	}
+	if s := strings.TrimSpace(strings.Join(current, "\n")); s != "" {
+		sections = append(sections, s)
+	}
+	return sections
+}
+
+func splitParagraphs(text string) []string {
+	var result []string
+	for _, p := range strings.Split(text, "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func splitSentences(text string) []string {
+	var result []string
+	for _, s := range sentenceRegexp.FindAllString(text, -1) {
+		if s = strings.TrimSpace(s); s != "" {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,82 @@
+package chunk
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func wordCounter(s string) int {
+	return len(strings.Fields(s))
+}
+
+func TestSplitSections(t *testing.T) {
+	c := New(100, 0, wordCounter)
+	doc := "# Title\n\nintro text\n\n```sh\n# comment in code\n```\n\n## Usage\n\nrun it"
+	assert.Equal(t, []string{doc}, c.Split(doc))
+
+	c = New(10, 0, wordCounter)
+	assert.Equal(t, []string{
+		"# Title\n\nintro text\n\n```sh\n# comment in code\n```",
+		"## Usage\n\nrun it",
+	}, c.Split(doc))
+}
+
+func TestSplitSentencesWithOverlap(t *testing.T) {
+	doc := "One two three. Four five six. Seven eight nine. Ten eleven twelve."
+	c := New(6, 3, wordCounter)
+
+	chunks := c.Split(doc)
+	assert.Equal(t, []string{
+		"One two three. Four five six.",
+		"Four five six. Seven eight nine.",
+		"Seven eight nine. Ten eleven twelve.",
+	}, chunks)
+
+	for _, v := range chunks {
+		assert.LessOrEqual(t, wordCounter(v), 6)
+	}
+}
+
+func TestSplitHard(t *testing.T) {
+	c := New(4, 0, func(s string) int { return len([]rune(s)) })
+	assert.Equal(t, []string{"abcd", "efgh", "ij"}, c.Split("abcdefghij"))
+}
+
+func TestSplitDeterministicAndComplete(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 200; i++ {
+		if i%20 == 0 {
+			fmt.Fprintf(&b, "## Section %d\n\n", i/20)
+		}
+		fmt.Fprintf(&b, "This is sentence number %d of the document. ", i)
+		if i%5 == 4 {
+			b.WriteString("\n\n")
+		}
+	}
+	doc := b.String()
+
+	c, err := NewTiktoken(64, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := c.Split(doc)
+	assert.Equal(t, first, c.Split(doc))
+	assert.Greater(t, len(first), 10)
+
+	counter, _ := TiktokenCounter()
+	for _, v := range first {
+		assert.LessOrEqual(t, counter(v), 64)
+	}
+	// 不设置重叠时，所有内容都应该被保留下来
+	assert.Equal(t, strings.Join(strings.Fields(doc), " "), strings.Join(strings.Fields(strings.Join(first, " ")), " "))
+}
+
+func TestGroup(t *testing.T) {
+	c := New(5, 2, wordCounter)
+	lines := []string{"one two", "three four", "five six seven eight nine ten", "eleven"}
+	assert.Equal(t, [][2]int{{0, 2}, {2, 3}, {3, 4}}, c.Group(lines))
+	assert.Nil(t, c.Group(nil))
+}
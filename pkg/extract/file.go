@@ -0,0 +1,249 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+const (
+	// 单个上传文件的最大长度
+	MAX_FILE_SIZE = 20 << 20
+	// 单个音视频文件的最大长度，与 OpenAI 转写接口的限制一致
+	MAX_MEDIA_SIZE = 25 << 20
+
+	MIME_TYPE_MARKDOWN = "text/markdown"
+	MIME_TYPE_HTML     = "text/html"
+	MIME_TYPE_PDF      = "application/pdf"
+	MIME_TYPE_TEXT     = "text/plain"
+
+	MIME_TYPE_PNG  = "image/png"
+	MIME_TYPE_JPEG = "image/jpeg"
+	MIME_TYPE_WEBP = "image/webp"
+	MIME_TYPE_GIF  = "image/gif"
+
+	MIME_TYPE_MP3  = "audio/mpeg"
+	MIME_TYPE_M4A  = "audio/mp4"
+	MIME_TYPE_WAV  = "audio/wav"
+	MIME_TYPE_OGG  = "audio/ogg"
+	MIME_TYPE_WEBA = "audio/webm"
+	MIME_TYPE_MP4  = "video/mp4"
+	MIME_TYPE_MPEG = "video/mpeg"
+	MIME_TYPE_WEBM = "video/webm"
+)
+
+// ErrUnsupportedFileType 不支持解析的文件类型
+var ErrUnsupportedFileType = fmt.Errorf("unsupported file type")
+
+var fileParsers = map[string]func(raw []byte) (*Article, error){
+	MIME_TYPE_MARKDOWN: parseMarkdown,
+	MIME_TYPE_HTML:     parseHTMLFile,
+	MIME_TYPE_PDF:      parsePDF,
+	MIME_TYPE_TEXT:     parseText,
+}
+
+// imageTypes 支持的图片类型，图片中的内容由视觉模型识别，不在此处解析
+var imageTypes = map[string]bool{
+	MIME_TYPE_PNG:  true,
+	MIME_TYPE_JPEG: true,
+	MIME_TYPE_WEBP: true,
+	MIME_TYPE_GIF:  true,
+}
+
+// mediaTypes 支持转写的音视频类型，key 为客户端或内容检测可能给出的类型，value 为统一后的类型
+var mediaTypes = map[string]string{
+	MIME_TYPE_MP3:  MIME_TYPE_MP3,
+	"audio/mp3":    MIME_TYPE_MP3,
+	MIME_TYPE_M4A:  MIME_TYPE_M4A,
+	"audio/x-m4a":  MIME_TYPE_M4A,
+	MIME_TYPE_WAV:  MIME_TYPE_WAV,
+	"audio/wave":   MIME_TYPE_WAV,
+	"audio/x-wav":  MIME_TYPE_WAV,
+	MIME_TYPE_OGG:  MIME_TYPE_OGG,
+	MIME_TYPE_WEBA: MIME_TYPE_WEBA,
+	MIME_TYPE_MP4:  MIME_TYPE_MP4,
+	MIME_TYPE_MPEG: MIME_TYPE_MPEG,
+	MIME_TYPE_WEBM: MIME_TYPE_WEBM,
+}
+
+var extMimeTypes = map[string]string{
+	".md":       MIME_TYPE_MARKDOWN,
+	".markdown": MIME_TYPE_MARKDOWN,
+	".html":     MIME_TYPE_HTML,
+	".htm":      MIME_TYPE_HTML,
+	".pdf":      MIME_TYPE_PDF,
+	".txt":      MIME_TYPE_TEXT,
+	".png":      MIME_TYPE_PNG,
+	".jpg":      MIME_TYPE_JPEG,
+	".jpeg":     MIME_TYPE_JPEG,
+	".webp":     MIME_TYPE_WEBP,
+	".gif":      MIME_TYPE_GIF,
+	".mp3":      MIME_TYPE_MP3,
+	".mpga":     MIME_TYPE_MP3,
+	".m4a":      MIME_TYPE_M4A,
+	".wav":      MIME_TYPE_WAV,
+	".ogg":      MIME_TYPE_OGG,
+	".mp4":      MIME_TYPE_MP4,
+	".mpeg":     MIME_TYPE_MPEG,
+	".webm":     MIME_TYPE_WEBM,
+}
+
+// DetectMimeType 根据文件后缀、客户端声明的类型以及文件内容判断文件的mime type
+// 后缀优先，因为浏览器对markdown等文件给出的类型通常并不准确
+func DetectMimeType(filename, declared string, head []byte) string {
+	if t, ok := extMimeTypes[strings.ToLower(filepath.Ext(filename))]; ok {
+		return t
+	}
+	if mediaType, _, err := mime.ParseMediaType(declared); err == nil {
+		if _, ok := fileParsers[mediaType]; ok || imageTypes[mediaType] {
+			return mediaType
+		}
+		if t, ok := mediaTypes[mediaType]; ok {
+			return t
+		}
+	}
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if t, ok := mediaTypes[mediaType]; ok {
+		return t
+	}
+	return mediaType
+}
+
+// IsSupportedFileType 判断是否支持从该类型的文件中提取文本
+func IsSupportedFileType(mimeType string) bool {
+	_, ok := fileParsers[mimeType]
+	return ok
+}
+
+// IsImageType 判断是否为支持的图片类型
+func IsImageType(mimeType string) bool {
+	return imageTypes[mimeType]
+}
+
+// IsMediaType 判断是否为支持转写的音视频类型
+func IsMediaType(mimeType string) bool {
+	_, ok := mediaTypes[mimeType]
+	return ok
+}
+
+// MaxFileSize 该类型文件允许的最大长度
+func MaxFileSize(mimeType string) int64 {
+	if IsMediaType(mimeType) {
+		return MAX_MEDIA_SIZE
+	}
+	return MAX_FILE_SIZE
+}
+
+// ReadFile 读取文件内容，超过 limit 时返回错误
+func ReadFile(r io.Reader, limit int64) ([]byte, error) {
+	raw, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file, %w", err)
+	}
+	if int64(len(raw)) > limit {
+		return nil, fmt.Errorf("file size exceeds the limit of %d bytes", limit)
+	}
+	return raw, nil
+}
+
+// ParseFile 按文件类型提取文件中的文本内容，返回的Article.Title在无法识别时为文件名
+func ParseFile(filename, mimeType string, r io.Reader) (*Article, error) {
+	parser, ok := fileParsers[mimeType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFileType, mimeType)
+	}
+
+	raw, err := ReadFile(r, MAX_FILE_SIZE)
+	if err != nil {
+		return nil, err
+	}
+
+	article, err := parser(raw)
+	if err != nil {
+		return nil, err
+	}
+	if article.Title == "" {
+		article.Title = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	article.Source = filename
+	return article, nil
+}
+
+func parseMarkdown(raw []byte) (*Article, error) {
+	content, err := decodeText(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	article := &Article{Content: content}
+	inFence := false
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if !inFence && strings.HasPrefix(trimmed, "# ") {
+			article.Title = strings.TrimSpace(strings.TrimPrefix(trimmed, "# "))
+			break
+		}
+	}
+	return article, nil
+}
+
+func parseHTMLFile(raw []byte) (*Article, error) {
+	return ParseHTML(bytes.NewReader(raw), "")
+}
+
+func parseText(raw []byte) (*Article, error) {
+	content, err := decodeText(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &Article{Content: content}, nil
+}
+
+func parsePDF(raw []byte) (article *Article, err error) {
+	// pdf库在遇到损坏的文件时可能会panic
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to parse pdf, %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pdf, %w", err)
+	}
+
+	var pages []string
+	for i := 1; i <= reader.NumPage(); i++ {
+		text, err := reader.Page(i).GetPlainText(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pdf page %d, %w", i, err)
+		}
+		if text = strings.TrimSpace(text); text != "" {
+			pages = append(pages, text)
+		}
+	}
+
+	return &Article{
+		Title:   strings.TrimSpace(reader.Trailer().Key("Info").Key("Title").Text()),
+		Content: strings.Join(pages, "\n\n"),
+	}, nil
+}
+
+func decodeText(raw []byte) (string, error) {
+	raw = bytes.TrimPrefix(raw, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(raw) {
+		return "", fmt.Errorf("file content is not valid utf-8 text")
+	}
+	return strings.TrimSpace(strings.ReplaceAll(string(raw), "\r\n", "\n")), nil
+}
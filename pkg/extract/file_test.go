@@ -0,0 +1,98 @@
+package extract
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// buildPDF 生成一个只包含单页文本的最小pdf文件
+func buildPDF(title, text string) []byte {
+	stream := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		fmt.Sprintf("<< /Title (%s) >>", title),
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 6 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func TestDetectMimeType(t *testing.T) {
+	assert.Equal(t, MIME_TYPE_MARKDOWN, DetectMimeType("README.MD", "application/octet-stream", nil))
+	assert.Equal(t, MIME_TYPE_PDF, DetectMimeType("export", "application/pdf", nil))
+	assert.Equal(t, MIME_TYPE_TEXT, DetectMimeType("notes", "", []byte("just some text")))
+	assert.Equal(t, "image/png", DetectMimeType("logo", "", []byte("\x89PNG\r\n\x1a\n")))
+	assert.Equal(t, MIME_TYPE_JPEG, DetectMimeType("receipt.JPG", "", nil))
+	assert.Equal(t, MIME_TYPE_WEBP, DetectMimeType("photo", "image/webp", nil))
+	assert.True(t, IsImageType(MIME_TYPE_PNG))
+	assert.False(t, IsImageType(MIME_TYPE_PDF))
+
+	assert.Equal(t, MIME_TYPE_M4A, DetectMimeType("standup.m4a", "", nil))
+	assert.Equal(t, MIME_TYPE_WAV, DetectMimeType("recording", "audio/x-wav", nil))
+	assert.Equal(t, MIME_TYPE_WEBM, DetectMimeType("meeting", "", []byte("\x1a\x45\xdf\xa3")))
+	assert.True(t, IsMediaType(MIME_TYPE_MP4))
+	assert.Equal(t, int64(MAX_MEDIA_SIZE), MaxFileSize(MIME_TYPE_MP3))
+	assert.Equal(t, int64(MAX_FILE_SIZE), MaxFileSize(MIME_TYPE_PDF))
+}
+
+func TestParseFile(t *testing.T) {
+	md := "\xef\xbb\xbf```bash\n# not a title\n```\n\n# Design doc\r\n\nSome *content*."
+	article, err := ParseFile("docs/design.md", MIME_TYPE_MARKDOWN, strings.NewReader(md))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Design doc", article.Title)
+	assert.Equal(t, "docs/design.md", article.Source)
+	assert.Equal(t, "```bash\n# not a title\n```\n\n# Design doc\n\nSome *content*.", article.Content)
+
+	article, err = ParseFile("todo.txt", MIME_TYPE_TEXT, strings.NewReader("  buy milk\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "todo", article.Title)
+	assert.Equal(t, "buy milk", article.Content)
+
+	article, err = ParseFile("page.html", MIME_TYPE_HTML, strings.NewReader(testPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Go 1.22 release notes", article.Title)
+	assert.Contains(t, article.Content, "## Language changes")
+
+	article, err = ParseFile("report.pdf", MIME_TYPE_PDF, bytes.NewReader(buildPDF("Quarterly report", "Revenue grew by ten percent")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Quarterly report", article.Title)
+	assert.Contains(t, article.Content, "Revenue grew by ten percent")
+
+	_, err = ParseFile("broken.pdf", MIME_TYPE_PDF, strings.NewReader("%PDF-1.4 garbage"))
+	assert.Error(t, err)
+
+	_, err = ParseFile("logo.png", "image/png", strings.NewReader("\x89PNG"))
+	assert.True(t, errors.Is(err, ErrUnsupportedFileType))
+
+	_, err = ParseFile("binary.txt", MIME_TYPE_TEXT, bytes.NewReader([]byte{0xff, 0xfe, 0x00}))
+	assert.Error(t, err)
+}
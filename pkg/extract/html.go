@@ -0,0 +1,554 @@
+package extract
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Article 从网页中提取出的正文
+type Article struct {
+	Title   string
+	Content string // markdown
+	Source  string
+}
+
+var (
+	// 不属于正文的节点，直接丢弃
+	ignoreNodes = map[atom.Atom]bool{
+		atom.Script:   true,
+		atom.Style:    true,
+		atom.Noscript: true,
+		atom.Nav:      true,
+		atom.Header:   true,
+		atom.Footer:   true,
+		atom.Aside:    true,
+		atom.Form:     true,
+		atom.Iframe:   true,
+		atom.Svg:      true,
+		atom.Button:   true,
+		atom.Select:   true,
+		atom.Template: true,
+	}
+
+	// boilerplateWords class、id、role 中以空白、- 或 _ 分隔的单词命中时视为非正文，整词匹配避免误删如 navigate、shareholder
+	boilerplateWords = map[string]bool{
+		"comment": true, "comments": true, "sidebar": true, "footer": true, "header": true,
+		"menu": true, "nav": true, "navbar": true, "navigation": true, "share": true, "sharing": true,
+		"social": true, "advert": true, "advertisement": true, "ad": true, "ads": true, "banner": true,
+		"related": true, "popup": true, "cookie": true, "cookies": true, "subscribe": true,
+	}
+	spaceRegexp      = regexp.MustCompile(`[ \t\r\n]+`)
+	blankLinesRegexp = regexp.MustCompile(`\n{3,}`)
+)
+
+// ParseHTML 解析html文档，提取标题与正文并转换为markdown
+// base 用于将相对链接转换为绝对链接，可以为空
+func ParseHTML(r io.Reader, base string) (*Article, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse html, %w", err)
+	}
+
+	var baseURL *url.URL
+	if base != "" {
+		if baseURL, err = url.Parse(base); err != nil {
+			return nil, fmt.Errorf("invalid base url, %w", err)
+		}
+	}
+
+	article := &Article{
+		Title:  findTitle(doc),
+		Source: base,
+	}
+
+	body := findFirst(doc, atom.Body)
+	if body == nil {
+		body = doc
+	}
+	removeBoilerplate(body)
+
+	main := findMainContent(body)
+	c := &mdConverter{base: baseURL}
+	c.convert(main)
+	article.Content = c.String()
+	return article, nil
+}
+
+// HTMLToMarkdown 将html片段整体转换为markdown，不做正文识别
+func HTMLToMarkdown(raw string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(raw))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse html, %w", err)
+	}
+	body := findFirst(doc, atom.Body)
+	if body == nil {
+		body = doc
+	}
+	removeBoilerplate(body)
+
+	c := &mdConverter{}
+	c.convert(body)
+	return c.String(), nil
+}
+
+func findTitle(doc *html.Node) string {
+	var title, ogTitle string
+	walk(doc, func(n *html.Node) bool {
+		if n.Type != html.ElementNode {
+			return true
+		}
+		switch n.DataAtom {
+		case atom.Title:
+			if title == "" {
+				title = collapseSpace(textContent(n))
+			}
+		case atom.Meta:
+			prop := attr(n, "property")
+			if prop == "" {
+				prop = attr(n, "name")
+			}
+			if ogTitle == "" && (prop == "og:title" || prop == "twitter:title") {
+				ogTitle = collapseSpace(attr(n, "content"))
+			}
+		}
+		return true
+	})
+
+	if ogTitle != "" {
+		return ogTitle
+	}
+	if title != "" {
+		return title
+	}
+	if h1 := findFirst(doc, atom.H1); h1 != nil {
+		return collapseSpace(textContent(h1))
+	}
+	return ""
+}
+
+func removeBoilerplate(root *html.Node) {
+	var remove []*html.Node
+	walk(root, func(n *html.Node) bool {
+		switch n.Type {
+		case html.CommentNode:
+			remove = append(remove, n)
+			return false
+		case html.ElementNode:
+			if ignoreNodes[n.DataAtom] || attr(n, "aria-hidden") == "true" || hasAttr(n, "hidden") {
+				remove = append(remove, n)
+				return false
+			}
+			// article/main/body 本身即使命中了关键字也要保留
+			if n.DataAtom != atom.Article && n.DataAtom != atom.Main && n.DataAtom != atom.Body &&
+				isBoilerplate(attr(n, "class")+" "+attr(n, "id")+" "+attr(n, "role")) {
+				remove = append(remove, n)
+				return false
+			}
+		}
+		return true
+	})
+	for _, n := range remove {
+		if n.Parent != nil {
+			n.Parent.RemoveChild(n)
+		}
+	}
+}
+
+// findMainContent 优先使用语义化标签，否则按段落文本量为容器打分
+func findMainContent(body *html.Node) *html.Node {
+	if n := findFirst(body, atom.Article); n != nil && len(textContent(n)) > 200 {
+		return n
+	}
+	if n := findFirst(body, atom.Main); n != nil && len(textContent(n)) > 200 {
+		return n
+	}
+
+	scores := make(map[*html.Node]float64)
+	walk(body, func(n *html.Node) bool {
+		if n.Type != html.ElementNode || (n.DataAtom != atom.P && n.DataAtom != atom.Pre) {
+			return true
+		}
+		text := collapseSpace(textContent(n))
+		if len([]rune(text)) < 25 {
+			return false
+		}
+		score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "，")) + float64(min(len([]rune(text))/100, 3))
+		if parent := n.Parent; parent != nil {
+			scores[parent] += score
+			if grand := parent.Parent; grand != nil {
+				scores[grand] += score / 2
+			}
+		}
+		return false
+	})
+
+	var (
+		best      *html.Node
+		bestScore float64
+	)
+	for n, score := range scores {
+		score = score * (1 - linkDensity(n))
+		if score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	if best == nil {
+		return body
+	}
+	return best
+}
+
+func linkDensity(n *html.Node) float64 {
+	total := len(collapseSpace(textContent(n)))
+	if total == 0 {
+		return 0
+	}
+	var linkLen int
+	walk(n, func(c *html.Node) bool {
+		if c.Type == html.ElementNode && c.DataAtom == atom.A {
+			linkLen += len(collapseSpace(textContent(c)))
+			return false
+		}
+		return true
+	})
+	return float64(linkLen) / float64(total)
+}
+
+type mdConverter struct {
+	base  *url.URL
+	b     strings.Builder
+	lists []listState
+	pre   bool
+}
+
+type listState struct {
+	ordered bool
+	index   int
+}
+
+func (c *mdConverter) String() string {
+	s := blankLinesRegexp.ReplaceAllString(c.b.String(), "\n\n")
+	lines := strings.Split(s, "\n")
+	for i, v := range lines {
+		lines[i] = strings.TrimRight(v, " \t")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func (c *mdConverter) block() {
+	s := c.b.String()
+	if s == "" || strings.HasSuffix(s, "\n\n") {
+		return
+	}
+	if strings.HasSuffix(s, "\n") {
+		c.b.WriteString("\n")
+		return
+	}
+	c.b.WriteString("\n\n")
+}
+
+func (c *mdConverter) newline() {
+	s := c.b.String()
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		c.b.WriteString("\n")
+	}
+}
+
+func (c *mdConverter) children(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.convert(child)
+	}
+}
+
+func (c *mdConverter) convert(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if c.pre {
+			c.b.WriteString(n.Data)
+			return
+		}
+		text := spaceRegexp.ReplaceAllString(n.Data, " ")
+		if strings.HasSuffix(c.b.String(), "\n") || c.b.Len() == 0 {
+			text = strings.TrimLeft(text, " ")
+		}
+		c.b.WriteString(text)
+		return
+	case html.DocumentNode:
+		c.children(n)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		text := collapseSpace(c.inline(n))
+		if text == "" {
+			return
+		}
+		c.block()
+		c.b.WriteString(strings.Repeat("#", int(n.Data[1]-'0')))
+		c.b.WriteString(" ")
+		c.b.WriteString(text)
+		c.block()
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.Figure, atom.Dl:
+		c.block()
+		c.children(n)
+		c.block()
+	case atom.Br:
+		c.b.WriteString("\n")
+	case atom.Hr:
+		c.block()
+		c.b.WriteString("---")
+		c.block()
+	case atom.Pre:
+		c.block()
+		c.b.WriteString("```")
+		if lang := codeLang(n); lang != "" {
+			c.b.WriteString(lang)
+		}
+		c.b.WriteString("\n")
+		c.b.WriteString(strings.Trim(textContent(n), "\n"))
+		c.b.WriteString("\n```")
+		c.block()
+	case atom.Code:
+		if c.pre {
+			c.children(n)
+			return
+		}
+		c.b.WriteString("`")
+		c.b.WriteString(textContent(n))
+		c.b.WriteString("`")
+	case atom.Strong, atom.B:
+		if text := strings.TrimSpace(c.inline(n)); text != "" {
+			c.b.WriteString("**" + text + "**")
+		}
+	case atom.Em, atom.I:
+		if text := strings.TrimSpace(c.inline(n)); text != "" {
+			c.b.WriteString("*" + text + "*")
+		}
+	case atom.A:
+		text := collapseSpace(c.inline(n))
+		href := c.resolve(attr(n, "href"))
+		if href == "" || strings.HasPrefix(href, "javascript:") || strings.HasPrefix(href, "#") {
+			c.b.WriteString(text)
+			return
+		}
+		if text == "" {
+			return
+		}
+		c.b.WriteString("[" + text + "](" + href + ")")
+	case atom.Img:
+		src := c.resolve(attr(n, "src"))
+		if src == "" || strings.HasPrefix(src, "data:") {
+			return
+		}
+		c.b.WriteString("![" + collapseSpace(attr(n, "alt")) + "](" + src + ")")
+	case atom.Ul, atom.Ol:
+		c.block()
+		c.lists = append(c.lists, listState{ordered: n.DataAtom == atom.Ol})
+		c.children(n)
+		c.lists = c.lists[:len(c.lists)-1]
+		c.block()
+	case atom.Li:
+		c.newline()
+		depth := len(c.lists)
+		if depth == 0 {
+			c.b.WriteString("- ")
+		} else {
+			c.b.WriteString(strings.Repeat("  ", depth-1))
+			state := &c.lists[depth-1]
+			if state.ordered {
+				state.index++
+				c.b.WriteString(fmt.Sprintf("%d. ", state.index))
+			} else {
+				c.b.WriteString("- ")
+			}
+		}
+		c.b.WriteString(strings.TrimSpace(c.inline(n)))
+		c.newline()
+	case atom.Blockquote:
+		text := (&mdConverter{base: c.base}).render(n)
+		if text == "" {
+			return
+		}
+		c.block()
+		for i, line := range strings.Split(text, "\n") {
+			if i != 0 {
+				c.b.WriteString("\n")
+			}
+			c.b.WriteString("> " + line)
+		}
+		c.block()
+	case atom.Table:
+		c.table(n)
+	case atom.Dt:
+		c.newline()
+		c.b.WriteString("**" + collapseSpace(c.inline(n)) + "**")
+		c.newline()
+	case atom.Dd:
+		c.newline()
+		c.b.WriteString(": " + collapseSpace(c.inline(n)))
+		c.newline()
+	default:
+		c.children(n)
+	}
+}
+
+// inline 渲染子节点但不输出块级换行，用于标题、列表项等
+func (c *mdConverter) inline(n *html.Node) string {
+	sub := &mdConverter{base: c.base, lists: c.lists}
+	sub.children(n)
+	c.lists = sub.lists
+	return strings.TrimSpace(blankLinesRegexp.ReplaceAllString(sub.b.String(), "\n"))
+}
+
+func (c *mdConverter) render(n *html.Node) string {
+	c.children(n)
+	return c.String()
+}
+
+func (c *mdConverter) table(n *html.Node) {
+	var rows [][]string
+	walk(n, func(tr *html.Node) bool {
+		if tr.Type != html.ElementNode || tr.DataAtom != atom.Tr {
+			return true
+		}
+		var row []string
+		for cell := tr.FirstChild; cell != nil; cell = cell.NextSibling {
+			if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+				row = append(row, strings.ReplaceAll(collapseSpace(c.inline(cell)), "|", "\\|"))
+			}
+		}
+		if len(row) > 0 {
+			rows = append(rows, row)
+		}
+		return false
+	})
+	if len(rows) == 0 {
+		return
+	}
+
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+
+	c.block()
+	for i, row := range rows {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		c.b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			c.b.WriteString("|" + strings.Repeat(" --- |", width) + "\n")
+		}
+	}
+	c.block()
+}
+
+func (c *mdConverter) resolve(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || c.base == nil {
+		return ref
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return c.base.ResolveReference(u).String()
+}
+
+func codeLang(pre *html.Node) string {
+	for _, n := range []*html.Node{pre, findFirst(pre, atom.Code)} {
+		if n == nil {
+			continue
+		}
+		for _, class := range strings.Fields(attr(n, "class")) {
+			if lang, ok := strings.CutPrefix(class, "language-"); ok {
+				return lang
+			}
+			if lang, ok := strings.CutPrefix(class, "lang-"); ok {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+func walk(n *html.Node, fn func(n *html.Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		walk(child, fn)
+	}
+}
+
+func findFirst(root *html.Node, a atom.Atom) *html.Node {
+	var found *html.Node
+	walk(root, func(n *html.Node) bool {
+		if found != nil {
+			return false
+		}
+		if n.Type == html.ElementNode && n.DataAtom == a {
+			found = n
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	walk(n, func(c *html.Node) bool {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+		}
+		return true
+	})
+	return b.String()
+}
+
+func isBoilerplate(names string) bool {
+	for _, v := range strings.FieldsFunc(strings.ToLower(names), func(r rune) bool {
+		return unicode.IsSpace(r) || r == '-' || r == '_'
+	}) {
+		if boilerplateWords[v] {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAttr 判断属性是否存在，用于 hidden 这类无需取值的布尔属性
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func collapseSpace(s string) string {
+	return strings.TrimSpace(spaceRegexp.ReplaceAllString(s, " "))
+}
@@ -0,0 +1,172 @@
+package extract
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPage = `<!DOCTYPE html>
+<html>
+<head>
+	<title>Fallback title</title>
+	<meta property="og:title" content="Go 1.22 release notes">
+	<script>var tracking = true;</script>
+</head>
+<body>
+	<header><nav><a href="/">Home</a> <a href="/blog">Blog</a></nav></header>
+	<div class="sidebar"><p>Subscribe to our newsletter for weekly updates, tips and tricks.</p></div>
+	<article>
+		<h1>Go 1.22 is released</h1>
+		<p>The latest Go release, version 1.22, arrives six months after Go 1.21. Most of its changes are in the implementation of the toolchain, runtime, and libraries.</p>
+		<h2>Language changes</h2>
+		<p>Each iteration of a <code>for</code> loop now creates new variables, see the <a href="/doc/go1.22">release notes</a>.</p>
+		<ul>
+			<li>Range over <strong>integers</strong></li>
+			<li>Enhanced routing patterns</li>
+		</ul>
+		<pre><code class="language-go">for i := range 10 {
+	fmt.Println(i)
+}</code></pre>
+		<img src="/img/gopher.png" alt="gopher">
+	</article>
+	<footer><p>Copyright 2024 The Go Authors, all rights reserved.</p></footer>
+</body>
+</html>`
+
+func TestParseHTML(t *testing.T) {
+	article, err := ParseHTML(strings.NewReader(testPage), "https://go.dev/blog/go1.22")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "Go 1.22 release notes", article.Title)
+	assert.Contains(t, article.Content, "# Go 1.22 is released")
+	assert.Contains(t, article.Content, "## Language changes")
+	assert.Contains(t, article.Content, "Each iteration of a `for` loop now creates new variables, see the [release notes](https://go.dev/doc/go1.22).")
+	assert.Contains(t, article.Content, "- Range over **integers**")
+	assert.Contains(t, article.Content, "```go\nfor i := range 10 {\n\tfmt.Println(i)\n}\n```")
+	assert.Contains(t, article.Content, "![gopher](https://go.dev/img/gopher.png)")
+
+	assert.NotContains(t, article.Content, "tracking")
+	assert.NotContains(t, article.Content, "Subscribe")
+	assert.NotContains(t, article.Content, "Copyright")
+	assert.NotContains(t, article.Content, "Home")
+}
+
+func TestRemoveBoilerplate(t *testing.T) {
+	page := `<html><head><title>Notes</title></head><body><article>
+		<p>Investors and shareholders should read the navigation guide before the annual meeting starts.</p>
+		<div hidden><p>Hidden draft paragraph.</p></div>
+		<div class="post-comments"><p>Great post!</p></div>
+		<div id="site_nav"><a href="/a">A</a></div>
+		<div class="shareholder-letter"><p>Letter to shareholders about the results of this year.</p></div>
+	</article></body></html>`
+
+	article, err := ParseHTML(strings.NewReader(page), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotContains(t, article.Content, "Hidden draft")
+	assert.NotContains(t, article.Content, "Great post")
+	assert.NotContains(t, article.Content, "[A]")
+	assert.Contains(t, article.Content, "navigation guide")
+	assert.Contains(t, article.Content, "Letter to shareholders")
+}
+
+func TestParseHTMLWithoutSemanticTags(t *testing.T) {
+	page := `<html><head><title>Notes</title></head><body>
+		<div id="menu"><a href="/a">A</a><a href="/b">B</a></div>
+		<div class="post">
+			<p>First paragraph of the post, long enough to be considered readable content by the scorer.</p>
+			<p>Second paragraph, with a comma, and another one, explaining the details of the topic.</p>
+		</div>
+		<div class="links"><p><a href="/x">A very long link text that should not win the scoring at all</a></p></div>
+	</body></html>`
+
+	article, err := ParseHTML(strings.NewReader(page), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "Notes", article.Title)
+	assert.Equal(t, "First paragraph of the post, long enough to be considered readable content by the scorer.\n\nSecond paragraph, with a comma, and another one, explaining the details of the topic.", article.Content)
+}
+
+func TestHTMLToMarkdownTable(t *testing.T) {
+	md, err := HTMLToMarkdown(`<table><tr><th>Name</th><th>Value</th></tr><tr><td>a</td><td>1</td></tr></table><blockquote><p>quoted</p></blockquote><ol><li>one</li><li>two</li></ol>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "| Name | Value |\n| --- | --- |\n| a | 1 |\n\n> quoted\n\n1. one\n2. two", md)
+}
+
+func TestFetchURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/article":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(testPage))
+		case "/plain":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("  plain text body \n"))
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/binary":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte{0, 1, 2})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	article, err := FetchURL(context.Background(), srv.Client(), srv.URL+"/article")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Go 1.22 release notes", article.Title)
+	assert.Equal(t, srv.URL+"/article", article.Source)
+	assert.Contains(t, article.Content, "[release notes]("+srv.URL+"/doc/go1.22)")
+
+	article, err = FetchURL(context.Background(), srv.Client(), srv.URL+"/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "plain text body", article.Content)
+
+	_, err = FetchURL(context.Background(), srv.Client(), srv.URL+"/binary")
+	assert.Error(t, err)
+
+	_, err = FetchURL(context.Background(), srv.Client(), srv.URL+"/missing")
+	assert.Error(t, err)
+
+	_, err = FetchURL(context.Background(), srv.Client(), "ftp://example.com/file")
+	assert.Error(t, err)
+
+	// 跳转次数超过限制
+	client := srv.Client()
+	client.CheckRedirect = checkRedirect
+	_, err = FetchURL(context.Background(), client, srv.URL+"/loop")
+	assert.ErrorContains(t, err, "redirects")
+
+	// 默认 client 不允许访问回环地址
+	_, err = FetchURL(context.Background(), nil, srv.URL+"/article")
+	assert.ErrorIs(t, err, ErrNonPublicAddress)
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	for _, v := range []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1", "0.0.0.0", "::1", "fe80::1", "fd00::1", "::ffff:127.0.0.1"} {
+		assert.False(t, isPublicAddr(netip.MustParseAddr(v)), v)
+	}
+	for _, v := range []string{"8.8.8.8", "93.184.216.34", "2606:4700:4700::1111"} {
+		assert.True(t, isPublicAddr(netip.MustParseAddr(v)), v)
+	}
+}
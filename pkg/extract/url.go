@@ -0,0 +1,177 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	// 网页内容的最大读取长度
+	MAX_FETCH_BODY_SIZE = 10 << 20
+
+	DEFAULT_USER_AGENT = "Mozilla/5.0 (compatible; BrewBot/1.0; +https://github.com/breeew/brew)"
+
+	// 抓取网页时最多跟随的跳转次数
+	MAX_FETCH_REDIRECTS = 5
+	// 抓取网页的超时时间
+	FETCH_TIMEOUT = 30 * time.Second
+)
+
+// ErrNonPublicAddress 目标地址解析到了回环、内网、链路本地等非公网地址
+var ErrNonPublicAddress = errors.New("non-public address is not allowed")
+
+// 除 netip 已能判断的类型外，同样不允许访问的保留网段
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// defaultClient FetchURL 未指定 client 时使用，只允许访问公网地址
+var defaultClient = NewFetchClient()
+
+// NewFetchClient 创建抓取用户提交网页的 client，在 DNS 解析后的建连阶段拒绝非公网地址，跳转后的地址同样校验
+// 不使用环境变量中的代理，避免经代理绕过地址校验
+func NewFetchClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if !isPublicAddr(addr) {
+				return fmt.Errorf("%w: %s", ErrNonPublicAddress, host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: FETCH_TIMEOUT,
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 10 * time.Second,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: checkRedirect,
+	}
+}
+
+// checkRedirect 限制跳转次数，且只允许跳转到 http(s) 地址
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= MAX_FETCH_REDIRECTS {
+		return fmt.Errorf("stopped after %d redirects", MAX_FETCH_REDIRECTS)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return fmt.Errorf("unsupported redirect url: %s", req.URL)
+	}
+	return nil
+}
+
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, v := range reservedPrefixes {
+		if v.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsURL 判断是否为可抓取的 http(s) 地址
+func IsURL(s string) bool {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// FetchURL 抓取网页并提取正文，非html的文本类响应会原样返回
+// client 为空时使用 NewFetchClient 创建的 client，只允许访问公网地址
+func FetchURL(ctx context.Context, client *http.Client, rawURL string) (*Article, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if !IsURL(rawURL) {
+		return nil, fmt.Errorf("unsupported url: %s", rawURL)
+	}
+	if client == nil {
+		client = defaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request, %w", err)
+	}
+	req.Header.Set("User-Agent", DEFAULT_USER_AGENT)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain;q=0.9,*/*;q=0.8")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch url, %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	// 跟随跳转后以最终地址作为相对链接的基准
+	source := rawURL
+	if resp.Request != nil && resp.Request.URL != nil {
+		source = resp.Request.URL.String()
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, MAX_FETCH_BODY_SIZE), contentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode response body, %w", err)
+	}
+
+	switch {
+	case mediaType == "" || mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		article, err := ParseHTML(body, source)
+		if err != nil {
+			return nil, err
+		}
+		article.Source = rawURL
+		return article, nil
+	case strings.HasPrefix(mediaType, "text/"):
+		raw, err := io.ReadAll(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body, %w", err)
+		}
+		return &Article{
+			Content: strings.TrimSpace(string(raw)),
+			Source:  rawURL,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported content type: %s", mediaType)
+	}
+}
@@ -21,4 +21,10 @@ const (
 	ERROR_INVALID_ACCOUNT = "error.invalid.account"
 
 	ERROR_LOGIC_VECTOR_DB_NOT_MATCHED_CONTENT_DB = "error.logic.vector.db.notmatch.content.db"
+	ERROR_LOGIC_URL_FETCH_FAILED                 = "error.logic.url.fetch.failed"
+	ERROR_LOGIC_UNSUPPORTED_FILE_TYPE            = "error.logic.file.unsupported"
+	ERROR_LOGIC_FILE_PARSE_FAILED                = "error.logic.file.parse.failed"
+	ERROR_LOGIC_SPACE_ARCHIVE_INVALID            = "error.logic.space.archive.invalid"
+	ERROR_LOGIC_KNOWLEDGE_DUPLICATE              = "error.logic.knowledge.duplicate"
+	ERROR_LOGIC_SHARE_PASSWORD                   = "error.logic.share.password"
 )
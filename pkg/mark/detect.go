@@ -0,0 +1,189 @@
+package mark
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 可自动识别的敏感信息类型
+const (
+	PII_EMAIL     = "email"
+	PII_PHONE     = "phone"
+	PII_ID_CARD   = "id_card"
+	PII_PASSPORT  = "passport"
+	PII_BANK_CARD = "bank_card"
+	PII_IP        = "ip"
+
+	// PII_CUSTOM 自定义正则匹配到的内容
+	PII_CUSTOM = "custom"
+)
+
+// PIIKinds 全部内置的敏感信息类型
+var PIIKinds = []string{PII_EMAIL, PII_PHONE, PII_ID_CARD, PII_PASSPORT, PII_BANK_CARD, PII_IP}
+
+type detectRule struct {
+	kind  string
+	re    *regexp.Regexp
+	check func(value string) bool
+}
+
+var builtinRules = map[string][]detectRule{
+	PII_EMAIL: {
+		{re: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`)},
+	},
+	PII_PHONE: {
+		// 中国大陆手机号
+		{re: regexp.MustCompile(`(?:\+?86[ \-]?)?\b1[3-9]\d{9}\b`)},
+		// 带国家码的号码
+		{re: regexp.MustCompile(`\+\d{1,3}[ \-]?\(?\d{1,4}\)?(?:[ \-]?\d{2,4}){2,4}\b`)},
+		// 以分隔符分段的号码，如 010-12345678、(555) 123-4567
+		{re: regexp.MustCompile(`(?:\(\d{3}\)\s?|\b\d{3,4}[\-.])\d{3,4}[\-.]?\d{4}\b`)},
+	},
+	PII_ID_CARD: {
+		// 中国大陆居民身份证号，校验末位校验码
+		{re: regexp.MustCompile(`\b[1-9]\d{5}(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]\b`), check: validIDCard},
+	},
+	PII_PASSPORT: {
+		{re: regexp.MustCompile(`\b[EGDSPH][A-Z]?\d{7,8}\b`)},
+	},
+	PII_BANK_CARD: {
+		// 13~19位卡号，允许以空格或-分段，校验 Luhn，未分段时还需以常见卡组织的号段开头，避免误识别毫秒时间戳等数字
+		{re: regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`), check: validBankCard},
+	},
+	PII_IP: {
+		{re: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`)},
+		{re: regexp.MustCompile(`[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){2,7}`), check: validIPv6},
+	},
+}
+
+// Detector 按配置的类型与自定义正则识别文本中的敏感信息
+type Detector struct {
+	rules []detectRule
+}
+
+// DetectMatch 识别出的敏感信息在文本中的位置
+type DetectMatch struct {
+	Kind  string
+	Start int
+	End   int
+}
+
+// NewDetector 创建敏感信息识别器，kinds 为内置类型，patterns 为自定义正则，两者都为空时返回 nil
+func NewDetector(kinds []string, patterns []string) (*Detector, error) {
+	if len(kinds) == 0 && len(patterns) == 0 {
+		return nil, nil
+	}
+
+	d := &Detector{}
+	for _, kind := range kinds {
+		rules, ok := builtinRules[kind]
+		if !ok {
+			return nil, fmt.Errorf("unknown pii kind %s", kind)
+		}
+		for _, v := range rules {
+			v.kind = kind
+			d.rules = append(d.rules, v)
+		}
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s, %w", pattern, err)
+		}
+		d.rules = append(d.rules, detectRule{kind: PII_CUSTOM, re: re})
+	}
+	return d, nil
+}
+
+// Find 返回文本中互不重叠的敏感信息位置，按出现顺序排列，重叠时保留先出现且更长的一项
+func (d *Detector) Find(text string) []DetectMatch {
+	if d == nil || text == "" {
+		return nil
+	}
+
+	var all []DetectMatch
+	for _, rule := range d.rules {
+		for _, loc := range rule.re.FindAllStringIndex(text, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			if rule.check != nil && !rule.check(text[loc[0]:loc[1]]) {
+				continue
+			}
+			all = append(all, DetectMatch{Kind: rule.kind, Start: loc[0], End: loc[1]})
+		}
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].Start != all[j].Start {
+			return all[i].Start < all[j].Start
+		}
+		return all[i].End > all[j].End
+	})
+
+	var (
+		res []DetectMatch
+		end int
+	)
+	for _, v := range all {
+		if v.Start < end {
+			continue
+		}
+		res = append(res, v)
+		end = v.End
+	}
+	return res
+}
+
+var idCardWeights = []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+
+func validIDCard(value string) bool {
+	if len(value) != 18 {
+		return false
+	}
+	var sum int
+	for i, w := range idCardWeights {
+		sum += int(value[i]-'0') * w
+	}
+	return "10X98765432"[sum%11] == strings.ToUpper(value[17:])[0]
+}
+
+// cardPrefixRegexp Visa、Mastercard、American Express、JCB、Diners Club、银联、Discover 的号段
+var cardPrefixRegexp = regexp.MustCompile(`^(?:4|5[1-5]|2[2-7]|3[0-8]|6[025])`)
+
+func validBankCard(value string) bool {
+	if !strings.ContainsAny(value, " -") && !cardPrefixRegexp.MatchString(value) {
+		return false
+	}
+	return validLuhn(value)
+}
+
+func validLuhn(value string) bool {
+	var (
+		sum    int
+		digits int
+	)
+	for i := len(value) - 1; i >= 0; i-- {
+		c := value[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		n := int(c - '0')
+		if digits%2 == 1 {
+			if n *= 2; n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+		digits++
+	}
+	return digits >= 13 && sum%10 == 0
+}
+
+func validIPv6(value string) bool {
+	ip := net.ParseIP(value)
+	return ip != nil && ip.To4() == nil
+}
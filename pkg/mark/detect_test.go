@@ -0,0 +1,89 @@
+package mark
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectorFind(t *testing.T) {
+	d, err := NewDetector(PIIKinds, []string{`CUST-\d{6}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		text string
+		want []string
+	}{
+		{"联系邮箱 alice.w@example.com.cn 谢谢", []string{"alice.w@example.com.cn"}},
+		{"手机13812345678，座机 010-62345678", []string{"13812345678", "010-62345678"}},
+		{"call +1 415 555 0100 now", []string{"+1 415 555 0100"}},
+		{"身份证 11010519491231002X 已核验", []string{"11010519491231002X"}},
+		{"身份证 110105194912310021 校验码错误", nil},
+		{"passport E12345678", []string{"E12345678"}},
+		{"card 4111 1111 1111 1111 ok", []string{"4111 1111 1111 1111"}},
+		{"not a card 4111 1111 1111 1112", nil},
+		{"银联 6217000000000000004 到账", []string{"6217000000000000004"}},
+		{"created_at 1729000000003 ms", nil},
+		{"server 192.168.1.20 and fe80::1ff:fe23:4567:890a", []string{"192.168.1.20", "fe80::1ff:fe23:4567:890a"}},
+		{"meeting at 12:30:45, version 1.2.3", nil},
+		{"customer CUST-004211 complained", []string{"CUST-004211"}},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, m := range d.Find(c.text) {
+			got = append(got, c.text[m.Start:m.End])
+		}
+		assert.Equal(t, c.want, got, c.text)
+	}
+}
+
+func TestNewDetector(t *testing.T) {
+	d, err := NewDetector(nil, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, d)
+	assert.Nil(t, d.Find("13812345678"))
+
+	_, err = NewDetector([]string{"unknown"}, nil)
+	assert.Error(t, err)
+
+	_, err = NewDetector(nil, []string{"("})
+	assert.Error(t, err)
+}
+
+func TestSensitiveWorkerDetect(t *testing.T) {
+	d, err := NewDetector([]string{PII_PHONE, PII_EMAIL}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sw := NewSensitiveWork().WithDetector(d)
+	text := "请联系 13812345678 或 bob@example.com，备用 13812345678，密码 $hidden[s3cret]"
+	masked := sw.Do(text)
+
+	assert.NotContains(t, masked, "13812345678")
+	assert.NotContains(t, masked, "bob@example.com")
+	assert.NotContains(t, masked, "s3cret")
+	// 相同的原文使用相同的替换内容，手动标记的内容不重复处理
+	assert.Len(t, HiddenRegexp.FindAllString(masked, -1), 4)
+	assert.Len(t, sw.Map(), 3)
+
+	// 自动识别的内容还原为 $hidden[原文]，与手动标记一致
+	assert.Equal(t, "请联系 $hidden[13812345678] 或 $hidden[bob@example.com]，备用 $hidden[13812345678]，密码 $hidden[s3cret]", sw.Undo(masked))
+
+	// 模型回答中的替换内容可以被还原
+	var fake string
+	for k, v := range sw.Map() {
+		if v == "$hidden[13812345678]" {
+			fake = k
+		}
+	}
+	answer, replaced := ResolveHidden("您的号码是 "+fake, func(fakeValue string) string {
+		return sw.Map()[fakeValue]
+	})
+	assert.True(t, replaced)
+	assert.Equal(t, "您的号码是 $hidden[13812345678]", answer)
+	assert.False(t, strings.Contains(answer, fake))
+}
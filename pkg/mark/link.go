@@ -0,0 +1,33 @@
+package mark
+
+import (
+	"regexp"
+	"strings"
+)
+
+// WikiLinkRegexp 匹配 [[knowledge-id]]、[[title]] 以及带显示文字的 [[title|text]]
+var WikiLinkRegexp = regexp.MustCompile(`\[\[([^\[\]\n]+?)\]\]`)
+
+// MAX_WIKI_LINK_LENGTH 链接目标的最大长度，超出时不视为链接
+const MAX_WIKI_LINK_LENGTH = 256
+
+// ParseWikiLinks 解析内容中的链接目标，按出现顺序去重，最多返回 limit 个
+func ParseWikiLinks(text string, limit int) []string {
+	var (
+		targets []string
+		seen    = make(map[string]bool)
+	)
+	for _, match := range WikiLinkRegexp.FindAllStringSubmatch(text, -1) {
+		target, _, _ := strings.Cut(match[1], "|")
+		target = strings.TrimSpace(target)
+		if target == "" || len(target) > MAX_WIKI_LINK_LENGTH || seen[target] {
+			continue
+		}
+		seen[target] = true
+		targets = append(targets, target)
+		if len(targets) >= limit {
+			break
+		}
+	}
+	return targets
+}
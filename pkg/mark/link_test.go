@@ -0,0 +1,14 @@
+package mark
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWikiLinks(t *testing.T) {
+	text := "see [[Weekly Sync]] and [[ k8F2x9 | the design doc]], again [[Weekly Sync]], not [[]] or [[a\nb]], code [x] [[Roadmap]]"
+	assert.Equal(t, []string{"Weekly Sync", "k8F2x9", "Roadmap"}, ParseWikiLinks(text, 10))
+	assert.Equal(t, []string{"Weekly Sync"}, ParseWikiLinks(text, 1))
+	assert.Nil(t, ParseWikiLinks("no links $hidden[secret]", 10))
+}
@@ -10,6 +10,10 @@ import (
 type sensitiveWorker struct {
 	contents []string
 	index    map[string]string
+
+	// detector 不为空时，Do 会将自动识别出的敏感信息一并替换，detected 记录原文与替换后内容的对应关系
+	detector *Detector
+	detected map[string]string
 }
 
 var (
@@ -24,6 +28,14 @@ func ResolveHidden(text string, getValueFunc func(fakeValue string) string) (str
 	return text, len(matches) > 0
 }
 
+// HIDDEN_MASK 向非作者展示内容时 $hidden[] 中的内容替换为该文本
+const HIDDEN_MASK = "******"
+
+// MaskHidden 隐藏 $hidden[] 中的内容，用于向作者以外的人展示内容
+func MaskHidden(text string) string {
+	return HiddenRegexp.ReplaceAllLiteralString(text, HIDDEN_MASK)
+}
+
 func (s *sensitiveWorker) Do(text string) string {
 	matches := HiddenRegexp.FindAllStringSubmatch(text, -1)
 
@@ -35,9 +47,64 @@ func (s *sensitiveWorker) Do(text string) string {
 
 		text = strings.Replace(text, o, n, 1)
 	}
+
+	if s.detector != nil {
+		text = s.mask(text)
+	}
 	return text
 }
 
+// mask 将 detector 识别出的敏感信息替换为 $hidden[随机内容]，还原时恢复为 $hidden[原文]，保持与手动标记一致，已在 $hidden[] 中的内容不重复处理
+// 相同的原文使用相同的替换内容，便于模型理解上下文
+func (s *sensitiveWorker) mask(text string) string {
+	found := s.detector.Find(text)
+	if len(found) == 0 {
+		return text
+	}
+	hidden := HiddenRegexp.FindAllStringIndex(text, -1)
+
+	var (
+		sb   strings.Builder
+		last int
+	)
+	for _, v := range found {
+		if overlapped(hidden, v.Start, v.End) {
+			continue
+		}
+		raw := text[v.Start:v.End]
+		fake, ok := s.detected[raw]
+		if !ok {
+			fake = s.newFake()
+			s.detected[raw] = fake
+			s.index[fake] = "$hidden[" + raw + "]"
+			s.contents = append(s.contents, raw)
+		}
+		sb.WriteString(text[last:v.Start])
+		sb.WriteString(fake)
+		last = v.End
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+func (s *sensitiveWorker) newFake() string {
+	for {
+		fake := "$hidden[" + utils.RandomStr(10) + "]"
+		if _, exist := s.index[fake]; !exist {
+			return fake
+		}
+	}
+}
+
+func overlapped(spans [][]int, start, end int) bool {
+	for _, v := range spans {
+		if start < v[1] && v[0] < end {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *sensitiveWorker) Undo(text string) string {
 	for n, o := range s.index {
 		text = strings.ReplaceAll(text, n, o)
@@ -49,6 +116,12 @@ func (s *sensitiveWorker) Map() map[string]string {
 	return s.index
 }
 
+// WithDetector 启用敏感信息的自动识别，detector 为空时只处理手动标记的 $hidden[] 内容
+func (s *sensitiveWorker) WithDetector(detector *Detector) *sensitiveWorker {
+	s.detector = detector
+	return s
+}
+
 type sensitiveWords struct {
 	Old string
 	New string
@@ -56,6 +129,7 @@ type sensitiveWords struct {
 
 func NewSensitiveWork() *sensitiveWorker {
 	return &sensitiveWorker{
-		index: make(map[string]string),
+		index:    make(map[string]string),
+		detected: make(map[string]string),
 	}
 }
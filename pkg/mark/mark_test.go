@@ -0,0 +1,12 @@
+package mark
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskHidden(t *testing.T) {
+	assert.Equal(t, "call ****** at ******, then reply", MaskHidden("call $hidden[Alice] at $hidden[555-0100], then reply"))
+	assert.Equal(t, "nothing hidden", MaskHidden("nothing hidden"))
+}
@@ -0,0 +1,146 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DATA_KEY_SIZE 数据密钥与主密钥的长度，使用 AES-256-GCM
+const DATA_KEY_SIZE = 32
+
+var ErrUnknownMasterKey = errors.New("unknown master key")
+
+// ParseKey 解析 base64 或 hex 编码的 32 字节密钥
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if raw, err := base64.StdEncoding.DecodeString(s); err == nil && len(raw) == DATA_KEY_SIZE {
+		return raw, nil
+	}
+	if raw, err := hex.DecodeString(s); err == nil && len(raw) == DATA_KEY_SIZE {
+		return raw, nil
+	}
+	return nil, fmt.Errorf("key must be %d bytes encoded in base64 or hex", DATA_KEY_SIZE)
+}
+
+// GenDataKey 生成随机的数据密钥
+func GenDataKey() ([]byte, error) {
+	key := make([]byte, DATA_KEY_SIZE)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// GenRandomToken 生成 size 字节的随机 token，以 base64url 编码，用于分享链接等需要防止猜测的场景
+func GenRandomToken(size int) (string, error) {
+	raw := make([]byte, size)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// Seal 使用 AES-256-GCM 加密，返回 nonce 与密文拼接后的结果，aad 需要在解密时原样提供
+func Seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// Open 解密 Seal 的结果
+func Open(key, ciphertext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Keyring 持有当前主密钥与轮换前的旧主密钥，用于包裹（加密）空间的数据密钥
+// 包裹结果中记录了主密钥的指纹，旧主密钥包裹的数据密钥仍可解开，重新包裹后即可移除旧主密钥
+type Keyring struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewKeyring 创建 Keyring，current 用于包裹新的数据密钥，previous 只用于解开已有的数据密钥
+func NewKeyring(current []byte, previous ...[]byte) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string][]byte)}
+	for i, key := range append([][]byte{current}, previous...) {
+		if len(key) != DATA_KEY_SIZE {
+			return nil, fmt.Errorf("master key must be %d bytes", DATA_KEY_SIZE)
+		}
+		id := keyID(key)
+		if i == 0 {
+			k.current = id
+		}
+		k.keys[id] = key
+	}
+	return k, nil
+}
+
+// keyID 主密钥的指纹，不会泄露密钥本身
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// ID 当前主密钥的指纹
+func (k *Keyring) ID() string {
+	return k.current
+}
+
+// Wrap 使用当前主密钥包裹数据密钥，结果格式为 {主密钥指纹}${base64密文}
+func (k *Keyring) Wrap(dataKey []byte) (string, error) {
+	sealed, err := Seal(k.keys[k.current], dataKey, []byte(k.current))
+	if err != nil {
+		return "", err
+	}
+	return k.current + "$" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Unwrap 解开 Wrap 包裹的数据密钥，主密钥不在 Keyring 中时返回 ErrUnknownMasterKey
+func (k *Keyring) Unwrap(wrapped string) ([]byte, error) {
+	id, data, ok := strings.Cut(wrapped, "$")
+	if !ok {
+		return nil, errors.New("invalid wrapped key")
+	}
+	key, exist := k.keys[id]
+	if !exist {
+		return nil, fmt.Errorf("%w %s", ErrUnknownMasterKey, id)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, err
+	}
+	return Open(key, sealed, []byte(id))
+}
+
+// IsCurrent 数据密钥是否由当前主密钥包裹
+func (k *Keyring) IsCurrent(wrapped string) bool {
+	return strings.HasPrefix(wrapped, k.current+"$")
+}
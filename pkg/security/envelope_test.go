@@ -0,0 +1,75 @@
+package security
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKey(t *testing.T) {
+	key, _ := GenDataKey()
+
+	parsed, err := ParseKey(base64.StdEncoding.EncodeToString(key) + "\n")
+	assert.NoError(t, err)
+	assert.Equal(t, key, parsed)
+
+	parsed, err = ParseKey(hex.EncodeToString(key))
+	assert.NoError(t, err)
+	assert.Equal(t, key, parsed)
+
+	_, err = ParseKey("short")
+	assert.Error(t, err)
+}
+
+func TestKeyring(t *testing.T) {
+	current, _ := GenDataKey()
+	previous, _ := GenDataKey()
+	dataKey, _ := GenDataKey()
+
+	old, err := NewKeyring(previous)
+	assert.NoError(t, err)
+	wrapped, err := old.Wrap(dataKey)
+	assert.NoError(t, err)
+
+	k, err := NewKeyring(current, previous)
+	assert.NoError(t, err)
+	assert.False(t, k.IsCurrent(wrapped))
+
+	unwrapped, err := k.Unwrap(wrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, dataKey, unwrapped)
+
+	rewrapped, err := k.Wrap(dataKey)
+	assert.NoError(t, err)
+	assert.True(t, k.IsCurrent(rewrapped))
+
+	_, err = old.Unwrap(rewrapped)
+	assert.ErrorIs(t, err, ErrUnknownMasterKey)
+
+	_, err = NewKeyring([]byte("short"))
+	assert.Error(t, err)
+}
+
+func TestSeal(t *testing.T) {
+	key, _ := GenDataKey()
+	sealed, err := Seal(key, []byte("hello"), []byte("s1"))
+	assert.NoError(t, err)
+
+	plaintext, err := Open(key, sealed, []byte("s1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(plaintext))
+
+	_, err = Open(key, sealed, []byte("s2"))
+	assert.Error(t, err)
+}
+
+func TestGenRandomToken(t *testing.T) {
+	a, err := GenRandomToken(24)
+	assert.NoError(t, err)
+	b, _ := GenRandomToken(24)
+	assert.Len(t, a, 32)
+	assert.NotEqual(t, a, b)
+	assert.NotContains(t, a, "/")
+}
@@ -21,13 +21,19 @@ type ChatMessage struct {
 type RAGDocs struct {
 	Refs []QueryResult
 	Docs []*PassageInfo
+	// Prompt 检索限定在单个资源且该资源设置了自定义提示词时使用的提示词模板
+	Prompt string
 }
 
 type PassageInfo struct {
 	ID       string `json:"id"`
 	Content  string `json:"content"`
 	DateTime string `json:"date_time"`
-	SW       Undo   `json:"-"`
+	// Offsets 音视频转写内容中与问题相关的片段的起止时间，不为空时提示模型在回答中注明时间
+	Offsets []ChunkTimestamp `json:"offsets,omitempty"`
+	// LinkedFrom 不为空时表示该知识并非检索命中，而是与命中知识 LinkedFrom 存在链接关系
+	LinkedFrom string `json:"linked_from,omitempty"`
+	SW         Undo   `json:"-"`
 }
 
 type Undo interface {
@@ -5,12 +5,12 @@ import (
 )
 
 type ChatMessageExt struct {
-	MessageID        string               `db:"message_id"`
-	SessionID        string               `db:"session_id"`
-	SpaceID          string               `db:"space_id"`
-	Evaluate         EvaluateType         `db:"evaluate"`
-	GenerationStatus GenerationStatusType `db:"generation_status"`
-	RelDocs          pq.StringArray       `db:"rel_docs"` // relevance docs
-	CreatedAt        int64                `db:"created_at"`
-	UpdatedAt        int64                `db:"updated_at"`
+	MessageID        string               `db:"message_id" json:"message_id"`
+	SessionID        string               `db:"session_id" json:"session_id"`
+	SpaceID          string               `db:"space_id" json:"space_id"`
+	Evaluate         EvaluateType         `db:"evaluate" json:"evaluate"`
+	GenerationStatus GenerationStatusType `db:"generation_status" json:"generation_status"`
+	RelDocs          pq.StringArray       `db:"rel_docs" json:"rel_docs"` // relevance docs
+	CreatedAt        int64                `db:"created_at" json:"created_at"`
+	UpdatedAt        int64                `db:"updated_at" json:"updated_at"`
 }
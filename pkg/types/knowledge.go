@@ -1,11 +1,14 @@
 package types
 
 import (
+	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 	"strings"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/lib/pq"
+	"github.com/samber/lo"
 )
 
 const (
@@ -37,7 +40,7 @@ type KnowledgeKind string
 const (
 	KNOWLEDGE_KIND_TEXT    KnowledgeKind = "text"
 	KNOWLEDGE_KIND_IMAGE                 = "image"
-	KNOWLEDGE_KIND_VIDEO                 = "video"
+	KNOWLEDGE_KIND_VIDEO                 = "video" // 音频与视频，内容为带时间标记的转写文字
 	KNOWLEDGE_KIND_URL                   = "url"
 	KNOWLEDGE_KIND_UNKNOWN               = "unknown"
 )
@@ -50,6 +53,8 @@ func KindNewFromString(s string) KnowledgeKind {
 		return KNOWLEDGE_KIND_IMAGE
 	case string(KNOWLEDGE_KIND_VIDEO):
 		return KNOWLEDGE_KIND_VIDEO
+	case string(KNOWLEDGE_KIND_URL):
+		return KNOWLEDGE_KIND_URL
 	default:
 		return KNOWLEDGE_KIND_UNKNOWN
 	}
@@ -66,6 +71,8 @@ const (
 	KNOWLEDGE_STAGE_SUMMARIZE KnowledgeStage = 1
 	KNOWLEDGE_STAGE_EMBEDDING KnowledgeStage = 2
 	KNOWLEDGE_STAGE_DONE      KnowledgeStage = 3
+	// KNOWLEDGE_STAGE_ARCHIVED 超出所属资源的保留周期后被归档，分块与向量已删除，不再参与检索
+	KNOWLEDGE_STAGE_ARCHIVED KnowledgeStage = 4
 )
 
 var namesForKnowledgeStage = map[KnowledgeStage]string{
@@ -73,6 +80,7 @@ var namesForKnowledgeStage = map[KnowledgeStage]string{
 	KNOWLEDGE_STAGE_SUMMARIZE: "Summarize",
 	KNOWLEDGE_STAGE_EMBEDDING: "Embedding",
 	KNOWLEDGE_STAGE_DONE:      "Done",
+	KNOWLEDGE_STAGE_ARCHIVED:  "Archived",
 }
 
 func (v KnowledgeStage) String() string {
@@ -96,31 +104,75 @@ type KnowledgeLite struct {
 }
 
 type Knowledge struct {
-	ID         string         `json:"id" db:"id"`
-	SpaceID    string         `json:"space_id" db:"space_id"`
-	Kind       KnowledgeKind  `json:"kind" db:"kind"`
-	Resource   string         `json:"resource" db:"resource"`
-	Title      string         `json:"title" db:"title"`
-	Tags       pq.StringArray `json:"tags" db:"tags"`
-	Content    string         `json:"content" db:"content"`
-	UserID     string         `json:"user_id" db:"user_id"`
-	Summary    string         `json:"summary" db:"summary"`
-	MaybeDate  string         `json:"maybe_date" db:"maybe_date"`
-	Stage      KnowledgeStage `json:"stage" db:"stage"`
-	CreatedAt  int64          `json:"created_at" db:"created_at"`
-	UpdatedAt  int64          `json:"updated_at" db:"updated_at"`
-	RetryTimes int            `json:"retry_times" db:"retry_times"`
+	ID          string         `json:"id" db:"id"`
+	SpaceID     string         `json:"space_id" db:"space_id"`
+	Kind        KnowledgeKind  `json:"kind" db:"kind"`
+	Resource    string         `json:"resource" db:"resource"`
+	Title       string         `json:"title" db:"title"`
+	Tags        pq.StringArray `json:"tags" db:"tags"`
+	Content     string         `json:"content" db:"content"`
+	UserID      string         `json:"user_id" db:"user_id"`
+	Summary     string         `json:"summary" db:"summary"`
+	MaybeDate   string         `json:"maybe_date" db:"maybe_date"`
+	Meta        KnowledgeMeta  `json:"meta" db:"meta"`
+	ContentHash string         `json:"content_hash" db:"content_hash"`
+	Stage       KnowledgeStage `json:"stage" db:"stage"`
+	CreatedAt   int64          `json:"created_at" db:"created_at"`
+	UpdatedAt   int64          `json:"updated_at" db:"updated_at"`
+	RetryTimes  int            `json:"retry_times" db:"retry_times"`
+}
+
+// KnowledgeMeta 知识的附加信息，以jsonb形式存储
+type KnowledgeMeta struct {
+	// Source 知识来源，如url类型知识的原始地址
+	Source string `json:"source,omitempty"`
+	// Filename 通过文件上传创建的知识所对应的原始文件名
+	Filename string `json:"filename,omitempty"`
+	// MimeType 原始文件的类型
+	MimeType string `json:"mime_type,omitempty"`
+	// Blob 原始文件在 BlobStore 中的ID，如图片知识的原图与音视频文件
+	Blob string `json:"blob,omitempty"`
+	// Duration 音视频知识的时长，单位秒
+	Duration float64 `json:"duration,omitempty"`
+}
+
+func (m KnowledgeMeta) Value() (driver.Value, error) {
+	return json.Marshal(m)
+}
+
+func (m *KnowledgeMeta) Scan(src any) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return fmt.Errorf("unsupported knowledge meta type %T", src)
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, m)
 }
 
 type GetKnowledgeOptions struct {
-	ID         string
-	IDs        []string
-	Kind       []KnowledgeKind
-	SpaceID    string
-	UserID     string
-	Resource   *ResourceQuery
-	Stage      KnowledgeStage
-	RetryTimes int
+	ID          string
+	IDs         []string
+	Kind        []KnowledgeKind
+	SpaceID     string
+	UserID      string
+	Resource    *ResourceQuery
+	Stage       KnowledgeStage
+	RetryTimes  int
+	ContentHash string
+	Tags        []string
+	Titles      []string
+	Filter      *KnowledgeFilter
+	// ExpiredAt 不为0时只返回在该时间点已超出所属资源保留周期的知识
+	ExpiredAt int64
 }
 
 func (opts GetKnowledgeOptions) Apply(query *sq.SelectBuilder) {
@@ -147,6 +199,28 @@ func (opts GetKnowledgeOptions) Apply(query *sq.SelectBuilder) {
 	if opts.RetryTimes > 0 {
 		*query = query.Where(sq.Eq{"retry_times": opts.RetryTimes})
 	}
+	if opts.ContentHash != "" {
+		*query = query.Where(sq.Eq{"content_hash": opts.ContentHash})
+	}
+	if len(opts.Titles) > 0 {
+		*query = query.Where(sq.Eq{"title": opts.Titles})
+	}
+	if len(opts.Tags) > 0 {
+		// 需要同时包含所有指定的标签
+		*query = query.Where(sq.Expr("tags @> ?", pq.Array(opts.Tags)))
+	}
+	if !opts.Filter.IsEmpty() {
+		*query = query.Where(opts.Filter.ToQuery())
+	}
+	if opts.ExpiredAt > 0 {
+		*query = query.Where(sq.Expr("id IN (?)", ExpiredKnowledgeIDs(opts.SpaceID, opts.ExpiredAt)))
+	}
+}
+
+// TagCount 空间中的标签及其被知识引用的次数
+type TagCount struct {
+	Tag   string `json:"tag" db:"tag"`
+	Count int64  `json:"count" db:"count"`
 }
 
 type ResourceQuery struct {
@@ -161,12 +235,23 @@ func (r *ResourceQuery) ToQuery() sq.Sqlizer {
 	return sq.NotEq{"resource": r.Exclude}
 }
 
+// Match 与 ToQuery 的条件一致，用于不经过数据库的过滤
+func (r *ResourceQuery) Match(resource string) bool {
+	if len(r.Include) > 0 {
+		return lo.Contains(r.Include, resource)
+	}
+	return !lo.Contains(r.Exclude, resource)
+}
+
 type UpdateKnowledgeArgs struct {
-	Title    string
-	Resource string
-	Kind     KnowledgeKind
-	Content  string
-	Tags     []string
-	Stage    KnowledgeStage
-	Summary  string
+	Title       string
+	Resource    string
+	Kind        KnowledgeKind
+	Content     string
+	Tags        []string
+	Stage       KnowledgeStage
+	Summary     string
+	ContentHash string
+	// Meta 不为空时覆盖知识的附加信息
+	Meta *KnowledgeMeta
 }
@@ -1,13 +1,54 @@
 package types
 
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
 // KnowledgeChunk 表的结构体
 type KnowledgeChunk struct {
-	ID             string `json:"id" db:"id"`                           // 主键，字符串类型
-	KnowledgeID    string `json:"knowledge_id" db:"knowledge_id"`       // 知识点ID
-	SpaceID        string `json:"space_id" db:"space_id"`               // 空间ID
-	UserID         string `json:"user_id" db:"user_id"`                 // 用户ID
-	Chunk          string `json:"chunk" db:"chunk"`                     // 知识片段
-	OriginalLength int    `json:"original_length" db:"original_length"` // 原文长度
-	UpdatedAt      int64  `json:"updated_at" db:"updated_at"`           // 更新时间
-	CreatedAt      int64  `json:"created_at" db:"created_at"`           // 创建时间
+	ID             string    `json:"id" db:"id"`                           // 主键，字符串类型
+	KnowledgeID    string    `json:"knowledge_id" db:"knowledge_id"`       // 知识点ID
+	SpaceID        string    `json:"space_id" db:"space_id"`               // 空间ID
+	UserID         string    `json:"user_id" db:"user_id"`                 // 用户ID
+	Chunk          string    `json:"chunk" db:"chunk"`                     // 知识片段
+	OriginalLength int       `json:"original_length" db:"original_length"` // 原文长度
+	Meta           ChunkMeta `json:"meta" db:"meta"`                       // 片段附加信息
+	UpdatedAt      int64     `json:"updated_at" db:"updated_at"`           // 更新时间
+	CreatedAt      int64     `json:"created_at" db:"created_at"`           // 创建时间
+}
+
+// ChunkMeta 知识片段的附加信息，以jsonb形式存储
+type ChunkMeta struct {
+	// Timestamp 音视频转写内容的片段在原始文件中的时间
+	Timestamp *ChunkTimestamp `json:"timestamp,omitempty"`
+}
+
+// ChunkTimestamp 片段的起止时间，单位秒
+type ChunkTimestamp struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+func (m ChunkMeta) Value() (driver.Value, error) {
+	return json.Marshal(m)
+}
+
+func (m *ChunkMeta) Scan(src any) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return fmt.Errorf("unsupported chunk meta type %T", src)
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, m)
 }
@@ -0,0 +1,16 @@
+package types
+
+// KnowledgeDuplicate 疑似重复的两条知识
+type KnowledgeDuplicate struct {
+	SpaceID     string  `json:"space_id" db:"space_id"`         // 空间ID
+	KnowledgeID string  `json:"knowledge_id" db:"knowledge_id"` // 后写入的知识ID
+	DuplicateID string  `json:"duplicate_id" db:"duplicate_id"` // 与之重复的已有知识ID
+	Similarity  float32 `json:"similarity" db:"similarity"`     // 相似度，内容完全相同时为1
+	CreatedAt   int64   `json:"created_at" db:"created_at"`     // 创建时间
+}
+
+type KnowledgeDuplicateDetail struct {
+	Knowledge  *KnowledgeLite `json:"knowledge"`
+	Duplicate  *KnowledgeLite `json:"duplicate"`
+	Similarity float32        `json:"similarity"`
+}
@@ -0,0 +1,72 @@
+package types
+
+import (
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+)
+
+const KNOWLEDGE_FILTER_DATE_FORMAT = "2006-01-02"
+
+// KnowledgeFilter 检索时对知识元数据的过滤条件，各条件之间为"且"的关系
+type KnowledgeFilter struct {
+	// Kinds 知识类型，满足其中之一即可
+	Kinds []KnowledgeKind `json:"kinds"`
+	// Authors 知识的创建者
+	Authors []string `json:"authors"`
+	// Tags 需要同时包含的标签
+	Tags []string `json:"tags"`
+	// DateFrom 与 DateTo 为事件发生日期(maybe_date)的范围，格式为 2006-01-02，包含起止当天
+	DateFrom string `json:"date_from"`
+	DateTo   string `json:"date_to"`
+}
+
+func (f *KnowledgeFilter) IsEmpty() bool {
+	return f == nil || (len(f.Kinds) == 0 && len(f.Authors) == 0 && len(f.Tags) == 0 && f.DateFrom == "" && f.DateTo == "")
+}
+
+func (f *KnowledgeFilter) Validate() error {
+	if f == nil {
+		return nil
+	}
+	var from, to time.Time
+	var err error
+	if f.DateFrom != "" {
+		if from, err = time.Parse(KNOWLEDGE_FILTER_DATE_FORMAT, f.DateFrom); err != nil {
+			return fmt.Errorf("invalid date_from %s", f.DateFrom)
+		}
+	}
+	if f.DateTo != "" {
+		if to, err = time.Parse(KNOWLEDGE_FILTER_DATE_FORMAT, f.DateTo); err != nil {
+			return fmt.Errorf("invalid date_to %s", f.DateTo)
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return fmt.Errorf("date_to must not be earlier than date_from")
+	}
+	return nil
+}
+
+// ToQuery 生成作用于知识表的过滤条件
+func (f *KnowledgeFilter) ToQuery() sq.Sqlizer {
+	cond := sq.And{}
+	if len(f.Kinds) > 0 {
+		cond = append(cond, sq.Eq{"kind": f.Kinds})
+	}
+	if len(f.Authors) > 0 {
+		cond = append(cond, sq.Eq{"user_id": f.Authors})
+	}
+	if len(f.Tags) > 0 {
+		cond = append(cond, sq.Expr("tags @> ?", pq.Array(f.Tags)))
+	}
+	// maybe_date 以 2006-01-02 15:04 的格式存储，取日期部分比较
+	if f.DateFrom != "" {
+		cond = append(cond, sq.GtOrEq{"LEFT(maybe_date, 10)": f.DateFrom})
+	}
+	if f.DateTo != "" {
+		cond = append(cond, sq.LtOrEq{"LEFT(maybe_date, 10)": f.DateTo})
+	}
+	return cond
+}
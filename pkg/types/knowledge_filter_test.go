@@ -0,0 +1,34 @@
+package types
+
+import (
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKnowledgeFilterValidate(t *testing.T) {
+	var filter *KnowledgeFilter
+	assert.NoError(t, filter.Validate())
+	assert.True(t, filter.IsEmpty())
+
+	assert.NoError(t, (&KnowledgeFilter{DateFrom: "2024-03-01", DateTo: "2024-03-31"}).Validate())
+	assert.Error(t, (&KnowledgeFilter{DateFrom: "2024/03/01"}).Validate())
+	assert.Error(t, (&KnowledgeFilter{DateFrom: "2024-03-31", DateTo: "2024-03-01"}).Validate())
+}
+
+func TestVectorsOptionsWithFilter(t *testing.T) {
+	query := sq.Select("id").From(TABLE_VECTORS.Name()).PlaceholderFormat(sq.Dollar)
+	GetVectorsOptions{
+		SpaceID: "space",
+		Filter: &KnowledgeFilter{
+			Kinds:    []KnowledgeKind{KNOWLEDGE_KIND_TEXT},
+			DateFrom: "2024-03-01",
+		},
+	}.Apply(&query)
+
+	sql, args, err := query.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT id FROM bw_vectors WHERE space_id = $1 AND knowledge_id IN (SELECT id FROM bw_knowledge WHERE (kind IN ($2) AND LEFT(maybe_date, 10) >= $3) AND space_id = $4)", sql)
+	assert.Equal(t, []any{"space", KNOWLEDGE_KIND_TEXT, "2024-03-01", "space"}, args)
+}
@@ -0,0 +1,33 @@
+package types
+
+// KnowledgeImportRecord 批量导入时 NDJSON 中的单条记录
+type KnowledgeImportRecord struct {
+	Content  string   `json:"content"`
+	Resource string   `json:"resource"`
+	Kind     string   `json:"kind"`
+	Tags     []string `json:"tags"`
+	Title    string   `json:"title"`
+	Date     string   `json:"date"`
+}
+
+type KnowledgeImportError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// KnowledgeImportResult 批量导入请求的解析结果
+type KnowledgeImportResult struct {
+	ImportID string                 `json:"import_id"`
+	Accepted int                    `json:"accepted"`
+	Failed   int                    `json:"failed"`
+	Errors   []KnowledgeImportError `json:"errors"`
+}
+
+// KnowledgeImportProgress 通过 websocket 推送的导入进度
+type KnowledgeImportProgress struct {
+	ImportID  string `json:"import_id"`
+	Accepted  int    `json:"accepted"`
+	Processed int    `json:"processed"`
+	Failed    int    `json:"failed"`
+	Finished  bool   `json:"finished"`
+}
@@ -0,0 +1,66 @@
+package types
+
+import (
+	sq "github.com/Masterminds/squirrel"
+)
+
+type KnowledgeJobStatus int8
+
+const (
+	// KNOWLEDGE_JOB_STATUS_PENDING 等待处理或等待重试
+	KNOWLEDGE_JOB_STATUS_PENDING KnowledgeJobStatus = 1
+	// KNOWLEDGE_JOB_STATUS_FAILED 重试次数用尽，不再自动处理
+	KNOWLEDGE_JOB_STATUS_FAILED KnowledgeJobStatus = 2
+	// KNOWLEDGE_JOB_STATUS_CANCELED 被管理员取消，知识停留在当前阶段
+	KNOWLEDGE_JOB_STATUS_CANCELED KnowledgeJobStatus = 3
+)
+
+var namesForKnowledgeJobStatus = map[KnowledgeJobStatus]string{
+	KNOWLEDGE_JOB_STATUS_PENDING:  "Pending",
+	KNOWLEDGE_JOB_STATUS_FAILED:   "Failed",
+	KNOWLEDGE_JOB_STATUS_CANCELED: "Canceled",
+}
+
+func (s KnowledgeJobStatus) String() string {
+	return namesForKnowledgeJobStatus[s]
+}
+
+// KnowledgeJob 知识处理流水线中的任务，每条知识同时只有一个任务
+// 完成 summary 后任务进入 embedding 阶段，全部完成后删除
+type KnowledgeJob struct {
+	KnowledgeID string             `json:"knowledge_id" db:"knowledge_id"`
+	SpaceID     string             `json:"space_id" db:"space_id"`
+	Stage       KnowledgeStage     `json:"stage" db:"stage"`
+	Status      KnowledgeJobStatus `json:"status" db:"status"`
+	Attempts    int                `json:"attempts" db:"attempts"`
+	RunAt       int64              `json:"run_at" db:"run_at"`
+	LockID      string             `json:"-" db:"lock_id"`
+	LockedUntil int64              `json:"locked_until" db:"locked_until"`
+	LastError   string             `json:"last_error" db:"last_error"`
+	CreatedAt   int64              `json:"created_at" db:"created_at"`
+	UpdatedAt   int64              `json:"updated_at" db:"updated_at"`
+}
+
+type GetKnowledgeJobOptions struct {
+	SpaceID string
+	Stage   KnowledgeStage
+	Status  KnowledgeJobStatus
+}
+
+func (opts GetKnowledgeJobOptions) Apply(query *sq.SelectBuilder) {
+	if opts.SpaceID != "" {
+		*query = query.Where(sq.Eq{"space_id": opts.SpaceID})
+	}
+	if opts.Stage != KNOWLEDGE_STAGE_NONE {
+		*query = query.Where(sq.Eq{"stage": opts.Stage})
+	}
+	if opts.Status != 0 {
+		*query = query.Where(sq.Eq{"status": opts.Status})
+	}
+}
+
+// KnowledgeJobDetail 流水线管理中展示的任务及其对应的知识
+type KnowledgeJobDetail struct {
+	Knowledge *KnowledgeLite `json:"knowledge"`
+	Job       *KnowledgeJob  `json:"job"`
+}
@@ -0,0 +1,22 @@
+package types
+
+import (
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKnowledgeJobOptions(t *testing.T) {
+	query := sq.Select("knowledge_id").From(TABLE_KNOWLEDGE_JOB.Name()).PlaceholderFormat(sq.Dollar)
+	GetKnowledgeJobOptions{
+		SpaceID: "space",
+		Status:  KNOWLEDGE_JOB_STATUS_FAILED,
+	}.Apply(&query)
+
+	sql, args, err := query.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT knowledge_id FROM bw_knowledge_job WHERE space_id = $1 AND status = $2", sql)
+	assert.Equal(t, []any{"space", KNOWLEDGE_JOB_STATUS_FAILED}, args)
+	assert.Equal(t, "Failed", KNOWLEDGE_JOB_STATUS_FAILED.String())
+}
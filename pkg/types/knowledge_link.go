@@ -0,0 +1,40 @@
+package types
+
+const (
+	// MAX_KNOWLEDGE_LINKS 单条知识最多记录的链接数量
+	MAX_KNOWLEDGE_LINKS = 100
+	// MAX_LINK_GRAPH_DEPTH 知识关系图允许展开的最大层数
+	MAX_LINK_GRAPH_DEPTH = 3
+	// MAX_LINK_GRAPH_NODES 知识关系图的最大节点数量
+	MAX_LINK_GRAPH_NODES = 100
+)
+
+// KnowledgeLink 知识内容中通过 [[knowledge-id]] 或 [[title]] 引用其他知识的链接
+type KnowledgeLink struct {
+	SpaceID  string `json:"space_id" db:"space_id"`   // 空间ID
+	SourceID string `json:"source_id" db:"source_id"` // 链接所在的知识ID
+	// TargetID 被引用的知识ID，按标题引用且暂无同名知识时为空，同名知识创建后自动关联
+	TargetID string `json:"target_id" db:"target_id"`
+	// Title 按标题引用时的标题，按ID引用时为空
+	Title     string `json:"title" db:"title"`
+	CreatedAt int64  `json:"created_at" db:"created_at"` // 创建时间
+}
+
+// KnowledgeLinks 知识的链接、反向链接以及周围的关系图
+type KnowledgeLinks struct {
+	Outgoing []*KnowledgeLite `json:"outgoing"`
+	// Unresolved 按标题引用但暂无同名知识的链接
+	Unresolved []string         `json:"unresolved"`
+	Backlinks  []*KnowledgeLite `json:"backlinks"`
+	Graph      KnowledgeGraph   `json:"graph"`
+}
+
+type KnowledgeGraph struct {
+	Nodes []*KnowledgeLite     `json:"nodes"`
+	Edges []KnowledgeGraphEdge `json:"edges"`
+}
+
+type KnowledgeGraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
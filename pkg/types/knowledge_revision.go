@@ -0,0 +1,26 @@
+package types
+
+import "github.com/lib/pq"
+
+// KnowledgeRevision 知识被修改前的内容快照
+type KnowledgeRevision struct {
+	ID          string         `json:"id" db:"id"`                     // 修订记录ID
+	KnowledgeID string         `json:"knowledge_id" db:"knowledge_id"` // 知识ID
+	SpaceID     string         `json:"space_id" db:"space_id"`         // 空间ID
+	UserID      string         `json:"user_id" db:"user_id"`           // 快照内容的作者，即修改前知识的所属用户
+	ReplacedBy  string         `json:"replaced_by" db:"replaced_by"`   // 执行本次修改、覆盖该快照内容的用户
+	Title       string         `json:"title" db:"title"`               // 修改前的标题
+	Tags        pq.StringArray `json:"tags" db:"tags"`                 // 修改前的标签
+	Content     string         `json:"content" db:"content"`           // 修改前的内容
+	CreatedAt   int64          `json:"created_at" db:"created_at"`     // 修改时间
+}
+
+// KnowledgeRevisionDiff 两个版本之间的文本差异
+type KnowledgeRevisionDiff struct {
+	// From 旧版本的修订ID
+	From string `json:"from"`
+	// To 新版本的修订ID，为空时表示知识的当前内容
+	To string `json:"to"`
+	// Diff unified 格式的差异文本
+	Diff string `json:"diff"`
+}
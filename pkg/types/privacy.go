@@ -0,0 +1,59 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// MAX_PRIVACY_PATTERNS 空间允许设置的自定义正则数量
+	MAX_PRIVACY_PATTERNS = 20
+	// MAX_PRIVACY_PATTERN_LENGTH 单个自定义正则的最大长度
+	MAX_PRIVACY_PATTERN_LENGTH = 256
+)
+
+// PrivacySettings 空间的敏感信息识别配置，以jsonb形式存储
+// 识别出的内容在发送给模型前会像手动标记的 $hidden[] 一样被替换，并在回答中还原
+type PrivacySettings struct {
+	// Detect 自动识别的敏感信息类型，如 email、phone，为空时使用服务的默认配置
+	Detect []string `json:"detect,omitempty"`
+	// Patterns 自定义正则，与 Detect 同时生效
+	Patterns []string `json:"patterns,omitempty"`
+	// Disabled 关闭空间的自动识别，手动标记的 $hidden[] 内容仍会被替换
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+func (m PrivacySettings) Validate() error {
+	if len(m.Patterns) > MAX_PRIVACY_PATTERNS {
+		return fmt.Errorf("at most %d patterns are allowed", MAX_PRIVACY_PATTERNS)
+	}
+	for _, v := range m.Patterns {
+		if v == "" || len(v) > MAX_PRIVACY_PATTERN_LENGTH {
+			return fmt.Errorf("pattern length must be between 1 and %d", MAX_PRIVACY_PATTERN_LENGTH)
+		}
+	}
+	return nil
+}
+
+func (m PrivacySettings) Value() (driver.Value, error) {
+	return json.Marshal(m)
+}
+
+func (m *PrivacySettings) Scan(src any) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return fmt.Errorf("unsupported privacy settings type %T", src)
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, m)
+}
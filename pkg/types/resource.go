@@ -1,12 +1,44 @@
 package types
 
+import (
+	sq "github.com/Masterminds/squirrel"
+)
+
 type Resource struct {
-	ID          string `json:"id" db:"id"`                     // 资源的唯一标识
-	Title       string `json:"title" db:"title"`               // 资源标题
-	UserID      string `json:"user_id" db:"user_id"`           // 用户id
-	SpaceID     string `json:"space_id" db:"space_id"`         // 资源所属空间ID
-	Description string `json:"description" db:"description"`   // 资源描述信息
-	Cycle       int    `json:"cycle" db:"cycle"`               // 资源周期，0为不限制
-	Prompt      string `json:"prompt" db:"prompt"`             // 自定义prompt
-	CreatedAt   int64  `json:"created_at" db:"created_at"` // 资源创建时间，UNIX时间戳
+	ID          string            `json:"id" db:"id"`                   // 资源的唯一标识
+	Title       string            `json:"title" db:"title"`             // 资源标题
+	UserID      string            `json:"user_id" db:"user_id"`         // 用户id
+	SpaceID     string            `json:"space_id" db:"space_id"`       // 资源所属空间ID
+	Description string            `json:"description" db:"description"` // 资源描述信息
+	Cycle       int               `json:"cycle" db:"cycle"`             // 资源周期，单位天，超出周期的知识会被归档或删除，0为不限制
+	Prompt      string            `json:"prompt" db:"prompt"`           // 自定义prompt
+	ChunkMode   string            `json:"chunk_mode" db:"chunk_mode"`   // 分块方式，为空时使用全局配置
+	Retrieval   RetrievalSettings `json:"retrieval" db:"retrieval"`     // 检索配置，未设置的项使用空间的配置
+	CreatedAt   int64             `json:"created_at" db:"created_at"`   // 资源创建时间，UNIX时间戳
+}
+
+const (
+	CHUNK_MODE_LLM   = "llm"
+	CHUNK_MODE_LOCAL = "local"
+)
+
+// ExpiredKnowledgeIDs 查询在 now 时已超出所属资源保留周期的知识ID，已归档的知识不再返回
+func ExpiredKnowledgeIDs(spaceID string, now int64) sq.SelectBuilder {
+	query := sq.Select("k.id").From(TABLE_KNOWLEDGE.Name() + " AS k").
+		Join(TABLE_RESOURCE.Name() + " AS r ON r.space_id = k.space_id AND r.id = k.resource").
+		Where("r.cycle > 0").
+		Where(sq.Expr("k.created_at < ?::BIGINT - r.cycle * 86400", now)).
+		Where(sq.NotEq{"k.stage": KNOWLEDGE_STAGE_ARCHIVED})
+	if spaceID != "" {
+		query = query.Where(sq.Eq{"k.space_id": spaceID})
+	}
+	return query
+}
+
+// RetentionReport 资源保留周期的清理预览
+type RetentionReport struct {
+	// Mode 超出保留周期的知识的处理方式，archive/delete
+	Mode  string           `json:"mode"`
+	Total uint64           `json:"total"`
+	List  []*KnowledgeLite `json:"list"`
 }
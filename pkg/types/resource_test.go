@@ -0,0 +1,21 @@
+package types
+
+import (
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVectorsOptionsExcludeExpired(t *testing.T) {
+	query := sq.Select("id").From(TABLE_VECTORS.Name()).PlaceholderFormat(sq.Dollar)
+	GetVectorsOptions{
+		SpaceID:          "space",
+		ExcludeExpiredAt: 1700000000,
+	}.Apply(&query)
+
+	sql, args, err := query.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT id FROM bw_vectors WHERE space_id = $1 AND knowledge_id NOT IN (SELECT k.id FROM bw_knowledge AS k JOIN bw_resource AS r ON r.space_id = k.space_id AND r.id = k.resource WHERE r.cycle > 0 AND k.created_at < $2::BIGINT - r.cycle * 86400 AND k.stage <> $3 AND k.space_id = $4)", sql)
+	assert.Equal(t, []any{"space", int64(1700000000), KNOWLEDGE_STAGE_ARCHIVED, "space"}, args)
+}
@@ -0,0 +1,116 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// MAX_RETRIEVAL_TOP_K 单次检索允许召回的最大候选分块数量
+	MAX_RETRIEVAL_TOP_K = 200
+	// MAX_RETRIEVAL_DOCS 允许放入提示词的最大知识数量
+	MAX_RETRIEVAL_DOCS = 100
+	// MAX_LINKED_DOCS 允许额外放入提示词的关联知识数量
+	MAX_LINKED_DOCS = 10
+)
+
+// RetrievalSettings 空间或资源的检索配置，以jsonb形式存储
+// 未设置的项依次使用资源、空间、服务的默认配置
+type RetrievalSettings struct {
+	// KeywordWeight 混合检索中全文检索排名的权重，为0时只使用向量检索
+	KeywordWeight *float32 `json:"keyword_weight,omitempty"`
+	// VectorWeight 混合检索中向量检索排名的权重
+	VectorWeight *float32 `json:"vector_weight,omitempty"`
+	// TopK 召回的候选分块数量
+	TopK *int `json:"top_k,omitempty"`
+	// MaxDistance 候选分块与问题的最大余弦距离，超过该距离且未命中全文检索的分块将被丢弃
+	MaxDistance *float32 `json:"max_distance,omitempty"`
+	// MaxDocs 放入提示词的最大知识数量
+	MaxDocs *int `json:"max_docs,omitempty"`
+	// MinScore 重排得分的下限，仅在启用重排时生效
+	MinScore *float32 `json:"min_score,omitempty"`
+	// LinkedDocs 额外放入提示词的关联知识数量，关联知识为命中知识通过 [[...]] 链接或被链接的知识，为0时不启用
+	LinkedDocs *int `json:"linked_docs,omitempty"`
+}
+
+func (m RetrievalSettings) Validate() error {
+	if (m.KeywordWeight != nil && *m.KeywordWeight < 0) || (m.VectorWeight != nil && *m.VectorWeight < 0) {
+		return fmt.Errorf("weight must not be negative")
+	}
+	if m.TopK != nil && (*m.TopK <= 0 || *m.TopK > MAX_RETRIEVAL_TOP_K) {
+		return fmt.Errorf("top_k must be between 1 and %d", MAX_RETRIEVAL_TOP_K)
+	}
+	if m.MaxDistance != nil && (*m.MaxDistance < 0 || *m.MaxDistance > 2) {
+		return fmt.Errorf("max_distance must be between 0 and 2")
+	}
+	if m.MaxDocs != nil && (*m.MaxDocs <= 0 || *m.MaxDocs > MAX_RETRIEVAL_DOCS) {
+		return fmt.Errorf("max_docs must be between 1 and %d", MAX_RETRIEVAL_DOCS)
+	}
+	if m.MinScore != nil && *m.MinScore < 0 {
+		return fmt.Errorf("min_score must not be negative")
+	}
+	if m.LinkedDocs != nil && (*m.LinkedDocs < 0 || *m.LinkedDocs > MAX_LINKED_DOCS) {
+		return fmt.Errorf("linked_docs must be between 0 and %d", MAX_LINKED_DOCS)
+	}
+	return nil
+}
+
+// RetrievalOptions 合并各级配置后实际生效的检索参数
+type RetrievalOptions struct {
+	KeywordWeight float32
+	VectorWeight  float32
+	TopK          uint64
+	MaxDistance   float32
+	MaxDocs       uint64
+	MinScore      float32
+	LinkedDocs    uint64
+}
+
+// Apply 使用 settings 中已设置的项覆盖当前参数
+func (o RetrievalOptions) Apply(settings RetrievalSettings) RetrievalOptions {
+	if settings.KeywordWeight != nil {
+		o.KeywordWeight = *settings.KeywordWeight
+	}
+	if settings.VectorWeight != nil {
+		o.VectorWeight = *settings.VectorWeight
+	}
+	if settings.TopK != nil {
+		o.TopK = uint64(*settings.TopK)
+	}
+	if settings.MaxDistance != nil {
+		o.MaxDistance = *settings.MaxDistance
+	}
+	if settings.MaxDocs != nil {
+		o.MaxDocs = uint64(*settings.MaxDocs)
+	}
+	if settings.MinScore != nil {
+		o.MinScore = *settings.MinScore
+	}
+	if settings.LinkedDocs != nil {
+		o.LinkedDocs = uint64(*settings.LinkedDocs)
+	}
+	return o
+}
+
+func (m RetrievalSettings) Value() (driver.Value, error) {
+	return json.Marshal(m)
+}
+
+func (m *RetrievalSettings) Scan(src any) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return fmt.Errorf("unsupported retrieval settings type %T", src)
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, m)
+}
@@ -0,0 +1,47 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetrievalOptionsApply(t *testing.T) {
+	defaults := RetrievalOptions{
+		KeywordWeight: 1,
+		VectorWeight:  1,
+		TopK:          40,
+		MaxDistance:   0.5,
+		MaxDocs:       20,
+	}
+
+	var space, resource RetrievalSettings
+	assert.NoError(t, json.Unmarshal([]byte(`{"top_k": 10, "max_distance": 0.3}`), &space))
+	assert.NoError(t, json.Unmarshal([]byte(`{"max_distance": 0.6, "max_docs": 5, "min_score": 0.2, "linked_docs": 3}`), &resource))
+
+	opts := defaults.Apply(space).Apply(resource)
+	assert.Equal(t, RetrievalOptions{
+		KeywordWeight: 1,
+		VectorWeight:  1,
+		TopK:          10,
+		MaxDistance:   0.6,
+		MaxDocs:       5,
+		MinScore:      0.2,
+		LinkedDocs:    3,
+	}, opts)
+	assert.Equal(t, defaults, defaults.Apply(RetrievalSettings{}))
+}
+
+func TestRetrievalSettingsValidate(t *testing.T) {
+	topK, maxDocs, linkedDocs := 0, MAX_RETRIEVAL_DOCS+1, MAX_LINKED_DOCS+1
+	distance, weight := float32(0.4), float32(-1)
+
+	assert.NoError(t, RetrievalSettings{}.Validate())
+	assert.NoError(t, RetrievalSettings{MaxDistance: &distance}.Validate())
+	assert.Error(t, RetrievalSettings{TopK: &topK}.Validate())
+	assert.Error(t, RetrievalSettings{MaxDocs: &maxDocs}.Validate())
+	assert.Error(t, RetrievalSettings{LinkedDocs: &linkedDocs}.Validate())
+	assert.Error(t, RetrievalSettings{KeywordWeight: &weight}.Validate())
+	assert.Error(t, RetrievalSettings{MinScore: &weight}.Validate())
+}
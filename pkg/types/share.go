@@ -0,0 +1,71 @@
+package types
+
+import (
+	"github.com/lib/pq"
+)
+
+type ShareKind string
+
+const (
+	SHARE_KIND_KNOWLEDGE ShareKind = "knowledge"
+	SHARE_KIND_SESSION   ShareKind = "session"
+)
+
+// MAX_SHARE_SESSION_MESSAGES 分享会话时最多展示的消息数量
+const MAX_SHARE_SESSION_MESSAGES = 500
+
+// ShareToken 知识或会话的公开只读分享链接，持有 token 即可在未登录的情况下查看分享的内容
+type ShareToken struct {
+	Token    string    `json:"token" db:"token"`         // 分享token，主键
+	SpaceID  string    `json:"space_id" db:"space_id"`   // 空间ID
+	Kind     ShareKind `json:"kind" db:"kind"`           // 分享的对象类型
+	ObjectID string    `json:"object_id" db:"object_id"` // 分享的知识ID或会话ID
+	UserID   string    `json:"user_id" db:"user_id"`     // 创建分享的用户ID
+	// Password 访问密码，为空时无需密码即可查看
+	Password  string `json:"-" db:"password"`
+	Salt      string `json:"-" db:"salt"`
+	ExpiredAt int64  `json:"expired_at" db:"expired_at"` // 过期时间，为0时永不过期
+	CreatedAt int64  `json:"created_at" db:"created_at"` // 创建时间
+	// Protected 是否设置了访问密码
+	Protected bool `json:"protected" db:"-"`
+}
+
+// Expired 分享在 now 时是否已过期
+func (t ShareToken) Expired(now int64) bool {
+	return t.ExpiredAt > 0 && t.ExpiredAt <= now
+}
+
+// SharedContent 通过分享链接查看到的内容，非作者查看时 $hidden[] 中的内容会被隐藏
+type SharedContent struct {
+	Kind      ShareKind        `json:"kind"`
+	ExpiredAt int64            `json:"expired_at"`
+	Knowledge *SharedKnowledge `json:"knowledge,omitempty"`
+	Session   *SharedSession   `json:"session,omitempty"`
+}
+
+type SharedKnowledge struct {
+	ID        string         `json:"id"`
+	Kind      KnowledgeKind  `json:"kind"`
+	Title     string         `json:"title"`
+	Tags      pq.StringArray `json:"tags"`
+	Content   string         `json:"content"`
+	MaybeDate string         `json:"maybe_date"`
+	// MimeType 存在原始文件时为文件类型，可以通过分享链接获取原始文件
+	MimeType  string `json:"mime_type,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+type SharedSession struct {
+	ID        string           `json:"id"`
+	Title     string           `json:"title"`
+	CreatedAt int64            `json:"created_at"`
+	Messages  []*SharedMessage `json:"messages"`
+}
+
+type SharedMessage struct {
+	ID       string          `json:"id"`
+	Role     MessageUserRole `json:"role"`
+	Message  string          `json:"message"`
+	SendTime int64           `json:"send_time"`
+}
@@ -0,0 +1,13 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShareTokenExpired(t *testing.T) {
+	assert.False(t, ShareToken{}.Expired(1700000000))
+	assert.False(t, ShareToken{ExpiredAt: 1700000001}.Expired(1700000000))
+	assert.True(t, ShareToken{ExpiredAt: 1700000000}.Expired(1700000000))
+}
@@ -0,0 +1,32 @@
+package types
+
+// SPACE_ARCHIVE_VERSION 空间备份文件的格式版本，格式发生不兼容变化时递增
+const SPACE_ARCHIVE_VERSION = 1
+
+// SpaceArchiveManifest 空间备份文件的描述信息
+type SpaceArchiveManifest struct {
+	Version        int    `json:"version"`
+	SpaceID        string `json:"space_id"`
+	EmbeddingModel string `json:"embedding_model"`
+	ExportedAt     int64  `json:"exported_at"`
+}
+
+type SpaceImportOptions struct {
+	// SpaceID 导入的目标空间，为空时创建新的空间
+	SpaceID string
+	// UserID 导入后数据的所属用户
+	UserID string
+	// ReuseVectors 当备份中的embedding模型与当前一致时直接使用备份中的向量，否则重新进行embedding
+	ReuseVectors bool
+}
+
+type SpaceImportResult struct {
+	SpaceID     string `json:"space_id"`
+	Resources   int    `json:"resources"`
+	Knowledges  int    `json:"knowledges"`
+	Chunks      int    `json:"chunks"`
+	Vectors     int    `json:"vectors"`
+	Sessions    int    `json:"sessions"`
+	Messages    int    `json:"messages"`
+	Reembedding bool   `json:"reembedding"`
+}
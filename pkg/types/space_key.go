@@ -0,0 +1,20 @@
+package types
+
+// SpaceKey 空间的数据密钥，数据密钥由主密钥包裹后存储，每次轮换生成一个新版本
+// 新写入的内容使用最新版本加密，旧版本在重新加密完成前仍用于解密
+type SpaceKey struct {
+	SpaceID    string `json:"space_id" db:"space_id"`
+	Version    int    `json:"version" db:"version"`
+	WrappedKey string `json:"-" db:"wrapped_key"`
+	CreatedAt  int64  `json:"created_at" db:"created_at"`
+}
+
+// ReencryptResult 重新加密的结果
+type ReencryptResult struct {
+	SpaceID string `json:"space_id"`
+	Version int    `json:"version"`
+	// Rows 各表重新加密的行数
+	Rows map[string]int64 `json:"rows"`
+	// Pruned 删除的旧版本数据密钥数量
+	Pruned int64 `json:"pruned"`
+}
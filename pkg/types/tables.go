@@ -11,16 +11,23 @@ func (s TableName) Name() string {
 const TABLE_PREFIX = "bw_"
 
 const (
-	TABLE_KNOWLEDGE        = TableName("knowledge")
-	TABLE_KNOWLEDGE_CHUNK  = TableName("knowledge_chunk")
-	TABLE_VECTORS          = TableName("vectors")
-	TABLE_ACCESS_TOKEN     = TableName("access_token")
-	TABLE_USER_SPACE       = TableName("user_space")
-	TABLE_SPACE            = TableName("space")
-	TABLE_RESOURCE         = TableName("resource")
-	TABLE_USER             = TableName("user")
-	TABLE_CHAT_SESSION     = TableName("chat_session")
-	TABLE_CHAT_MESSAGE     = TableName("chat_message")
-	TABLE_CHAT_SUMMARY     = TableName("chat_summary")
-	TABLE_CHAT_MESSAGE_EXT = TableName("chat_message_ext")
+	TABLE_KNOWLEDGE           = TableName("knowledge")
+	TABLE_KNOWLEDGE_CHUNK     = TableName("knowledge_chunk")
+	TABLE_KNOWLEDGE_REVISION  = TableName("knowledge_revision")
+	TABLE_KNOWLEDGE_DUPLICATE = TableName("knowledge_duplicate")
+	TABLE_KNOWLEDGE_JOB       = TableName("knowledge_job")
+	TABLE_VECTORS             = TableName("vectors")
+	TABLE_ACCESS_TOKEN        = TableName("access_token")
+	TABLE_USER_SPACE          = TableName("user_space")
+	TABLE_SPACE               = TableName("space")
+	TABLE_RESOURCE            = TableName("resource")
+	TABLE_USER                = TableName("user")
+	TABLE_CHAT_SESSION        = TableName("chat_session")
+	TABLE_CHAT_MESSAGE        = TableName("chat_message")
+	TABLE_CHAT_SUMMARY        = TableName("chat_summary")
+	TABLE_CHAT_MESSAGE_EXT    = TableName("chat_message_ext")
+	TABLE_SPACE_KEY           = TableName("space_key")
+	TABLE_BLOB                = TableName("blob")
+	TABLE_KNOWLEDGE_LINK      = TableName("knowledge_link")
+	TABLE_SHARE_TOKEN         = TableName("share_token")
 )
@@ -26,10 +26,12 @@ func (opts ListUserSpaceOptions) Apply(query *sq.SelectBuilder) {
 }
 
 type Space struct {
-	SpaceID     string `json:"space_id" db:"space_id"` // 空间ID
-	Title       string `json:"title" db:"title"`
-	Description string `json:"description" db:"description"`
-	CreatedAt   int64  `json:"created_at" db:"created_at"` // 创建时间，存储为时间戳
+	SpaceID     string            `json:"space_id" db:"space_id"` // 空间ID
+	Title       string            `json:"title" db:"title"`
+	Description string            `json:"description" db:"description"`
+	Retrieval   RetrievalSettings `json:"retrieval" db:"retrieval"`   // 检索配置
+	Privacy     PrivacySettings   `json:"privacy" db:"privacy"`       // 敏感信息识别配置
+	CreatedAt   int64             `json:"created_at" db:"created_at"` // 创建时间，存储为时间戳
 }
 
 type UserSpaceDetail struct {
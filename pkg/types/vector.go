@@ -12,6 +12,8 @@ type Vector struct {
 	Resource       string          `json:"resource" db:"resource"`               // 关联 knowledge resource
 	UserID         string          `json:"user_id" db:"user_id"`                 // 用户ID，用于标识向量所属用户
 	Embedding      pgvector.Vector `json:"embedding" db:"embedding"`             // 文本向量，存储经过编码后的文本向量表示
+	Model          string          `json:"model" db:"model"`                     // 生成向量的模型标识，格式为 driver:model
+	Dim            int             `json:"dim" db:"dim"`                         // 向量维度
 	OriginalLength int             `json:"original_length" db:"original_length"` // 原文长度
 	CreatedAt      int64           `json:"created_at" db:"created_at"`           // 创建时间，UNIX时间戳
 	UpdatedAt      int64           `json:"updated_at" db:"updated_at"`           // 更新时间，UNIX时间戳
@@ -22,6 +24,23 @@ type QueryResult struct {
 	KnowledgeID    string  `json:"knowledge_id" db:"knowledge_id"`
 	Cos            float32 `json:"cos" db:"cos"`
 	OriginalLength int     `json:"original_length" db:"original_length"`
+	// Score 混合检索的融合得分，纯向量检索时为0
+	Score float32 `json:"score,omitempty" db:"score"`
+	// KeywordMatched 是否命中全文检索
+	KeywordMatched bool `json:"keyword_matched,omitempty" db:"keyword_matched"`
+	// Timestamp 分块为音视频转写内容时，其在原始文件中的起止时间
+	Timestamp *ChunkTimestamp `json:"timestamp,omitempty" db:"-"`
+}
+
+// HybridQuery 混合检索参数，使用 reciprocal rank fusion 融合全文检索与向量检索的排名
+type HybridQuery struct {
+	Text             string
+	Embedding        pgvector.Vector
+	TextSearchConfig string
+	KeywordWeight    float32
+	VectorWeight     float32
+	// RRFK 融合时的平滑常数，得分为 weight / (k + rank)
+	RRFK int
 }
 
 type GetVectorsOptions struct {
@@ -29,7 +48,14 @@ type GetVectorsOptions struct {
 	SpaceID     string
 	UserID      string
 	KnowledgeID string
-	Resource    *ResourceQuery
+	// ExcludeKnowledgeID 排除该知识自身的向量
+	ExcludeKnowledgeID string
+	Resource           *ResourceQuery
+	Filter             *KnowledgeFilter
+	// Model 只匹配该模型生成的向量，不同模型的向量不可相互比较
+	Model string
+	// ExcludeExpiredAt 不为0时排除在该时间点已超出所属资源保留周期的知识
+	ExcludeExpiredAt int64
 }
 
 func (opts GetVectorsOptions) Apply(query *sq.SelectBuilder) {
@@ -37,7 +63,10 @@ func (opts GetVectorsOptions) Apply(query *sq.SelectBuilder) {
 		*query = query.Where(sq.Eq{"id": opts.ID})
 	}
 	if opts.KnowledgeID != "" {
-		*query = query.Where(sq.Eq{"knowledge_id": opts.ID})
+		*query = query.Where(sq.Eq{"knowledge_id": opts.KnowledgeID})
+	}
+	if opts.ExcludeKnowledgeID != "" {
+		*query = query.Where(sq.NotEq{"knowledge_id": opts.ExcludeKnowledgeID})
 	}
 	if opts.SpaceID != "" {
 		*query = query.Where(sq.Eq{"space_id": opts.SpaceID})
@@ -45,7 +74,48 @@ func (opts GetVectorsOptions) Apply(query *sq.SelectBuilder) {
 	if opts.UserID != "" {
 		*query = query.Where(sq.Eq{"user_id": opts.UserID})
 	}
+	if opts.Model != "" {
+		*query = query.Where(sq.Eq{"model": opts.Model})
+	}
 	if opts.Resource != nil {
 		*query = query.Where(opts.Resource.ToQuery())
 	}
+	if !opts.Filter.IsEmpty() {
+		// 向量表不保存知识的元数据，通过子查询在所属知识上过滤
+		knowledges := sq.Select("id").From(TABLE_KNOWLEDGE.Name()).Where(opts.Filter.ToQuery())
+		if opts.SpaceID != "" {
+			knowledges = knowledges.Where(sq.Eq{"space_id": opts.SpaceID})
+		}
+		*query = query.Where(sq.Expr("knowledge_id IN (?)", knowledges))
+	}
+	if opts.ExcludeExpiredAt > 0 {
+		// 保留周期的清理任务定时执行，检索时同样排除已过期但还未清理的知识
+		*query = query.Where(sq.Expr("knowledge_id NOT IN (?)", ExpiredKnowledgeIDs(opts.SpaceID, opts.ExcludeExpiredAt)))
+	}
+}
+
+type ReembedOptions struct {
+	// SpaceID 为空时处理全部空间
+	SpaceID string
+	// Driver 生成向量所使用的 embedding driver，为空时使用当前配置的文档 embedding
+	Driver string
+	// Prune 全部分块生成目标模型的向量后，删除其他模型的向量
+	Prune bool
+	// AdoptLegacy 没有记录模型的向量视为由目标模型生成，不再重新生成
+	AdoptLegacy bool
+}
+
+// ReembedProgress 重新生成向量的进度
+type ReembedProgress struct {
+	Model string `json:"model"`
+	// Total 本次执行开始时缺少目标模型向量的分块数量，使用 postgres 以外的向量存储时为待检查的分块数量
+	Total int64 `json:"total"`
+	// Embedded 本次执行已生成向量的分块数量
+	Embedded int64 `json:"embedded"`
+	// Skipped 已有目标模型向量而跳过的分块数量
+	Skipped int64 `json:"skipped"`
+	// Adopted 标记为目标模型的旧向量数量
+	Adopted int64 `json:"adopted"`
+	// Pruned 删除的其他模型的向量数量
+	Pruned int64 `json:"pruned"`
 }
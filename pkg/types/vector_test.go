@@ -0,0 +1,34 @@
+package types
+
+import (
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVectorsOptionsModel(t *testing.T) {
+	query := sq.Select("id").From(TABLE_VECTORS.Name()).PlaceholderFormat(sq.Dollar)
+	GetVectorsOptions{
+		SpaceID: "space",
+		Model:   "openai:text-embedding-3-small",
+	}.Apply(&query)
+
+	sql, args, err := query.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT id FROM bw_vectors WHERE space_id = $1 AND model = $2", sql)
+	assert.Equal(t, []any{"space", "openai:text-embedding-3-small"}, args)
+}
+
+func TestVectorsOptionsExcludeKnowledge(t *testing.T) {
+	query := sq.Select("id").From(TABLE_VECTORS.Name()).PlaceholderFormat(sq.Dollar)
+	GetVectorsOptions{
+		SpaceID:            "space",
+		ExcludeKnowledgeID: "knowledge",
+	}.Apply(&query)
+
+	sql, args, err := query.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT id FROM bw_vectors WHERE knowledge_id <> $1 AND space_id = $2", sql)
+	assert.Equal(t, []any{"knowledge", "space"}, args)
+}
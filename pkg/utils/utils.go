@@ -2,6 +2,7 @@ package utils
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"math/rand"
@@ -71,6 +72,13 @@ func MD5(s string) string {
 	return hex.EncodeToString(cipherStr)
 }
 
+// ContentHash 计算忽略大小写与空白差异后的文本sha256，用于判断内容是否重复
+func ContentHash(s string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
 func BindArgsWithGin(c *gin.Context, req interface{}) error {
 	err := c.ShouldBindWith(req, binding.Default(c.Request.Method, c.ContentType()))
 	if err != nil {
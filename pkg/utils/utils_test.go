@@ -7,3 +7,13 @@ func TestGenRandomID(t *testing.T) {
 
 	t.Log(GenSpecIDStr(), len(GenSpecIDStr()))
 }
+
+func TestContentHash(t *testing.T) {
+	a := ContentHash("Hello   World\n\nfoo")
+	if a != ContentHash("  hello world foo ") {
+		t.Fatal("content hash should ignore case and whitespace")
+	}
+	if a == ContentHash("hello world bar") {
+		t.Fatal("different content should have different hash")
+	}
+}